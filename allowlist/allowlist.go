@@ -0,0 +1,245 @@
+package allowlist
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Layout of the expiry dates of the entries
+const dateLayout = "2006-01-02"
+
+// Separator of the path inside an archive from the path of the archive
+const archivePathSeparator = "!/"
+
+// Entry Known secrets to ignore. Secrets are ignored if they match every kind of criteria set
+// in the entry, and any of the patterns of each kind
+type Entry struct {
+	Paths   []string `yaml:"paths"`  // Glob patterns of the files, e.g. test/**/*.pem
+	Rules   []int    `yaml:"rules"`  // IDs of the rules
+	Values  []string `yaml:"values"` // Regular expressions matched against the secret value
+	Reason  string   `yaml:"reason"`
+	Expires string   `yaml:"expires"` // YYYY-MM-DD, the entry is no longer applied after this date
+
+	values    []*regexp.Regexp
+	expiresAt time.Time
+}
+
+func (e *Entry) UnmarshalYAML(value *yaml.Node) error {
+	type rawEntry Entry
+	raw := struct {
+		rawEntry `yaml:",inline"`
+		Expired  string `yaml:"expired"` // Accepted as an alias of expires
+	}{}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*e = Entry(raw.rawEntry)
+	if e.Expires == "" {
+		e.Expires = raw.Expired
+	}
+	return nil
+}
+
+// Allowlist Entries of known secrets not to report
+type Allowlist struct {
+	Entries []Entry `yaml:"allowlist"`
+
+	expired []Entry
+}
+
+// Load Read an allowlist file. Entries past their expiry date are not applied and logged as stale
+// @parameters
+// path - Path of the YAML allowlist file
+// @returns
+// *Allowlist - Allowlist with the entries still in effect
+// Error - Errors if any. Otherwise, returns nil
+func Load(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	allowlist, err := Parse(data, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, entry := range allowlist.Expired() {
+		log.Warnf("allowlist %s: entry expired on %s is no longer applied: %s", path, entry.Expires, entry.describe())
+	}
+	return allowlist, nil
+}
+
+// Parse Parse an allowlist
+// @parameters
+// data - Contents of the YAML allowlist file
+// now - Time the expiry dates are compared to
+// @returns
+// *Allowlist - Allowlist with the entries still in effect
+// Error - Errors if any. Otherwise, returns nil
+func Parse(data []byte, now time.Time) (*Allowlist, error) {
+	var allowlist Allowlist
+	if err := yaml.Unmarshal(data, &allowlist); err != nil {
+		return nil, err
+	}
+
+	entries := allowlist.Entries[:0]
+	for i, entry := range allowlist.Entries {
+		if len(entry.Paths) == 0 && len(entry.Rules) == 0 && len(entry.Values) == 0 {
+			return nil, fmt.Errorf("entry %d: at least one of paths, rules or values is required", i+1)
+		}
+		for _, value := range entry.Values {
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: invalid value pattern %q: %w", i+1, value, err)
+			}
+			entry.values = append(entry.values, re)
+		}
+		if entry.Expires != "" {
+			expiresAt, err := time.ParseInLocation(dateLayout, entry.Expires, now.Location())
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: invalid expiry date %q, expected YYYY-MM-DD", i+1, entry.Expires)
+			}
+			// The entry is still applied on its expiry date
+			entry.expiresAt = expiresAt.AddDate(0, 0, 1)
+			if !now.Before(entry.expiresAt) {
+				allowlist.expired = append(allowlist.expired, entry)
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	allowlist.Entries = entries
+	return &allowlist, nil
+}
+
+// Expired Returns the entries not applied because they are past their expiry date
+func (a *Allowlist) Expired() []Entry {
+	if a == nil {
+		return nil
+	}
+	return a.expired
+}
+
+// Allows Checks if a secret found matches an entry of the allowlist, so must not be reported
+// @parameters
+// secret - Secret found
+// @returns
+// bool - true if the secret is allowed, false if it must be reported or the allowlist is nil
+func (a *Allowlist) Allows(secret output.SecretFound) bool {
+	if a == nil {
+		return false
+	}
+	for i := range a.Entries {
+		if a.Entries[i].matches(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter Returns the secrets not allowed by the allowlist
+func (a *Allowlist) Filter(secrets []output.SecretFound) []output.SecretFound {
+	if a == nil {
+		return secrets
+	}
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		if !a.Allows(secret) {
+			kept = append(kept, secret)
+		}
+	}
+	return kept
+}
+
+func (e *Entry) matches(secret output.SecretFound) bool {
+	return e.matchesPath(secret.CompleteFilename) && e.matchesRule(secret.RuleID) &&
+		e.matchesValue(output.MatchedValue(secret))
+}
+
+func (e *Entry) matchesPath(path string) bool {
+	if len(e.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range e.Paths {
+		if core.MatchGlobAnywhere(pattern, path) {
+			return true
+		}
+		// Files inside an archive are also matched by their path in the archive
+		inner := path
+		for {
+			_, after, found := strings.Cut(inner, archivePathSeparator)
+			if !found {
+				break
+			}
+			if core.MatchGlobAnywhere(pattern, after) {
+				return true
+			}
+			inner = after
+		}
+	}
+	return false
+}
+
+func (e *Entry) matchesRule(ruleID int) bool {
+	if len(e.Rules) == 0 {
+		return true
+	}
+	for _, id := range e.Rules {
+		if id == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) matchesValue(value string) bool {
+	if len(e.values) == 0 {
+		return true
+	}
+	for _, re := range e.values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) describe() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("paths %v, rules %v, values %v", e.Paths, e.Rules, e.Values)
+}
+
+var current atomic.Pointer[Allowlist]
+
+// Enable Load the allowlist applied by the scans
+// @parameters
+// path - Path of the YAML allowlist file, empty to report all secrets
+// @returns
+// Error - Errors if any. The allowlist in effect is kept on errors
+func Enable(path string) error {
+	if path == "" {
+		current.Store(nil)
+		return nil
+	}
+	allowlist, err := Load(path)
+	if err != nil {
+		return err
+	}
+	current.Store(allowlist)
+	return nil
+}
+
+// Current Returns the allowlist applied by the scans, nil if none is enabled
+func Current() *Allowlist {
+	return current.Load()
+}
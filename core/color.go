@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"os"
+)
+
+// Values of --color
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Colors Checks if the output written to a file is colored, from --color and --no-color. With auto, the default,
+// it is colored when the file is a terminal and the NO_COLOR environment variable is not set
+// @parameters
+// file - File the output is written to, e.g. os.Stdout for the table of the secrets and os.Stderr for the logs
+// @returns
+// bool - true if the output is colored
+func Colors(file *os.File) bool {
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+	return colors(*session.Options.Color, *session.Options.NoColor, noColorEnv, isTerminal(file))
+}
+
+func colors(mode string, noColor, noColorEnv, terminal bool) bool {
+	switch {
+	case noColor || mode == ColorNever:
+		return false
+	case mode == ColorAlways:
+		return true
+	default:
+		return terminal && !noColorEnv
+	}
+}
+
+// Character devices are terminals, files and pipes are not
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// CheckColor Check the value of --color
+// @returns
+// Error - Errors if --color is not always, never or auto
+func CheckColor() error {
+	switch *session.Options.Color {
+	case ColorAuto, ColorAlways, ColorNever:
+		return nil
+	}
+	return fmt.Errorf("--color must be %s, %s or %s, got %q", ColorAuto, ColorAlways, ColorNever, *session.Options.Color)
+}
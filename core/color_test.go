@@ -0,0 +1,37 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Colors(t *testing.T) {
+	for _, test := range []struct {
+		mode                          string
+		noColor, noColorEnv, terminal bool
+		expected                      bool
+	}{
+		{ColorAuto, false, false, true, true},
+		{ColorAuto, false, false, false, false},
+		{ColorAuto, false, true, true, false},
+		{ColorAlways, false, true, false, true},
+		{ColorAlways, true, false, true, false},
+		{ColorNever, false, false, true, false},
+	} {
+		if colored := colors(test.mode, test.noColor, test.noColorEnv, test.terminal); colored != test.expected {
+			t.Errorf("colors(%s, --no-color %v, NO_COLOR %v, terminal %v) = %v, want %v", test.mode, test.noColor,
+				test.noColorEnv, test.terminal, colored, test.expected)
+		}
+	}
+
+	// Output redirected to a file is not a terminal
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if isTerminal(file) {
+		t.Errorf("file %s detected as a terminal", file.Name())
+	}
+}
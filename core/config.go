@@ -6,11 +6,13 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	RulesVersion                 string            `yaml:"rules_version,omitempty"`
 	BlacklistedStrings           []string          `yaml:"blacklisted_strings"`
 	BlacklistedExtensions        []string          `yaml:"blacklisted_extensions"`
 	BlacklistedPaths             []string          `yaml:"blacklisted_paths"`
@@ -20,16 +22,36 @@ type Config struct {
 }
 
 type ConfigSignature struct {
-	Name          string `yaml:"name"`
-	Part          string `yaml:"part"`
-	Match         string `yaml:"match,omitempty"`
-	Regex         string `yaml:"regex,omitempty"`
-	RegexType     string `yaml:"regextype,omitempty"`
-	CompiledRegex *regexp.Regexp
-	Verifier      string  `yaml:"verifier,omitempty"`
-	Severity      string  `yaml:"severity,omitempty"`
-	SeverityScore float64 `yaml:"severityscore,omitempty"`
-	ID            int     `yaml:"ID,omitempty"`
+	Name            string `yaml:"name"`
+	Part            string `yaml:"part"`
+	Match           string `yaml:"match,omitempty"`
+	WordBoundary    bool   `yaml:"word_boundary,omitempty"` // match a word of the input, not the whole input
+	PrecededBy      string `yaml:"preceded_by,omitempty"`   // chars one of which precedes the match, spaces aside
+	FollowedBy      string `yaml:"followed_by,omitempty"`   // chars one of which follows the match, spaces aside
+	Regex           string `yaml:"regex,omitempty"`
+	RegexType       string `yaml:"regextype,omitempty"`
+	CompiledRegex   *regexp.Regexp
+	CaseInsensitive bool                `yaml:"case_insensitive,omitempty"`
+	Keywords        []string            `yaml:"keywords,omitempty"`    // one of them is in every match, derived from the regex if empty
+	MaxMatches      int                 `yaml:"max_matches,omitempty"` // matches reported per file, -1 for all, --multi-match options if 0
+	Verifier        string              `yaml:"verifier,omitempty"`
+	HTTPVerifier    *HTTPVerifierConfig `yaml:"http_verifier,omitempty"`
+	Severity        string              `yaml:"severity,omitempty"`
+	SeverityScore   float64             `yaml:"severityscore,omitempty"`
+	ID              int                 `yaml:"ID,omitempty"`
+	AdHoc           bool                `yaml:"-"` // Added by --regex, not by a config file
+}
+
+// HTTPVerifierConfig Request checking whether the secret matched by a rule is live.
+// {{match}} in the url, headers and body is replaced by the secret
+type HTTPVerifierConfig struct {
+	Method         string            `yaml:"method,omitempty"`
+	URL            string            `yaml:"url"`
+	Headers        map[string]string `yaml:"headers,omitempty"`
+	Body           string            `yaml:"body,omitempty"`
+	SuccessStatus  []int             `yaml:"success_status,omitempty"` // 200 if empty
+	SuccessBody    string            `yaml:"success_body,omitempty"`   // substring of the response of a live secret
+	TimeoutSeconds int               `yaml:"timeout,omitempty"`
 }
 
 func (c *Config) Merge(in *Config) {
@@ -71,7 +93,68 @@ func mergeStringSlices(old, new []string) []string {
 	return old
 }
 
+// ParseConfig Load the config files of the options, with the ad-hoc rules of --regex added to their signatures
+// @parameters
+// options - Options of the scan
+// @returns
+// *Config - Config of the scans
+// Error - Errors if any. Otherwise, returns nil
 func ParseConfig(options *Options) (*Config, error) {
+	config, err := parseConfigFiles(options)
+	if err != nil {
+		return nil, err
+	}
+	adHoc, err := AdHocSignatures(options.Regex.Values(), options.RegexName.Values(), options.RegexSeverity.Values())
+	if err != nil {
+		return nil, err
+	}
+	config.Signatures = append(config.Signatures, adHoc...)
+	return config, nil
+}
+
+// Severity scores of the ad-hoc rules, the defaults of the signatures of the config
+var adHocSeverityScores = map[string]float64{"low": 2.5, "medium": 5.0, "high": 7.5}
+
+// AdHocSignatures Signatures of the --regex options, matching the contents of the files. Their rule IDs follow
+// the IDs of the signatures of the config
+// @parameters
+// regexes - Regexes of the rules
+// names - Names of the rules, in the order of the regexes. AdHocRegex followed by the position of the regex if missing
+// severities - Severities of the rules, in the order of the regexes. medium if missing
+// @returns
+// []ConfigSignature - Signatures of the regexes
+// Error - The first regex which doesn't compile, or an invalid name or severity. Otherwise, returns nil
+func AdHocSignatures(regexes []string, names []string, severities []string) ([]ConfigSignature, error) {
+	if len(names) > len(regexes) || len(severities) > len(regexes) {
+		return nil, fmt.Errorf("more --regex-name or --regex-severity than --regex options")
+	}
+	var signatures []ConfigSignature
+	for i, regex := range regexes {
+		// Compiled by the signatures once the rule IDs are assigned, failing at startup here
+		if _, err := regexp.Compile(regex); err != nil {
+			return nil, fmt.Errorf("invalid --regex %s: %w", regex, err)
+		}
+		signature := ConfigSignature{
+			Name: fmt.Sprintf("AdHocRegex%d", i+1), Part: "contents", Regex: regex,
+			Severity: "medium", AdHoc: true,
+		}
+		if i < len(names) && names[i] != "" {
+			signature.Name = names[i]
+		}
+		if i < len(severities) {
+			signature.Severity = strings.ToLower(severities[i])
+		}
+		score, ok := adHocSeverityScores[signature.Severity]
+		if !ok {
+			return nil, fmt.Errorf("invalid --regex-severity %s, expected low, medium or high", severities[i])
+		}
+		signature.SeverityScore = score
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+func parseConfigFiles(options *Options) (*Config, error) {
 	configFileDirs := options.ConfigPath.Values()
 
 	if len(configFileDirs) > 0 {
@@ -121,18 +204,77 @@ func getDefaultConfig() (*Config, error) {
 	return config, nil
 }
 
-func loadConfigFile(configPath string) (*Config, error) {
-	fstat, err := os.Stat(configPath)
+// RulesFilePath Returns the config file the rules are loaded from: the first
+// --config-path, or the default config next to the executable or in the
+// current directory
+func RulesFilePath(options *Options) (string, error) {
+	if configFileDirs := options.ConfigPath.Values(); len(configFileDirs) > 0 {
+		return configFilePath(configFileDirs[0])
+	}
+	return defaultConfigFilePath()
+}
+
+// Default config file, next to the executable if there is one, in the current directory otherwise
+func defaultConfigFilePath() (string, error) {
+	ex, err := os.Executable()
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("os.Executable: %w", err)
+	}
+	filePath, err := configFilePath(filepath.Dir(ex))
+	if err == nil {
+		if _, err = os.Stat(filePath); err == nil {
+			return filePath, nil
+		}
 	}
+	dir, _ := os.Getwd()
+	return configFilePath(dir)
+}
 
-	var data []byte
-	if fstat.IsDir() {
-		data, err = os.ReadFile(path.Join(configPath, "config.yaml"))
+// Sizes and modification times of the config files of the options, changing whenever one of them is edited
+func configFilesState(options *Options) string {
+	var filePaths []string
+	if configFileDirs := options.ConfigPath.Values(); len(configFileDirs) > 0 && *options.MergeConfigs {
+		// Merged onto the default config
+		filePath, _ := defaultConfigFilePath()
+		filePaths = append(filePaths, filePath)
+		for _, dir := range configFileDirs {
+			filePath, _ := configFilePath(dir)
+			filePaths = append(filePaths, filePath)
+		}
 	} else {
-		data, err = os.ReadFile(configPath)
+		filePath, _ := RulesFilePath(options)
+		filePaths = append(filePaths, filePath)
 	}
+
+	var state strings.Builder
+	for _, filePath := range filePaths {
+		if fstat, err := os.Stat(filePath); err == nil {
+			fmt.Fprintf(&state, "%s %d %d\n", filePath, fstat.Size(), fstat.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&state, "%s missing\n", filePath)
+		}
+	}
+	return state.String()
+}
+
+func configFilePath(configPath string) (string, error) {
+	fstat, err := os.Stat(configPath)
+	if err != nil {
+		return "", err
+	}
+	if fstat.IsDir() {
+		return path.Join(configPath, "config.yaml"), nil
+	}
+	return configPath, nil
+}
+
+func loadConfigFile(configPath string) (*Config, error) {
+	filePath, err := configFilePath(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -130,3 +130,31 @@ func mustMarshal(in interface{}) string {
 	}
 	return buf.String()
 }
+
+func Test_AdHocSignatures(t *testing.T) {
+	signatures, err := core.AdHocSignatures([]string{`corp_[a-z0-9]{32}`, `ticket-\d+`}, []string{"Corp token"}, []string{"HIGH"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []core.ConfigSignature{
+		{Name: "Corp token", Part: "contents", Regex: `corp_[a-z0-9]{32}`, Severity: "high", SeverityScore: 7.5, AdHoc: true},
+		{Name: "AdHocRegex2", Part: "contents", Regex: `ticket-\d+`, Severity: "medium", SeverityScore: 5.0, AdHoc: true},
+	}
+	if !reflect.DeepEqual(signatures, expected) {
+		t.Errorf("unexpected ad-hoc signatures %v, want %v", signatures, expected)
+	}
+
+	for _, test := range []struct {
+		regexes    []string
+		names      []string
+		severities []string
+	}{
+		{[]string{`token_(`}, nil, nil},
+		{[]string{`token`}, nil, []string{"critical"}},
+		{[]string{`token`}, []string{"a", "b"}, nil},
+	} {
+		if _, err := core.AdHocSignatures(test.regexes, test.names, test.severities); err == nil {
+			t.Errorf("%v %v %v: expected an error", test.regexes, test.names, test.severities)
+		}
+	}
+}
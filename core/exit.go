@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Exit codes of a scan, so that CI can tell a broken scan from secrets found
+const (
+	ExitClean     = 0 // The scan completed, no fail-on threshold was reached
+	ExitFindings  = 1 // The scan completed and the secrets found reached a fail-on threshold
+	ExitScanError = 2 // The scan failed, e.g. the image could not be saved or pulled
+	ExitUsage     = 3 // Invalid options or config, nothing was scanned
+	ExitTimeout   = 4 // The scan was stopped by --timeout, the secrets found before are reported
+)
+
+// UsageError Error of invalid options or config
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// NewUsageError Create an error of invalid options, formatted like fmt.Errorf
+func NewUsageError(format string, args ...interface{}) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// ExitCode Returns the exit code of a run ended by an error
+// @parameters
+// err - Error ending the run, nil if it completed
+// @returns
+// int - ExitUsage for usage errors, ExitTimeout for expired deadlines, ExitScanError for any other error,
+// ExitClean for nil
+func ExitCode(err error) int {
+	var usageErr *UsageError
+	switch {
+	case err == nil:
+		return ExitClean
+	case errors.As(err, &usageErr):
+		return ExitUsage
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	}
+	return ExitScanError
+}
+
+// Fatal Logs the error ending a run and exits with its exit code, see ExitCode
+// @parameters
+// err - Error ending the run
+func Fatal(err error) {
+	log.Error(err)
+	os.Exit(ExitCode(err))
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func Test_ExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitClean},
+		{NewUsageError("invalid platform %q", "linux"), ExitUsage},
+		{fmt.Errorf("main: %w", NewUsageError("unknown runtime")), ExitUsage},
+		{errors.New("image not found"), ExitScanError},
+		{fmt.Errorf("layer abc: %w", context.DeadlineExceeded), ExitTimeout},
+		{context.Canceled, ExitScanError},
+	}
+	for _, test := range tests {
+		if code := ExitCode(test.err); code != test.expected {
+			t.Errorf("ExitCode(%v) = %d, want %d", test.err, code, test.expected)
+		}
+	}
+}
+
+// Errors ending the child processes of Test_FatalExitCode
+var fatalErrors = map[string]error{
+	"scan":    fmt.Errorf("main: error while scanning image: %w", errors.New("pull failed")),
+	"usage":   NewUsageError("main: unknown rule ID %q for --test-rule", "abc"),
+	"timeout": fmt.Errorf("main: error while scanning dir: %w", context.DeadlineExceeded),
+}
+
+func Test_FatalExitCode(t *testing.T) {
+	// The child process exits through Fatal, the parent checks its exit code
+	if name := os.Getenv("SECRETSCANNER_TEST_FATAL"); name != "" {
+		Fatal(fatalErrors[name])
+	}
+	for name, expected := range map[string]int{"scan": ExitScanError, "usage": ExitUsage, "timeout": ExitTimeout} {
+		cmd := exec.Command(os.Args[0], "-test.run=^Test_FatalExitCode$")
+		cmd.Env = append(os.Environ(), "SECRETSCANNER_TEST_FATAL="+name)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != expected {
+			t.Errorf("Fatal of the %s error exited with %v, want exit code %d", name, err, expected)
+		}
+	}
+}
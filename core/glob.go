@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MatchGlob Checks if a slash separated path matches a glob pattern. Besides the syntax of path.Match
+// for each segment, a ** segment matches any number of segments, including none
+// @parameters
+// pattern - Glob pattern, e.g. vendor/**/*.json
+// name - Path to match
+// @returns
+// bool - true if the path matches
+func MatchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive ** and try every number of segments
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ValidateGlob Checks if a glob pattern of MatchGlob is well formed
+// @parameters
+// pattern - Glob pattern
+// @returns
+// Error - Errors if a segment of the pattern is malformed, e.g. an unclosed [. Otherwise, returns nil
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchGlobAnywhere Checks if a path matches a glob pattern. Patterns starting with / are matched
+// against the whole path, others against the path and all its trailing segments, like .gitignore
+// @parameters
+// pattern - Glob pattern, e.g. /build/** or **/testdata/*
+// name - Path to match
+// @returns
+// bool - true if the path matches
+func MatchGlobAnywhere(pattern string, name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(pattern, "/") {
+		return MatchGlob(strings.TrimPrefix(pattern, "/"), strings.TrimPrefix(name, "/"))
+	}
+	segments := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	patternSegments := strings.Split(pattern, "/")
+	for i := range segments {
+		if matchSegments(patternSegments, segments[i:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package core
+
+import "testing"
+
+func Test_MatchGlob(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"vendor/**", "vendor/github.com/x/y.go", true},
+		{"vendor/**", "vendor", true},
+		{"vendor/**", "src/vendor/x.go", false},
+		{"**/testdata/*", "pkg/a/testdata/key.pem", true},
+		{"**/testdata/*", "testdata/key.pem", true},
+		{"**/testdata/*", "pkg/testdata/sub/key.pem", false},
+		{"*.json", "config.json", true},
+		{"*.json", "dir/config.json", false},
+		{"a/**/b/*.go", "a/x/y/b/c.go", true},
+		{"a/**/b/*.go", "a/b/c.go", true},
+		{"a/?.go", "a/bc.go", false},
+	} {
+		if actual := MatchGlob(test.pattern, test.name); actual != test.match {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", test.pattern, test.name, actual, test.match)
+		}
+	}
+}
+
+func Test_MatchGlobAnywhere(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"vendor/**", "/home/dev/app/vendor/lib/key.go", true},
+		{"/vendor/**", "/home/dev/app/vendor/lib/key.go", false},
+		{"/vendor/**", "vendor/lib/key.go", true},
+		{"*.pem", "/etc/ssl/private/server.pem", true},
+		{"fixtures/*.env", "app/fixtures/test.env", true},
+		{"fixtures/*.env", "app/fixtures/nested/test.env", false},
+	} {
+		if actual := MatchGlobAnywhere(test.pattern, test.name); actual != test.match {
+			t.Errorf("MatchGlobAnywhere(%q, %q) = %v, want %v", test.pattern, test.name, actual, test.match)
+		}
+	}
+}
+
+func Test_ValidateGlob(t *testing.T) {
+	for _, pattern := range []string{"**/test/**", "config/*.env", "[abc]/x"} {
+		if err := ValidateGlob(pattern); err != nil {
+			t.Errorf("ValidateGlob(%q) = %s", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"[abc/x", "a/b\\"} {
+		if err := ValidateGlob(pattern); err == nil {
+			t.Errorf("ValidateGlob(%q) should fail", pattern)
+		}
+	}
+}
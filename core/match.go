@@ -55,17 +55,56 @@ func IsSkippableDir(path string, baseDir string) bool {
 	return false
 }
 
-// IsSkippableFileExtension Checks if the file extension is blacklisted
+// IsSkippableFileExtension Checks if the file extension is blacklisted, or not in --include-extensions when set
 func IsSkippableFileExtension(path string) bool {
-	extension := strings.ToLower(filepath.Ext(path))
-	for _, skippableExt := range session.Config.BlacklistedExtensions {
-		if extension == skippableExt {
+	if !IsIncludedFileExtension(path) {
+		return true
+	}
+	return HasExtension(path, session.Config.BlacklistedExtensions)
+}
+
+// IsIncludedFileExtension Checks if the file extension is in --include-extensions, always true when it is not set
+func IsIncludedFileExtension(path string) bool {
+	return len(session.includedExtensions) == 0 || HasExtension(path, session.includedExtensions)
+}
+
+// HasExtension Checks if the file name ends with one of the extensions, ignoring case. Extensions may
+// have several dots, e.g. .min.js, and a dotfile such as .env has its whole name as extension
+// @parameters
+// path - Path of the file
+// extensions - Extensions starting with a dot
+// @returns
+// bool - true if the file has one of the extensions
+func HasExtension(path string, extensions []string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, extension := range extensions {
+		if strings.HasSuffix(name, strings.ToLower(extension)) {
 			return true
 		}
 	}
 	return false
 }
 
+// ParseExtensions Parse a comma separated list of extensions, such as the value of --include-extensions
+// @parameters
+// list - Extensions separated by commas, the leading dot is optional, e.g. ".env,yaml"
+// @returns
+// []string - Extensions in lower case, starting with a dot
+func ParseExtensions(list string) []string {
+	var extensions []string
+	for _, extension := range strings.Split(list, ",") {
+		extension = strings.ToLower(strings.TrimSpace(extension))
+		if extension == "" || extension == "." {
+			continue
+		}
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		extensions = append(extensions, extension)
+	}
+	return extensions
+}
+
 // CanCheckEntropy Checks if entropy based scanning is appropriate for this file
 func (match MatchFile) CanCheckEntropy() bool {
 	if match.Filename == "id_rsa" {
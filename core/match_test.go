@@ -0,0 +1,35 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseExtensions(t *testing.T) {
+	expected := []string{".env", ".yaml", ".min.js"}
+	if actual := ParseExtensions(" .ENV,yaml,, .min.js ,."); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ParseExtensions = %v, want %v", actual, expected)
+	}
+	if actual := ParseExtensions(""); actual != nil {
+		t.Errorf("ParseExtensions of an empty list = %v, want nil", actual)
+	}
+}
+
+func Test_HasExtension(t *testing.T) {
+	extensions := []string{".env", ".yaml", ".min.js"}
+	for path, expected := range map[string]bool{
+		"app/config.yaml":       true,
+		"app/CONFIG.YAML":       true,
+		"app/.env":              true,
+		"app/prod.env":          true,
+		"static/app.min.js":     true,
+		"static/app.js":         false,
+		"app/.bashrc":           false,
+		"app/Dockerfile":        false,
+		"app/environment/x.yml": false,
+	} {
+		if actual := HasExtension(path, extensions); actual != expected {
+			t.Errorf("HasExtension(%q) = %v, want %v", path, actual, expected)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package core
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -11,33 +16,141 @@ const (
 	ExtractedImageFilesDir = "ExtractedFiles"
 	JSONOutput             = "json"
 	TableOutput            = "table"
+	NDJSONOutput           = "ndjson"
+	TemplateOutput         = "template"
+	GitLabSASTOutput       = "gitlab-sast"
+	JUnitOutput            = "junit"
 )
 
 type Options struct {
-	Threads           *int
-	Debug             *bool
-	MaximumFileSize   *uint
-	TempDirectory     *string
-	Local             *string
-	HostMountPath     *string
-	ConfigPath        *repeatableStringValue
-	MergeConfigs      *bool
-	ImageName         *string
-	MultipleMatch     *bool
-	MaxMultiMatch     *uint
-	MaxSecrets        *uint
-	ContainerID       *string
-	ContainerNS       *string
-	WorkersPerScan    *int
-	InactiveThreshold *int
-	OutFormat         *string
-	ConsoleURL        *string
-	ConsolePort       *int
-	KhulnasoftKey     *string
-	FailOnCount       *int
-	FailOnHighCount   *int
-	FailOnMediumCount *int
-	FailOnLowCount    *int
+	Threads            *int
+	Debug              *bool
+	MaximumFileSize    *uint
+	MaxFileSizes       *extensionSizesValue
+	TempDirectory      *string
+	Local              *string
+	Stdin              *bool
+	File               *string
+	ForceExtension     *bool
+	HostMountPath      *string
+	ConfigPath         *repeatableStringValue
+	MergeConfigs       *bool
+	ImageName          *repeatableStringValue
+	PerImageThreshold  *bool
+	MultipleMatch      *bool
+	MaxMultiMatch      *uint
+	MaxSecrets         *uint
+	MinSeverity        *string
+	CountAll           *bool
+	ContainerID        *string
+	ContainerNS        *string
+	WorkersPerScan     *int
+	InactiveThreshold  *int
+	OutFormat          *string
+	OutputFile         *string
+	Template           *string
+	TemplateFile       *string
+	ConsoleURL         *string
+	ConsolePort        *int
+	KhulnasoftKey      *string
+	KhulnasoftKeyFile  *string
+	FailOnCount        *int
+	FailOnHighCount    *int
+	FailOnMediumCount  *int
+	FailOnLowCount     *int
+	FailOnSeverity     *string
+	CumulativeSeverity *bool
+	FromContentStore   *bool
+	ContentStorePath   *string
+	ContainerdAddress  *string
+	Runtime            *string
+	Platform           *string
+	Staged             *bool
+	StagedFiles        *bool
+	CoverageReport     *string
+	CheckRulesUpdate   *bool
+	UpdateRules        *bool
+	ListRules          *bool
+	TestRule           *string
+	TestInput          *string
+	RulesManifestURL   *string
+	Verify             *bool
+	VerifySeverities   *string
+	VerifyConcurrency  *int
+	VerifyRate         *float64
+	SortResults        *bool
+	ScanPackages       *bool
+	DecodeK8sSecrets   *bool
+	RecursiveArchives  *bool
+	MaxArchiveDepth    *int
+	MaxExtractSize     *uint
+	MaxExtractEntries  *int
+	Remediate          *string
+	ConfirmRemediation *bool
+	RegistryAuth       *string
+	PullFromRegistry   *bool
+	InsecureRegistry   *repeatableStringValue
+	RegistryCA         *string
+	Proxy              *string
+	NoProxy            *string
+	Archive            *string
+	OCILayout          *string
+	NoWhiteout         *bool
+	Flatten            *bool
+	NoDedupe           *bool
+	EntropyThreshold   *float64
+	EntropyMinLength   *uint
+	NoEntropy          *bool
+	ShowSuppressed     *bool
+	Allowlist          *string
+	Baseline           *string
+	WriteBaseline      *bool
+	GitHistory         *string
+	Since              *string
+	EnableRule         *repeatableStringValue
+	DisableRule        *repeatableStringValue
+	ExcludePath        *repeatableStringValue
+	Regex              *repeatableStringValue
+	RegexName          *repeatableStringValue
+	RegexSeverity      *repeatableStringValue
+	IncludeExtensions  *string
+	ExcludeExtensions  *string
+	RespectGitignore   *bool
+	Timeout            *time.Duration
+	Progress           *bool
+	NoPrefilter        *bool
+	CacheDir           *string
+	NoCache            *bool
+	ConfigFile         *string
+	UploadRetries      *int
+	UploadBackoff      *time.Duration
+	UploadTimeout      *time.Duration
+	UploadBatchSize    *int
+	UploadBatchDelay   *time.Duration
+	MetricsAddr        *string
+	StaleTempAge       *time.Duration
+	StreamLayers       *bool
+	ScanBinaries       *bool
+	DecodeBase64       *bool
+	Color              *string
+	NoColor            *bool
+	ChangedFilesFrom   *string
+	Redact             *bool
+	RedactKeep         *int
+	RedactChar         *string
+	ScanEnvFiles       *bool
+	URL                *string
+	S3                 *string
+	S3Region           *string
+	S3SSECustomerKey   *string
+	GCS                *string
+	AzureBlob          *string
+	Helm               *string
+	HelmValues         *repeatableStringValue
+	ReportCertificates *bool
+
+	// Source of each option set: the command line, an environment variable or the --config-file
+	sources map[string]string
 }
 
 type repeatableStringValue struct {
@@ -59,32 +172,192 @@ func (v *repeatableStringValue) Values() []string {
 
 func ParseOptions() (*Options, error) {
 	options := &Options{
-		Threads:           flag.Int("threads", 0, "Number of concurrent threads (default number of logical CPUs)"),
-		Debug:             flag.Bool("debug", false, "enable debug logs"),
-		MaximumFileSize:   flag.Uint("maximum-file-size", 256, "Maximum file size to process in KB"),
-		TempDirectory:     flag.String("temp-directory", os.TempDir(), "Directory to process and store repositories/matches"),
-		Local:             flag.String("local", "", "Specify local directory (absolute path) which to scan. Scans only given directory recursively."),
-		HostMountPath:     flag.String("host-mount-path", "", "If scanning the host, specify the host mount path for path exclusions to work correctly."),
-		ConfigPath:        &repeatableStringValue{},
-		MergeConfigs:      flag.Bool("merge-configs", false, "Merge config files specified by --config-path into the default config"),
-		ImageName:         flag.String("image-name", "", "Name of the image along with tag to scan for secrets"),
-		MultipleMatch:     flag.Bool("multi-match", false, "Output multiple matches of same pattern in one file. By default, only one match of a pattern is output for a file for better performance"),
-		MaxMultiMatch:     flag.Uint("max-multi-match", 3, "Maximum number of matches of same pattern in one file. This is used only when multi-match option is enabled."),
-		MaxSecrets:        flag.Uint("max-secrets", 1000, "Maximum number of secrets to find in one container image or file system."),
-		ContainerID:       flag.String("container-id", "", "Id of existing container ID"),
-		ContainerNS:       flag.String("container-ns", "", "Namespace of existing container to scan, empty for docker runtime"),
-		WorkersPerScan:    flag.Int("workers-per-scan", 1, "Number of concurrent workers per scan"),
-		InactiveThreshold: flag.Int("inactive-threshold", 600, "Threshold for Inactive scan in seconds"),
-		OutFormat:         flag.String("output", TableOutput, "Output format: json or table"),
-		ConsoleURL:        flag.String("console-url", "", "Khulnasoft Management Console URL"),
-		ConsolePort:       flag.Int("console-port", 443, "Khulnasoft Management Console Port"),
-		KhulnasoftKey:     flag.String("khulnasoft-key", "", "Khulnasoft key for auth"),
-		FailOnCount:       flag.Int("fail-on-count", -1, "Exit with status 1 if number of secrets found is >= this value (Default: -1)"),
-		FailOnHighCount:   flag.Int("fail-on-high-count", -1, "Exit with status 1 if number of high secrets found is >= this value (Default: -1)"),
-		FailOnMediumCount: flag.Int("fail-on-medium-count", -1, "Exit with status 1 if number of medium secrets found is >= this value (Default: -1)"),
-		FailOnLowCount:    flag.Int("fail-on-low-count", -1, "Exit with status 1 if number of low secrets found is >= this value (Default: -1)"),
+		Threads:            flag.Int("threads", 0, "Number of concurrent threads (default number of logical CPUs)"),
+		Debug:              flag.Bool("debug", false, "enable debug logs"),
+		MaximumFileSize:    new(uint),
+		MaxExtractSize:     new(uint),
+		TempDirectory:      flag.String("temp-directory", os.TempDir(), "Directory to process and store repositories/matches"),
+		Local:              flag.String("local", "", "Specify local directory (absolute path) which to scan. Scans only given directory recursively."),
+		File:               flag.String("file", "", "Scan only this file, without walking its directory. Files larger than --maximum-file-size or with a skipped extension are not scanned"),
+		ForceExtension:     flag.Bool("force-extension", false, "Scan the --file even if its extension is in the blacklisted_extensions of the config or not in --include-extensions"),
+		Stdin:              flag.Bool("stdin", false, "Scan the contents read from the standard input, reported as the file <stdin>, e.g. cat file | SecretScanner --stdin"),
+		HostMountPath:      flag.String("host-mount-path", "", "If scanning the host, specify the host mount path for path exclusions to work correctly."),
+		ConfigPath:         &repeatableStringValue{},
+		EnableRule:         &repeatableStringValue{},
+		DisableRule:        &repeatableStringValue{},
+		ExcludePath:        &repeatableStringValue{},
+		InsecureRegistry:   &repeatableStringValue{},
+		MaxFileSizes:       &extensionSizesValue{},
+		Regex:              &repeatableStringValue{},
+		RegexName:          &repeatableStringValue{},
+		RegexSeverity:      &repeatableStringValue{},
+		HelmValues:         &repeatableStringValue{},
+		IncludeExtensions:  flag.String("include-extensions", "", "Comma separated extensions of the only files to scan, e.g. .env,.yaml,.json. A dotfile such as .env is matched by its name"),
+		Progress:           flag.Bool("progress", false, "Report the files and bytes scanned, the current layer and an estimate of the time left to stderr every few seconds"),
+		Timeout:            flag.Duration("timeout", 0, "Stop the scan after this duration, e.g. 30m, report the secrets found so far and exit with status 4. 0 for no timeout"),
+		RespectGitignore:   flag.Bool("respect-gitignore", false, "Skip the paths ignored by the .gitignore files of the scanned directory, nested ones included, and by its .dockerignore"),
+		ExcludeExtensions:  flag.String("exclude-extensions", "", "Comma separated extensions of the files to skip besides the blacklisted_extensions of the config, e.g. .min.js"),
+		MergeConfigs:       flag.Bool("merge-configs", false, "Merge config files specified by --config-path into the default config"),
+		ImageName:          &repeatableStringValue{},
+		PerImageThreshold:  flag.Bool("per-image-threshold", false, "Apply the fail-on thresholds to the secrets of each --image-name, instead of to the secrets of all the images"),
+		MultipleMatch:      flag.Bool("multi-match", false, "Output multiple matches of same pattern in one file. By default, only one match of a pattern is output for a file for better performance"),
+		MaxMultiMatch:      flag.Uint("max-multi-match", 3, "Maximum number of matches of same pattern in one file. This is used only when multi-match option is enabled."),
+		MaxSecrets:         flag.Uint("max-secrets", 1000, "Maximum number of secrets to find in one container image or file system."),
+		MinSeverity:        flag.String("min-severity", "", "Only report the secrets of this severity or a higher one: high, medium or low"),
+		CountAll:           flag.Bool("count-all", false, "Count the secrets below --min-severity towards --max-secrets, they are still not reported"),
+		ContainerID:        flag.String("container-id", "", "Id of existing container ID"),
+		ContainerNS:        flag.String("container-ns", "", "Namespace of existing container to scan, or of the containerd image to scan without docker, empty for docker runtime"),
+		WorkersPerScan:     flag.Int("workers-per-scan", 1, "Number of concurrent workers per scan"),
+		InactiveThreshold:  flag.Int("inactive-threshold", 600, "Threshold for Inactive scan in seconds"),
+		OutFormat:          flag.String("output", TableOutput, "Output format: json, table, ndjson, template, gitlab-sast or junit"),
+		OutputFile:         flag.String("output-file", "", "Write the results to this file, creating its parent directories, instead of stdout"),
+		Template:           flag.String("template", "", "Built-in template of --output template: compact, csv or markdown"),
+		TemplateFile:       flag.String("template-file", "", "Go text/template file rendering the secrets with --output template"),
+		ConsoleURL:         flag.String("console-url", "", "Khulnasoft Management Console URL"),
+		ConsolePort:        flag.Int("console-port", 443, "Khulnasoft Management Console Port"),
+		KhulnasoftKey:      flag.String("khulnasoft-key", "", "Khulnasoft key for auth. Deprecated, the key shows in the process table: use --khulnasoft-key-file or SECRETSCANNER_KHULNASOFT_KEY"),
+		KhulnasoftKeyFile:  flag.String("khulnasoft-key-file", "", "File holding the Khulnasoft key for auth, which unlike --khulnasoft-key keeps it out of the process table and shell history"),
+		FailOnCount:        flag.Int("fail-on-count", -1, "Exit with status 1 if number of secrets found is >= this value (Default: -1)"),
+		FailOnHighCount:    flag.Int("fail-on-high-count", -1, "Exit with status 1 if number of high secrets found is >= this value (Default: -1)"),
+		FailOnMediumCount:  flag.Int("fail-on-medium-count", -1, "Exit with status 1 if number of medium secrets found is >= this value (Default: -1)"),
+		FailOnLowCount:     flag.Int("fail-on-low-count", -1, "Exit with status 1 if number of low secrets found is >= this value (Default: -1)"),
+		FailOnSeverity:     flag.String("fail-on-severity", "", "Exit with status 1 if any secret of this severity or a higher one is found: high, medium or low"),
+		FromContentStore:   flag.Bool("from-content-store", false, "Read the layers of --image-name directly from the local containerd content store instead of saving the image. Falls back to saving the image if the content store is not accessible"),
+		Runtime:            flag.String("runtime", "", "Container runtime saving --image-name: docker or podman. Auto-detected if empty, podman being used when the docker socket is absent"),
+		Platform:           flag.String("platform", "", "Platform of the image to scan in multi-arch images, as os/architecture[/variant], e.g. linux/arm64. The host platform if empty"),
+		ContainerdAddress:  flag.String("containerd-address", "/run/containerd/containerd.sock", "Socket of containerd, used to resolve the tags of images read from the content store"),
+		ContentStorePath:   flag.String("content-store-path", "", "Path of the containerd content store, auto-detected if empty"),
+		Staged:             flag.Bool("staged", false, "Scan only the lines added by the staged changes of the git repository in --local or the current directory, e.g. from a pre-commit hook"),
+		StagedFiles:        flag.Bool("staged-files", false, "With --staged, scan the whole staged version of the files changed, read from the git index, instead of only the lines added"),
+		CoverageReport:     flag.String("coverage-report", "", "Write a JSON report of every file scanned, skipped (with reason) and errored to this path"),
+		CheckRulesUpdate:   flag.Bool("check-rules-update", false, "Check whether newer rules than the local config are available from --rules-manifest-url, without applying them"),
+		UpdateRules:        flag.Bool("update-rules", false, "Download and apply newer rules from --rules-manifest-url to the local config file"),
+		ListRules:          flag.Bool("list-rules", false, "List the rules applied by the scans, with the config merged and --enable-rule and --disable-rule applied, then exit without scanning. Listed as JSON with --output json"),
+		TestRule:           flag.String("test-rule", "", "Match only the rule with this ID against --test-input and print every match with its offsets, then exit. Exits with 1 if the rule matches nothing"),
+		TestInput:          flag.String("test-input", "", "Sample input of --test-rule: a file, or the string itself matched as contents and as a path"),
+		RulesManifestURL:   flag.String("rules-manifest-url", "", "URL of the remote rules manifest, a JSON document with the version, sha256 and url of the latest rules"),
+		Verify:             flag.Bool("verify", false, "Check whether the secrets found are live with the verifier of their rule. Needs network access"),
+		VerifyConcurrency:  flag.Int("verify-concurrency", 4, "Number of secrets verified at the same time by --verify"),
+		VerifyRate:         flag.Float64("verify-rate", 5, "Maximum verification requests per second sent by --verify, 0 for no limit"),
+		VerifySeverities:   flag.String("verify-severities", "", "Comma separated severities verified by --verify, e.g. high,medium. All severities by default"),
+		SortResults:        flag.Bool("sort-results", false, "Sort secrets by path, line and rule ID before output, so scans of the same target produce identical reports. Disables ndjson streaming"),
+		ScanPackages:       flag.Bool("scan-packages", false, "Scan inside npm (.tgz), pip (.whl, .tar.gz) and gem (.gem) package archives found while scanning"),
+		DecodeK8sSecrets:   flag.Bool("decode-k8s-secrets", false, "Match the signatures against the base64-decoded data of Kubernetes Secret manifests, and report the values of their stringData"),
+		RecursiveArchives:  flag.Bool("recursive-archives", false, "Extract and scan the tar, zip, jar, war and ear archives found while scanning, and the archives nested in them"),
+		MaxArchiveDepth:    flag.Int("max-archive-depth", 3, "Maximum nesting depth of the archives extracted with --recursive-archives"),
+		MaxExtractEntries:  flag.Int("max-extract-entries", 1000000, "Maximum number of entries read from an image, its tar and all its layers, or from an archive. The scan is aborted once it is exceeded"),
+		Remediate:          flag.String("remediate", "", "Remediation of the secrets found by a --local scan: replace, to replace them in the files with a placeholder after backing the files up"),
+		ConfirmRemediation: flag.Bool("confirm-remediation", false, "Modify the files for --remediate without asking for confirmation"),
+		RegistryAuth:       flag.String("registry-auth", "", "Credentials of the registry of --image-name as username:password, instead of the docker config. The image is then pulled from its registry"),
+		PullFromRegistry:   flag.Bool("pull-from-registry", false, "Pull --image-name from its registry without a container runtime even when no credentials are configured for it"),
+		Proxy:              flag.String("proxy", "", "URL of the http://, https:// or socks5:// proxy of the registry pulls and the console connections, overriding HTTP_PROXY and HTTPS_PROXY"),
+		NoProxy:            flag.String("no-proxy", "", "Comma separated hosts, domains and CIDRs not reached through the proxy, overriding NO_PROXY"),
+		RegistryCA:         flag.String("registry-ca", "", "PEM file of the CA certificates trusted, along with the system ones, when pulling images from their registry, e.g. for registries with self-signed certificates"),
+		URL:                flag.String("url", "", "Download this http or https URL to a temp dir and scan it. Zip, tar and tar.gz archives are extracted, other files are scanned as they are. Secrets are reported in the path of the URL"),
+		S3:                 flag.String("s3", "", "Scan the objects of an S3 bucket under a prefix, s3://bucket/prefix, with the AWS credentials of the environment. Secrets are reported in the object keys"),
+		S3Region:           flag.String("s3-region", "", "Region of the --s3 bucket, overriding AWS_REGION and the shared config file"),
+		S3SSECustomerKey:   flag.String("s3-sse-customer-key", "", "Base64 256-bit key of the --s3 objects encrypted with SSE-C. Prefer SECRETSCANNER_S3_SSE_CUSTOMER_KEY, the key shows in the process table"),
+		GCS:                flag.String("gcs", "", "Scan the objects of a GCS bucket under a prefix, gs://bucket/prefix, with the Google application default credentials. Secrets are reported in the object names"),
+		AzureBlob:          flag.String("azure-blob", "", "Scan the blobs of an Azure Blob Storage container under a prefix, https://account.blob.core.windows.net/container/prefix, with the shared access signature of the URL or the Azure credentials of the environment. Secrets are reported in the blob names"),
+		Helm:               flag.String("helm", "", "Scan a Helm chart directory rendered with its values and the --helm-values files, as helm template does. Secrets of the manifests are reported in their templates, e.g. chart/templates/secret.yaml, at their rendered line. When the chart can't be rendered, its templates are scanned raw"),
+		Archive:            flag.String("archive", "", "Scan the files of a .zip, .tar or .tar.gz archive without unpacking it first. --local also accepts such an archive"),
+		OCILayout:          flag.String("oci-layout", "", "Scan the image of an OCI image layout directory, as written by skopeo or docker buildx. --local also accepts such a directory"),
+		NoWhiteout:         flag.Bool("no-whiteout", false, "Report the secrets of image layers in files deleted by a higher layer, which are not in the final image. Useful for forensics"),
+		Flatten:            flag.Bool("flatten", false, "Overlay the layers of --image-name into its final rootfs and scan it once, instead of scanning each layer. Secrets are reported with the top-most layer providing their file"),
+		NoDedupe:           flag.Bool("no-dedupe", false, "Report a secret once per image layer it is found in, instead of once with the list of its layers"),
+		EntropyThreshold:   flag.Float64("entropy-threshold", 4.5, "Minimum entropy in bits per char of the base64 strings reported as GenericHighEntropy secrets. Hex strings use 3/4 of it"),
+		EntropyMinLength:   flag.Uint("entropy-min-length", 20, "Minimum length of the base64 and hex strings checked for high entropy"),
+		NoEntropy:          flag.Bool("no-entropy", false, "Don't report high entropy strings which match no signature"),
+		ShowSuppressed:     flag.Bool("show-suppressed", false, "Report the secrets suppressed by a secretscanner:ignore comment at the suppressed severity, instead of dropping them"),
+		Baseline:           flag.String("baseline", "", "JSON file of the secrets known from a previous scan, not reported again"),
+		WriteBaseline:      flag.Bool("write-baseline", false, "Write the secrets found to the --baseline file instead of filtering them out, and don't fail the scan"),
+		GitHistory:         flag.String("git-history", "", "Scan the files of every commit of the git repository in this directory, reporting the commit introducing each secret"),
+		Since:              flag.String("since", "", "With --git-history, only scan the commits more recent than this date, e.g. 2024-01-31 or \"6 months ago\""),
+		Allowlist:          flag.String("allowlist", "", "YAML file of known secrets not to report, matched by path glob, rule ID or secret value"),
+		NoPrefilter:        flag.Bool("no-prefilter", false, "Match the regex of every rule against every file, instead of skipping the rules none of whose keywords is in the file"),
+		CacheDir:           flag.String("cache-dir", "", "Cache the secrets found in image layers, by layer digest, in this directory. The entries hold the secrets found, layers are not cached without it"),
+		NoCache:            flag.Bool("no-cache", false, "Extract and scan every image layer, without reading or writing the layer cache of --cache-dir"),
+		UploadRetries:      flag.Int("upload-retries", 5, "Number of retries of a failed write of the results or status of a scan requested over gRPC, before the scan is failed"),
+		UploadBackoff:      flag.Duration("upload-backoff", time.Second, "Wait before the first retry of a failed write of the results of a scan requested over gRPC, doubled after each retry up to 30s, with jitter"),
+		UploadTimeout:      flag.Duration("upload-timeout", 2*time.Minute, "Maximum time spent retrying a write of the results of a scan requested over gRPC, 0 for no limit"),
+		UploadBatchSize:    flag.Int("upload-batch-size", 100, "Number of secrets of a scan requested over gRPC written together"),
+		UploadBatchDelay:   flag.Duration("upload-batch-delay", 5*time.Second, "Longest time a secret of a scan requested over gRPC waits for its batch to fill up before being written"),
+		MetricsAddr:        flag.String("metrics-addr", "", "Address serving Prometheus metrics of the scans on /metrics, e.g. :9090. Meant for the gRPC server (--socket-path), disabled when empty"),
+		StaleTempAge:       flag.Duration("stale-temp-age", 24*time.Hour, "Temp dirs left under --temp-directory by killed scans are deleted at startup once not modified for this long, 0 to keep them"),
+		StreamLayers:       flag.Bool("stream-layers", false, "Scan the files of the image layers as they are read from the layer tars, without extracting the layers to --temp-directory. Ignored with --flatten and --respect-gitignore"),
+		ScanBinaries:       flag.Bool("scan-binaries", false, "Match the signatures against the printable strings of the binary files, like strings does, and scan executables and libraries such as .exe and .so even if their extension is blacklisted"),
+		DecodeBase64:       flag.Bool("decode-base64", false, "Also match the signatures against the base64-encoded strings of the files once decoded, reporting the secrets at the encoded strings"),
+		ScanEnvFiles:       flag.Bool("scan-env-files", false, "Report the values of the variables of .env files named like secrets, e.g. DB_PASSWORD or API_KEY, or with a high entropy, with the name of their variable"),
+		ReportCertificates: flag.Bool("report-certificates", false, "Report the certificates of the PEM blocks, at low severity. Only their private keys are reported otherwise"),
+		Color:              flag.String("color", ColorAuto, "Color the severities of the table output and the logs: always, never, or auto to color them only when written to a terminal and NO_COLOR is not set"),
+		NoColor:            flag.Bool("no-color", false, "Don't color the output, same as --color=never"),
+		ChangedFilesFrom:   flag.String("changed-files-from", "", "File listing the files to scan in the --local directory, one per line, e.g. the output of git diff --name-only, instead of walking the whole directory. Relative paths are relative to the current directory"),
+		Redact:             flag.Bool("redact", false, "Mask the secrets found in the JSON, NDJSON and template reports, the results sent over gRPC and those published to the console, keeping their first --redact-keep characters"),
+		RedactKeep:         flag.Int("redact-keep", 4, "Characters of a secret shown by --redact. Secrets shorter than twice as many characters are masked whole"),
+		RedactChar:         flag.String("redact-char", "*", "Character replacing the masked characters of the secrets with --redact"),
+		ConfigFile:         flag.String(configFileFlag, "", "YAML or JSON file of options named like the flags, e.g. max-secrets: 500, to check a scan profile into a repository. Flags on the command line override it. Unlike --config-path, it holds no rules"),
+		CumulativeSeverity: flag.Bool("cumulative-severity", false, "Count secrets towards the fail-on thresholds of their own and all lower severities, e.g. a high secret also counts for --fail-on-medium-count"),
 	}
 	flag.Var(options.ConfigPath, "config-path", "Searches for config.yaml from given directory. If not set, tries to find it from SecretScanner binary's and current directory.  Can be specified multiple times.")
-	flag.Parse()
+	flag.Var(options.ImageName, "image-name", "Name of the image along with tag to scan for secrets. Can be specified multiple times, to scan several images into one report.")
+	flag.Var(options.EnableRule, "enable-rule", "Only apply the rule with this ID, -1 for high entropy strings. Can be specified multiple times.")
+	flag.Var(options.DisableRule, "disable-rule", "Don't apply the rule with this ID, -1 for high entropy strings. Can be specified multiple times.")
+	flag.Var(options.ExcludePath, "exclude-path", "Skip the files and dirs whose path relative to the scanned dir or image matches this glob pattern, e.g. **/test/**. Can be specified multiple times.")
+	flag.Var(options.InsecureRegistry, "insecure-registry", "Don't verify the TLS certificate of this registry host, e.g. registry.internal:5000, when pulling images from it. Can be specified multiple times.")
+	flag.Var(options.Regex, "regex", "Also report the matches of this regex in the contents of the files, as an ad-hoc rule. Can be specified multiple times.")
+	flag.Var(options.RegexName, "regex-name", "Name of the ad-hoc rule of the --regex at the same position, AdHocRegex1, AdHocRegex2... by default. Can be specified multiple times.")
+	flag.Var(options.HelmValues, "helm-values", "Values file the --helm chart is rendered with, over the values.yaml of the chart, like helm template --values. The files are scanned too. Can be specified multiple times, the last one wins.")
+	flag.Var(options.RegexSeverity, "regex-severity", "Severity of the ad-hoc rule of the --regex at the same position: low, medium (default) or high. Can be specified multiple times.")
+	*options.MaximumFileSize = 256
+	flag.Var((*kilobytesValue)(options.MaximumFileSize), "maximum-file-size", "Maximum file size to process, with a unit such as 256k, 5M or 1G. A number without unit is in KB")
+	*options.MaxExtractSize = 10 * 1024 * 1024
+	flag.Var((*kilobytesValue)(options.MaxExtractSize), "max-extract-size", "Maximum size extracted from an image, its tar and all its layers, or from an archive, with a unit such as 512M or 20G. The scan is aborted once it is exceeded")
+	flag.Var(options.MaxFileSizes, "max-file-size", "Comma separated maximum sizes of the files of some extensions, overriding --maximum-file-size, e.g. .env=5MB,.log=1MB. Sizes are in B, K, M or G, KB without unit. Can be specified multiple times.")
+	// Invalid flags exit with ExitUsage instead of the status 2 of the flag package
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(ExitClean)
+		}
+		return nil, err
+	}
+	// The command line wins over environment variables, which win over the config file
+	options.sources = commandLineSources(flag.CommandLine)
+	if err := loadOptionsEnv(flag.CommandLine, os.LookupEnv, options.sources); err != nil {
+		return nil, err
+	}
+	if *options.ConfigFile != "" {
+		if err := loadOptionsFile(flag.CommandLine, *options.ConfigFile, options.sources); err != nil {
+			return nil, err
+		}
+	}
+	if err := options.loadKhulnasoftKey(); err != nil {
+		return nil, err
+	}
 	return options, nil
 }
+
+// Read the Khulnasoft key from --khulnasoft-key-file, and warn when it is given on the command line where other
+// users can read it in the process table
+func (options *Options) loadKhulnasoftKey() error {
+	if options.sources["khulnasoft-key"] == commandLineSource {
+		log.Warn("--khulnasoft-key is deprecated, the key shows in the process table and the shell history: " +
+			"use --khulnasoft-key-file or SECRETSCANNER_KHULNASOFT_KEY instead")
+	}
+	if *options.KhulnasoftKeyFile == "" {
+		return nil
+	}
+	if *options.KhulnasoftKey != "" {
+		return errors.New("--khulnasoft-key and --khulnasoft-key-file can't both be set")
+	}
+	data, err := os.ReadFile(*options.KhulnasoftKeyFile)
+	if err != nil {
+		return fmt.Errorf("--khulnasoft-key-file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return fmt.Errorf("--khulnasoft-key-file: %s is empty", *options.KhulnasoftKeyFile)
+	}
+	*options.KhulnasoftKey = key
+	return nil
+}
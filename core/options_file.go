@@ -0,0 +1,158 @@
+package core
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of the options, logged with --debug
+const (
+	commandLineSource = "command line"
+	configFileFlag    = "config-file"
+	envVarPrefix      = "SECRETSCANNER_"
+)
+
+// Options whose values are never logged
+var secretOptions = map[string]bool{"khulnasoft-key": true, "registry-auth": true, "proxy": true,
+	"s3-sse-customer-key": true, "azure-blob": true}
+
+// Names of the flags set on the command line
+func commandLineSources(flags *flag.FlagSet) map[string]string {
+	sources := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		sources[f.Name] = commandLineSource
+	})
+	return sources
+}
+
+// Name of the environment variable of a flag, e.g. SECRETSCANNER_MAX_SECRETS for --max-secrets
+func envVarName(flagName string) string {
+	return envVarPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Load the options set by environment variables into the flags which are not set on the command line. Empty
+// variables are ignored, and the values of repeatable flags are separated by commas
+// @parameters
+// flags - Flag set, already parsed from the command line
+// lookupEnv - Returns the value of an environment variable, os.LookupEnv
+// sources - Source of each option set so far, updated with the options set by environment variables
+// @returns
+// Error - Errors if a value is invalid. Otherwise, returns nil
+func loadOptionsEnv(flags *flag.FlagSet, lookupEnv func(string) (string, bool), sources map[string]string) error {
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := envVarName(f.Name)
+		value, found := lookupEnv(name)
+		if err != nil || !found || value == "" || sources[f.Name] != "" {
+			return
+		}
+		values := []string{value}
+		if _, repeatable := f.Value.(*repeatableStringValue); repeatable {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if setErr := f.Value.Set(strings.TrimSpace(v)); setErr != nil {
+				err = fmt.Errorf("environment variable %s: %w", name, setErr)
+				return
+			}
+		}
+		sources[f.Name] = "environment variable " + name
+	})
+	return err
+}
+
+// Load the options of a --config-file into the flags which are not set on the command line or by environment
+// variables
+// @parameters
+// flags - Flag set, already parsed from the command line
+// path - Path of the YAML or JSON file, mapping the names of the flags to their values. Lists set repeatable flags
+// sources - Source of each option set so far, updated with the options set by the file
+// @returns
+// Error - Errors if the file can't be read, has unknown keys or invalid values. Otherwise, returns nil
+func loadOptionsFile(flags *flag.FlagSet, path string, sources map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	// JSON documents are YAML documents too
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	options := root.Content[0]
+	if options.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s: expected a mapping of option names to values", path)
+	}
+
+	var unknown []string
+	for i := 0; i+1 < len(options.Content); i += 2 {
+		key, value := options.Content[i], options.Content[i+1]
+		name := strings.TrimLeft(key.Value, "-")
+		f := flags.Lookup(name)
+		if f == nil || name == configFileFlag {
+			unknown = append(unknown, fmt.Sprintf("%s (line %d)", key.Value, key.Line))
+			continue
+		}
+		if sources[name] != "" {
+			continue
+		}
+		if err = setFlagFromNode(f, value); err != nil {
+			return fmt.Errorf("config file %s line %d: %s: %w", path, value.Line, name, err)
+		}
+		sources[name] = path
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("config file %s: unknown options %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Set a flag to a value of the config file, each item of a list being set in turn for repeatable flags
+func setFlagFromNode(f *flag.Flag, value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return f.Value.Set(value.Value)
+	case yaml.SequenceNode:
+		switch f.Value.(type) {
+		case *repeatableStringValue, *extensionSizesValue:
+		default:
+			return fmt.Errorf("a single value is expected, only repeatable options accept lists")
+		}
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: lists may only hold values", item.Line)
+			}
+			if err := f.Value.Set(item.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("expected a value or a list of values")
+}
+
+// LogSources Logs the value of each option set on the command line, by an environment variable or in the
+// --config-file, and where it comes from. Logged at debug level, the values of credentials are masked
+func (options *Options) LogSources() {
+	names := make([]string, 0, len(options.sources))
+	for name := range options.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := "***"
+		if f := flag.Lookup(name); f != nil && !secretOptions[name] {
+			value = f.Value.String()
+		}
+		log.Debugf("option %s=%s from %s", name, value, options.sources[name])
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testFlagSet() (*flag.FlagSet, *uint, *string, *bool, *repeatableStringValue) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	maxSecrets := flags.Uint("max-secrets", 1000, "")
+	output := flags.String("output", TableOutput, "")
+	noEntropy := flags.Bool("no-entropy", false, "")
+	flags.String(configFileFlag, "", "")
+	excludePath := &repeatableStringValue{}
+	flags.Var(excludePath, "exclude-path", "")
+	return flags, maxSecrets, output, noEntropy, excludePath
+}
+
+func writeOptionsFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_LoadOptionsFile(t *testing.T) {
+	path := writeOptionsFile(t, "scan.yaml", "max-secrets: 50\noutput: json\nno-entropy: true\n"+
+		"exclude-path:\n  - '**/test/**'\n  - vendor/**\n")
+	flags, maxSecrets, output, noEntropy, excludePath := testFlagSet()
+	if err := flags.Parse([]string{"--output", "ndjson", "--config-file", path}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags on the command line override the file
+	sources := commandLineSources(flags)
+	if err := loadOptionsFile(flags, path, sources); err != nil {
+		t.Fatal(err)
+	}
+	if *maxSecrets != 50 || *output != "ndjson" || !*noEntropy {
+		t.Errorf("unexpected options max-secrets=%d output=%s no-entropy=%t", *maxSecrets, *output, *noEntropy)
+	}
+	if expected := []string{"**/test/**", "vendor/**"}; !reflect.DeepEqual(excludePath.Values(), expected) {
+		t.Errorf("exclude-path %v, want %v", excludePath.Values(), expected)
+	}
+	expected := map[string]string{"output": commandLineSource, configFileFlag: commandLineSource, "max-secrets": path,
+		"no-entropy": path, "exclude-path": path}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("sources %v, want %v", sources, expected)
+	}
+}
+
+func Test_LoadOptionsFileJSON(t *testing.T) {
+	path := writeOptionsFile(t, "scan.json", `{"max-secrets": 20, "exclude-path": ["dist/**"]}`)
+	flags, maxSecrets, _, _, excludePath := testFlagSet()
+	if err := loadOptionsFile(flags, path, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if *maxSecrets != 20 || !reflect.DeepEqual(excludePath.Values(), []string{"dist/**"}) {
+		t.Errorf("unexpected options max-secrets=%d exclude-path=%v", *maxSecrets, excludePath.Values())
+	}
+}
+
+func Test_LoadOptionsFileErrors(t *testing.T) {
+	for contents, expected := range map[string]string{
+		"max-secrets: 5\nmax-secret: 5\nconfig-file: other.yaml\n": "unknown options max-secret (line 2), config-file (line 3)",
+		"max-secrets: many\n":         "line 1: max-secrets",
+		"output: [json, table]\n":     "only repeatable options accept lists",
+		"- max-secrets\n":             "expected a mapping",
+		"exclude-path:\n  - [a, b]\n": "lists may only hold values",
+	} {
+		flags, _, _, _, _ := testFlagSet()
+		err := loadOptionsFile(flags, writeOptionsFile(t, "scan.yaml", contents), map[string]string{})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", contents, expected, err)
+		}
+	}
+}
+
+func Test_EnvVarName(t *testing.T) {
+	if name := envVarName("max-secrets"); name != "SECRETSCANNER_MAX_SECRETS" {
+		t.Errorf("envVarName(max-secrets) = %s", name)
+	}
+}
+
+func Test_LoadOptionsEnv(t *testing.T) {
+	path := writeOptionsFile(t, "scan.yaml", "max-secrets: 50\noutput: json\nno-entropy: true\n")
+	env := map[string]string{
+		"SECRETSCANNER_OUTPUT":       "ndjson",
+		"SECRETSCANNER_MAX_SECRETS":  "7",
+		"SECRETSCANNER_NO_ENTROPY":   "",
+		"SECRETSCANNER_EXCLUDE_PATH": "dist/**, vendor/**",
+		"SECRETSCANNER_CONFIG_FILE":  path,
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+	flags, maxSecrets, output, noEntropy, excludePath := testFlagSet()
+	if err := flags.Parse([]string{"--output", "table"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Command line > environment variable > config file > default
+	sources := commandLineSources(flags)
+	if err := loadOptionsEnv(flags, lookupEnv, sources); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadOptionsFile(flags, flags.Lookup(configFileFlag).Value.String(), sources); err != nil {
+		t.Fatal(err)
+	}
+	if *output != TableOutput || *maxSecrets != 7 || !*noEntropy {
+		t.Errorf("unexpected options max-secrets=%d output=%s no-entropy=%t", *maxSecrets, *output, *noEntropy)
+	}
+	if expected := []string{"dist/**", "vendor/**"}; !reflect.DeepEqual(excludePath.Values(), expected) {
+		t.Errorf("exclude-path %v, want %v", excludePath.Values(), expected)
+	}
+	expected := map[string]string{"output": commandLineSource, "max-secrets": "environment variable SECRETSCANNER_MAX_SECRETS",
+		"exclude-path": "environment variable SECRETSCANNER_EXCLUDE_PATH", "no-entropy": path,
+		configFileFlag: "environment variable SECRETSCANNER_CONFIG_FILE"}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("sources %v, want %v", sources, expected)
+	}
+
+	env["SECRETSCANNER_MAX_SECRETS"] = "many"
+	flags, _, _, _, _ = testFlagSet()
+	err := loadOptionsEnv(flags, lookupEnv, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "SECRETSCANNER_MAX_SECRETS") {
+		t.Errorf("expected an invalid value to be reported with its variable, got %v", err)
+	}
+}
+
+func Test_LoadKhulnasoftKey(t *testing.T) {
+	key, keyFile := "", writeOptionsFile(t, "key", "s3cr3t-key\n")
+	options := &Options{KhulnasoftKey: &key, KhulnasoftKeyFile: &keyFile, sources: map[string]string{}}
+	if err := options.loadKhulnasoftKey(); err != nil || key != "s3cr3t-key" {
+		t.Errorf("unexpected key %q, %v", key, err)
+	}
+
+	// The key must come from a single option
+	if err := options.loadKhulnasoftKey(); err == nil {
+		t.Errorf("expected an error when both the key and the key file are set")
+	}
+
+	key, keyFile = "", writeOptionsFile(t, "empty", " \n")
+	if err := options.loadKhulnasoftKey(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("expected an empty key file to be rejected, got %v", err)
+	}
+}
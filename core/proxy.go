@@ -0,0 +1,67 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Schemes of the proxy URLs supported by net/http
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true}
+
+// Proxy settings of the environment, overridden by --proxy and --no-proxy
+func proxyConfig(proxy, noProxy string) *httpproxy.Config {
+	config := httpproxy.FromEnvironment()
+	if proxy != "" {
+		config.HTTPProxy = proxy
+		config.HTTPSProxy = proxy
+	}
+	if noProxy != "" {
+		config.NoProxy = noProxy
+	}
+	return config
+}
+
+// ProxyFunc Proxy of the requests of the registry pulls and the console connections, for http.Transport.Proxy.
+// Like http.ProxyFromEnvironment, requests to localhost are never proxied
+// @returns
+// func(*http.Request) (*url.URL, error) - URL of the proxy of a request, nil for no proxy
+func ProxyFunc() func(*http.Request) (*url.URL, error) {
+	return proxyFunc(*session.Options.Proxy, *session.Options.NoProxy)
+}
+
+func proxyFunc(proxy, noProxy string) func(*http.Request) (*url.URL, error) {
+	proxyURL := proxyConfig(proxy, noProxy).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyURL(req.URL)
+	}
+}
+
+// ApplyProxy Check --proxy and export it and --no-proxy to HTTP_PROXY, HTTPS_PROXY and NO_PROXY, for the clients
+// of the libraries reading them, such as the console client. Must be called before the first request
+// @returns
+// Error - Errors if --proxy is not a valid http, https or socks5 URL
+func ApplyProxy() error {
+	return applyProxy(*session.Options.Proxy, *session.Options.NoProxy)
+}
+
+func applyProxy(proxy, noProxy string) error {
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || !proxySchemes[u.Scheme] || u.Host == "" {
+			return fmt.Errorf("--proxy must be an http://, https:// or socks5:// URL, got %q", proxy)
+		}
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			os.Setenv(name, proxy)
+		}
+	}
+	if noProxy != "" {
+		for _, name := range []string{"NO_PROXY", "no_proxy"} {
+			os.Setenv(name, noProxy)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func Test_ApplyProxy(t *testing.T) {
+	// Restored after the test
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		t.Setenv(name, os.Getenv(name))
+	}
+	for proxy, valid := range map[string]bool{"http://proxy.corp:3128": true, "socks5://127.0.0.1:1080": true,
+		"proxy.corp:3128": false, "ftp://proxy.corp": false, "http://": false} {
+		if err := applyProxy(proxy, ""); (err == nil) != valid {
+			t.Errorf("applyProxy() with --proxy %s = %v, want valid %v", proxy, err, valid)
+		}
+	}
+}
+
+func Test_ProxyFunc(t *testing.T) {
+	proxyOf := proxyFunc("socks5://proxy.corp:1080", "internal.corp")
+
+	for target, expected := range map[string]string{"https://registry-1.docker.io/v2/": "socks5://proxy.corp:1080",
+		"http://console.example.com/": "socks5://proxy.corp:1080", "https://registry.internal.corp/v2/": "",
+		"https://localhost:5000/v2/": ""} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		proxy, err := proxyOf(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := ""; proxy != nil {
+			actual = proxy.String()
+			if actual != expected {
+				t.Errorf("proxy of %s = %s, want %s", target, actual, expected)
+			}
+		} else if expected != "" {
+			t.Errorf("%s not proxied, want %s", target, expected)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Time allowed to fetch the remote manifest or rules
+const RulesUpdateTimeout = 30 * time.Second
+
+// Largest rules file accepted from the remote
+const maxRulesSize = 16 << 20
+
+// RulesManifest Remote description of the latest rules
+type RulesManifest struct {
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	URL     string `json:"url"`
+}
+
+// RulesUpdateStatus Result of comparing the local rules against the remote manifest
+type RulesUpdateStatus struct {
+	RulesPath    string
+	LocalVersion string
+	LocalSHA256  string
+	Remote       RulesManifest
+	Available    bool
+}
+
+func (s *RulesUpdateStatus) String() string {
+	local := s.LocalVersion
+	if local == "" {
+		local = "unversioned"
+	}
+	if !s.Available {
+		return fmt.Sprintf("Rules in %s are up to date (version %s)", s.RulesPath, local)
+	}
+	return fmt.Sprintf("Newer rules are available for %s: version %s (local version %s)", s.RulesPath, s.Remote.Version, local)
+}
+
+// CheckRulesUpdate Compare the local rules file against the remote manifest
+// @parameters
+// ctx - Context bounding the fetch of the manifest
+// rulesPath - Local config file holding the rules
+// manifestURL - URL of the remote manifest
+// @returns
+// *RulesUpdateStatus - Local and remote versions, and whether an update is available
+// Error - Errors if any. Otherwise, returns nil
+func CheckRulesUpdate(ctx context.Context, rulesPath, manifestURL string) (*RulesUpdateStatus, error) {
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid rules in %s: %w", rulesPath, err)
+	}
+
+	body, err := fetch(ctx, manifestURL, maxRulesSize)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch rules manifest: %w", err)
+	}
+	var manifest RulesManifest
+	if err = json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid rules manifest from %s: %w", manifestURL, err)
+	}
+	if manifest.Version == "" && manifest.SHA256 == "" {
+		return nil, fmt.Errorf("rules manifest from %s has neither version nor sha256", manifestURL)
+	}
+
+	status := &RulesUpdateStatus{
+		RulesPath:    rulesPath,
+		LocalVersion: config.RulesVersion,
+		LocalSHA256:  sha256Hex(data),
+		Remote:       manifest,
+	}
+	status.Available = rulesUpdateAvailable(status.LocalVersion, status.LocalSHA256, manifest)
+	return status, nil
+}
+
+// UpdateRules Download the rules described by the manifest and replace the local rules file
+// @parameters
+// ctx - Context bounding the download
+// status - Result of CheckRulesUpdate
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func UpdateRules(ctx context.Context, status *RulesUpdateStatus) error {
+	if status.Remote.URL == "" {
+		return fmt.Errorf("rules manifest has no url to download the rules from")
+	}
+	data, err := fetch(ctx, status.Remote.URL, maxRulesSize)
+	if err != nil {
+		return fmt.Errorf("could not download rules: %w", err)
+	}
+	if status.Remote.SHA256 != "" && !strings.EqualFold(sha256Hex(data), status.Remote.SHA256) {
+		return fmt.Errorf("downloaded rules do not match the manifest sha256 %s", status.Remote.SHA256)
+	}
+	config := &Config{}
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("downloaded rules are invalid: %w", err)
+	}
+
+	// Write next to the rules file and rename, so a failure never leaves partial rules
+	tmp, err := os.CreateTemp(filepath.Dir(status.RulesPath), ".rules-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp creates the file 0600, the rules keep the mode of the file they replace
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(status.RulesPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), status.RulesPath)
+}
+
+func rulesUpdateAvailable(localVersion, localSHA256 string, remote RulesManifest) bool {
+	if remote.Version != "" && localVersion != "" {
+		return CompareVersions(remote.Version, localVersion) > 0
+	}
+	return remote.SHA256 != "" && !strings.EqualFold(remote.SHA256, localSHA256)
+}
+
+// CompareVersions Compare dotted versions such as 1.10.2, ignoring a leading v
+// @returns
+// int - -1 if a is older than b, 1 if a is newer than b, 0 if equal
+func CompareVersions(a, b string) int {
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+		if aPart == "" {
+			aNum, aErr = 0, nil
+		}
+		if bPart == "" {
+			bNum, bErr = 0, nil
+		}
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return compareInts(aNum, bNum)
+			}
+		case aPart != bPart:
+			return strings.Compare(aPart, bPart)
+		}
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+func fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("GET %s: response larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package core_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+func Test_CompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"v1.2.0", "1.1.9", 1},
+		{"1.9.0", "1.10.0", -1},
+		{"2", "1.99", 1},
+	}
+	for _, test := range tests {
+		if actual := core.CompareVersions(test.a, test.b); actual != test.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, actual, test.expected)
+		}
+	}
+}
+
+func Test_RulesUpdate(t *testing.T) {
+	newRules := []byte("rules_version: '1.1.0'\nsignatures: []\n")
+	sum := sha256.Sum256(newRules)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version": "1.1.0", "sha256": %q, "url": %q}`, hex.EncodeToString(sum[:]), server.URL+"/config.yaml")
+	})
+	mux.HandleFunc("/config.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(newRules)
+	})
+
+	rulesPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(rulesPath, []byte("rules_version: '1.0.0'\nsignatures: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := core.CheckRulesUpdate(context.Background(), rulesPath, server.URL+"/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Available || status.LocalVersion != "1.0.0" {
+		t.Fatalf("expected an update from 1.0.0, got %+v", status)
+	}
+
+	// The check alone never modifies the rules
+	if data, _ := os.ReadFile(rulesPath); string(data) == string(newRules) {
+		t.Fatalf("rules were modified by the check")
+	}
+
+	if err = core.UpdateRules(context.Background(), status); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(rulesPath); string(data) != string(newRules) {
+		t.Errorf("rules were not updated, got:\n%s", data)
+	}
+	if info, err := os.Stat(rulesPath); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Errorf("rules mode changed to %v by the update, want 0644", info.Mode().Perm())
+	}
+
+	status, err = core.CheckRulesUpdate(context.Background(), rulesPath, server.URL+"/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Available {
+		t.Errorf("expected rules to be up to date after the update, got %+v", status)
+	}
+}
+
+func Test_UpdateRulesChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("signatures: []\n"))
+	}))
+	defer server.Close()
+
+	rulesPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := []byte("signatures: []\n# local\n")
+	if err := os.WriteFile(rulesPath, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status := &core.RulesUpdateStatus{
+		RulesPath: rulesPath,
+		Remote:    core.RulesManifest{Version: "2.0.0", SHA256: "deadbeef", URL: server.URL},
+	}
+	if err := core.UpdateRules(context.Background(), status); err == nil {
+		t.Errorf("expected a checksum error")
+	}
+	if data, _ := os.ReadFile(rulesPath); string(data) != string(original) {
+		t.Errorf("rules were modified despite the checksum error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"sync"
 
@@ -16,6 +17,11 @@ type Session struct {
 	Options *Options
 	Config  *Config
 	Context context.Context
+
+	// Extensions of --include-extensions, empty to scan files of any extension
+	includedExtensions []string
+	// Sizes and modification times of the config files Config is loaded from, see ReloadConfig
+	configFiles string
 }
 
 var (
@@ -24,6 +30,20 @@ var (
 	err         error
 )
 
+// Version of SecretScanner, set when building with -ldflags "-X github.com/khulnasoft-lab/SecretScanner/core.Version=2.2.0".
+// Builds without it report the version of the module, e.g. with go install, or "dev"
+var Version string
+
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
 func (s *Session) Start() {
 	s.InitThreads()
 }
@@ -40,36 +60,72 @@ func (s *Session) InitThreads() {
 func GetSession() *Session {
 	sessionSync.Do(func() {
 		session = &Session{
+			Version: buildVersion(),
 			Context: context.Background(),
 		}
 
 		if session.Options, err = ParseOptions(); err != nil {
 			log.Error(err)
-			os.Exit(1)
+			os.Exit(ExitUsage)
 		}
 
+		session.configFiles = configFilesState(session.Options)
 		if session.Config, err = ParseConfig(session.Options); err != nil {
 			log.Error(err)
-			os.Exit(1)
+			os.Exit(ExitUsage)
 		}
-
-		pathSeparator := string(os.PathSeparator)
-		nameSeperator := "-"
-		var blacklistedPaths []string
-		for _, blacklistedPath := range session.Config.BlacklistedPaths {
-			blacklistedPaths = append(blacklistedPaths, strings.ReplaceAll(blacklistedPath, "{sep}", pathSeparator))
-		}
-		session.Config.BlacklistedPaths = blacklistedPaths
-		var excludePaths []string
-		for _, excludePath := range session.Config.ExcludePaths {
-			excludePaths = append(excludePaths, strings.ReplaceAll(excludePath, "{sep}", pathSeparator))
-			excludePaths = append(excludePaths, strings.ReplaceAll(excludePath, "{name_sep}", nameSeperator))
-
-		}
-		session.Config.ExcludePaths = excludePaths
+		session.expandConfig(session.Config)
+		session.includedExtensions = ParseExtensions(*session.Options.IncludeExtensions)
 
 		session.Start()
 	})
 
 	return session
 }
+
+// Expand the placeholders of the paths of a config, and add the extensions skipped by the options
+func (s *Session) expandConfig(config *Config) {
+	pathSeparator := string(os.PathSeparator)
+	nameSeperator := "-"
+	var blacklistedPaths []string
+	for _, blacklistedPath := range config.BlacklistedPaths {
+		blacklistedPaths = append(blacklistedPaths, strings.ReplaceAll(blacklistedPath, "{sep}", pathSeparator))
+	}
+	config.BlacklistedPaths = blacklistedPaths
+	var excludePaths []string
+	for _, excludePath := range config.ExcludePaths {
+		excludePaths = append(excludePaths, strings.ReplaceAll(excludePath, "{sep}", pathSeparator))
+		excludePaths = append(excludePaths, strings.ReplaceAll(excludePath, "{name_sep}", nameSeperator))
+
+	}
+	config.ExcludePaths = excludePaths
+	config.BlacklistedExtensions = append(config.BlacklistedExtensions, ParseExtensions(*s.Options.ExcludeExtensions)...)
+}
+
+// ReloadConfig Parse the config files again if they changed since they were loaded, and replace Config once
+// applied. The new config is never modified afterwards, scans in progress keep reading the one they started with
+// @parameters
+// apply - Applies the new config, e.g. compiles its signatures, before it replaces Config
+// @returns
+// bool - Indicates if the config files changed and Config was replaced
+// Error - Errors if any, Config is kept and the files parsed again on the next reload. Otherwise, returns nil
+func (s *Session) ReloadConfig(apply func(config *Config) error) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	configFiles := configFilesState(s.Options)
+	if configFiles == s.configFiles {
+		return false, nil
+	}
+	config, err := ParseConfig(s.Options)
+	if err != nil {
+		return false, err
+	}
+	s.expandConfig(config)
+	if err := apply(config); err != nil {
+		return false, err
+	}
+	s.Config = config
+	s.configFiles = configFiles
+	return true, nil
+}
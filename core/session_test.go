@@ -0,0 +1,57 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("rules_version: '1'\nblacklisted_paths: ['{sep}vendor']\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	excludeExtensions := ".min.js"
+	options := &Options{ConfigPath: &repeatableStringValue{values: []string{dir}}, MergeConfigs: new(bool),
+		Regex: &repeatableStringValue{}, RegexName: &repeatableStringValue{}, RegexSeverity: &repeatableStringValue{},
+		ExcludeExtensions: &excludeExtensions}
+	session := &Session{Options: options}
+
+	applied := 0
+	apply := func(config *Config) error {
+		applied++
+		return nil
+	}
+	if changed, err := session.ReloadConfig(apply); err != nil || !changed {
+		t.Fatalf("config not loaded: changed %v, error %v", changed, err)
+	}
+	config := session.Config
+	if config.RulesVersion != "1" || config.BlacklistedPaths[0] != string(os.PathSeparator)+"vendor" ||
+		!HasExtension("app.min.js", config.BlacklistedExtensions) {
+		t.Errorf("config %+v not expanded", config)
+	}
+
+	// Unchanged files are not parsed again
+	if changed, err := session.ReloadConfig(apply); err != nil || changed || session.Config != config || applied != 1 {
+		t.Errorf("unchanged config reloaded: changed %v, error %v, applied %d times", changed, err, applied)
+	}
+
+	// A config failing to apply is not used, and parsed again on the next reload
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(configFile, []byte("rules_version: '2'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(configFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.ReloadConfig(func(*Config) error { return errors.New("invalid") }); err == nil ||
+		session.Config != config {
+		t.Errorf("config failing to apply should be kept out, error %v", err)
+	}
+	if changed, err := session.ReloadConfig(apply); err != nil || !changed || session.Config.RulesVersion != "2" {
+		t.Errorf("edited config not reloaded: changed %v, error %v", changed, err)
+	}
+}
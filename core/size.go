@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Units of the sizes of the options, a bare number being in KB
+var sizeUnits = []struct {
+	suffix     string
+	multiplier uint
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize Parse a size with a unit, e.g. 512KB, 5M or 1GB. A bare number is in KB, like --maximum-file-size
+// @parameters
+// value - Size to parse, the unit is case-insensitive
+// @returns
+// uint - Size in bytes
+// Error - Errors if the size is invalid or not positive. Otherwise, returns nil
+func ParseSize(value string) (uint, error) {
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), uint(1<<10)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional unit B, K, M or G", value)
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid size %q, must be positive", value)
+	}
+	return uint(size * float64(multiplier)), nil
+}
+
+// Size in KB of --maximum-file-size, given with a unit or as a bare number of KB
+type kilobytesValue uint
+
+func (v *kilobytesValue) String() string {
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+// Set Parse a size such as 256k, 5M or 1G, rounded up to whole KB
+func (v *kilobytesValue) Set(s string) error {
+	size, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = kilobytesValue((size + 1<<10 - 1) >> 10)
+	return nil
+}
+
+// Maximum file sizes of --max-file-size, by extension
+type extensionSizesValue struct {
+	extensions []string
+	sizes      []uint
+}
+
+func (v *extensionSizesValue) String() string {
+	var values []string
+	for i, extension := range v.extensions {
+		values = append(values, fmt.Sprintf("%s=%d", extension, v.sizes[i]))
+	}
+	return strings.Join(values, ",")
+}
+
+// Set Parse comma separated extension=size pairs, e.g. .env=5MB,.log=1MB
+func (v *extensionSizesValue) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		extension, value, found := strings.Cut(pair, "=")
+		extensions := ParseExtensions(extension)
+		if !found || len(extensions) != 1 {
+			return fmt.Errorf("invalid %q, expected extension=size, e.g. .env=5MB", pair)
+		}
+		size, err := ParseSize(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", extensions[0], err)
+		}
+		v.extensions = append(v.extensions, extensions[0])
+		v.sizes = append(v.sizes, size)
+	}
+	return nil
+}
+
+// Limit Returns the maximum size in bytes of a file: the size of its longest matching extension, so that .min.js
+// wins over .js, or the fallback if no extension matches
+// @parameters
+// path - Path of the file
+// fallback - Maximum size of the files of other extensions
+// @returns
+// uint - Maximum size of the file
+func (v *extensionSizesValue) Limit(path string, fallback uint) uint {
+	limit, matched := fallback, ""
+	for i, extension := range v.extensions {
+		if len(extension) > len(matched) && HasExtension(path, []string{extension}) {
+			limit, matched = v.sizes[i], extension
+		}
+	}
+	return limit
+}
+
+// Largest Returns the largest maximum size of the files, whatever their extension
+// @parameters
+// fallback - Maximum size of the files of other extensions
+// @returns
+// uint - Largest maximum size
+func (v *extensionSizesValue) Largest(fallback uint) uint {
+	largest := fallback
+	for _, size := range v.sizes {
+		if size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
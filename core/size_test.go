@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+)
+
+func Test_ParseSize(t *testing.T) {
+	for value, expected := range map[string]uint{
+		"100B": 100, "512": 512 << 10, "512k": 512 << 10, "5M": 5 << 20, "1g": 1 << 30, "512KB": 512 << 10, "5MB": 5 << 20, "5mb": 5 << 20, "1.5 MB": 3 << 19, "1GB": 1 << 30,
+	} {
+		if size, err := ParseSize(value); err != nil || size != expected {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", value, size, err, expected)
+		}
+	}
+	for _, value := range []string{"", "MB", "5TB", "-1MB", "0", "0KB", "five"} {
+		if _, err := ParseSize(value); err == nil {
+			t.Errorf("ParseSize(%q): expected an error", value)
+		}
+	}
+}
+
+func Test_ExtensionSizes(t *testing.T) {
+	sizes := &extensionSizesValue{}
+	if err := sizes.Set(".env=5MB, log=1MB"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sizes.Set(".js=512KB,.min.js=16KB"); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback := uint(256 << 10)
+	for path, expected := range map[string]uint{
+		"app/.env": 5 << 20, "prod.ENV": 5 << 20, "var/app.log": 1 << 20, "dist/app.min.js": 16 << 10,
+		"src/app.js": 512 << 10, "config.yaml": fallback,
+	} {
+		if limit := sizes.Limit(path, fallback); limit != expected {
+			t.Errorf("Limit(%s) = %d, want %d", path, limit, expected)
+		}
+	}
+	if largest := sizes.Largest(fallback); largest != 5<<20 {
+		t.Errorf("Largest() = %d, want %d", largest, 5<<20)
+	}
+
+	for _, value := range []string{".env", ".env=", "=5MB", ".env=0", ".env=-5MB", ".env=5XB", ".a,.b=1MB"} {
+		if err := (&extensionSizesValue{}).Set(value); err == nil {
+			t.Errorf("Set(%q): expected an error", value)
+		}
+	}
+}
+
+func Test_MaximumFileSize(t *testing.T) {
+	for value, expected := range map[string]uint{
+		"256": 256, "10485760": 10485760, "256k": 256, "256K": 256, "256KB": 256, "5M": 5 << 10, "5mb": 5 << 10,
+		"1G": 1 << 20, "1.5M": 1536, "100B": 1, "1025B": 2,
+	} {
+		var size uint
+		if err := (*kilobytesValue)(&size).Set(value); err != nil || size != expected {
+			t.Errorf("--maximum-file-size %s = %d KB, %v, want %d KB", value, size, err, expected)
+		}
+	}
+	for _, value := range []string{"", "5T", "-1k", "0", "k"} {
+		var size uint
+		if err := (*kilobytesValue)(&size).Set(value); err == nil {
+			t.Errorf("--maximum-file-size %s: expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Temp dirs of the scans in progress, removed on SIGINT and SIGTERM as their deferred removals don't run then
+type tmpDirSet struct {
+	sync.Mutex
+	dirs map[string]bool
+}
+
+var activeTmpDirs = &tmpDirSet{dirs: map[string]bool{}}
+
+func (s *tmpDirSet) add(dir string) {
+	s.Lock()
+	defer s.Unlock()
+	s.dirs[dir] = true
+}
+
+func (s *tmpDirSet) remove(dir string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.dirs, dir)
+}
+
+// Remove all the dirs, once the scans are stopped
+func (s *tmpDirSet) deleteAll() {
+	s.Lock()
+	defer s.Unlock()
+	for dir := range s.dirs {
+		log.Infof("Deleting temporary dir %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Errorf("deleteTmpDirs: Could not delete temp dir: %s", err)
+		}
+		delete(s.dirs, dir)
+	}
+}
+
+// Directory of all the temp dirs of SecretScanner under --temp-directory, cleaned up at startup
+func tmpDirRoot() string {
+	return filepath.Join(*session.Options.TempDirectory, "Khulnasoft", TempDirSuffix)
+}
+
+// MkdirTemp Create a new temp dir for a scan, removed with DeleteTmpDir
+// @parameters
+// pattern - Prefix of the name of the dir, followed by a random string
+// @returns
+// String - Complete path of the dir
+// Error - Errors if any. Otherwise, returns nil
+func MkdirTemp(pattern string) (string, error) {
+	root := tmpDirRoot()
+	if err := CreateRecursiveDir(root); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(root, pattern)
+	if err != nil {
+		return "", err
+	}
+	activeTmpDirs.add(dir)
+	return dir, nil
+}
+
+// DeleteStaleTmpDirs Delete the temp dirs left by the scans of killed processes, which may hold the secrets found.
+// Only the dirs not modified for a while are deleted, the others may belong to scans of other processes
+// @parameters
+// maxAge - Age of the dirs to delete, 0 to keep them all
+func DeleteStaleTmpDirs(maxAge time.Duration) {
+	if maxAge > 0 {
+		deleteStaleTmpDirs(tmpDirRoot(), time.Now().Add(-maxAge))
+	}
+}
+
+func deleteStaleTmpDirs(root string, before time.Time) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("deleteStaleTmpDirs: %s", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		log.Infof("Deleting stale temporary dir %s, modified %s", dir, info.ModTime().Format(time.RFC3339))
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("deleteStaleTmpDirs: Could not delete temp dir: %s", err)
+		}
+	}
+}
+
+// DeleteTmpDirsOnSignal Delete the temp dirs of the scans in progress and exit when SIGINT or SIGTERM is received,
+// with the status 128 + the signal number like shells report it
+func DeleteTmpDirsOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Warnf("Received %s, deleting temporary dirs", sig)
+		activeTmpDirs.deleteAll()
+		os.Exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
+
+// DeleteTmpDirs Delete the temp dirs of the scans in progress, once they are stopped
+func DeleteTmpDirs() {
+	activeTmpDirs.deleteAll()
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_DeleteStaleTmpDirs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{"df_stale": 48 * time.Hour, "nested-stale": 25 * time.Hour,
+		"df_recent": time.Hour} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Join(dir, ExtractedImageFilesDir), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleteStaleTmpDirs(root, now.Add(-24*time.Hour))
+	for name, kept := range map[string]bool{"df_stale": false, "nested-stale": false, "df_recent": true} {
+		if _, err := os.Stat(filepath.Join(root, name)); (err == nil) != kept {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, kept)
+		}
+	}
+
+	// The temp dir of a first run doesn't exist yet
+	deleteStaleTmpDirs(filepath.Join(root, "missing"), now)
+}
+
+func Test_DeleteTmpDirs(t *testing.T) {
+	root := t.TempDir()
+	active, deleted := filepath.Join(root, "active"), filepath.Join(root, "deleted")
+	for _, dir := range []string{active, deleted} {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		activeTmpDirs.add(dir)
+	}
+	if err := DeleteTmpDir(deleted); err != nil {
+		t.Fatal(err)
+	}
+	// Recreated by another scan, not tracked anymore
+	if err := os.Mkdir(deleted, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	DeleteTmpDirs()
+	if _, err := os.Stat(active); !os.IsNotExist(err) {
+		t.Errorf("active temp dir not deleted: %v", err)
+	}
+	if _, err := os.Stat(deleted); err != nil {
+		t.Errorf("untracked temp dir deleted: %v", err)
+	}
+}
@@ -61,8 +61,7 @@ func GetTmpDir(imageName string) (string, error) {
 
 	scanID := "df_" + getSanitizedString(imageName)
 
-	dir := *session.Options.TempDirectory
-	tempPath := filepath.Join(dir, "Khulnasoft", TempDirSuffix, scanID)
+	tempPath := filepath.Join(tmpDirRoot(), scanID)
 
 	// if runtime.GOOS == "windows" {
 	//	tempPath = dir + "\temp\Khulnasoft\SecretScanning\df_" + scanId
@@ -75,6 +74,7 @@ func GetTmpDir(imageName string) (string, error) {
 		log.Errorf("getTmpDir: Could not create temp dir %s", err)
 		return "", err
 	}
+	activeTmpDirs.add(tempPath)
 
 	return tempPath, err
 }
@@ -89,6 +89,7 @@ func DeleteTmpDir(outputDir string) error {
 	// Output dir will be empty string in case of error, don't delete
 	if outputDir != "" {
 		// deleteFiles(outputDir+"/", "*")
+		activeTmpDirs.remove(outputDir)
 		err := os.RemoveAll(outputDir)
 		if err != nil {
 			log.Errorf("deleteTmpDir: Could not delete temp dir: %s", err)
@@ -31,6 +31,7 @@ const sidebars = {
         'secretscanner/using/build',
         'secretscanner/using/scan',
         'secretscanner/using/standalone',
+        'secretscanner/using/pre-commit',
         'secretscanner/using/grpc',
       ]
     },
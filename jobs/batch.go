@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	pb "github.com/khulnasoft-lab/agent-plugins-grpc/srcgo"
+)
+
+// Buffer of the documents of the secrets found by a scan, written together with writeMultiScanData once
+// --upload-batch-size of them are pending, and every --upload-batch-delay. At most a batch is held in memory:
+// the secrets are not read from the scan while a batch is written
+type secretBatcher struct {
+	scanID   string
+	versions output.ReportVersions
+	policy   retryPolicy
+	size     int
+	delay    time.Duration
+	pending  []*pb.SecretInfo
+}
+
+func newSecretBatcher(scanID string, versions output.ReportVersions, policy retryPolicy) *secretBatcher {
+	options := core.GetSession().Options
+	return &secretBatcher{scanID: scanID, versions: versions, policy: policy, size: *options.UploadBatchSize,
+		delay: *options.UploadBatchDelay}
+}
+
+// Add the document of a secret, writing the batch once it is full
+// @returns
+// Error - Errors if the batch could not be written despite the retries. Otherwise, returns nil
+func (b *secretBatcher) add(secret *pb.SecretInfo) error {
+	b.pending = append(b.pending, secret)
+	if len(b.pending) >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// Write the pending secrets, which are dropped even if the write fails
+// @returns
+// Error - Errors if the batch could not be written despite the retries. Otherwise, returns nil
+func (b *secretBatcher) flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	err := writeMultiScanData(b.pending, b.scanID, b.versions, b.policy)
+	b.pending = b.pending[:0]
+	return err
+}
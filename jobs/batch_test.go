@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+func Test_SecretBatcher(t *testing.T) {
+	defer func(filename string) { scanFilename = filename }(scanFilename)
+	scanFilename = filepath.Join(t.TempDir(), "secret_scan.log")
+	batcher := &secretBatcher{scanID: "scan-1", versions: output.ReportVersions{Version: "2.2.0"},
+		policy: retryPolicy{backoff: time.Millisecond}, size: 2, delay: time.Second}
+
+	countLines := func() int {
+		f, err := os.Open(scanFilename)
+		if os.IsNotExist(err) {
+			return 0
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		lines := 0
+		for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+			if !strings.Contains(scanner.Text(), `"scan_id":"scan-1"`) {
+				t.Errorf("unexpected document %s", scanner.Text())
+			}
+		}
+		return lines
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := batcher.add(output.SecretToSecretInfo(output.SecretFound{CompleteFilename: "/app/.env"})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first batch is full, the third secret waits for the next one
+	if lines := countLines(); lines != 2 || len(batcher.pending) != 1 {
+		t.Errorf("%d secrets written and %d pending, want 2 and 1", lines, len(batcher.pending))
+	}
+	if err := batcher.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := countLines(); lines != 3 || len(batcher.pending) != 0 {
+		t.Errorf("%d secrets written and %d pending, want 3 and 0", lines, len(batcher.pending))
+	}
+
+	// Secrets which can't be written fail the batch
+	scanFilename = filepath.Join(t.TempDir(), "missing", "dir")
+	if err := os.WriteFile(filepath.Dir(scanFilename), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	batcher.add(output.SecretToSecretInfo(output.SecretFound{CompleteFilename: "/app/.env"}))
+	if err := batcher.flush(); err == nil {
+		t.Errorf("expected the batch to fail when it can't be written")
+	}
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Longest wait between two attempts of a write
+const maxUploadBackoff = 30 * time.Second
+
+// Retries of the writes of the scan results and statuses, set by --upload-retries, --upload-backoff and
+// --upload-timeout
+type retryPolicy struct {
+	retries int           // Attempts after the first one
+	backoff time.Duration // Wait before the first retry, doubled after each retry
+	timeout time.Duration // No retry is started past this time after the first attempt, 0 for no limit
+}
+
+func uploadRetryPolicy() retryPolicy {
+	options := core.GetSession().Options
+	return retryPolicy{retries: *options.UploadRetries, backoff: *options.UploadBackoff, timeout: *options.UploadTimeout}
+}
+
+// Wait before a retry: the backoff doubled for each previous retry, capped by maxUploadBackoff, of which a random
+// half is waited so that the scans failing together don't retry together
+func (p retryPolicy) wait(retry int) time.Duration {
+	backoff := p.backoff
+	for i := 1; i < retry && backoff < maxUploadBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxUploadBackoff)
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Call a write until it succeeds, retrying with an exponential backoff
+// @parameters
+// what - Description of the write, for the logs and the error
+// write - Write to attempt
+// @returns
+// Error - Error of the last attempt once the retries or the timeout are exhausted. Otherwise, returns nil
+func (p retryPolicy) do(what string, write func() error) error {
+	start := time.Now()
+	for retry := 0; ; retry++ {
+		err := write()
+		if err == nil {
+			return nil
+		}
+		if retry >= p.retries {
+			return fmt.Errorf("%s failed after %d attempts: %w", what, retry+1, err)
+		}
+		wait := p.wait(retry + 1)
+		if p.timeout > 0 && time.Since(start)+wait > p.timeout {
+			return fmt.Errorf("%s failed after %d attempts in %s: %w", what, retry+1,
+				time.Since(start).Round(time.Millisecond), err)
+		}
+		log.Warnf("%s failed, retrying in %s: %s", what, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+	}
+}
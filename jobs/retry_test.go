@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicyWait(t *testing.T) {
+	policy := retryPolicy{retries: 10, backoff: time.Second}
+	for retry, backoff := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 10: maxUploadBackoff} {
+		for i := 0; i < 20; i++ {
+			if wait := policy.wait(retry); wait < backoff/2 || wait > backoff {
+				t.Errorf("wait before retry %d is %s, want between %s and %s", retry, wait, backoff/2, backoff)
+			}
+		}
+	}
+}
+
+func Test_RetryPolicyDo(t *testing.T) {
+	policy := retryPolicy{retries: 3, backoff: time.Millisecond}
+	attempts := 0
+	err := policy.do("writing", func() error {
+		if attempts++; attempts < 3 {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expected the write to succeed at the third attempt, got %d attempts, %v", attempts, err)
+	}
+
+	attempts = 0
+	err = policy.do("writing", func() error {
+		attempts++
+		return errors.New("disk full")
+	})
+	if attempts != 4 || err == nil || !strings.Contains(err.Error(), "writing failed after 4 attempts: disk full") {
+		t.Errorf("expected the last error after 4 attempts, got %d attempts, %v", attempts, err)
+	}
+
+	// No retry would end past the timeout
+	policy = retryPolicy{retries: 3, backoff: time.Minute, timeout: 10 * time.Second}
+	attempts = 0
+	if err = policy.do("writing", func() error { attempts++; return errors.New("disk full") }); err == nil || attempts != 1 {
+		t.Errorf("expected the timeout to stop the retries, got %d attempts, %v", attempts, err)
+	}
+}
@@ -7,8 +7,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khulnasoft-lab/SecretScanner/allowlist"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
 	"github.com/khulnasoft-lab/SecretScanner/output"
 	"github.com/khulnasoft-lab/SecretScanner/scan"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"github.com/khulnasoft-lab/SecretScanner/verify"
 	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
 
 	pb "github.com/khulnasoft-lab/agent-plugins-grpc/srcgo"
@@ -17,50 +22,78 @@ import (
 
 var ScanMap sync.Map
 
-func DispatchScan(r *pb.FindRequest) {
+// DispatchScan Start the scan requested by the console in the background
+// @parameters
+// r - Scan request
+// overrides - Rule overrides for this scan only, nil to report all rules as configured
+func DispatchScan(r *pb.FindRequest, overrides *signature.RuleOverrides) {
 	go func() {
 		startScanJob()
 		defer stopScanJob()
 
+		// Reload the allowlist, so edits apply to the next scan without restarting the server
+		if err := allowlist.Enable(*core.GetSession().Options.Allowlist); err != nil {
+			log.Errorf("scan %s: cannot reload allowlist, keeping the previous one: %s", r.ScanId, err)
+		}
+		// Recompile the signatures only if the config files changed, scans in progress keep matching with theirs
+		if err := reloadSignatures(); err != nil {
+			log.Errorf("scan %s: cannot reload signatures, keeping the previous ones: %s", r.ScanId, err)
+		}
+
+		// Stored with each secret, to know which rules found it
+		versions := signature.Versions()
+		policy := uploadRetryPolicy()
+
 		var err error
 		res, scanCtx := tasks.StartStatusReporter(
 			r.ScanId,
 			func(ss tasks.ScanStatus) error {
-				return writeSecretScanStatus(ss.ScanStatus, ss.ScanId, ss.ScanMessage)
+				return policy.do("writing status of scan "+ss.ScanId, func() error {
+					return writeSecretScanStatus(ss.ScanStatus, ss.ScanId, ss.ScanMessage)
+				})
 			},
 			tasks.StatusValues{
-				IN_PROGRESS: "IN_PROGRESS",
-				CANCELLED:   "CANCELLED",
-				FAILED:      "ERROR",
-				SUCCESS:     "COMPLETE",
+				IN_PROGRESS: scanInProgress,
+				CANCELLED:   scanCancelled,
+				FAILED:      scanFailed,
+				SUCCESS:     scanComplete,
 			},
 			time.Minute*20,
 		)
+		// The matchers leave out the rules turned off for this scan, before counting the secrets
+		scanCtx.Context = signature.WithRuleOverrides(scanCtx.Context, overrides)
 
+		progress := scans.start(r.ScanId)
 		ScanMap.Store(r.ScanId, scanCtx)
+		metrics.ScansStarted.Inc()
 
 		defer func() {
+			status := finalStatus(scanCtx, err)
+			// Recorded first, so that the scan is never unknown to StopScan
+			scans.finish(r.ScanId, status)
+			recordScanMetrics(status, time.Since(progress.started))
 			ScanMap.Delete(r.ScanId)
 			res <- err
 			close(res)
 		}()
 
 		var secrets chan output.SecretFound
+		var status *scan.StreamStatus
 
 		if r.GetPath() != "" {
 			var isFirstSecret bool = true
-			secrets, err = scan.ScanSecretsInDirStream("", r.GetPath(), r.GetPath(),
+			secrets, status, err = scan.ScanSecretsInDirStream("", r.GetPath(), r.GetPath(),
 				&isFirstSecret, scanCtx)
 			if err != nil {
 				return
 			}
 		} else if r.GetImage() != nil && r.GetImage().Name != "" {
-			secrets, err = scan.ExtractAndScanImageStream(r.GetImage().Name, scanCtx)
+			secrets, status, err = scan.ExtractAndScanImageStream(r.GetImage().Name, scanCtx)
 			if err != nil {
 				return
 			}
 		} else if r.GetContainer() != nil && r.GetContainer().Id != "" {
-			secrets, err = scan.ExtractAndScanContainerStream(r.GetContainer().Id,
+			secrets, status, err = scan.ExtractAndScanContainerStream(r.GetContainer().Id,
 				r.GetContainer().Namespace, scanCtx)
 			if err != nil {
 				return
@@ -70,55 +103,154 @@ func DispatchScan(r *pb.FindRequest) {
 			return
 		}
 
+		uploadErr := uploadSecrets(verify.Default.VerifyStream(scanCtx.Context, applyOverrides(secrets, overrides)),
+			newSecretBatcher(r.ScanId, versions, policy), progress, scanCtx)
+		if uploadErr != nil {
+			log.Errorf("scan %s: %s", r.ScanId, uploadErr)
+		}
+		if status.Truncated {
+			log.Warnf("scan %s stopped after %d secrets, max secrets reached", r.ScanId, status.Delivered)
+		}
+		if uploadErr != nil {
+			err = uploadErr
+		} else if status.Err != nil {
+			// Secrets found before the scan stopped have been written, report why it stopped
+			log.Warnf("scan %s stopped early after %d secrets (%d dropped): %s",
+				r.ScanId, status.Delivered, status.Dropped, status.Err)
+			err = status.Err
+		}
+	}()
+}
+
+// Secrets of a scan with the severities of its rule overrides, without the secrets of the rules they disable
+// @parameters
+// secrets - Secrets found by the scan, closed once it stops
+// overrides - Rule overrides of the scan, setting the severity of the secrets of its selected rules
+// @returns
+// <-chan output.SecretFound - Secrets to report, closed after secrets
+func applyOverrides(secrets <-chan output.SecretFound, overrides *signature.RuleOverrides) <-chan output.SecretFound {
+	applied := make(chan output.SecretFound)
+	go func() {
+		defer close(applied)
 		for secret := range secrets {
-			writeSingleScanData(output.SecretToSecretInfo(secret), r.ScanId)
+			if overrides.Apply(&secret) {
+				applied <- secret
+			}
 		}
 	}()
+	return applied
+}
+
+// Write the secrets of a scan in batches as they are found
+// @parameters
+// secrets - Secrets found by the scan and verified, closed once it stops
+// batcher - Batches of the documents of the scan
+// progress - Progress of the scan, counting the secrets found
+// scanCtx - Context of the scan, cancelled if a batch can't be written
+// @returns
+// Error - Errors if secrets could not be written despite the retries. Otherwise, returns nil
+func uploadSecrets(secrets <-chan output.SecretFound, batcher *secretBatcher, progress *scanProgress,
+	scanCtx *tasks.ScanContext) error {
+	// Secrets wait at most the batch delay, even if the scan finds no more
+	ticker := time.NewTicker(batcher.delay)
+	defer ticker.Stop()
+
+	var err error
+	for {
+		select {
+		case secret, ok := <-secrets:
+			if !ok {
+				if err == nil {
+					err = batcher.flush()
+				}
+				return err
+			}
+			// Once secrets are lost the scan is failed, the secrets are drained until it stops
+			if err != nil {
+				continue
+			}
+			progress.secrets.Add(1)
+			metrics.SecretsFound.Inc(secret.Severity)
+			err = batcher.add(output.SecretToSecretInfo(secret))
+		case <-ticker.C:
+			if err == nil {
+				err = batcher.flush()
+			}
+		}
+		if err != nil && scanCtx.Context.Err() == nil {
+			scanCtx.Cancel()
+		}
+	}
+}
+
+// Count a finished scan in the metrics by its final status
+func recordScanMetrics(status string, duration time.Duration) {
+	switch status {
+	case scanComplete:
+		metrics.ScansCompleted.Inc()
+	case scanCancelled:
+		metrics.ScansCancelled.Inc()
+	default:
+		metrics.ScansFailed.Inc()
+	}
+	metrics.ScanDuration.ObserveDuration(duration)
+}
+
+// Reload the config and its signatures if the config files changed since they were loaded
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func reloadSignatures() error {
+	_, err := core.GetSession().ReloadConfig(func(config *core.Config) error {
+		changed, err := signature.ReloadSignatures(config.Signatures, config.RulesVersion)
+		if err != nil || !changed {
+			return err
+		}
+		// Rule IDs are positions in the config, the verifiers of the rules may have moved
+		return verify.Default.SetSignatures(config.Signatures)
+	})
+	return err
 }
 
 type SecretScanDoc struct {
 	pb.SecretInfo
-	ScanID string `json:"scan_id,omitempty"`
+	ScanID         string `json:"scan_id,omitempty"`
+	ScannerVersion string `json:"scanner_version,omitempty"`
+	RulesVersion   string `json:"rules_version,omitempty"`
+	RulesDigest    string `json:"rules_digest,omitempty"`
+}
+
+// Document of a secret found by a scan, with the versions of SecretScanner and of the rules which found it
+func newSecretScanDoc(secret *pb.SecretInfo, scan_id string, versions output.ReportVersions) SecretScanDoc {
+	return SecretScanDoc{
+		SecretInfo:     *secret,
+		ScanID:         scan_id,
+		ScannerVersion: versions.Version,
+		RulesVersion:   versions.RulesVersion,
+		RulesDigest:    versions.RulesDigest,
+	}
 }
 
-func writeMultiScanData(secrets []*pb.SecretInfo, scan_id string) {
+// Write the documents of secrets found by a scan at once, retrying failed writes
+// @returns
+// Error - Errors if the documents could not be written despite the retries. Otherwise, returns nil
+func writeMultiScanData(secrets []*pb.SecretInfo, scan_id string, versions output.ReportVersions,
+	policy retryPolicy) error {
+	docs := make([]string, 0, len(secrets))
 	for _, secret := range secrets {
 		if SecretScanDir == HostMountDir {
 			secret.GetMatch().FullFilename = strings.Replace(secret.GetMatch().GetFullFilename(), SecretScanDir, "", 1)
 		}
-		secretScanDoc := SecretScanDoc{
-			SecretInfo: *secret,
-			ScanID:     scan_id,
-		}
-		byteJson, err := json.Marshal(secretScanDoc)
+		byteJson, err := json.Marshal(newSecretScanDoc(secret, scan_id, versions))
 		if err != nil {
 			log.Errorf("Error marshalling json: ", err)
 			continue
 		}
-		err = writeScanDataToFile(string(byteJson), scanFilename)
-		if err != nil {
-			log.Errorf("Error in sending data to secretScanIndex:" + err.Error())
-			continue
-		}
-	}
-}
-
-func writeSingleScanData(secret *pb.SecretInfo, scan_id string) {
-	if SecretScanDir == HostMountDir {
-		secret.GetMatch().FullFilename = strings.Replace(secret.GetMatch().GetFullFilename(), SecretScanDir, "", 1)
-	}
-	secretScanDoc := SecretScanDoc{
-		SecretInfo: *secret,
-		ScanID:     scan_id,
-	}
-	byteJson, err := json.Marshal(secretScanDoc)
-	if err != nil {
-		log.Errorf("Error marshalling json: ", err)
-		return
+		docs = append(docs, string(byteJson))
 	}
-	err = writeScanDataToFile(string(byteJson), scanFilename)
-	if err != nil {
-		log.Errorf("Error in sending data to secretScanIndex:" + err.Error())
-		return
+	if len(docs) == 0 {
+		return nil
 	}
+	return policy.do(fmt.Sprintf("sending %d secrets to secretScanIndex", len(docs)), func() error {
+		return writeScanDataToFile(scanFilename, docs...)
+	})
 }
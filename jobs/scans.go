@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
+)
+
+// Number of finished scans whose final status is remembered
+const maxFinishedScans = 1000
+
+// Statuses of the scans reported to the console
+const (
+	scanInProgress = "IN_PROGRESS"
+	scanCancelled  = "CANCELLED"
+	scanFailed     = "ERROR"
+	scanComplete   = "COMPLETE"
+)
+
+var ErrUnknownScan = errors.New("unknown scan")
+
+// ScanState State of a scan, while it runs or once it finished
+type ScanState struct {
+	Status       string
+	SecretsFound int64
+	Elapsed      time.Duration // Time since the scan started, or that it ran for once it finished
+}
+
+// Progress of a scan, updated during the scan
+type scanProgress struct {
+	started time.Time
+	ended   time.Time // Zero while the scan runs
+	status  string
+	secrets atomic.Int64
+}
+
+// Progress of the running scans and of the last finished ones, to tell the scans which finished from unknown ones
+type scanRegistry struct {
+	sync.Mutex
+	scans    map[string]*scanProgress
+	finished []string // IDs of the finished scans from the oldest, the oldest is forgotten first
+	max      int
+}
+
+func newScanRegistry(max int) *scanRegistry {
+	return &scanRegistry{scans: map[string]*scanProgress{}, max: max}
+}
+
+var scans = newScanRegistry(maxFinishedScans)
+
+// Register a scan starting now, replacing a finished scan of the same ID
+func (r *scanRegistry) start(scanID string) *scanProgress {
+	r.Lock()
+	defer r.Unlock()
+	if i := slices.Index(r.finished, scanID); i >= 0 {
+		r.finished = slices.Delete(r.finished, i, i+1)
+	}
+	progress := &scanProgress{started: time.Now(), status: scanInProgress}
+	r.scans[scanID] = progress
+	return progress
+}
+
+// Record the final status of a scan, forgetting the oldest finished scan if too many are remembered
+func (r *scanRegistry) finish(scanID string, status string) {
+	r.Lock()
+	defer r.Unlock()
+	progress, found := r.scans[scanID]
+	if !found || !progress.ended.IsZero() {
+		return
+	}
+	progress.ended = time.Now()
+	progress.status = status
+	r.finished = append(r.finished, scanID)
+	if len(r.finished) > r.max {
+		delete(r.scans, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
+
+func (r *scanRegistry) lookup(scanID string) (ScanState, bool) {
+	r.Lock()
+	defer r.Unlock()
+	progress, found := r.scans[scanID]
+	if !found {
+		return ScanState{}, false
+	}
+	ended := progress.ended
+	if ended.IsZero() {
+		ended = time.Now()
+	}
+	return ScanState{Status: progress.status, SecretsFound: progress.secrets.Load(),
+		Elapsed: ended.Sub(progress.started)}, true
+}
+
+// Final status of a scan, as reported by tasks.StartStatusReporter
+func finalStatus(scanCtx *tasks.ScanContext, err error) string {
+	switch {
+	case err == nil:
+		return scanComplete
+	case scanCtx.StopTriggered.Load():
+		return scanCancelled
+	}
+	return scanFailed
+}
+
+// GetScanState Get the state of a scan in progress or of one of the last finished scans
+// @parameters
+// scanID - ID of the scan
+// @returns
+// ScanState - Status of the scan, secrets found so far and time elapsed since it started
+// Error - Wraps ErrUnknownScan if the scan is unknown. Otherwise, returns nil
+func GetScanState(scanID string) (ScanState, error) {
+	state, found := scans.lookup(scanID)
+	if !found {
+		return state, fmt.Errorf("%w %s", ErrUnknownScan, scanID)
+	}
+	return state, nil
+}
+
+// StopScan Cancel a scan in progress, which stops at its next checkpoint and is reported as cancelled
+// @parameters
+// scanID - ID of the scan
+// @returns
+// Error - Wraps ErrUnknownScan if the scan is unknown, errors if it already finished. Otherwise, returns nil
+func StopScan(scanID string) error {
+	obj, found := ScanMap.Load(scanID)
+	if !found {
+		if state, known := scans.lookup(scanID); known {
+			return fmt.Errorf("scan %s already finished with status %s", scanID, state.Status)
+		}
+		return fmt.Errorf("%w %s", ErrUnknownScan, scanID)
+	}
+	scanCtx := obj.(*tasks.ScanContext)
+	scanCtx.StopTriggered.Store(true)
+	scanCtx.Cancel()
+	return nil
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
+)
+
+func Test_StopScan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	scanCtx := &tasks.ScanContext{Context: ctx, Cancel: cancel}
+	ScanMap.Store("running", scanCtx)
+	defer ScanMap.Delete("running")
+
+	if err := StopScan("running"); err != nil {
+		t.Errorf("StopScan(running) = %v, want nil", err)
+	}
+	if ctx.Err() == nil || !scanCtx.StopTriggered.Load() {
+		t.Errorf("StopScan(running) did not cancel the scan")
+	}
+	if status := finalStatus(scanCtx, ctx.Err()); status != scanCancelled {
+		t.Errorf("finalStatus() = %s, want %s", status, scanCancelled)
+	}
+
+	scans.start("finished")
+	scans.finish("finished", scanComplete)
+	if err := StopScan("finished"); err == nil || !strings.Contains(err.Error(), scanComplete) {
+		t.Errorf("StopScan(finished) = %v, want already finished with status %s", err, scanComplete)
+	}
+	if err := StopScan("unknown"); !errors.Is(err, ErrUnknownScan) {
+		t.Errorf("StopScan(unknown) = %v, want %v", err, ErrUnknownScan)
+	}
+}
+
+func Test_ScanRegistry(t *testing.T) {
+	registry := newScanRegistry(2)
+	registry.start("scan-1")
+	registry.finish("scan-1", scanComplete)
+	registry.start("scan-2").secrets.Add(3)
+	registry.finish("scan-2", scanFailed)
+	registry.start("scan-3")
+	registry.finish("scan-3", scanComplete)
+	registry.start("scan-4").secrets.Add(1)
+
+	if _, found := registry.lookup("scan-1"); found {
+		t.Errorf("lookup(scan-1) found, want forgotten")
+	}
+	want := map[string]ScanState{
+		"scan-2": {Status: scanFailed, SecretsFound: 3},
+		"scan-3": {Status: scanComplete},
+		"scan-4": {Status: scanInProgress, SecretsFound: 1},
+	}
+	for scanID, wantState := range want {
+		state, found := registry.lookup(scanID)
+		if state.Elapsed < 0 {
+			t.Errorf("lookup(%s) elapsed %s, want positive", scanID, state.Elapsed)
+		}
+		state.Elapsed = 0
+		if !found || state != wantState {
+			t.Errorf("lookup(%s) = %+v, %v, want %+v", scanID, state, found, wantState)
+		}
+	}
+
+	// A running scan is never forgotten, and restarting a finished scan resets it
+	registry.start("scan-2")
+	registry.finish("scan-3", scanFailed)
+	if state, _ := registry.lookup("scan-2"); state.Status != scanInProgress || state.SecretsFound != 0 {
+		t.Errorf("lookup(scan-2) = %+v, want running without secrets", state)
+	}
+	if state, _ := registry.lookup("scan-4"); state.Status != scanInProgress {
+		t.Errorf("lookup(scan-4) = %+v, want running", state)
+	}
+	if state, _ := registry.lookup("scan-3"); state.Status != scanComplete {
+		t.Errorf("lookup(scan-3) status %s after a second finish, want %s", state.Status, scanComplete)
+	}
+}
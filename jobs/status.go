@@ -18,14 +18,15 @@ func writeSecretScanStatus(status, scan_id, scan_message string) error {
 		return err
 	}
 
-	err = writeScanDataToFile(string(byteJson), scanStatusFilename)
+	err = writeScanDataToFile(scanStatusFilename, string(byteJson))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func writeScanDataToFile(secretScanMsg string, filename string) error {
+// Append documents to a file, one per line, with a single write
+func writeScanDataToFile(filename string, secretScanMsgs ...string) error {
 	err := os.MkdirAll(filepath.Dir(filename), 0755)
 	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
@@ -34,11 +35,15 @@ func writeScanDataToFile(secretScanMsg string, filename string) error {
 
 	defer f.Close()
 
-	secretScanMsg = strings.Replace(secretScanMsg, "\n", " ", -1)
-	if _, err = f.WriteString(secretScanMsg + "\n"); err != nil {
+	var lines strings.Builder
+	for _, secretScanMsg := range secretScanMsgs {
+		lines.WriteString(strings.Replace(secretScanMsg, "\n", " ", -1))
+		lines.WriteString("\n")
+	}
+	if _, err = f.WriteString(lines.String()); err != nil {
 		return err
 	}
-	return nil
+	return f.Close()
 }
 
 func getDfInstallDir() string {
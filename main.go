@@ -25,19 +25,30 @@ package main
 // ------------------------------------------------------------------------------
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/fatih/color"
+	"github.com/khulnasoft-lab/SecretScanner/allowlist"
 	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
 	"github.com/khulnasoft-lab/SecretScanner/output"
 	"github.com/khulnasoft-lab/SecretScanner/scan"
 	"github.com/khulnasoft-lab/SecretScanner/server"
 	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"github.com/khulnasoft-lab/SecretScanner/verify"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -53,6 +64,15 @@ var (
 // and setup the session to start scanning for secrets
 var session = core.GetSession()
 
+// Start of the scan, reported by the gitlab-sast and junit outputs
+var scanStart = time.Now()
+
+// Template of --output template, parsed before scanning
+var reportTemplate *template.Template
+
+// Secrets of the --baseline file, not reported again. Nil when writing the baseline
+var knownSecrets *output.Baseline
+
 // Scan a container image for secrets layer by layer
 // @parameters
 // image - Name of the container image to scan (e.g. "alpine:3.5")
@@ -61,7 +81,7 @@ var session = core.GetSession()
 func findSecretsInImage(image string) (*output.JSONImageSecretsOutput, error) {
 
 	res, err := scan.ExtractAndScanImage(image)
-	if err != nil {
+	if res == nil {
 		return nil, err
 	}
 	jsonImageSecretsOutput := output.JSONImageSecretsOutput{ImageName: image}
@@ -69,7 +89,57 @@ func findSecretsInImage(image string) (*output.JSONImageSecretsOutput, error) {
 	jsonImageSecretsOutput.SetImageID(res.ImageId)
 	jsonImageSecretsOutput.SetSecrets(res.Secrets)
 
-	return &jsonImageSecretsOutput, nil
+	// Secrets found before the --timeout are returned with the error
+	return &jsonImageSecretsOutput, err
+}
+
+// Scan several container images one after the other into a single report. The images that can't be
+// scanned are listed in the report with their error, the other images are still scanned
+// @parameters
+// images - Names of the container images to scan
+// @returns
+// *output.JSONImagesSecretsOutput - Secrets found in the images, naming their image
+// int - Number of images that could not be scanned
+func findSecretsInImages(images []string) (*output.JSONImagesSecretsOutput, int) {
+	result := output.JSONImagesSecretsOutput{}
+	result.SetTime()
+	scanned := map[string]bool{}
+	failed := 0
+	for i, image := range images {
+		if scanned[image] {
+			continue
+		}
+		scanned[image] = true
+
+		log.Infof("Scanning image %s for secrets (%d/%d)...", image, i+1, len(images))
+		res, err := scan.ExtractAndScanImage(image)
+		if timedOut() {
+			// The images left are not scanned, the results are reported as they are
+			if res != nil {
+				result.AddImage(output.ImageScanned{ImageName: image, ImageID: res.ImageId}, res.Secrets)
+			}
+			break
+		}
+		if err != nil {
+			log.Errorf("main: error while scanning image %s: %s", image, err)
+			result.AddImage(output.ImageScanned{ImageName: image, Error: err.Error()}, nil)
+			failed++
+			continue
+		}
+		result.AddImage(output.ImageScanned{ImageName: image, ImageID: res.ImageId}, res.Secrets)
+	}
+	return &result, failed
+}
+
+// Secrets found in one of the images of a scan of several images
+func imageSecrets(result *output.JSONImagesSecretsOutput, image string) []output.SecretFound {
+	var secrets []output.SecretFound
+	for _, secret := range result.GetSecrets() {
+		if secret.ImageName == image {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
 }
 
 // Scan a directory
@@ -93,6 +163,236 @@ func findSecretsInDir(dir string) (*output.JSONDirSecretsOutput, error) {
 	return &jsonDirSecretsOutput, nil
 }
 
+// Scan a single file
+// @parameters
+// path - Path of the file to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInFile(path string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInFile(path, *session.Options.ForceExtension)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: path}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the contents read from the standard input
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInStdin() (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInReader(os.Stdin, scan.StdinFilename)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: scan.StdinFilename}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the files of a zip, tar or tar.gz archive
+// @parameters
+// archivePath - Complete path of the archive to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInArchive(archivePath string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInArchive(archivePath)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: archivePath}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Download and scan a file or an archive
+// @parameters
+// rawURL - URL of the file to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInURL(rawURL string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInURL(rawURL)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: scan.URLName(rawURL)}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the objects of an S3 bucket under a prefix
+// @parameters
+// rawURL - s3://bucket/prefix URL of the objects to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInS3(rawURL string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInS3(rawURL)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: rawURL}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the objects of a GCS bucket under a prefix
+// @parameters
+// rawURL - gs://bucket/prefix URL of the objects to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInGCS(rawURL string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInGCS(rawURL)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: rawURL}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the blobs of an Azure Blob Storage container under a prefix
+// @parameters
+// rawURL - URL of the container and prefix of the blobs to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInAzureBlob(rawURL string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInAzureBlob(rawURL)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: scan.URLName(rawURL)}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Render and scan a Helm chart
+// @parameters
+// chartDir - Directory of the chart to be scanned
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInHelmChart(chartDir string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanSecretsInHelmChart(chartDir, session.Options.HelmValues.Values())
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: chartDir}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// The archive to scan, from --archive or --local if it is an archive
+func archivePath() string {
+	if len(*session.Options.Archive) > 0 {
+		return *session.Options.Archive
+	}
+	if scan.IsArchive(*session.Options.Local) {
+		return *session.Options.Local
+	}
+	return ""
+}
+
+// OCI image layout to scan, from --oci-layout or a --local directory in that format
+func ociLayoutPath() string {
+	if len(*session.Options.OCILayout) > 0 {
+		return *session.Options.OCILayout
+	}
+	if len(*session.Options.Local) > 0 && scan.IsOCILayout(*session.Options.Local) {
+		return *session.Options.Local
+	}
+	return ""
+}
+
+// Scan the image of an OCI image layout directory layer by layer
+// @parameters
+// dir - Path of the OCI image layout
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInOCILayout(dir string) (*output.JSONImageSecretsOutput, error) {
+	res, err := scan.ExtractAndScanOCILayout(dir, nil)
+	if res == nil {
+		return nil, err
+	}
+	jsonImageSecretsOutput := output.JSONImageSecretsOutput{ImageName: dir}
+	jsonImageSecretsOutput.SetTime()
+	jsonImageSecretsOutput.SetImageID(res.ImageId)
+	jsonImageSecretsOutput.SetSecrets(res.Secrets)
+
+	return &jsonImageSecretsOutput, err
+}
+
+// Scan only the lines added by the staged changes of a git repository, or the whole staged files with --staged-files
+// @parameters
+// repoDir - Directory inside the git repository
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInStaged(repoDir string) (*output.JSONDirSecretsOutput, error) {
+	scanStaged := scan.ScanStagedChanges
+	if *session.Options.StagedFiles {
+		scanStaged = scan.ScanStagedFiles
+	}
+	secrets, err := scanStaged(repoDir)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: repoDir}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// Scan the files of every commit of a git repository
+// @parameters
+// repoDir - Directory inside the git repository
+// @returns
+// Error, if any. Otherwise, returns nil
+func findSecretsInGitHistory(repoDir string) (*output.JSONDirSecretsOutput, error) {
+	secrets, err := scan.ScanGitHistory(repoDir, *session.Options.Since)
+	if err != nil && !timedOut() {
+		return nil, err
+	}
+
+	jsonDirSecretsOutput := output.JSONDirSecretsOutput{DirName: repoDir}
+	jsonDirSecretsOutput.SetTime()
+	jsonDirSecretsOutput.SetSecrets(secrets)
+
+	return &jsonDirSecretsOutput, err
+}
+
+// The repository for --staged is the --local directory, or the current one
+func stagedRepoDir() string {
+	if len(*session.Options.Local) > 0 {
+		return *session.Options.Local
+	}
+	return "."
+}
+
 // Scan a container for secrets
 // @parameters
 // containerId - Id of the container to scan (e.g. "0fdasf989i0")
@@ -113,9 +413,30 @@ func findSecretsInContainer(containerId string, containerNS string) (*output.JSO
 }
 
 type SecretsWriter interface {
-	WriteJSON() error
-	WriteTable() error
+	WriteJSON(w io.Writer) error
+	WriteTable(w io.Writer) error
 	GetSecrets() []output.SecretFound
+	SetSecrets(secrets []output.SecretFound)
+	SetSummary(summary output.ScanSummary)
+}
+
+// Summary of the scan written by the json and ndjson outputs
+// @parameters
+// counts - Number of secrets reported, by severity
+// imageID - ID of the image scanned, empty if no single image was scanned
+// @returns
+// output.ScanSummary - Totals of the scan with the versions of SecretScanner and of the rules
+func scanSummary(counts output.SevCount, imageID string) output.ScanSummary {
+	summary := output.ScanSummary{
+		FilteredSecrets: scan.Stats.Filtered(),
+		FilesScanned:    scan.Stats.Files(),
+		Layers:          scan.Stats.Layers(),
+		DurationSeconds: time.Since(scanStart).Round(time.Millisecond).Seconds(),
+		ImageID:         imageID,
+		ReportVersions:  signature.Versions(),
+	}
+	summary.SetCounts(counts)
+	return summary
 }
 
 func runOnce(format string) {
@@ -124,24 +445,109 @@ func runOnce(format string) {
 	node_type := ""
 	node_id := ""
 
-	// Scan container image for secrets
-	if len(*session.Options.ImageName) > 0 {
+	// Images that could not be scanned, when scanning several
+	failedImages := 0
+
+	// Scan container images for secrets
+	images := session.Options.ImageName.Values()
+	if len(images) == 1 {
 		node_type = "image"
-		node_id = *session.Options.ImageName
-		log.Infof("Scanning image %s for secrets...", *session.Options.ImageName)
-		result, err = findSecretsInImage(*session.Options.ImageName)
-		if err != nil {
-			log.Fatal("main: error while scanning image: %s", err)
+		node_id = images[0]
+		log.Infof("Scanning image %s for secrets...", images[0])
+		result, err = findSecretsInImage(images[0])
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning image: %w", err))
 		}
+	} else if len(images) > 1 {
+		node_type = "image"
+		result, failedImages = findSecretsInImages(images)
 	}
 
-	// Scan local directory for secrets
-	if len(*session.Options.Local) > 0 {
+	// Scan the staged changes of a git repository, or a local directory for secrets
+	if *session.Options.Staged {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning staged changes of repository: %s", stagedRepoDir())
+		result, err = findSecretsInStaged(stagedRepoDir())
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning staged changes: %w", err))
+		}
+	} else if len(*session.Options.GitHistory) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning git history of repository: %s", *session.Options.GitHistory)
+		result, err = findSecretsInGitHistory(*session.Options.GitHistory)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning git history: %w", err))
+		}
+	} else if len(*session.Options.Helm) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning Helm chart: %s", *session.Options.Helm)
+		result, err = findSecretsInHelmChart(*session.Options.Helm)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning Helm chart: %w", err))
+		}
+	} else if len(*session.Options.S3) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning S3 objects: %s", *session.Options.S3)
+		result, err = findSecretsInS3(*session.Options.S3)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning S3 objects: %w", err))
+		}
+	} else if len(*session.Options.GCS) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning GCS objects: %s", *session.Options.GCS)
+		result, err = findSecretsInGCS(*session.Options.GCS)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning GCS objects: %w", err))
+		}
+	} else if len(*session.Options.AzureBlob) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning Azure blobs: %s", scan.URLName(*session.Options.AzureBlob))
+		result, err = findSecretsInAzureBlob(*session.Options.AzureBlob)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning Azure blobs: %w", err))
+		}
+	} else if len(*session.Options.URL) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning URL: %s", scan.URLName(*session.Options.URL))
+		result, err = findSecretsInURL(*session.Options.URL)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning URL: %w", err))
+		}
+	} else if len(*session.Options.File) > 0 {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning file: %s", *session.Options.File)
+		result, err = findSecretsInFile(*session.Options.File)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning file: %w", err))
+		}
+	} else if *session.Options.Stdin {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning standard input")
+		result, err = findSecretsInStdin()
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning standard input: %w", err))
+		}
+	} else if layout := ociLayoutPath(); layout != "" {
+		node_type = "image"
+		node_id = layout
+		log.Debugf("Scanning OCI image layout: %s", layout)
+		result, err = findSecretsInOCILayout(layout)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning OCI image layout: %w", err))
+		}
+	} else if archive := archivePath(); archive != "" {
+		node_id = output.GetHostname()
+		log.Debugf("Scanning archive: %s", archive)
+		result, err = findSecretsInArchive(archive)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning archive: %w", err))
+		}
+	} else if len(*session.Options.Local) > 0 {
 		node_id = output.GetHostname()
 		log.Debugf("Scanning local directory: %s", *session.Options.Local)
 		result, err = findSecretsInDir(*session.Options.Local)
-		if err != nil {
-			log.Fatal("main: error while scanning dir: %s", err)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning dir: %w", err))
 		}
 	}
 
@@ -151,91 +557,736 @@ func runOnce(format string) {
 		node_id = *session.Options.ContainerID
 		log.Debugf("Scanning container %s for secrets...", *session.Options.ContainerID)
 		result, err = findSecretsInContainer(*session.Options.ContainerID, *session.Options.ContainerNS)
-		if err != nil {
-			log.Fatal("main: error while scanning container: %s", err)
+		if err != nil && !timedOut() {
+			core.Fatal(fmt.Errorf("main: error while scanning container: %w", err))
 		}
 	}
 
 	if result == nil {
-		log.Error("set either -local or -image-name flag")
-		return
+		// Nothing was scanned before the --timeout
+		exitOnTimeout()
+		usageFatalf("main: set either -local, -archive, -oci-layout, -git-history, -helm, -file, -url, -s3, -gcs, -azure-blob, -stdin or -image-name flag")
 	}
 
-	if len(*core.GetSession().Options.ConsoleURL) != 0 && len(*core.GetSession().Options.KhulnasoftKey) != 0 {
-		pub, err := output.NewPublisher(
-			*core.GetSession().Options.ConsoleURL,
-			strconv.Itoa(*core.GetSession().Options.ConsolePort),
-			*core.GetSession().Options.KhulnasoftKey,
-		)
-		if err != nil {
-			log.Error(err.Error())
-		}
+	scan.Progress.Finish()
+
+	if *session.Options.WriteBaseline {
+		writeBaseline(result.GetSecrets())
+	} else {
+		result.SetSecrets(knownSecrets.Filter(result.GetSecrets()))
+	}
+
+	verify.Default.VerifyAll(session.Context, result.GetSecrets())
+
+	if *session.Options.SortResults {
+		output.SortSecrets(result.GetSecrets())
+	}
 
-		pub.SendReport(output.GetHostname(), *session.Options.ImageName, *session.Options.ContainerID, node_type)
-		scanId := pub.StartScan(node_id, node_type)
-		if len(scanId) == 0 {
-			scanId = fmt.Sprintf("%s-%d", node_id, time.Now().UnixMilli())
+	writeCoverageReport()
+
+	imagesResult, severalImages := result.(*output.JSONImagesSecretsOutput)
+
+	if publishToConsole() && severalImages {
+		for _, image := range imagesResult.Images {
+			publishResults(imageSecrets(imagesResult, image.ImageName), node_type, image.ImageName)
 		}
-		pub.IngestSecretScanResults(scanId, result.GetSecrets())
-		log.Info("scan id %s", scanId)
+	} else if publishToConsole() {
+		publishResults(result.GetSecrets(), node_type, node_id)
 	}
 
 	counts := output.CountBySeverity(result.GetSecrets())
 	log.Infof("result severity counts: %+v", counts)
 
+	imageID := ""
+	if imageResult, ok := result.(*output.JSONImageSecretsOutput); ok {
+		imageID = imageResult.ImageID
+	}
+	summary := scanSummary(counts, imageID)
+
+	out := openResults()
 	if format == core.JSONOutput {
-		err = result.WriteJSON()
-		if err != nil {
-			log.Fatal("main: error while writing secrets: %s", err)
+		result.SetSummary(summary)
+		err = result.WriteJSON(out)
+	} else if format == core.NDJSONOutput {
+		writer := output.NewNDJSONWriter(out)
+		writer.SetScan(summary)
+		for _, secret := range result.GetSecrets() {
+			if err = writer.Write(secret); err != nil {
+				break
+			}
 		}
+		if err == nil {
+			err = writer.WriteSummary()
+		}
+	} else if format == core.TemplateOutput {
+		err = output.WriteTemplate(out, reportTemplate, result.GetSecrets(), summary.ReportVersions)
+	} else if format == core.GitLabSASTOutput {
+		err = output.WriteGitLabSAST(out, result.GetSecrets(),
+			output.GitLabScan{Start: scanStart, End: time.Now(), ReportVersions: summary.ReportVersions})
+	} else if format == core.JUnitOutput {
+		err = output.WriteJUnit(out, result.GetSecrets(),
+			output.JUnitScan{Start: scanStart, End: time.Now(), ReportVersions: summary.ReportVersions})
 	} else {
-		fmt.Println("summary:")
-		fmt.Printf("  total=%d high=%d medium=%d low=%d\n", counts.Total, counts.High, counts.Medium, counts.Low)
-		err = result.WriteTable()
+		fmt.Fprintln(out, "summary:")
+		fmt.Fprintf(out, "  total=%d high=%d medium=%d low=%d\n", counts.Total, counts.High, counts.Medium, counts.Low)
+		if minSeverity := *session.Options.MinSeverity; minSeverity != "" {
+			fmt.Fprintf(out, "  filtered=%d below %s\n", summary.FilteredSecrets, minSeverity)
+		}
+		fmt.Fprintf(out, "  secretscanner=%s rules=%s\n", summary.Version, summary.Rules())
+		err = result.WriteTable(out)
+	}
+	if err != nil {
+		log.Fatalf("main: error while writing secrets: %s", err)
+	}
+	out.close()
+
+	if *session.Options.Remediate != "" {
+		remediate(result.GetSecrets())
+	}
+
+	// The secrets of a failed scan are incomplete, a timeout or a scan error wins over the thresholds
+	exitOnTimeout()
+	if failedImages > 0 {
+		log.Fatalf("main: %d of %d images could not be scanned", failedImages, len(images))
+	}
+
+	if severalImages && *session.Options.PerImageThreshold {
+		for _, image := range imagesResult.Images {
+			failOn(output.CountBySeverity(imageSecrets(imagesResult, image.ImageName)))
+		}
+	} else {
+		failOn(counts)
+	}
+}
+
+// Check that --remediate only modifies the files of a local directory scan
+func validateRemediation() {
+	options := core.GetSession().Options
+	if *options.Remediate != scan.RemediateReplace {
+		usageFatalf("main: unknown --remediate mode %s, expected %s", *options.Remediate, scan.RemediateReplace)
+	}
+	if *options.Local == "" || len(options.ImageName.Values()) > 0 || *options.ContainerID != "" || *options.Staged ||
+		*options.GitHistory != "" || archivePath() != "" {
+		usageFatalf("main: --remediate is only supported for --local scans")
+	}
+}
+
+// Restrict the scan of the local directory to the files of --changed-files-from
+func enableChangedFiles() {
+	options := core.GetSession().Options
+	if *options.Local == "" || len(options.ImageName.Values()) > 0 || *options.ContainerID != "" || *options.Staged ||
+		*options.GitHistory != "" || archivePath() != "" || ociLayoutPath() != "" {
+		usageFatalf("main: --changed-files-from is only supported for --local scans")
+	}
+	if err := scan.EnableChangedFiles(*options.Local, *options.ChangedFilesFrom); err != nil {
+		usageFatalf("main: cannot read --changed-files-from: %s", err)
+	}
+}
+
+// Replace the secrets found in the local files, once the user confirmed it
+func remediate(secrets []output.SecretFound) {
+	remediable := scan.RemediableSecrets(secrets)
+	if len(remediable) == 0 {
+		return
+	}
+
+	if !*core.GetSession().Options.ConfirmRemediation {
+		fmt.Fprintf(os.Stderr, "Replace %d secrets in the files of %s with %s? The files are backed up first. Type yes to continue: ",
+			len(remediable), *core.GetSession().Options.Local, scan.RemediationPlaceholder)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			log.Info("main: remediation cancelled, no file was modified")
+			return
+		}
+	}
+
+	result, err := scan.Remediate(remediable, scan.RemediationPlaceholder)
+	if err != nil {
+		log.Errorf("main: remediation failed: %s", err)
+	}
+	log.Infof("replaced %d secrets in %d files, %d secrets left untouched", result.Replaced, len(result.Files), result.Skipped)
+	for _, file := range result.Files {
+		log.Infof("remediated %s", file)
+	}
+}
+
+// Scan the requested target and write secrets as NDJSON while they are found,
+// followed by a summary record, without buffering the whole result set
+func runOnceStream() {
+	var secrets chan output.SecretFound
+	var status *scan.StreamStatus
+	var err error
+	node_type := ""
+	node_id := ""
+
+	if images := session.Options.ImageName.Values(); len(images) > 0 {
+		node_type = "image"
+		node_id = images[0]
+		log.Infof("Scanning image %s for secrets...", images[0])
+		secrets, status, err = scan.ExtractAndScanImageStream(images[0], nil)
+		if err != nil {
+			exitOnTimeout()
+			core.Fatal(fmt.Errorf("main: error while scanning image: %w", err))
+		}
+	} else if layout := ociLayoutPath(); layout != "" {
+		node_type = "image"
+		node_id = layout
+		log.Debugf("Scanning OCI image layout: %s", layout)
+		secrets, status, err = scan.ExtractAndScanOCILayoutStream(layout, nil)
+		if err != nil {
+			exitOnTimeout()
+			core.Fatal(fmt.Errorf("main: error while scanning OCI image layout: %w", err))
+		}
+	} else if len(*session.Options.Local) > 0 {
+		var isFirstSecret bool = true
+		node_id = output.GetHostname()
+		log.Debugf("Scanning local directory: %s", *session.Options.Local)
+		secrets, status, err = scan.ScanSecretsInDirStream("", "", *session.Options.Local, &isFirstSecret, nil)
 		if err != nil {
-			log.Fatal("main: error while writing secrets: %s", err)
+			exitOnTimeout()
+			core.Fatal(fmt.Errorf("main: error while scanning dir: %w", err))
+		}
+	} else if len(*session.Options.ContainerID) > 0 {
+		node_type = "container_image"
+		node_id = *session.Options.ContainerID
+		log.Debugf("Scanning container %s for secrets...", *session.Options.ContainerID)
+		secrets, status, err = scan.ExtractAndScanContainerStream(*session.Options.ContainerID, *session.Options.ContainerNS, nil)
+		if err != nil {
+			exitOnTimeout()
+			core.Fatal(fmt.Errorf("main: error while scanning container: %w", err))
+		}
+	} else {
+		usageFatalf("main: set either -local or -image-name flag")
+	}
+
+	// Secrets are only kept in memory when they have to be sent to the console
+	publish := publishToConsole()
+	var published []output.SecretFound
+
+	var baselined []output.SecretFound
+
+	// The secrets of the baseline are left out before they are verified
+	reported := make(chan output.SecretFound)
+	go func() {
+		defer close(reported)
+		for secret := range secrets {
+			if *session.Options.WriteBaseline {
+				baselined = append(baselined, secret)
+			} else if knownSecrets.Contains(secret) {
+				continue
+			}
+			reported <- secret
+		}
+	}()
+
+	out := openResults()
+	writer := output.NewNDJSONWriter(out)
+	for secret := range verify.Default.VerifyStream(session.Context, reported) {
+		if err = writer.Write(secret); err != nil {
+			log.Fatalf("main: error while writing secrets: %s", err)
 		}
+		// Downstream tools get each secret as soon as it is found
+		if err = out.Flush(); err != nil {
+			log.Fatalf("main: error while writing secrets: %s", err)
+		}
+		if publish {
+			published = append(published, secret)
+		}
+	}
+	scan.Progress.Finish()
+	if status.Truncated {
+		log.Warnf("main: stopped after %d secrets, raise --max-secrets to find more", status.Delivered)
+		writer.MarkTruncated()
+	}
+	if status.Err != nil {
+		log.Warnf("main: scan stopped early after %d secrets: %s", status.Delivered, status.Err)
+	}
+	writer.SetScan(scanSummary(writer.Counts(), status.ImageID))
+	if err = writer.WriteSummary(); err != nil {
+		log.Fatalf("main: error while writing summary: %s", err)
+	}
+	out.close()
+	if *session.Options.WriteBaseline {
+		writeBaseline(baselined)
+	}
+
+	writeCoverageReport()
+
+	if publish {
+		publishResults(published, node_type, node_id)
+	}
+
+	counts := writer.Counts()
+	log.Infof("result severity counts: %+v", counts)
+
+	// The secrets of a failed scan are incomplete, a timeout or a scan error wins over the thresholds
+	exitOnTimeout()
+	if status.Err != nil {
+		core.Fatal(fmt.Errorf("main: scan stopped early: %w", status.Err))
 	}
 
-	output.FailOn(
-		counts,
-		*core.GetSession().Options.FailOnHighCount,
-		*core.GetSession().Options.FailOnMediumCount,
-		*core.GetSession().Options.FailOnLowCount,
-		*core.GetSession().Options.FailOnCount,
+	failOn(counts)
+}
+
+// Indicates if the scan was stopped by --timeout
+func timedOut() bool {
+	return errors.Is(session.Context.Err(), context.DeadlineExceeded)
+}
+
+// Exit with core.ExitTimeout if the scan was stopped by --timeout, once its results are written
+func exitOnTimeout() {
+	if timedOut() {
+		log.Errorf("main: scan stopped by --timeout %s, the results are incomplete", *session.Options.Timeout)
+		os.Exit(core.ExitTimeout)
+	}
+}
+
+func writeCoverageReport() {
+	coverageReport := *core.GetSession().Options.CoverageReport
+	if coverageReport == "" {
+		return
+	}
+	if err := scan.Coverage.Write(coverageReport); err != nil {
+		log.Errorf("main: error while writing coverage report: %s", err)
+		return
+	}
+	log.Infof("coverage report written to %s", coverageReport)
+}
+
+func publishToConsole() bool {
+	return len(*core.GetSession().Options.ConsoleURL) != 0 && len(*core.GetSession().Options.KhulnasoftKey) != 0
+}
+
+func publishResults(secrets []output.SecretFound, node_type string, node_id string) {
+	pub, err := output.NewPublisher(
+		*core.GetSession().Options.ConsoleURL,
+		strconv.Itoa(*core.GetSession().Options.ConsolePort),
+		*core.GetSession().Options.KhulnasoftKey,
 	)
+	if err != nil {
+		log.Error(err.Error())
+	}
+
+	imageName := ""
+	if node_type == "image" {
+		imageName = node_id
+	}
+	pub.SendReport(output.GetHostname(), imageName, *session.Options.ContainerID, node_type)
+	scanId := pub.StartScan(node_id, node_type)
+	if len(scanId) == 0 {
+		scanId = fmt.Sprintf("%s-%d", node_id, time.Now().UnixMilli())
+	}
+	pub.IngestSecretScanResults(scanId, secrets)
+	log.Info("scan id %s", scanId)
+}
+
+func failOn(counts output.SevCount) {
+	// The secrets found are the accepted baseline
+	if *core.GetSession().Options.WriteBaseline {
+		return
+	}
+	options := core.GetSession().Options
+	thresholds := output.FailThresholds{
+		Severity:   *options.FailOnSeverity,
+		Cumulative: *options.CumulativeSeverity,
+		High:       *options.FailOnHighCount,
+		Medium:     *options.FailOnMediumCount,
+		Low:        *options.FailOnLowCount,
+		Total:      *options.FailOnCount,
+	}
+	// As a pre-commit gate, any secret staged fails the commit unless thresholds are set
+	if *options.Staged && thresholds.Severity == "" && thresholds.High < 0 && thresholds.Medium < 0 &&
+		thresholds.Low < 0 && thresholds.Total < 0 {
+		thresholds.Total = 1
+	}
+	output.FailOn(counts, thresholds)
+}
+
+// Load the --baseline file, unless it is being written
+func loadBaseline() {
+	options := core.GetSession().Options
+	if *options.WriteBaseline && *options.Baseline == "" {
+		usageFatalf("main: --write-baseline needs the --baseline file to write")
+	}
+	if *options.Baseline == "" || *options.WriteBaseline {
+		return
+	}
+	baseline, err := output.LoadBaseline(*options.Baseline)
+	if err != nil {
+		usageFatalf("main: cannot load baseline: %s", err)
+	}
+	knownSecrets = baseline
+}
+
+// Destination of the results: the --output-file, or stdout. Logs stay on stderr either way
+type resultsWriter struct {
+	*bufio.Writer
+	file *os.File
+}
+
+// Open the destination of the results, creating the parent directories of the --output-file
+func openResults() *resultsWriter {
+	outputFile := *session.Options.OutputFile
+	if outputFile == "" {
+		return &resultsWriter{Writer: bufio.NewWriter(os.Stdout), file: os.Stdout}
+	}
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		log.Fatalf("main: cannot create the directory of the output file: %s", err)
+	}
+	file, err := os.Create(outputFile)
+	if err != nil {
+		log.Fatalf("main: cannot create the output file: %s", err)
+	}
+	return &resultsWriter{Writer: bufio.NewWriter(file), file: file}
+}
+
+// Write out the results buffered and close the --output-file, exiting if they can't be written
+func (r *resultsWriter) close() {
+	err := r.Flush()
+	if r.file != os.Stdout {
+		if closeErr := r.file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			log.Infof("main: results written to %s", r.file.Name())
+		}
+	}
+	if err != nil {
+		log.Fatalf("main: cannot write results to %s: %s", r.file.Name(), err)
+	}
+}
+
+// Exit with core.ExitUsage, for invalid options or config. Other fatal errors exit with core.ExitScanError
+func usageFatalf(format string, args ...interface{}) {
+	core.Fatal(core.NewUsageError(format, args...))
+}
+
+// Parse the template of --output template, so that a broken template fails before scanning
+func loadTemplate() {
+	options := core.GetSession().Options
+	if *options.OutFormat != core.TemplateOutput {
+		return
+	}
+	tmpl, err := output.LoadTemplate(*options.Template, *options.TemplateFile)
+	if err != nil {
+		usageFatalf("main: cannot load template: %s", err)
+	}
+	reportTemplate = tmpl
+}
+
+// Write the secrets found to the --baseline file
+func writeBaseline(secrets []output.SecretFound) {
+	baseline := output.NewBaseline(secrets)
+	if err := baseline.WriteFile(*session.Options.Baseline); err != nil {
+		log.Fatalf("main: cannot write baseline: %s", err)
+	}
+	log.Infof("main: wrote %d secrets to baseline %s", len(baseline.Secrets), *session.Options.Baseline)
+}
+
+// checkRulesUpdate Report whether newer rules are available, and apply them with --update-rules
+func checkRulesUpdate() {
+	options := core.GetSession().Options
+	if *options.RulesManifestURL == "" {
+		usageFatalf("main: --rules-manifest-url is required to check for rules updates")
+	}
+	rulesPath, err := core.RulesFilePath(options)
+	if err != nil {
+		log.Fatalf("main: could not locate the rules file: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), core.RulesUpdateTimeout)
+	defer cancel()
+
+	status, err := core.CheckRulesUpdate(ctx, rulesPath, *options.RulesManifestURL)
+	if err != nil {
+		log.Fatalf("main: rules update check failed: %v", err)
+	}
+	fmt.Println(status)
+	if !status.Available {
+		return
+	}
+	if !*options.UpdateRules {
+		fmt.Println("Run with --update-rules to apply them")
+		return
+	}
+
+	if err = core.UpdateRules(ctx, status); err != nil {
+		log.Fatalf("main: rules update failed: %v", err)
+	}
+	fmt.Printf("Updated rules in %s to version %s\n", status.RulesPath, status.Remote.Version)
+}
+
+// List the rules of the signatures processed for --list-rules
+func listRules() {
+	rules := signature.ActiveRules(session.Options)
+	results := openResults()
+	var err error
+	if *session.Options.OutFormat == core.JSONOutput {
+		err = output.WriteRulesJSON(results, rules)
+	} else {
+		err = output.WriteRulesTable(results, rules)
+	}
+	if err != nil {
+		log.Fatalf("main: cannot list the rules: %s", err)
+	}
+	results.close()
+}
+
+// Match the rule of --test-rule against --test-input and print its matches, exiting with
+// core.ExitFindings if there are none
+func testRule() {
+	options := session.Options
+	if *options.TestInput == "" {
+		usageFatalf("main: --test-rule needs a --test-input")
+	}
+	contents, path, err := scan.ReadSampleInput(*options.TestInput)
+	if err != nil {
+		usageFatalf("main: cannot read --test-input: %s", err)
+	}
+
+	// Every match of the rule is reported
+	multipleMatch, maxMatches := true, ^uint(0)
+	options.MultipleMatch, options.MaxMultiMatch, options.MaxSecrets = &multipleMatch, &maxMatches, &maxMatches
+	matches, err := scan.MatchSample(session.Context, contents, path)
+	if err != nil {
+		log.Fatalf("main: cannot match --test-input: %s", err)
+	}
+	if len(matches) == 0 {
+		fmt.Printf("Rule %s: no match\n", *options.TestRule)
+		os.Exit(core.ExitFindings)
+	}
+
+	for _, match := range matches {
+		from := match.PrintBufferStartIndex + match.MatchFromByte
+		to := match.PrintBufferStartIndex + match.MatchToByte
+		location := fmt.Sprintf("%s bytes %d-%d", match.PartToMatch, from, to)
+		if match.LineNumber > 0 {
+			location += fmt.Sprintf(", line %d column %d", match.LineNumber, match.ColumnNumber)
+		}
+		fmt.Printf("Rule %d %s: match at %s: %q\n", match.RuleID, match.RuleName, location,
+			match.MatchedContents[match.MatchFromByte:match.MatchToByte])
+	}
+	fmt.Printf("Rule %s: %d matches\n", *options.TestRule, len(matches))
 }
 
 func main() {
 
+	// Fatal errors past the validation of the options are errors of the scan
+	log.StandardLogger().ExitFunc = func(int) { os.Exit(core.ExitScanError) }
 	log.SetOutput(os.Stderr)
 	log.SetLevel(log.InfoLevel)
 	log.SetReportCaller(true)
+	// The logs, and the matches they print, are colored like the output
+	colored := core.Colors(os.Stderr)
+	color.NoColor = !colored
 	log.SetFormatter(&log.TextFormatter{
-		DisableColors: false,
-		ForceColors:   true,
+		DisableColors: !colored,
+		ForceColors:   colored,
 		FullTimestamp: true,
 		CallerPrettyfier: func(f *runtime.Frame) (string, string) {
 			return "", " " + path.Base(f.File) + ":" + strconv.Itoa(f.Line)
 		},
 	})
+	enableRules, disableRules := session.Options.EnableRule.Values(), session.Options.DisableRule.Values()
+	if *session.Options.TestRule != "" {
+		// Only the rule tested is matched
+		enableRules, disableRules = []string{*session.Options.TestRule}, nil
+	}
+	unknownRules := signature.SelectRules(len(session.Config.Signatures), enableRules, disableRules)
+	for _, id := range unknownRules {
+		if *session.Options.TestRule != "" {
+			usageFatalf("main: unknown rule ID %q for --test-rule", id)
+		}
+		log.Warnf("main: unknown rule ID %q ignored", id)
+	}
+
+	signature.EnablePrefilter(!*session.Options.NoPrefilter)
+
 	// Process and store the read signatures
-	signature.ProcessSignatures(session.Config.Signatures)
+	signature.ProcessSignatures(session.Config.Signatures, session.Config.RulesVersion)
 
 	// Build Hyperscan database for fast scanning
-	signature.BuildHsDb()
+	if err := signature.BuildHsDb(); err != nil {
+		usageFatalf("main: %s", err)
+	}
 
 	flag.Parse()
 
 	if *core.GetSession().Options.Debug {
 		log.SetLevel(log.DebugLevel)
 	}
+	core.GetSession().Options.LogSources()
+	// Before the first request, which may go through the proxy
+	if err := core.ApplyProxy(); err != nil {
+		usageFatalf("main: %s", err)
+	}
+
+	if *core.GetSession().Options.CheckRulesUpdate || *core.GetSession().Options.UpdateRules {
+		checkRulesUpdate()
+		return
+	}
+
+	if *core.GetSession().Options.ListRules {
+		listRules()
+		return
+	}
+
+	if *core.GetSession().Options.TestRule != "" {
+		testRule()
+		return
+	}
+
+	if *core.GetSession().Options.CoverageReport != "" {
+		scan.EnableCoverage()
+	}
+
+	// Progress goes to stderr with the logs, stdout only holds the results
+	if *core.GetSession().Options.Progress && *socketPath == "" {
+		scan.EnableProgress(os.Stderr)
+	}
+
+	if *core.GetSession().Options.Remediate != "" {
+		validateRemediation()
+	}
+
+	if *core.GetSession().Options.ChangedFilesFrom != "" {
+		enableChangedFiles()
+	}
+	if *core.GetSession().Options.StagedFiles && !*core.GetSession().Options.Staged {
+		usageFatalf("main: --staged-files needs --staged")
+	}
+
+	if err := allowlist.Enable(*core.GetSession().Options.Allowlist); err != nil {
+		usageFatalf("main: cannot load allowlist: %s", err)
+	}
+
+	if err := scan.ValidateImageOptions(); err != nil {
+		usageFatalf("main: %s", err)
+	}
+	for _, pattern := range core.GetSession().Options.ExcludePath.Values() {
+		if err := core.ValidateGlob(pattern); err != nil {
+			usageFatalf("main: --exclude-path: %s", err)
+		}
+	}
+
+	if severity := *core.GetSession().Options.FailOnSeverity; severity != "" {
+		if _, err := output.CountAtOrAbove(output.SevCount{}, severity); err != nil {
+			usageFatalf("main: invalid --fail-on-severity: %s", err)
+		}
+	}
+	if severity := *core.GetSession().Options.MinSeverity; severity != "" {
+		if _, err := output.CountAtOrAbove(output.SevCount{}, severity); err != nil {
+			usageFatalf("main: invalid --min-severity: %s", err)
+		}
+	}
+	if *core.GetSession().Options.MaxArchiveDepth < 1 {
+		usageFatalf("main: --max-archive-depth must be at least 1")
+	}
+	if *core.GetSession().Options.MaxExtractEntries < 1 {
+		usageFatalf("main: --max-extract-entries must be at least 1")
+	}
+	if *core.GetSession().Options.UploadRetries < 0 || *core.GetSession().Options.UploadBackoff <= 0 {
+		usageFatalf("main: --upload-retries can't be negative and --upload-backoff must be positive")
+	}
+	if *core.GetSession().Options.UploadBatchSize < 1 || *core.GetSession().Options.UploadBatchDelay <= 0 {
+		usageFatalf("main: --upload-batch-size and --upload-batch-delay must be positive")
+	}
+	if err := core.CheckColor(); err != nil {
+		usageFatalf("main: %s", err)
+	}
+	if *core.GetSession().Options.Redact {
+		if err := output.EnableRedaction(*core.GetSession().Options.RedactKeep,
+			*core.GetSession().Options.RedactChar); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if err := scan.CheckRegistryTLS(); err != nil {
+		usageFatalf("main: %s", err)
+	}
+	if rawURL := *core.GetSession().Options.URL; rawURL != "" {
+		if err := scan.CheckURL(rawURL); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if chartDir := *core.GetSession().Options.Helm; chartDir != "" {
+		if err := scan.CheckHelm(chartDir, core.GetSession().Options.HelmValues.Values()); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	} else if len(core.GetSession().Options.HelmValues.Values()) > 0 {
+		usageFatalf("main: --helm-values needs --helm")
+	}
+	if since := *core.GetSession().Options.Since; since != "" {
+		if _, err := scan.ParseSince(since, time.Now()); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if s3URL := *core.GetSession().Options.S3; s3URL != "" {
+		if err := scan.CheckS3(s3URL, *core.GetSession().Options.S3SSECustomerKey); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if gcsURL := *core.GetSession().Options.GCS; gcsURL != "" {
+		if err := scan.CheckGCS(gcsURL); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if blobURL := *core.GetSession().Options.AzureBlob; blobURL != "" {
+		if err := scan.CheckAzureBlob(blobURL); err != nil {
+			usageFatalf("main: %s", err)
+		}
+	}
+	if *core.GetSession().Options.StaleTempAge < 0 {
+		usageFatalf("main: invalid --stale-temp-age %s", *core.GetSession().Options.StaleTempAge)
+	}
+	// Extracted layers of killed scans may hold secrets
+	core.DeleteStaleTmpDirs(*core.GetSession().Options.StaleTempAge)
+
+	if addr := *core.GetSession().Options.MetricsAddr; addr != "" {
+		if err := metrics.Serve(addr); err != nil {
+			log.Fatalf("main: %s", err)
+		}
+	}
+
+	loadBaseline()
+	loadTemplate()
+
+	if *core.GetSession().Options.Verify {
+		options := core.GetSession().Options
+		err := verify.Enable(core.GetSession().Config.Signatures, *options.VerifySeverities,
+			*options.VerifyConcurrency, *options.VerifyRate)
+		if err != nil {
+			log.Fatalf("main: cannot enable verification: %s", err)
+		}
+	}
+
+	if timeout := *core.GetSession().Options.Timeout; timeout < 0 {
+		usageFatalf("main: invalid --timeout %s", timeout)
+	} else if timeout > 0 && *socketPath == "" {
+		// The scans stop at their next checkpoint once the deadline expires
+		ctx, cancel := context.WithTimeout(core.GetSession().Context, timeout)
+		defer cancel()
+		core.GetSession().Context = ctx
+	}
+
+	if *socketPath == "" {
+		// The server removes the temp dirs of its scans once stopped
+		core.DeleteTmpDirsOnSignal()
+	}
 
 	if *socketPath != "" {
 		err := server.RunServer(*socketPath, PLUGIN_NAME)
 		if err != nil {
 			log.Fatal("main: failed to serve: %v", err)
 		}
+	} else if *core.GetSession().Options.OutFormat == core.NDJSONOutput && !*core.GetSession().Options.Staged &&
+		*core.GetSession().Options.GitHistory == "" && !*core.GetSession().Options.Stdin &&
+		*core.GetSession().Options.File == "" && *core.GetSession().Options.URL == "" &&
+		*core.GetSession().Options.S3 == "" && *core.GetSession().Options.GCS == "" &&
+		*core.GetSession().Options.AzureBlob == "" && *core.GetSession().Options.Helm == "" &&
+		!*core.GetSession().Options.SortResults &&
+		*core.GetSession().Options.Remediate == "" && archivePath() == "" &&
+		len(core.GetSession().Options.ImageName.Values()) <= 1 {
+		runOnceStream()
 	} else {
 		runOnce(*core.GetSession().Options.OutFormat)
 	}
@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Path of the metrics endpoint served on --metrics-addr
+const metricsPath = "/metrics"
+
+// Upper bounds in seconds of the buckets of the scan durations, from a directory to a large image
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// Metrics of the scans, written in the Prometheus text format. The names are stable, see the docs before
+// renaming one
+var (
+	ScansStarted   = newCounter("secretscanner_scans_started_total", "Scans requested over gRPC which started")
+	ScansCompleted = newCounter("secretscanner_scans_completed_total", "Scans requested over gRPC which completed")
+	ScansFailed    = newCounter("secretscanner_scans_failed_total", "Scans requested over gRPC which failed")
+	ScansCancelled = newCounter("secretscanner_scans_cancelled_total",
+		"Scans requested over gRPC which were stopped by a StopScan request")
+	SecretsFound = newCounterVec("secretscanner_secrets_found_total",
+		"Secrets found by the scans requested over gRPC, by severity", "severity")
+	ScanDuration = newHistogram("secretscanner_scan_duration_seconds",
+		"Duration of the scans requested over gRPC, whatever their status", durationBuckets)
+	BytesScanned  = newCounter("secretscanner_bytes_scanned_total", "Bytes of the files scanned")
+	LayersScanned = newCounter("secretscanner_image_layers_scanned_total", "Layers of container images scanned")
+)
+
+// Metrics written in this order
+var registry = []metric{ScansStarted, ScansCompleted, ScansFailed, ScansCancelled, SecretsFound, ScanDuration,
+	BytesScanned, LayersScanned}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Counter Metric which only goes up
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+func newCounter(name string, help string) *Counter {
+	return &Counter{name: name, help: help}
+}
+
+// Inc Add one to the counter
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add Add a positive value to the counter
+func (c *Counter) Add(value int64) {
+	c.value.Add(value)
+}
+
+// Value Returns the value of the counter
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec Counters told apart by the value of a label
+type CounterVec struct {
+	sync.Mutex
+	name   string
+	help   string
+	label  string
+	values map[string]int64
+}
+
+func newCounterVec(name string, help string, label string) *CounterVec {
+	return &CounterVec{name: name, help: help, label: label, values: map[string]int64{}}
+}
+
+// Inc Add one to the counter of a label value
+func (c *CounterVec) Inc(labelValue string) {
+	c.Lock()
+	defer c.Unlock()
+	c.values[labelValue]++
+}
+
+// Value Returns the counter of a label value
+func (c *CounterVec) Value(labelValue string) int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.Lock()
+	defer c.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	labelValues := make([]string, 0, len(c.values))
+	for labelValue := range c.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", c.name, c.label, quoteLabel(labelValue), c.values[labelValue])
+	}
+}
+
+// Histogram Distribution of observed values in cumulative buckets
+type Histogram struct {
+	sync.Mutex
+	name    string
+	help    string
+	buckets []float64 // Upper bounds, sorted
+	counts  []uint64  // Observations in each bucket, not cumulated
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(name string, help string, buckets []float64) *Histogram {
+	return &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe Record a value
+func (h *Histogram) Observe(value float64) {
+	h.Lock()
+	defer h.Unlock()
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// ObserveDuration Record a duration in seconds
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	cumulative := uint64(0)
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Label values escape backslashes, double quotes and line feeds
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// Write Writes all the metrics in the Prometheus text format
+func Write(w io.Writer) {
+	for _, m := range registry {
+		m.write(w)
+	}
+}
+
+// Handler HTTP handler of the metrics endpoint
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// Serve Serve the metrics on /metrics of an address in the background, for --metrics-addr
+// @parameters
+// addr - Address to listen on, e.g. :9090
+// @returns
+// Error - Errors if the address can't be listened on. Otherwise, returns nil
+func Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Errorf("metrics: %s", err)
+		}
+	}()
+	log.Infof("metrics: serving on %s%s", listener.Addr(), metricsPath)
+	return nil
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Write(t *testing.T) {
+	counter := newCounter("test_total", "Test counter")
+	counter.Inc()
+	counter.Add(2)
+	severities := newCounterVec("test_secrets_total", "Test secrets", "severity")
+	severities.Inc("high")
+	severities.Inc("low")
+	severities.Inc("high")
+	severities.Inc(`a"b`)
+	histogram := newHistogram("test_seconds", "Test durations", []float64{1, 10})
+	histogram.ObserveDuration(500 * time.Millisecond)
+	histogram.Observe(1)
+	histogram.Observe(20)
+
+	var out bytes.Buffer
+	for _, m := range []metric{counter, severities, histogram} {
+		m.write(&out)
+	}
+	want := `# HELP test_total Test counter
+# TYPE test_total counter
+test_total 3
+# HELP test_secrets_total Test secrets
+# TYPE test_secrets_total counter
+test_secrets_total{severity="a\"b"} 1
+test_secrets_total{severity="high"} 2
+test_secrets_total{severity="low"} 1
+# HELP test_seconds Test durations
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="10"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 21.5
+test_seconds_count 3
+`
+	if out.String() != want {
+		t.Errorf("write() = %s, want %s", out.String(), want)
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	ScansStarted.Inc()
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s, want text/plain; version=0.0.4", contentType)
+	}
+	for _, m := range []string{"secretscanner_scans_started_total 1", "secretscanner_scans_completed_total 0",
+		"secretscanner_scan_duration_seconds_count 0", "secretscanner_bytes_scanned_total 0"} {
+		if !strings.Contains(string(body), m+"\n") {
+			t.Errorf("metrics missing %q:\n%s", m, body)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Version of the baseline file format
+const baselineVersion = 1
+
+// Baseline Secrets known from a previous scan, not to be reported again
+type Baseline struct {
+	Version   int             `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	Secrets   []BaselineEntry `json:"secrets"`
+
+	keys map[string]bool
+}
+
+// BaselineEntry A known secret. The line number is left out so that moving the secret in its file
+// does not make it new, and only the hash of the matched string is stored
+type BaselineEntry struct {
+	RuleID   int    `json:"rule_id"`
+	RuleName string `json:"rule_name,omitempty"`
+	Path     string `json:"path"`
+	Hash     string `json:"sha256"`
+}
+
+// NewBaseline Create a baseline of the given secrets
+func NewBaseline(secrets []SecretFound) *Baseline {
+	baseline := &Baseline{Version: baselineVersion, Timestamp: time.Now().UTC(), keys: map[string]bool{}}
+	for _, secret := range secrets {
+		entry := BaselineEntry{RuleID: secret.RuleID, RuleName: secret.RuleName,
+			Path: secret.CompleteFilename, Hash: secretHash(secret)}
+		key := ruleFileHashKey(entry.RuleID, entry.Path, entry.Hash)
+		if baseline.keys[key] {
+			continue
+		}
+		baseline.keys[key] = true
+		baseline.Secrets = append(baseline.Secrets, entry)
+	}
+	// Stable order, so that the file can be reviewed and diffed when committed
+	sort.Slice(baseline.Secrets, func(i, j int) bool {
+		a, b := baseline.Secrets[i], baseline.Secrets[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.RuleID != b.RuleID {
+			return a.RuleID < b.RuleID
+		}
+		return a.Hash < b.Hash
+	})
+	return baseline
+}
+
+// LoadBaseline Read a baseline file
+// @parameters
+// path - Path of the baseline file written by WriteFile
+// @returns
+// *Baseline - Known secrets
+// Error - Errors if any. Otherwise, returns nil
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", baseline.Version, path)
+	}
+	baseline.keys = make(map[string]bool, len(baseline.Secrets))
+	for _, entry := range baseline.Secrets {
+		baseline.keys[ruleFileHashKey(entry.RuleID, entry.Path, entry.Hash)] = true
+	}
+	return &baseline, nil
+}
+
+// WriteFile Write the baseline as indented JSON
+func (b *Baseline) WriteFile(path string) error {
+	data, err := json.MarshalIndent(b, "", Indent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Contains Checks if a secret is known, false for a nil baseline
+func (b *Baseline) Contains(secret SecretFound) bool {
+	if b == nil {
+		return false
+	}
+	return b.keys[secretKey(secret)]
+}
+
+// Filter Returns the secrets not in the baseline
+func (b *Baseline) Filter(secrets []SecretFound) []SecretFound {
+	if b == nil {
+		return secrets
+	}
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		if !b.Contains(secret) {
+			kept = append(kept, secret)
+		}
+	}
+	return kept
+}
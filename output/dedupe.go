@@ -0,0 +1,70 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Key identifying the same secret found in several layers: rule, path and hash of the matched string
+func secretKey(secret SecretFound) string {
+	return ruleFileHashKey(secret.RuleID, secret.CompleteFilename, secretHash(secret))
+}
+
+func ruleFileHashKey(ruleID int, path string, hash string) string {
+	return strconv.Itoa(ruleID) + "\x00" + path + "\x00" + hash
+}
+
+// Hash of the matched string of a secret, identifying the secret without storing it
+func secretHash(secret SecretFound) string {
+	sum := sha256.Sum256([]byte(MatchedValue(secret)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchedValue Returns the matched string of a secret, without the surrounding contents displayed with it
+func MatchedValue(secret SecretFound) string {
+	matched := secret.MatchedContents
+	if secret.MatchFromByte >= 0 && secret.MatchFromByte <= secret.MatchToByte && secret.MatchToByte <= len(matched) {
+		matched = matched[secret.MatchFromByte:secret.MatchToByte]
+	}
+	return matched
+}
+
+// DedupeSecrets Report each secret found in several layers once, at its first occurrence,
+// with the Layers field listing all the layers it was found in
+func DedupeSecrets(secrets []SecretFound) []SecretFound {
+	index := map[string]int{}
+	deduped := make([]SecretFound, 0, len(secrets))
+	for _, secret := range secrets {
+		key := secretKey(secret)
+		if i, ok := index[key]; ok {
+			deduped[i].Layers = append(deduped[i].Layers, secret.LayerID)
+			continue
+		}
+		index[key] = len(deduped)
+		secret.Layers = []string{secret.LayerID}
+		deduped = append(deduped, secret)
+	}
+	return deduped
+}
+
+// Deduper Drops the secrets already seen in a stream of secrets, for streams which
+// cannot wait for all the layers to report the Layers of a secret
+type Deduper struct {
+	seen map[string]bool
+}
+
+func NewDeduper() *Deduper {
+	return &Deduper{seen: map[string]bool{}}
+}
+
+// First Indicates if the secret is seen for the first time, setting its Layers to its layer
+func (d *Deduper) First(secret *SecretFound) bool {
+	key := secretKey(*secret)
+	if d.seen[key] {
+		return false
+	}
+	d.seen[key] = true
+	secret.Layers = []string{secret.LayerID}
+	return true
+}
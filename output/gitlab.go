@@ -0,0 +1,184 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// Version of the GitLab report schema the report follows
+	gitLabSchemaVersion = "15.0.6"
+	gitLabTimeFormat    = "2006-01-02T15:04:05"
+	gitLabScannerID     = "secretscanner"
+	gitLabScannerName   = "SecretScanner"
+	gitLabVendor        = "Khulnasoft"
+	// Secrets of high severity with at least this score are critical in GitLab
+	gitLabCriticalScore = 9.0
+)
+
+// GitLabScan Details of the scan reported in the scan section of a GitLab report
+type GitLabScan struct {
+	Start time.Time
+	End   time.Time
+	// Version of SecretScanner, "unknown" if empty, and of the rules, reported in the messages of the scan
+	ReportVersions
+}
+
+type gitLabReport struct {
+	Version         string                `json:"version"`
+	Scan            gitLabScanSection     `json:"scan"`
+	Vulnerabilities []gitLabVulnerability `json:"vulnerabilities"`
+}
+
+type gitLabScanSection struct {
+	Analyzer  gitLabTool      `json:"analyzer"`
+	Scanner   gitLabTool      `json:"scanner"`
+	Type      string          `json:"type"`
+	StartTime string          `json:"start_time"`
+	EndTime   string          `json:"end_time"`
+	Status    string          `json:"status"`
+	Messages  []gitLabMessage `json:"messages,omitempty"`
+}
+
+type gitLabMessage struct {
+	Level string `json:"level"`
+	Value string `json:"value"`
+}
+
+type gitLabTool struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Vendor  struct {
+		Name string `json:"name"`
+	} `json:"vendor"`
+}
+
+type gitLabVulnerability struct {
+	ID          string             `json:"id"`
+	Category    string             `json:"category"`
+	Name        string             `json:"name"`
+	Message     string             `json:"message"`
+	Description string             `json:"description"`
+	CVE         string             `json:"cve"`
+	Severity    string             `json:"severity"`
+	Scanner     gitLabScanner      `json:"scanner"`
+	Location    gitLabLocation     `json:"location"`
+	Identifiers []gitLabIdentifier `json:"identifiers"`
+}
+
+type gitLabScanner struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type gitLabLocation struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+type gitLabIdentifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GitLabSeverity Returns the severity of a secret on the scale of GitLab: high secrets with a
+// severity score of 9 or more are Critical, the others keep their severity
+func GitLabSeverity(secret SecretFound) string {
+	switch secret.Severity {
+	case HIGH:
+		if secret.SeverityScore >= gitLabCriticalScore {
+			return "Critical"
+		}
+		return "High"
+	case MEDIUM:
+		return "Medium"
+	case LOW:
+		return "Low"
+	case SUPPRESSED:
+		return "Info"
+	}
+	return "Unknown"
+}
+
+// GitLabID Returns the ID of a secret in GitLab reports. It only depends on the rule, the file and
+// the matched string of the secret, so that GitLab tracks the same secret across pipelines
+func GitLabID(secret SecretFound) string {
+	sum := sha256.Sum256([]byte(secret.RuleName + "\x00" + secret.ImageName + "\x00" +
+		secret.CompleteFilename + "\x00" + secretHash(secret)))
+	id := hex.EncodeToString(sum[:16])
+	// Formatted as a UUID, the format of the IDs of GitLab analyzers
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32])
+}
+
+// WriteGitLabSAST Write the secrets as a GitLab SAST report, shown in the security widget of merge requests
+// @parameters
+// w - Writer of the report
+// secrets - Secrets found
+// scan - Details of the scan
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func WriteGitLabSAST(w io.Writer, secrets []SecretFound, scan GitLabScan) error {
+	version := scan.Version
+	if version == "" {
+		version = "unknown"
+	}
+	tool := gitLabTool{ID: gitLabScannerID, Name: gitLabScannerName, Version: version}
+	tool.Vendor.Name = gitLabVendor
+
+	report := gitLabReport{
+		Version: gitLabSchemaVersion,
+		Scan: gitLabScanSection{
+			Analyzer:  tool,
+			Scanner:   tool,
+			Type:      "sast",
+			StartTime: scan.Start.UTC().Format(gitLabTimeFormat),
+			EndTime:   scan.End.UTC().Format(gitLabTimeFormat),
+			Status:    "success",
+		},
+		Vulnerabilities: []gitLabVulnerability{},
+	}
+	if rules := scan.Rules(); rules != "" {
+		report.Scan.Messages = []gitLabMessage{{Level: "info", Value: "Rules version " + rules}}
+	}
+	for _, secret := range secrets {
+		file := secret.CompleteFilename
+		if secret.ImageName != "" {
+			file = secret.ImageName + ":" + file
+		}
+		id := GitLabID(secret)
+		endLine := secret.EndLineNumber
+		if endLine < secret.LineNumber {
+			endLine = secret.LineNumber
+		}
+		report.Vulnerabilities = append(report.Vulnerabilities, gitLabVulnerability{
+			ID:          id,
+			Category:    "sast",
+			Name:        secret.RuleName,
+			Message:     fmt.Sprintf("%s detected in %s", secret.RuleName, file),
+			Description: fmt.Sprintf("%s matched in the %s of %s. Rotate the secret and remove it from the sources.", secret.RuleName, secret.PartToMatch, file),
+			CVE:         id,
+			Severity:    GitLabSeverity(secret),
+			Scanner:     gitLabScanner{ID: gitLabScannerID, Name: gitLabScannerName},
+			Location:    gitLabLocation{File: file, StartLine: secret.LineNumber, EndLine: endLine},
+			Identifiers: []gitLabIdentifier{{
+				Type:  "secretscanner_rule_id",
+				Name:  fmt.Sprintf("SecretScanner rule %s", secret.RuleName),
+				Value: fmt.Sprint(secret.RuleID),
+			}},
+		})
+	}
+
+	data, err := json.MarshalIndent(report, "", Indent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,161 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	junitSuiteName  = "SecretScanner"
+	junitTimeFormat = "2006-01-02T15:04:05"
+)
+
+// JUnitScan Details of the scan reported in the test suite of a JUnit report
+type JUnitScan struct {
+	Start time.Time
+	End   time.Time
+	// Version of SecretScanner and of the rules, reported in the properties of the suite
+	ReportVersions
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// File of a secret, prefixed by its image when several images are scanned
+func junitFile(secret SecretFound) string {
+	if secret.ImageName != "" {
+		return secret.ImageName + ":" + secret.CompleteFilename
+	}
+	return secret.CompleteFilename
+}
+
+// Location of a secret as file:line:column
+func junitLocation(secret SecretFound) string {
+	location := junitFile(secret)
+	if secret.LineNumber > 0 {
+		location = fmt.Sprintf("%s:%d", location, secret.LineNumber)
+		if secret.ColumnNumber > 0 {
+			location = fmt.Sprintf("%s:%d", location, secret.ColumnNumber)
+		}
+	}
+	return location
+}
+
+// WriteJUnit Write the secrets as a JUnit XML report, for CI systems showing test results. Each secret is a failed
+// test case named after its rule and location, and a scan without secrets is a suite of one passing test case.
+// The matched contents are not reported
+// @parameters
+// w - Writer of the report
+// secrets - Secrets found
+// scan - Details of the scan
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func WriteJUnit(w io.Writer, secrets []SecretFound, scan JUnitScan) error {
+	elapsed := fmt.Sprintf("%.3f", scan.End.Sub(scan.Start).Seconds())
+	suite := junitTestSuite{
+		Name:      junitSuiteName,
+		Time:      elapsed,
+		Timestamp: scan.Start.UTC().Format(junitTimeFormat),
+	}
+	if scan.Version != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "version", Value: scan.Version})
+	}
+	if rules := scan.Rules(); rules != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "rules", Value: rules})
+	}
+
+	for _, secret := range secrets {
+		location := junitLocation(secret)
+		details := []string{
+			fmt.Sprintf("Rule: %s (ID %d)", secret.RuleName, secret.RuleID),
+			fmt.Sprintf("Severity: %s (score %.1f)", secret.Severity, secret.SeverityScore),
+			fmt.Sprintf("Matched part: %s", secret.PartToMatch),
+			fmt.Sprintf("Location: %s", location),
+		}
+		if secret.LayerID != "" {
+			details = append(details, fmt.Sprintf("Layer: %s", secret.LayerID))
+		}
+		if secret.KeyPath != "" {
+			details = append(details, fmt.Sprintf("Key path: %s", secret.KeyPath))
+		}
+		if secret.KeyType != "" {
+			key := secret.KeyType
+			if secret.KeyBits > 0 {
+				key = fmt.Sprintf("%s %d bits", key, secret.KeyBits)
+			}
+			if secret.KeyEncrypted {
+				key += ", encrypted"
+			}
+			details = append(details, fmt.Sprintf("Key: %s", key))
+		}
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			ClassName: junitFile(secret),
+			Name:      fmt.Sprintf("%s at %s", secret.RuleName, location),
+			Time:      "0",
+			Failure: &junitFailure{
+				Message: fmt.Sprintf("%s detected at %s", secret.RuleName, location),
+				Type:    secret.Severity,
+				Text:    strings.Join(details, "\n"),
+			},
+		})
+	}
+	suite.Failures = len(suite.TestCases)
+	if len(suite.TestCases) == 0 {
+		suite.TestCases = []junitTestCase{{ClassName: junitSuiteName, Name: "No secrets found", Time: elapsed}}
+	}
+	suite.Tests = len(suite.TestCases)
+
+	report := junitTestSuites{
+		Name:     junitSuiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     elapsed,
+		Suites:   []junitTestSuite{suite},
+	}
+	data, err := xml.MarshalIndent(report, "", Indent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
@@ -0,0 +1,90 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	NDJSONFindingType = "finding"
+	NDJSONSummaryType = "summary"
+)
+
+type ndjsonFinding struct {
+	Type string `json:"type"`
+	SecretFound
+}
+
+type ndjsonSummary struct {
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	High      int    `json:"high"`
+	Medium    int    `json:"medium"`
+	Low       int    `json:"low"`
+	Truncated bool   `json:"truncated,omitempty"` // The scan stopped at --max-secrets
+
+	Filtered        int     `json:"filtered,omitempty"` // Secrets below --min-severity, not reported
+	FilesScanned    int     `json:"files_scanned,omitempty"`
+	Layers          int     `json:"layers,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	ImageID         string  `json:"image_id,omitempty"`
+	Version         string  `json:"version,omitempty"`
+	RulesVersion    string  `json:"rules_version,omitempty"`
+	RulesDigest     string  `json:"rules_digest,omitempty"`
+}
+
+// NDJSONWriter writes secrets as newline delimited JSON, one record per secret
+// as soon as it is found, and a trailing summary record with the totals
+type NDJSONWriter struct {
+	enc       *json.Encoder
+	counts    SevCount
+	truncated bool
+	scan      ScanSummary // Totals of the scan other than the secrets, set before the summary record
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{enc: json.NewEncoder(w)}
+}
+
+// Write emits one finding record
+func (n *NDJSONWriter) Write(secret SecretFound) error {
+	n.counts.Add(secret.Severity)
+	return n.enc.Encode(ndjsonFinding{Type: NDJSONFindingType, SecretFound: redacted(secret)})
+}
+
+// WriteSummary emits the summary record, it should be called once after the last finding
+func (n *NDJSONWriter) WriteSummary() error {
+	return n.enc.Encode(ndjsonSummary{
+		Type:      NDJSONSummaryType,
+		Total:     n.counts.Total,
+		High:      n.counts.High,
+		Medium:    n.counts.Medium,
+		Low:       n.counts.Low,
+		Truncated: n.truncated,
+
+		Filtered:        n.scan.FilteredSecrets,
+		FilesScanned:    n.scan.FilesScanned,
+		Layers:          n.scan.Layers,
+		DurationSeconds: n.scan.DurationSeconds,
+		ImageID:         n.scan.ImageID,
+		Version:         n.scan.Version,
+		RulesVersion:    n.scan.RulesVersion,
+		RulesDigest:     n.scan.RulesDigest,
+	})
+}
+
+// SetScan sets the files, layers, duration, image and versions of the summary record.
+// The secret counts of the record are always the ones of the records written
+func (n *NDJSONWriter) SetScan(summary ScanSummary) {
+	n.scan = summary
+}
+
+// MarkTruncated flags in the summary record that the scan stopped before finding all the secrets
+func (n *NDJSONWriter) MarkTruncated() {
+	n.truncated = true
+}
+
+// Counts returns the severity counts of the secrets written so far
+func (n *NDJSONWriter) Counts() SevCount {
+	return n.counts
+}
@@ -3,10 +3,12 @@ package output
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/khulnasoft-lab/SecretScanner/core"
 	pb "github.com/khulnasoft-lab/agent-plugins-grpc/srcgo"
 	tw "github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
@@ -21,28 +23,51 @@ const (
 	HIGH   = "high"
 	MEDIUM = "medium"
 	LOW    = "low"
+
+	// Secrets suppressed by an inline comment, reported with --show-suppressed but never counted
+	SUPPRESSED = "suppressed"
 )
 
 type SecretFound struct {
-	LayerID               string  `json:"Image Layer ID,omitempty"`
-	RuleID                int     `json:"Matched Rule ID,omitempty"`
-	RuleName              string  `json:"Matched Rule Name,omitempty"`
-	PartToMatch           string  `json:"Matched Part,omitempty"`
-	Match                 string  `json:"String to Match,omitempty"`
-	Regex                 string  `json:"Signature to Match,omitempty"`
-	Severity              string  `json:"Severity,omitempty"`
-	SeverityScore         float64 `json:"Severity Score,omitempty"`
-	PrintBufferStartIndex int     `json:"Starting Index of Match in Original Content,omitempty"`
-	MatchFromByte         int     `json:"Relative Starting Index of Match in Displayed Substring"`
-	MatchToByte           int     `json:"Relative Ending Index of Match in Displayed Substring"`
-	CompleteFilename      string  `json:"Full File Name,omitempty"`
-	MatchedContents       string  `json:"Matched Contents,omitempty"`
+	ImageName             string   `json:"Image Name,omitempty"` // Image the secret was found in, when several images are scanned
+	LayerID               string   `json:"Image Layer ID,omitempty"`
+	Layers                []string `json:"Image Layer IDs,omitempty"` // All the layers the secret was found in
+	RuleID                int      `json:"Matched Rule ID,omitempty"`
+	RuleName              string   `json:"Matched Rule Name,omitempty"`
+	AdHoc                 bool     `json:"Ad-hoc Rule,omitempty"` // Matched by a --regex rule, not by a rule of the config
+	PartToMatch           string   `json:"Matched Part,omitempty"`
+	Match                 string   `json:"String to Match,omitempty"`
+	Regex                 string   `json:"Signature to Match,omitempty"`
+	Severity              string   `json:"Severity,omitempty"`
+	SeverityScore         float64  `json:"Severity Score,omitempty"`
+	PrintBufferStartIndex int      `json:"Starting Index of Match in Original Content,omitempty"`
+	MatchFromByte         int      `json:"Relative Starting Index of Match in Displayed Substring"`
+	MatchToByte           int      `json:"Relative Ending Index of Match in Displayed Substring"`
+	CompleteFilename      string   `json:"Full File Name,omitempty"`
+	LineNumber            int      `json:"Line Number,omitempty"`       // 1-based line of the first byte matched
+	ColumnNumber          int      `json:"Column Number,omitempty"`     // 1-based byte column of the first byte matched
+	EndLineNumber         int      `json:"End Line Number,omitempty"`   // Line of the last byte matched, for multi-line matches
+	EndColumnNumber       int      `json:"End Column Number,omitempty"` // Byte column of the last byte matched
+	DocumentIndex         int      `json:"Document Index,omitempty"`    // 1-based, for YAML files
+	ResourceName          string   `json:"Resource Name,omitempty"`     // kind/namespace/name of the YAML document, or address of the Terraform resource
+	KeyPath               string   `json:"Key Path,omitempty"`          // Key path of the value in a JSON or YAML file, e.g. data.password, variable of a .env file, or address of a Terraform value
+	KeyType               string   `json:"Key Type,omitempty"`          // Type of the key of a PEM block, RSA, EC, ED25519, DSA or OPENSSH
+	KeyEncrypted          bool     `json:"Key Encrypted,omitempty"`     // Whether the private key of a PEM block is encrypted with a passphrase
+	KeyBits               int      `json:"Key Bits,omitempty"`          // Size of the key of a PEM block, approximate for the encrypted RSA and EC keys
+	Encoding              string   `json:"Encoding,omitempty"`          // Encoding of the string the secret was decoded from, e.g. base64
+	MatchedContents       string   `json:"Matched Contents,omitempty"`
+	Commit                string   `json:"Commit,omitempty"` // Commit introducing the secret, for git history scans
+	CommitAuthor          string   `json:"Commit Author,omitempty"`
+	CommitDate            string   `json:"Commit Date,omitempty"`
+	Verified              bool     `json:"Verified,omitempty"`
+	VerificationError     string   `json:"Verification Error,omitempty"`
 }
 
 type JSONDirSecretsOutput struct {
 	Timestamp time.Time
 	DirName   string `json:"Directory Name"`
 	Secrets   []SecretFound
+	Summary   *ScanSummary `json:"Summary,omitempty"`
 }
 
 type JSONImageSecretsOutput struct {
@@ -51,6 +76,22 @@ type JSONImageSecretsOutput struct {
 	ImageID     string `json:"Image ID"`
 	ContainerID string `json:"Container ID"`
 	Secrets     []SecretFound
+	Summary     *ScanSummary `json:"Summary,omitempty"`
+}
+
+// Secrets found in several images, each secret naming its image
+type JSONImagesSecretsOutput struct {
+	Timestamp time.Time
+	Images    []ImageScanned
+	Secrets   []SecretFound
+	Summary   *ScanSummary `json:"Summary,omitempty"`
+}
+
+// Image scanned into a JSONImagesSecretsOutput
+type ImageScanned struct {
+	ImageName string `json:"Image Name"`
+	ImageID   string `json:"Image ID,omitempty"`
+	Error     string `json:"Error,omitempty"` // Why the image could not be scanned, if it could not
 }
 
 func (imageOutput *JSONImageSecretsOutput) SetImageName(imageName string) {
@@ -73,13 +114,52 @@ func (imageOutput *JSONImageSecretsOutput) GetSecrets() []SecretFound {
 	return imageOutput.Secrets
 }
 
-func (imageOutput JSONImageSecretsOutput) WriteJSON() error {
-	return printSecretsToJSON(imageOutput)
+func (imageOutput *JSONImageSecretsOutput) SetSummary(summary ScanSummary) {
+	imageOutput.Summary = &summary
+}
+
+func (imageOutput JSONImageSecretsOutput) WriteJSON(w io.Writer) error {
+	imageOutput.Secrets = redactedSecrets(imageOutput.Secrets)
+	return printSecretsToJSON(w, imageOutput)
+
+}
+
+func (imageOutput JSONImageSecretsOutput) WriteTable(w io.Writer) error {
+	return WriteTableOutput(w, &imageOutput.Secrets)
+}
+
+func (imagesOutput *JSONImagesSecretsOutput) SetTime() {
+	imagesOutput.Timestamp = time.Now()
+}
+
+// AddImage Add the secrets found in an image, naming the image in each secret
+func (imagesOutput *JSONImagesSecretsOutput) AddImage(image ImageScanned, secrets []SecretFound) {
+	imagesOutput.Images = append(imagesOutput.Images, image)
+	for _, secret := range secrets {
+		secret.ImageName = image.ImageName
+		imagesOutput.Secrets = append(imagesOutput.Secrets, secret)
+	}
+}
 
+func (imagesOutput *JSONImagesSecretsOutput) SetSecrets(secrets []SecretFound) {
+	imagesOutput.Secrets = secrets
 }
 
-func (imageOutput JSONImageSecretsOutput) WriteTable() error {
-	return WriteTableOutput(&imageOutput.Secrets)
+func (imagesOutput *JSONImagesSecretsOutput) GetSecrets() []SecretFound {
+	return imagesOutput.Secrets
+}
+
+func (imagesOutput *JSONImagesSecretsOutput) SetSummary(summary ScanSummary) {
+	imagesOutput.Summary = &summary
+}
+
+func (imagesOutput JSONImagesSecretsOutput) WriteJSON(w io.Writer) error {
+	imagesOutput.Secrets = redactedSecrets(imagesOutput.Secrets)
+	return printSecretsToJSON(w, imagesOutput)
+}
+
+func (imagesOutput JSONImagesSecretsOutput) WriteTable(w io.Writer) error {
+	return WriteTableOutput(w, &imagesOutput.Secrets)
 }
 
 func (dirOutput *JSONDirSecretsOutput) SetDirName(dirName string) {
@@ -97,24 +177,28 @@ func (dirOutput *JSONDirSecretsOutput) GetSecrets() []SecretFound {
 	return dirOutput.Secrets
 }
 
-func (dirOutput JSONDirSecretsOutput) WriteJSON() error {
-	return printSecretsToJSON(dirOutput)
+func (dirOutput *JSONDirSecretsOutput) SetSummary(summary ScanSummary) {
+	dirOutput.Summary = &summary
+}
+
+func (dirOutput JSONDirSecretsOutput) WriteJSON(w io.Writer) error {
+	dirOutput.Secrets = redactedSecrets(dirOutput.Secrets)
+	return printSecretsToJSON(w, dirOutput)
 }
 
-func (dirOutput JSONDirSecretsOutput) WriteTable() error {
-	return WriteTableOutput(&dirOutput.Secrets)
+func (dirOutput JSONDirSecretsOutput) WriteTable(w io.Writer) error {
+	return WriteTableOutput(w, &dirOutput.Secrets)
 }
 
-func printSecretsToJSON(secretsJSON interface{}) error {
+func printSecretsToJSON(w io.Writer, secretsJSON interface{}) error {
 	file, err := json.MarshalIndent(secretsJSON, "", Indent)
 	if err != nil {
 		log.Errorf("printSecretsToJsonFile: Couldn't format json output: %s", err)
 		return err
 	}
 
-	fmt.Println(string(file))
-
-	return nil
+	_, err = fmt.Fprintln(w, string(file))
+	return err
 }
 
 func PrintColoredSecrets(secrets []SecretFound, isFirstSecret *bool) {
@@ -129,6 +213,7 @@ func PrintColoredSecrets(secrets []SecretFound, isFirstSecret *bool) {
 // secret - Structure with details of the secret found
 // isFirstSecret - indicates if some secrets are already printed, used to properly format json
 func printColoredSecretJSONObject(secret SecretFound, isFirstSecret *bool) {
+	secret = redacted(secret)
 	Indent3 := Indent + Indent + Indent
 
 	if *isFirstSecret {
@@ -181,6 +266,7 @@ func SecretsToSecretInfos(out []SecretFound) []*pb.SecretInfo {
 }
 
 func SecretToSecretInfo(out SecretFound) *pb.SecretInfo {
+	out = redacted(out)
 	return &pb.SecretInfo{
 		ImageLayerId: out.LayerID,
 		Rule: &pb.MatchRule{
@@ -204,9 +290,37 @@ func SecretToSecretInfo(out SecretFound) *pb.SecretInfo {
 	}
 }
 
-func WriteTableOutput(report *[]SecretFound) error {
-	table := tw.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Matched Part", "Rule Name", "Severity", "File Name", "Signature"})
+// Colors of the severities in the tables written to terminals
+var severityColors = map[string]tw.Colors{
+	HIGH:   {tw.Bold, tw.FgRedColor},
+	MEDIUM: {tw.FgYellowColor},
+	LOW:    {tw.FgCyanColor},
+}
+
+// Checks if the table written to w is colored, depending on --color when w is a file such as os.Stdout
+func tableColors(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	return ok && core.Colors(file)
+}
+
+func WriteTableOutput(w io.Writer, report *[]SecretFound) error {
+	return writeTable(w, report, tableColors(w))
+}
+
+// Write the secrets as a table, with their severities colored if colored is true
+func writeTable(w io.Writer, report *[]SecretFound, colored bool) error {
+	// Secrets of several images are listed with their image
+	withImage := false
+	for _, r := range *report {
+		withImage = withImage || r.ImageName != ""
+	}
+
+	table := tw.NewWriter(w)
+	header := []string{"Matched Part", "Rule Name", "Severity", "File Name", "Signature"}
+	if withImage {
+		header = append([]string{"Image"}, header...)
+	}
+	table.SetHeader(header)
 	table.SetHeaderLine(true)
 	table.SetBorder(true)
 	table.SetAutoWrapText(true)
@@ -218,12 +332,46 @@ func WriteTableOutput(report *[]SecretFound) error {
 	table.SetColMinWidth(4, 20)
 
 	for _, r := range *report {
-		table.Append([]string{r.PartToMatch, r.RuleName, r.Severity, r.CompleteFilename, r.Regex})
+		fileName := r.CompleteFilename
+		if r.LineNumber > 0 {
+			fileName = fmt.Sprintf("%s:%d", fileName, r.LineNumber)
+			if r.ColumnNumber > 0 {
+				fileName = fmt.Sprintf("%s:%d", fileName, r.ColumnNumber)
+			}
+			if r.EndLineNumber > r.LineNumber {
+				fileName = fmt.Sprintf("%s-%d:%d", fileName, r.EndLineNumber, r.EndColumnNumber)
+			}
+		} else if r.KeyPath != "" {
+			// Secrets without a line, e.g. in the values of a Terraform state, are located by their address
+			fileName = fmt.Sprintf("%s (%s)", fileName, r.KeyPath)
+		}
+		if r.Commit != "" {
+			fileName = fmt.Sprintf("%s@%.12s", fileName, r.Commit)
+		}
+		row := []string{r.PartToMatch, ruleName(r.RuleName, r.AdHoc), r.Severity, fileName, r.Regex}
+		if withImage {
+			row = append([]string{r.ImageName}, row...)
+		}
+		if !colored {
+			table.Append(row)
+			continue
+		}
+		colors := make([]tw.Colors, len(row))
+		colors[len(row)-3] = severityColors[r.Severity]
+		table.Rich(row, colors)
 	}
 	table.Render()
 	return nil
 }
 
+// Name of a rule in the tables, ad-hoc rules being tagged
+func ruleName(name string, adHoc bool) string {
+	if adHoc {
+		return name + " (ad-hoc)"
+	}
+	return name
+}
+
 type SevCount struct {
 	Total  int
 	High   int
@@ -235,46 +383,139 @@ func CountBySeverity(report []SecretFound) SevCount {
 	detail := SevCount{}
 
 	for _, r := range report {
-		detail.Total += 1
-		switch r.Severity {
-		case HIGH:
-			detail.High += 1
-		case MEDIUM:
-			detail.Medium += 1
-		case LOW:
-			detail.Low += 1
-		}
+		detail.Add(r.Severity)
 	}
 
 	return detail
 }
 
-func ExitOnSeverity(severity string, count int, failOnCount int) {
-	log.Debugf("ExitOnSeverity severity=%s count=%d failOnCount=%d",
+// Add counts one more secret of the given severity
+func (s *SevCount) Add(severity string) {
+	if severity == SUPPRESSED {
+		return
+	}
+	s.Total += 1
+	switch severity {
+	case HIGH:
+		s.High += 1
+	case MEDIUM:
+		s.Medium += 1
+	case LOW:
+		s.Low += 1
+	}
+}
+
+// Cumulative folds higher severities into the lower buckets, so that a high
+// secret also counts towards the medium and low thresholds
+func (s SevCount) Cumulative() SevCount {
+	return SevCount{
+		Total:  s.Total,
+		High:   s.High,
+		Medium: s.High + s.Medium,
+		Low:    s.High + s.Medium + s.Low,
+	}
+}
+
+// Reason to fail the scan when the number of secrets of a severity reached its threshold, empty otherwise
+func thresholdReached(severity string, count int, failOnCount int) string {
+	log.Debugf("thresholdReached severity=%s count=%d failOnCount=%d",
 		severity, count, failOnCount)
-	if count >= failOnCount {
-		if len(severity) > 0 {
-			msg := "Exit secret scan. Number of %s secrets (%d) reached/exceeded the limit (%d).\n"
-			fmt.Printf(msg, severity, count, failOnCount)
-			os.Exit(1)
-		}
-		msg := "Exit secret scan. Number of secrets (%d) reached/exceeded the limit (%d).\n"
-		fmt.Printf(msg, count, failOnCount)
-		os.Exit(1)
+	if failOnCount <= 0 || count < failOnCount {
+		return ""
 	}
+	if len(severity) > 0 {
+		return fmt.Sprintf("Number of %s secrets (%d) reached/exceeded the limit (%d).", severity, count, failOnCount)
+	}
+	return fmt.Sprintf("Number of secrets (%d) reached/exceeded the limit (%d).", count, failOnCount)
+}
+
+// CountAtOrAbove Returns the number of secrets of a severity or of a higher one
+// @parameters
+// details - Number of secrets of each severity
+// severity - Lowest severity counted: high, medium or low
+// @returns
+// int - Number of secrets at or above the severity
+// Error - Errors if the severity is unknown. Otherwise, returns nil
+func CountAtOrAbove(details SevCount, severity string) (int, error) {
+	cumulative := details.Cumulative()
+	switch severity {
+	case HIGH:
+		return cumulative.High, nil
+	case MEDIUM:
+		return cumulative.Medium, nil
+	case LOW:
+		return cumulative.Low, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q, expected %s, %s or %s", severity, HIGH, MEDIUM, LOW)
+}
+
+// SeverityAtLeast Checks if a severity is the given one or a higher one. Suppressed secrets are below any severity
+// @parameters
+// severity - Severity of a secret
+// min - Minimum severity: high, medium or low
+// @returns
+// bool - true if the severity is at least min
+func SeverityAtLeast(severity string, min string) bool {
+	return severityRank(severity) >= severityRank(min)
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case HIGH:
+		return 3
+	case MEDIUM:
+		return 2
+	case LOW:
+		return 1
+	}
+	return 0
 }
 
-func FailOn(details SevCount, failOnHighCount int, failOnMediumCount int, failOnLowCount int, failOnCount int) {
-	if failOnHighCount > 0 {
-		ExitOnSeverity(HIGH, details.High, failOnHighCount)
+// FailThresholds Conditions failing the scan, any of them fails it
+type FailThresholds struct {
+	Severity   string // Fail on any secret of this severity or a higher one, empty to disable
+	Cumulative bool   // Count secrets towards the count thresholds of their own and all lower severities
+	High       int    // Fail when the number of secrets of the severity reaches this value, disabled if <= 0
+	Medium     int
+	Low        int
+	Total      int
+}
+
+// Reached Returns why the secrets fail the scan, empty if no threshold is reached.
+// The severity is checked first, then the counts from high to total
+// @parameters
+// details - Number of secrets of each severity
+// @returns
+// string - Reason of the first threshold reached, empty if none is
+func (t FailThresholds) Reached(details SevCount) string {
+	if t.Severity != "" {
+		count, err := CountAtOrAbove(details, t.Severity)
+		if err != nil {
+			log.Errorf("FailThresholds: %s", err)
+		} else if count > 0 {
+			return fmt.Sprintf("Found %d secrets of severity %s or higher.", count, t.Severity)
+		}
 	}
-	if failOnMediumCount > 0 {
-		ExitOnSeverity(MEDIUM, details.Medium, failOnMediumCount)
+	if t.Cumulative {
+		details = details.Cumulative()
 	}
-	if failOnLowCount > 0 {
-		ExitOnSeverity(LOW, details.Low, failOnLowCount)
+	for _, reason := range []string{
+		thresholdReached(HIGH, details.High, t.High),
+		thresholdReached(MEDIUM, details.Medium, t.Medium),
+		thresholdReached(LOW, details.Low, t.Low),
+		thresholdReached("", details.Total, t.Total),
+	} {
+		if reason != "" {
+			return reason
+		}
 	}
-	if failOnCount > 0 {
-		ExitOnSeverity("", details.Total, failOnCount)
+	return ""
+}
+
+// FailOn Exit with core.ExitFindings if the secrets reach any of the thresholds
+func FailOn(details SevCount, thresholds FailThresholds) {
+	if reason := thresholds.Reached(details); reason != "" {
+		fmt.Printf("Exit secret scan. %s\n", reason)
+		os.Exit(core.ExitFindings)
 	}
 }
@@ -0,0 +1,423 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+func Test_CountBySeverity(t *testing.T) {
+	secrets := []output.SecretFound{
+		{Severity: output.HIGH},
+		{Severity: output.HIGH},
+		{Severity: output.MEDIUM},
+		{Severity: output.LOW},
+		{Severity: output.LOW},
+		{Severity: output.LOW},
+	}
+
+	independent := output.CountBySeverity(secrets)
+	expected := output.SevCount{Total: 6, High: 2, Medium: 1, Low: 3}
+	if !reflect.DeepEqual(independent, expected) {
+		t.Errorf("independent counts do not match\nActual: %+v\nExpected: %+v", independent, expected)
+	}
+
+	cumulative := independent.Cumulative()
+	expected = output.SevCount{Total: 6, High: 2, Medium: 3, Low: 6}
+	if !reflect.DeepEqual(cumulative, expected) {
+		t.Errorf("cumulative counts do not match\nActual: %+v\nExpected: %+v", cumulative, expected)
+	}
+}
+
+func Test_CumulativeMediumIncludesHigh(t *testing.T) {
+	// Only high secrets: an independent medium threshold never trips,
+	// a cumulative one does
+	counts := output.CountBySeverity([]output.SecretFound{
+		{Severity: output.HIGH},
+		{Severity: output.HIGH},
+	})
+
+	if counts.Medium != 0 {
+		t.Errorf("independent medium count should ignore high secrets, got %d", counts.Medium)
+	}
+	if counts.Cumulative().Medium != 2 {
+		t.Errorf("cumulative medium count should include high secrets, got %d", counts.Cumulative().Medium)
+	}
+	if counts.Cumulative().Total != counts.Total {
+		t.Errorf("cumulative total should be unchanged, got %d want %d", counts.Cumulative().Total, counts.Total)
+	}
+}
+
+func Test_CountAtOrAbove(t *testing.T) {
+	counts := output.CountBySeverity([]output.SecretFound{
+		{Severity: output.HIGH},
+		{Severity: output.MEDIUM},
+		{Severity: output.MEDIUM},
+		{Severity: output.LOW},
+		{Severity: output.SUPPRESSED},
+	})
+	tests := []struct {
+		severity string
+		expected int
+	}{
+		{output.HIGH, 1},
+		{output.MEDIUM, 3},
+		{output.LOW, 4},
+	}
+	for _, test := range tests {
+		count, err := output.CountAtOrAbove(counts, test.severity)
+		if err != nil || count != test.expected {
+			t.Errorf("%d secrets at or above %s, %v, want %d", count, test.severity, err, test.expected)
+		}
+	}
+
+	// Only low secrets: the run fails at low, not at medium or high
+	lowOnly := output.CountBySeverity([]output.SecretFound{{Severity: output.LOW}})
+	for severity, expected := range map[string]int{output.HIGH: 0, output.MEDIUM: 0, output.LOW: 1} {
+		if count, _ := output.CountAtOrAbove(lowOnly, severity); count != expected {
+			t.Errorf("%d low secrets counted at or above %s, want %d", count, severity, expected)
+		}
+	}
+
+	if _, err := output.CountAtOrAbove(counts, "critical"); err == nil {
+		t.Errorf("unknown severity should be rejected")
+	}
+}
+
+func Test_NDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := output.NewNDJSONWriter(&buf)
+	for _, severity := range []string{output.HIGH, output.LOW} {
+		if err := writer.Write(output.SecretFound{RuleName: "rule", Severity: severity}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.WriteSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 findings and a summary, got %d lines:\n%s", len(lines), buf.String())
+	}
+
+	var finding map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &finding); err != nil {
+		t.Fatal(err)
+	}
+	if finding["type"] != output.NDJSONFindingType || finding["Matched Rule Name"] != "rule" {
+		t.Errorf("unexpected finding record: %s", lines[0])
+	}
+
+	expected := `{"type":"summary","total":2,"high":1,"medium":0,"low":1}`
+	if lines[2] != expected {
+		t.Errorf("unexpected summary record\nActual: %s\nExpected: %s", lines[2], expected)
+	}
+}
+
+func Test_SortSecrets(t *testing.T) {
+	secrets := []output.SecretFound{
+		{CompleteFilename: "b.txt", LineNumber: 1, RuleID: 1},
+		{CompleteFilename: "a.txt", LineNumber: 7, RuleID: 2},
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 9},
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 4, LayerID: "layer2"},
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 4, LayerID: "layer1"},
+	}
+
+	output.SortSecrets(secrets)
+
+	expected := []output.SecretFound{
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 4, LayerID: "layer1"},
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 4, LayerID: "layer2"},
+		{CompleteFilename: "a.txt", LineNumber: 3, RuleID: 9},
+		{CompleteFilename: "a.txt", LineNumber: 7, RuleID: 2},
+		{CompleteFilename: "b.txt", LineNumber: 1, RuleID: 1},
+	}
+	if !reflect.DeepEqual(secrets, expected) {
+		t.Errorf("secrets not sorted\nActual: %+v\nExpected: %+v", secrets, expected)
+	}
+}
+
+func Test_ImagesSecretsOutput(t *testing.T) {
+	result := output.JSONImagesSecretsOutput{}
+	result.AddImage(output.ImageScanned{ImageName: "web:1.0", ImageID: "abc"}, []output.SecretFound{{CompleteFilename: "b.env", RuleID: 1}})
+	result.AddImage(output.ImageScanned{ImageName: "broken:1.0", Error: "no such image"}, nil)
+	result.AddImage(output.ImageScanned{ImageName: "api:2.0", ImageID: "def"}, []output.SecretFound{{CompleteFilename: "a.env", RuleID: 2}})
+
+	output.SortSecrets(result.GetSecrets())
+
+	expected := []output.SecretFound{
+		{ImageName: "api:2.0", CompleteFilename: "a.env", RuleID: 2},
+		{ImageName: "web:1.0", CompleteFilename: "b.env", RuleID: 1},
+	}
+	if !reflect.DeepEqual(result.GetSecrets(), expected) {
+		t.Errorf("secrets should name their image\nActual: %+v\nExpected: %+v", result.GetSecrets(), expected)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `{"Image Name":"broken:1.0","Error":"no such image"}`) {
+		t.Errorf("images that could not be scanned should be reported with their error: %s", data)
+	}
+}
+
+func Test_WriteToWriter(t *testing.T) {
+	result := output.JSONDirSecretsOutput{DirName: "app"}
+	result.SetSecrets([]output.SecretFound{{RuleName: "Password", Severity: output.LOW, CompleteFilename: ".env", LineNumber: 2}})
+
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded output.JSONDirSecretsOutput
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded.Secrets, result.Secrets) {
+		t.Errorf("JSON output should hold the secrets only, got %s, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := result.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ".env:2") {
+		t.Errorf("table output should list the secrets, got %s", buf.String())
+	}
+	// Only tables written to terminals are colored
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("table output written to a buffer should not be colored, got %q", buf.String())
+	}
+}
+
+func Test_NDJSONWriterTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	writer := output.NewNDJSONWriter(&buf)
+	writer.MarkTruncated()
+	if err := writer.WriteSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"type":"summary","total":0,"high":0,"medium":0,"low":0,"truncated":true}`
+	if strings.TrimSpace(buf.String()) != expected {
+		t.Errorf("unexpected summary record\nActual: %s\nExpected: %s", buf.String(), expected)
+	}
+}
+
+func Test_NDJSONWriterSummary(t *testing.T) {
+	var buf bytes.Buffer
+	writer := output.NewNDJSONWriter(&buf)
+	if err := writer.Write(output.SecretFound{RuleName: "rule", Severity: output.MEDIUM}); err != nil {
+		t.Fatal(err)
+	}
+	// The counts of the records written win over the ones of the scan
+	writer.SetScan(output.ScanSummary{TotalSecrets: 5, FilesScanned: 12, Layers: 3, DurationSeconds: 1.5,
+		ImageID: "sha256:abc", ReportVersions: output.ReportVersions{Version: "2.1.0", RulesVersion: "7", RulesDigest: "3f2a"}})
+	if err := writer.WriteSummary(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := `{"type":"summary","total":1,"high":0,"medium":1,"low":0,"files_scanned":12,"layers":3,` +
+		`"duration_seconds":1.5,"image_id":"sha256:abc","version":"2.1.0","rules_version":"7","rules_digest":"3f2a"}`
+	if lines[len(lines)-1] != expected {
+		t.Errorf("unexpected summary record\nActual: %s\nExpected: %s", lines[len(lines)-1], expected)
+	}
+}
+
+func Test_JSONSummary(t *testing.T) {
+	result := output.JSONImageSecretsOutput{ImageName: "alpine:3.18", ImageID: "sha256:abc"}
+	result.SetSecrets([]output.SecretFound{{Severity: output.HIGH}, {Severity: output.LOW}})
+
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"Summary"`) {
+		t.Errorf("the summary should be omitted until it is set, got %s", buf.String())
+	}
+
+	summary := output.ScanSummary{FilesScanned: 4, Layers: 2, ImageID: result.ImageID}
+	summary.SetCounts(output.CountBySeverity(result.GetSecrets()))
+	result.SetSummary(summary)
+	buf.Reset()
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Summary map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Total Secrets": 2.0, "High": 1.0, "Medium": 0.0, "Low": 1.0,
+		"Files Scanned": 4.0, "Layers": 2.0, "Duration Seconds": 0.0, "Image ID": "sha256:abc",
+	}
+	if !reflect.DeepEqual(decoded.Summary, expected) {
+		t.Errorf("unexpected summary\nActual: %v\nExpected: %v", decoded.Summary, expected)
+	}
+}
+
+func Test_ReportVersionsRules(t *testing.T) {
+	tests := []struct {
+		versions output.ReportVersions
+		expected string
+	}{
+		{output.ReportVersions{}, ""},
+		{output.ReportVersions{RulesDigest: "3f2a9c81d0e4b7a5c6"}, "3f2a9c81d0e4"},
+		{output.ReportVersions{RulesVersion: "2024.05.1"}, "2024.05.1"},
+		{output.ReportVersions{RulesVersion: "2024.05.1", RulesDigest: "3f2a9c81d0e4b7a5c6"}, "2024.05.1 (3f2a9c81d0e4)"},
+	}
+	for _, test := range tests {
+		if rules := test.versions.Rules(); rules != test.expected {
+			t.Errorf("rules of %+v: %q, want %q", test.versions, rules, test.expected)
+		}
+	}
+}
+
+func Test_SeverityAtLeast(t *testing.T) {
+	tests := []struct {
+		severity string
+		min      string
+		expected bool
+	}{
+		{output.HIGH, output.HIGH, true},
+		{output.MEDIUM, output.HIGH, false},
+		{output.HIGH, output.LOW, true},
+		{output.LOW, output.MEDIUM, false},
+		{output.SUPPRESSED, output.LOW, false},
+	}
+	for _, test := range tests {
+		if atLeast := output.SeverityAtLeast(test.severity, test.min); atLeast != test.expected {
+			t.Errorf("%s at least %s: %v, want %v", test.severity, test.min, atLeast, test.expected)
+		}
+	}
+}
+
+func Test_DedupeSecrets(t *testing.T) {
+	secret := output.SecretFound{RuleID: 7, CompleteFilename: "app/.env", MatchedContents: "TOKEN=abc", MatchFromByte: 6, MatchToByte: 9}
+	inLayer := func(secret output.SecretFound, layer string) output.SecretFound {
+		secret.LayerID = layer
+		return secret
+	}
+	rotated := secret
+	rotated.MatchedContents = "TOKEN=xyz"
+	moved := secret
+	moved.CompleteFilename = "srv/.env"
+
+	deduped := output.DedupeSecrets([]output.SecretFound{
+		inLayer(secret, "layer1"),
+		inLayer(rotated, "layer2"),
+		inLayer(secret, "layer2"),
+		inLayer(moved, "layer3"),
+		inLayer(secret, "layer3"),
+	})
+
+	if len(deduped) != 3 {
+		t.Fatalf("expected 3 unique secrets, got %d: %+v", len(deduped), deduped)
+	}
+	if deduped[0].LayerID != "layer1" || !reflect.DeepEqual(deduped[0].Layers, []string{"layer1", "layer2", "layer3"}) {
+		t.Errorf("unexpected layers of the repeated secret: %s %v", deduped[0].LayerID, deduped[0].Layers)
+	}
+	if !reflect.DeepEqual(deduped[1].Layers, []string{"layer2"}) || !reflect.DeepEqual(deduped[2].Layers, []string{"layer3"}) {
+		t.Errorf("secrets with another match or path should be kept: %+v", deduped[1:])
+	}
+}
+
+func Test_Deduper(t *testing.T) {
+	deduper := output.NewDeduper()
+	first := output.SecretFound{RuleID: 1, CompleteFilename: "a", MatchedContents: "key", LayerID: "layer1"}
+	if !deduper.First(&first) || !reflect.DeepEqual(first.Layers, []string{"layer1"}) {
+		t.Errorf("first occurrence should be delivered with its layer, got %v", first.Layers)
+	}
+	again := output.SecretFound{RuleID: 1, CompleteFilename: "a", MatchedContents: "key", LayerID: "layer2"}
+	if deduper.First(&again) {
+		t.Errorf("repeated secret should not be delivered again")
+	}
+}
+
+func Test_Baseline(t *testing.T) {
+	secret := output.SecretFound{RuleID: 7, RuleName: "token", CompleteFilename: "app/.env",
+		MatchedContents: "TOKEN=abc", MatchFromByte: 6, MatchToByte: 9, LineNumber: 3}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := output.NewBaseline([]output.SecretFound{secret, secret}).WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "abc") {
+		t.Errorf("baseline should not store the secret:\n%s", data)
+	}
+
+	baseline, err := output.LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline.Secrets) != 1 {
+		t.Errorf("expected a single entry, got %+v", baseline.Secrets)
+	}
+
+	moved := secret
+	moved.LineNumber = 10
+	moved.MatchedContents = "export TOKEN=abc"
+	moved.MatchFromByte, moved.MatchToByte = 13, 16
+	rotated := secret
+	rotated.MatchedContents = "TOKEN=xyz"
+	otherRule := secret
+	otherRule.RuleID = 8
+
+	kept := baseline.Filter([]output.SecretFound{moved, rotated, otherRule})
+	if !reflect.DeepEqual(kept, []output.SecretFound{rotated, otherRule}) {
+		t.Errorf("only the moved secret should be known, kept %+v", kept)
+	}
+
+	var none *output.Baseline
+	if none.Contains(secret) || len(none.Filter([]output.SecretFound{secret})) != 1 {
+		t.Errorf("nil baseline should keep all secrets")
+	}
+}
+
+func Test_LoadBaselineInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "secrets": []}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := output.LoadBaseline(path); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+func Test_FailThresholds(t *testing.T) {
+	counts := output.CountBySeverity([]output.SecretFound{
+		{Severity: output.HIGH},
+		{Severity: output.MEDIUM},
+		{Severity: output.LOW},
+		{Severity: output.LOW},
+	})
+	tests := []struct {
+		thresholds output.FailThresholds
+		expected   string
+	}{
+		{output.FailThresholds{}, ""},
+		{output.FailThresholds{High: 2, Medium: 2}, ""},
+		{output.FailThresholds{Low: 2}, "Number of low secrets (2) reached/exceeded the limit (2)."},
+		{output.FailThresholds{Medium: 2, Cumulative: true}, "Number of medium secrets (2) reached/exceeded the limit (2)."},
+		{output.FailThresholds{Total: 4}, "Number of secrets (4) reached/exceeded the limit (4)."},
+		// The severity is checked before the counts
+		{output.FailThresholds{Severity: output.MEDIUM, Low: 1}, "Found 2 secrets of severity medium or higher."},
+		{output.FailThresholds{Severity: output.HIGH}, "Found 1 secrets of severity high or higher."},
+	}
+	for _, test := range tests {
+		if reason := test.thresholds.Reached(counts); reason != test.expected {
+			t.Errorf("%+v reached %q, want %q", test.thresholds, reason, test.expected)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/khulnasoft-lab/SecretScanner/core"
 	dsc "github.com/khulnasoft-lab/golang_sdk/client"
 	oahttp "github.com/khulnasoft-lab/golang_sdk/utils/http"
 	log "github.com/sirupsen/logrus"
@@ -75,6 +76,7 @@ func buildClient() (*http.Client, error) {
 	tlsConfig := &tls.Config{RootCAs: x509.NewCertPool(), InsecureSkipVerify: true}
 	client := &http.Client{
 		Transport: &http.Transport{
+			Proxy:               core.ProxyFunc(),
 			TLSClientConfig:     tlsConfig,
 			DisableKeepAlives:   false,
 			MaxIdleConnsPerHost: 1024,
@@ -233,7 +235,7 @@ func (p *Publisher) StopPublishScanStatus() {
 func (p *Publisher) IngestSecretScanResults(scanID string, secrets []SecretFound) error {
 	data := []dsc.IngestersSecret{}
 
-	for _, secret := range secrets {
+	for _, secret := range redactedSecrets(secrets) {
 		rule := dsc.NewIngestersSecretRule()
 		rule.SetId(int32(secret.RuleID))
 		rule.SetName(secret.RuleName)
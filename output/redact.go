@@ -0,0 +1,98 @@
+package output
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redaction Masking of the matched strings of the secrets in the reports, with --redact
+type Redaction struct {
+	Keep int  // Characters kept at the start of a matched string, the rest is masked
+	Char rune // Character replacing each masked character
+}
+
+// Redaction of the reports, nil to report the matched strings as found
+var redaction *Redaction
+
+// EnableRedaction Mask the matched strings of the secrets in the JSON, NDJSON and template reports, the documents
+// sent over gRPC and the results published to the console
+// @parameters
+// keep - Characters kept at the start of a matched string, --redact-keep
+// char - Character replacing the others, --redact-char
+// @returns
+// Error - Errors if keep is negative or char is not a single character
+func EnableRedaction(keep int, char string) error {
+	if keep < 0 {
+		return errors.New("--redact-keep can't be negative")
+	}
+	r, size := utf8.DecodeRuneInString(char)
+	if (r == utf8.RuneError && size < 2) || size != len(char) {
+		return errors.New("--redact-char must be a single character")
+	}
+	redaction = &Redaction{Keep: keep, Char: r}
+	return nil
+}
+
+// Mask Masks a string, keeping its first Keep characters. Strings shorter than twice Keep are masked whole, so
+// that at least as many characters are masked as are kept, and a non-empty string is never revealed whole
+// @parameters
+// value - String to mask
+// @returns
+// string - Masked string, of as many characters as value
+func (r Redaction) Mask(value string) string {
+	length := utf8.RuneCountInString(value)
+	keep := r.Keep
+	if length < 2*keep {
+		keep = 0
+	}
+	var masked strings.Builder
+	for i, c := range []rune(value) {
+		if i < keep {
+			masked.WriteRune(c)
+		} else {
+			masked.WriteRune(r.Char)
+		}
+	}
+	return masked.String()
+}
+
+// Redact Mask the matched string of a secret in its matched contents, moving the end of the match if the mask
+// changes its length in bytes. The contents are masked whole if the match can't be located in them
+// @parameters
+// secret - Secret found
+// @returns
+// SecretFound - Copy of the secret with its matched string masked
+func (r Redaction) Redact(secret SecretFound) SecretFound {
+	contents := secret.MatchedContents
+	from, to := secret.MatchFromByte, secret.MatchToByte
+	if from < 0 || from > to || to > len(contents) {
+		secret.MatchedContents = r.Mask(contents)
+		secret.MatchFromByte, secret.MatchToByte = 0, len(secret.MatchedContents)
+		return secret
+	}
+	masked := r.Mask(contents[from:to])
+	secret.MatchedContents = contents[:from] + masked + contents[to:]
+	secret.MatchToByte = from + len(masked)
+	return secret
+}
+
+// Secret as reported, with its matched string masked if redaction is enabled
+func redacted(secret SecretFound) SecretFound {
+	if redaction == nil {
+		return secret
+	}
+	return redaction.Redact(secret)
+}
+
+// Secrets as reported, copied with their matched strings masked if redaction is enabled
+func redactedSecrets(secrets []SecretFound) []SecretFound {
+	if redaction == nil {
+		return secrets
+	}
+	masked := make([]SecretFound, len(secrets))
+	for i, secret := range secrets {
+		masked[i] = redaction.Redact(secret)
+	}
+	return masked
+}
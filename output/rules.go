@@ -0,0 +1,41 @@
+package output
+
+import (
+	"io"
+	"strconv"
+
+	tw "github.com/olekukonko/tablewriter"
+)
+
+// Rule applied by the scans, listed by --list-rules
+type Rule struct {
+	ID       int    `json:"Rule ID"`
+	Name     string `json:"Rule Name"`
+	Part     string `json:"Matched Part"`
+	Severity string `json:"Severity"`
+	Pattern  string `json:"Pattern"` // String or regex matched, shortened if long
+	AdHoc    bool   `json:"Ad-hoc Rule,omitempty"`
+}
+
+// Write the rules applied by the scans as JSON
+func WriteRulesJSON(w io.Writer, rules []Rule) error {
+	if rules == nil {
+		rules = []Rule{}
+	}
+	return printSecretsToJSON(w, rules)
+}
+
+// Write the rules applied by the scans as a table
+func WriteRulesTable(w io.Writer, rules []Rule) error {
+	table := tw.NewWriter(w)
+	table.SetHeader([]string{"Rule ID", "Rule Name", "Matched Part", "Severity", "Pattern"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+	table.SetAutoWrapText(true)
+	table.SetAutoFormatHeaders(true)
+	for _, rule := range rules {
+		table.Append([]string{strconv.Itoa(rule.ID), ruleName(rule.Name, rule.AdHoc), rule.Part, rule.Severity, rule.Pattern})
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,28 @@
+package output
+
+import "sort"
+
+// SortSecrets Sort secrets in place by image, path, line and rule ID, so that scans of
+// the same target report the secrets in the same order whatever the walk order
+func SortSecrets(secrets []SecretFound) {
+	sort.SliceStable(secrets, func(i, j int) bool {
+		a, b := secrets[i], secrets[j]
+		if a.ImageName != b.ImageName {
+			return a.ImageName < b.ImageName
+		}
+		if a.CompleteFilename != b.CompleteFilename {
+			return a.CompleteFilename < b.CompleteFilename
+		}
+		if a.LineNumber != b.LineNumber {
+			return a.LineNumber < b.LineNumber
+		}
+		if a.RuleID != b.RuleID {
+			return a.RuleID < b.RuleID
+		}
+		// Same rule matched several times in the file, or in several layers
+		if a.PrintBufferStartIndex+a.MatchFromByte != b.PrintBufferStartIndex+b.MatchFromByte {
+			return a.PrintBufferStartIndex+a.MatchFromByte < b.PrintBufferStartIndex+b.MatchFromByte
+		}
+		return a.LayerID < b.LayerID
+	})
+}
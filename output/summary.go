@@ -0,0 +1,24 @@
+package output
+
+// ScanSummary Totals of a scan, written along with the secrets in the json output
+// and as the final record of the ndjson output
+type ScanSummary struct {
+	TotalSecrets    int     `json:"Total Secrets"`
+	High            int     `json:"High"`
+	Medium          int     `json:"Medium"`
+	Low             int     `json:"Low"`
+	FilteredSecrets int     `json:"Filtered Secrets,omitempty"` // Secrets below --min-severity, not reported
+	FilesScanned    int     `json:"Files Scanned"`
+	Layers          int     `json:"Layers"`
+	DurationSeconds float64 `json:"Duration Seconds"`
+	ImageID         string  `json:"Image ID,omitempty"`
+	ReportVersions
+}
+
+// SetCounts Sets the number of secrets of the summary by severity
+func (s *ScanSummary) SetCounts(counts SevCount) {
+	s.TotalSecrets = counts.Total
+	s.High = counts.High
+	s.Medium = counts.Medium
+	s.Low = counts.Low
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Built-in templates of the template output, selected by name with --template
+var builtinTemplates = map[string]string{
+	// One line per secret, with a header line
+	"csv": `rule_id,rule_name,severity,file,line,column,layer
+{{range .Secrets}}{{csv .RuleID .RuleName .Severity .CompleteFilename .LineNumber .ColumnNumber .LayerID}}
+{{end}}`,
+	// One line per secret referencing its line, like compilers and linters, e.g. for a pre-commit hook
+	"compact": `{{range .Secrets}}{{.CompleteFilename}}{{if .LineNumber}}:{{.LineNumber}}{{if .ColumnNumber}}:{{.ColumnNumber}}{{end}}{{end}}: {{.Severity}}: {{.RuleName}}
+{{end}}{{if .Secrets}}Secrets found: {{.Summary.Total}} (high: {{.Summary.High}}, medium: {{.Summary.Medium}}, low: {{.Summary.Low}})
+{{end}}`,
+	// Summary and table of the secrets, e.g. for a pull request comment
+	"markdown": `## Secrets found: {{.Summary.Total}}
+
+High: {{.Summary.High}}, medium: {{.Summary.Medium}}, low: {{.Summary.Low}}
+{{if .Secrets}}
+| Severity | Rule | File | Line |
+| --- | --- | --- | --- |
+{{range .Secrets}}| {{.Severity}} | {{.RuleName}} | {{.CompleteFilename}} | {{.LineNumber}} |
+{{end}}{{end}}{{with .Versions}}{{if .Version}}
+_SecretScanner {{.Version}}, rules {{.Rules}}_
+{{end}}{{end}}`,
+}
+
+// TemplateData Data a template is rendered with
+type TemplateData struct {
+	Secrets  []SecretFound
+	Summary  SevCount
+	Versions ReportVersions
+}
+
+var templateFuncs = template.FuncMap{
+	// Fields as a CSV record, quoted when needed, without the trailing newline
+	"csv": func(fields ...interface{}) (string, error) {
+		record := make([]string, len(fields))
+		for i, field := range fields {
+			record[i] = fmt.Sprint(field)
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+		w.Flush()
+		return strings.TrimSuffix(buf.String(), "\n"), w.Error()
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// BuiltinTemplateNames Returns the names of the built-in templates, sorted
+func BuiltinTemplateNames() []string {
+	var names []string
+	for name := range builtinTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadTemplate Parse the template of the template output, a built-in one or a template file
+// @parameters
+// name - Name of a built-in template, empty if a file is given
+// file - Path of a text/template file, empty if a built-in template is given
+// @returns
+// *template.Template - Template to render the secrets with
+// Error - Errors if neither or both are given, or if the template doesn't parse. Otherwise, returns nil
+func LoadTemplate(name string, file string) (*template.Template, error) {
+	switch {
+	case name != "" && file != "":
+		return nil, errors.New("set either a built-in template or a template file, not both")
+	case name != "":
+		text, ok := builtinTemplates[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown built-in template %q, expected one of %s", name, strings.Join(BuiltinTemplateNames(), ", "))
+		}
+		return template.New(name).Funcs(templateFuncs).Parse(text)
+	case file != "":
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(file).Funcs(templateFuncs).Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("the template output needs a template file or one of the built-in templates %s",
+		strings.Join(BuiltinTemplateNames(), ", "))
+}
+
+// WriteTemplate Render the secrets and their counts through the template. Nothing is written if the
+// template fails, so a broken template never produces a partial report
+// @parameters
+// w - Writer of the report
+// tmpl - Template from LoadTemplate
+// secrets - Secrets to render
+// versions - Versions of SecretScanner and of the rules
+// @returns
+// Error - Errors if the template fails to render. Otherwise, returns nil
+func WriteTemplate(w io.Writer, tmpl *template.Template, secrets []SecretFound, versions ReportVersions) error {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, TemplateData{Secrets: redactedSecrets(secrets), Summary: CountBySeverity(secrets), Versions: versions})
+	if err != nil {
+		return fmt.Errorf("cannot render template: %w", err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,99 @@
+package output_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+var templateSecrets = []output.SecretFound{
+	{RuleID: 3, RuleName: "AWS key", Severity: output.HIGH, CompleteFilename: "app/config, prod.yml", LineNumber: 4, ColumnNumber: 9, LayerID: "abc"},
+	{RuleID: 7, RuleName: "Password", Severity: output.LOW, CompleteFilename: ".env", LineNumber: 1},
+}
+
+func Test_BuiltinTemplates(t *testing.T) {
+	expected := map[string]string{
+		"csv": "rule_id,rule_name,severity,file,line,column,layer\n" +
+			"3,AWS key,high,\"app/config, prod.yml\",4,9,abc\n" +
+			"7,Password,low,.env,1,0,\n",
+		"compact": "app/config, prod.yml:4:9: high: AWS key\n.env:1: low: Password\n" +
+			"Secrets found: 2 (high: 1, medium: 0, low: 1)\n",
+		"markdown": "## Secrets found: 2\n\nHigh: 1, medium: 0, low: 1\n\n" +
+			"| Severity | Rule | File | Line |\n| --- | --- | --- | --- |\n" +
+			"| high | AWS key | app/config, prod.yml | 4 |\n| low | Password | .env | 1 |\n",
+	}
+	for _, name := range output.BuiltinTemplateNames() {
+		tmpl, err := output.LoadTemplate(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := output.WriteTemplate(&buf, tmpl, templateSecrets, output.ReportVersions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected[name] {
+			t.Errorf("template %s rendered\n%s\nExpected:\n%s", name, buf.String(), expected[name])
+		}
+	}
+}
+
+func Test_TemplateVersions(t *testing.T) {
+	tmpl, err := output.LoadTemplate("markdown", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	versions := output.ReportVersions{Version: "2.2.0", RulesDigest: "3f2a9c81d0e4b7a5"}
+	if err := output.WriteTemplate(&buf, tmpl, nil, versions); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n_SecretScanner 2.2.0, rules 3f2a9c81d0e4_\n") {
+		t.Errorf("the markdown report should end with the versions, got %q", buf.String())
+	}
+}
+
+func Test_TemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "report.tmpl")
+	os.WriteFile(valid, []byte(`{{.Summary.Total}}{{range .Secrets}} {{upper .Severity}}:{{.CompleteFilename}}:{{.LineNumber}}{{end}}`), 0644)
+	tmpl, err := output.LoadTemplate("", valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := output.WriteTemplate(&buf, tmpl, templateSecrets, output.ReportVersions{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "2 HIGH:app/config, prod.yml:4 LOW:.env:1" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	os.WriteFile(invalid, []byte(`{{range .Secrets}}`), 0644)
+	if _, err := output.LoadTemplate("", invalid); err == nil || !strings.Contains(err.Error(), "invalid template") {
+		t.Errorf("template that doesn't parse should be rejected, got %v", err)
+	}
+
+	// Failing on the second secret doesn't write the first one
+	failing := filepath.Join(dir, "failing.tmpl")
+	os.WriteFile(failing, []byte(`{{range .Secrets}}{{.CompleteFilename}} {{index .Layers 0}}{{end}}`), 0644)
+	tmpl, err = output.LoadTemplate("", failing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	secrets := []output.SecretFound{{CompleteFilename: "a", Layers: []string{"l1"}}, {CompleteFilename: "b"}}
+	if err := output.WriteTemplate(&buf, tmpl, secrets, output.ReportVersions{}); err == nil || buf.Len() != 0 {
+		t.Errorf("failing template should write nothing, got %q, %v", buf.String(), err)
+	}
+
+	for _, args := range [][2]string{{"", ""}, {"csv", valid}, {"xml", ""}} {
+		if _, err := output.LoadTemplate(args[0], args[1]); err == nil {
+			t.Errorf("LoadTemplate(%q, %q) should fail", args[0], args[1])
+		}
+	}
+}
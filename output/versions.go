@@ -0,0 +1,25 @@
+package output
+
+// ReportVersions Versions of SecretScanner and of the rules a report was produced with, so that
+// old findings can be traced back to the rules which found them
+type ReportVersions struct {
+	Version      string `json:"SecretScanner Version,omitempty"`
+	RulesVersion string `json:"Rules Version,omitempty"` // rules_version of the config, empty if it has none
+	RulesDigest  string `json:"Rules Digest,omitempty"`  // Digest of the signatures loaded
+}
+
+// Rules Returns the version of the rules followed by the start of their digest, e.g. "2024.05.1 (3f2a9c81d0e4)".
+// Rules edited without changing their version have another digest
+func (v ReportVersions) Rules() string {
+	digest := v.RulesDigest
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	if v.RulesVersion == "" {
+		return digest
+	}
+	if digest == "" {
+		return v.RulesVersion
+	}
+	return v.RulesVersion + " (" + digest + ")"
+}
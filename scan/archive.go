@@ -0,0 +1,381 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+)
+
+// Maximum number of bytes written and of entries read when extracting a single archive
+const (
+	maxArchiveExtractSize = 512 * 1024 * 1024
+	maxArchiveEntries     = 1000000
+)
+
+var archiveTooLarge = errors.New("archive exceeds maximum extraction size")
+
+var archiveTooManyEntries = errors.New("archive exceeds maximum number of entries")
+
+// Limits applied while extracting an archive
+type extractLimits struct {
+	maxFileSize  int64 // Larger entries are not extracted
+	maxTotalSize int64 // Extraction fails once this many bytes are written
+	written      int64
+	maxEntries   int64 // Extraction fails once this many entries are read
+	entries      int64
+	// Nested archives are extracted whatever their size, within maxTotalSize, to be scanned with --recursive-archives
+	keepArchives bool
+}
+
+func newExtractLimits(maxFileSize int64) *extractLimits {
+	return &extractLimits{maxFileSize: maxFileSize, maxTotalSize: maxArchiveExtractSize, maxEntries: maxArchiveEntries}
+}
+
+// Count an entry read from the archive, whether it is extracted or not
+func (limits *extractLimits) addEntry() error {
+	if limits.entries++; limits.entries > limits.maxEntries {
+		return archiveTooManyEntries
+	}
+	return nil
+}
+
+var extractBudgetExceeded = errors.New("extraction budget exceeded")
+
+// Budget of the bytes written and of the entries read while extracting an image: the image tar and all its
+// layers, which may be extracted concurrently. Set by --max-extract-size and --max-extract-entries
+type extractBudget struct {
+	maxSize    int64
+	maxEntries int64
+	size       atomic.Int64
+	entries    atomic.Int64
+}
+
+func newExtractBudget(maxSize int64, maxEntries int64) *extractBudget {
+	return &extractBudget{maxSize: maxSize, maxEntries: maxEntries}
+}
+
+// Budget of an image scan from the options of the session
+func newSessionExtractBudget() *extractBudget {
+	options := core.GetSession().Options
+	return newExtractBudget(int64(*options.MaxExtractSize)*1024, int64(*options.MaxExtractEntries))
+}
+
+// Charge an entry read from a tar to the budget, before it is written
+// @parameters
+// name - Name of the entry in the tar
+// size - Number of bytes written for the entry, 0 if it is skipped or is not a regular file
+// @returns
+// Error - Wraps extractBudgetExceeded once the budget is exceeded. Otherwise, returns nil
+func (b *extractBudget) charge(name string, size int64) error {
+	if entries := b.entries.Add(1); entries > b.maxEntries {
+		return fmt.Errorf("%w: more than %d entries read, raise --max-extract-entries to extract more",
+			extractBudgetExceeded, b.maxEntries)
+	}
+	if total := b.size.Add(size); total > b.maxSize {
+		return fmt.Errorf("%w: %s (%d bytes) would bring the size extracted over %d bytes, raise --max-extract-size "+
+			"to extract more", extractBudgetExceeded, name, size, b.maxSize)
+	}
+	return nil
+}
+
+// Join an archive entry name to the extraction dir, rejecting names escaping it (zip-slip)
+func safeJoin(dest string, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	target := filepath.Join(dest, filepath.Clean(string(os.PathSeparator)+name))
+	if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %s escapes the extraction dir", name)
+	}
+	return target, nil
+}
+
+// Maximum number of symlinks followed when resolving a path in an extraction dir
+const maxSymlinkFollows = 255
+
+// Resolve a path in an extraction dir the way it would be resolved inside the container, with the extraction dir
+// as root: symlinks already extracted are followed, absolute links start from the root and ".." never leaves it
+// @parameters
+// root - Absolute path of the extraction dir
+// name - Path to resolve, relative to the root
+// @returns
+// string - Resolved absolute path, always inside the root
+// Error - Errors if any. Otherwise, returns nil
+func resolveInRoot(root string, name string) (string, error) {
+	resolved := ""
+	remaining := filepath.ToSlash(name)
+	follows := 0
+	for remaining != "" {
+		var part string
+		part, remaining, _ = strings.Cut(remaining, "/")
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if i := strings.LastIndex(resolved, "/"); i >= 0 {
+				resolved = resolved[:i]
+			} else {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := part
+		if resolved != "" {
+			next = resolved + "/" + part
+		}
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if follows++; follows > maxSymlinkFollows {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", name)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = ""
+		}
+		remaining = filepath.ToSlash(target) + "/" + remaining
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// Remove a file or link in the way of an archive entry, so that it is replaced instead of written through
+func removeExisting(target string) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(target)
+}
+
+// Recreate a symlink of an archive entry. The link target is resolved inside the extraction dir and the link is
+// written relative to it, so that it can never point outside of the extraction dir
+// @parameters
+// root - Absolute path of the extraction dir
+// target - Path of the link, resolved with resolveInRoot
+// linkName - Target of the link as stored in the archive
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func extractSymlink(root string, target string, linkName string) error {
+	if !filepath.IsAbs(linkName) {
+		// Not joined with filepath.Join, which would clean "link/.." without following the link
+		dir, err := filepath.Rel(root, filepath.Dir(target))
+		if err != nil {
+			return err
+		}
+		linkName = dir + string(os.PathSeparator) + linkName
+	}
+	resolved, err := resolveInRoot(root, linkName)
+	if err != nil {
+		return err
+	}
+	relTarget, err := filepath.Rel(filepath.Dir(target), resolved)
+	if err != nil {
+		return err
+	}
+	if err = removeExisting(target); err != nil {
+		return err
+	}
+	return os.Symlink(relTarget, target)
+}
+
+// Recreate a hard link of an archive entry to a file previously extracted in the extraction dir
+// @parameters
+// root - Absolute path of the extraction dir
+// target - Path of the link, resolved with resolveInRoot
+// linkName - Name in the archive of the linked entry
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func extractHardlink(root string, target string, linkName string) error {
+	parent, err := resolveInRoot(root, filepath.Dir(linkName))
+	if err != nil {
+		return err
+	}
+	source := filepath.Join(parent, filepath.Base(linkName))
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("hard link %s points to the directory %s", target, linkName)
+	}
+	if err = removeExisting(target); err != nil {
+		return err
+	}
+	return os.Link(source, target)
+}
+
+// Write one archive entry to the extraction dir
+// @returns
+// bool - false if the entry was skipped for its size
+// Error - Errors if any. Otherwise, returns nil
+func extractEntry(target string, r io.Reader, size int64, limits *extractLimits) (bool, error) {
+	if size > limits.maxFileSize && !(limits.keepArchives && nestedArchiveKind(target) != "") {
+		return false, nil
+	}
+	if limits.written+size > limits.maxTotalSize {
+		return false, archiveTooLarge
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return false, err
+	}
+	// Never trust the size in the header, copy at most one byte more to detect lies
+	n, cpErr := io.Copy(file, io.LimitReader(r, size+1))
+	closeErr := file.Close()
+	limits.written += n
+	if cpErr != nil {
+		return false, cpErr
+	}
+	if closeErr != nil {
+		return false, closeErr
+	}
+	if n > size {
+		return false, fmt.Errorf("archive entry %s is larger than its header size", target)
+	}
+	return true, nil
+}
+
+// Extract the directories and regular files of a tar stream, other entries such as links are skipped
+// @parameters
+// r - Uncompressed tar stream
+// dest - Extraction dir
+// limits - Size limits of the extraction
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func extractTarStream(r io.Reader, dest string, limits *extractLimits) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = limits.addEntry(); err != nil {
+			return err
+		}
+
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if _, err = extractEntry(target, tr, hdr.Size, limits); err != nil {
+				return err
+			}
+		default:
+			log.Debugf("extractTarStream: skipping %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// Decompress a tar stream according to the suffix of its name or its magic bytes
+// @parameters
+// name - Name of the tar file, its suffix may be .gz, .gzip or .zst
+// br - Stream of the tar file
+// @returns
+// io.Reader - Uncompressed tar stream, br itself if it is not compressed
+// func() - Releases the decompressor
+// Error - Errors if any. Otherwise, returns nil
+func decompressReader(name string, br *bufio.Reader) (io.Reader, func(), error) {
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".gzip") || bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case strings.HasSuffix(name, ".zst") || bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}
+	return br, func() {}, nil
+}
+
+// Extract a tar file, gzip or zstd compressed or not
+func extractTarFileTo(tarPath string, dest string, limits *extractLimits) error {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, closeReader, err := decompressReader(tarPath, bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+	return extractTarStream(r, dest, limits)
+}
+
+// Extract the directories and regular files of a zip file
+func extractZipFileTo(zipPath string, dest string, limits *extractLimits) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		if err := limits.addEntry(); err != nil {
+			return err
+		}
+		target, err := safeJoin(dest, entry.Name)
+		if err != nil {
+			return err
+		}
+		if entry.FileInfo().IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			log.Debugf("extractZipFileTo: skipping %s of mode %s", entry.Name, entry.Mode())
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		_, err = extractEntry(target, rc, int64(entry.UncompressedSize64), limits)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Blobs of an Azure Blob Storage container under a prefix, read with the container client of the Azure SDK
+type azureContainer struct {
+	client *container.Client
+	// URL of the container in the logs, https://account.blob.core.windows.net/container, or with the account in its
+	// path for the emulators, without the shared access signature
+	containerURL string
+	prefix       string
+}
+
+// URL of the container, the prefix of the blobs and the shared access signature of an --azure-blob URL
+func parseAzureBlobURL(rawURL string) (string, string, url.Values, error) {
+	invalid := fmt.Errorf("--azure-blob must be an https://account.blob.core.windows.net/container/prefix URL, "+
+		"got %q", URLName(rawURL))
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return "", "", nil, invalid
+	}
+	sas, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", "", nil, invalid
+	}
+	// The emulators and the IP endpoints of the accounts have the account as the first segment of the path
+	segments := 1
+	if host := u.Hostname(); host == "localhost" || net.ParseIP(host) != nil {
+		segments = 2
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", segments+1)
+	if len(parts) < segments || parts[segments-1] == "" {
+		return "", "", nil, invalid
+	}
+	prefix := ""
+	if len(parts) > segments {
+		prefix = parts[segments]
+	}
+	containerURL := u.Scheme + "://" + u.Host + "/" + strings.Join(parts[:segments], "/")
+	return containerURL, prefix, sas, nil
+}
+
+// CheckAzureBlob Check the value of --azure-blob
+// @parameters
+// rawURL - URL of the container and prefix to scan, with a shared access signature or not
+// @returns
+// Error - Errors if the URL is not the URL of a container
+func CheckAzureBlob(rawURL string) error {
+	_, _, _, err := parseAzureBlobURL(rawURL)
+	return err
+}
+
+// Error of the Blob service, with its status and code, or of the transport, without the URL of the request and its
+// shared access signature
+func azureError(err error) error {
+	var respErr *azcore.ResponseError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &respErr):
+		return fmt.Errorf("%d %s: %s", respErr.StatusCode, http.StatusText(respErr.StatusCode), respErr.ErrorCode)
+	case errors.As(err, &urlErr):
+		return urlErr.Err
+	}
+	return err
+}
+
+func (c *azureContainer) list(ctx context.Context, token string) ([]storeObject, string, error) {
+	options := &container.ListBlobsFlatOptions{}
+	if c.prefix != "" {
+		options.Prefix = &c.prefix
+	}
+	if token != "" {
+		options.Marker = &token
+	}
+	page, err := c.client.NewListBlobsFlatPager(options).NextPage(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("list %s/%s: %w", c.containerURL, c.prefix, azureError(err))
+	}
+	objects := make([]storeObject, 0, len(page.Segment.BlobItems))
+	for _, item := range page.Segment.BlobItems {
+		object := storeObject{Key: *item.Name}
+		if properties := item.Properties; properties != nil {
+			if properties.ContentLength != nil {
+				object.Size = *properties.ContentLength
+			}
+			object.Archived = properties.AccessTier != nil && *properties.AccessTier == blob.AccessTierArchive
+		}
+		objects = append(objects, object)
+	}
+	next := ""
+	if page.NextMarker != nil {
+		next = *page.NextMarker
+	}
+	return objects, next, nil
+}
+
+func (c *azureContainer) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.client.NewBlobClient(key).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, azureError(err)
+	}
+	return resp.Body, nil
+}
+
+func (c *azureContainer) url(key string) string {
+	return c.containerURL + "/" + key
+}
+
+// newAzureContainerClient Create a client of a container, with a shared access signature, or else with the
+// DefaultAzureCredential of the Azure SDK. The requests, token requests included, go through --proxy or the proxy
+// of the environment
+// @parameters
+// containerURL - URL of the container, without its query
+// sas - Shared access signature, empty to use the Microsoft Entra ID credentials
+// @returns
+// *container.Client - Client of the container
+// Error - Errors if the client can't be created
+func newAzureContainerClient(containerURL string, sas url.Values) (*container.Client, error) {
+	options := &container.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: objectStoreClient()}}
+	if len(sas) > 0 {
+		return container.NewClientWithNoCredential(containerURL+"?"+sas.Encode(), options)
+	}
+	credential, err := azidentity.NewDefaultAzureCredential(
+		&azidentity.DefaultAzureCredentialOptions{ClientOptions: options.ClientOptions})
+	if err != nil {
+		return nil, err
+	}
+	return container.NewClient(containerURL, credential, options)
+}
+
+// ScanSecretsInAzureBlob Scans the blobs of an Azure Blob Storage container under a prefix, with the shared access
+// signature of the URL or of AZURE_STORAGE_SAS_TOKEN, else with the Microsoft Entra ID credentials of the
+// environment. Blobs are read as they are matched, without being written to disk, and the ones larger than their
+// maximum file size are skipped
+// @parameters
+// rawURL - https://account.blob.core.windows.net/container/prefix URL of the blobs to scan
+// @returns
+// []output.SecretFound - List of all secrets found, in the names of their blobs
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInAzureBlob(rawURL string) ([]output.SecretFound, error) {
+	containerURL, prefix, sas, err := parseAzureBlobURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); len(sas) == 0 && token != "" {
+		if sas, err = url.ParseQuery(strings.TrimPrefix(token, "?")); err != nil {
+			return nil, fmt.Errorf("AZURE_STORAGE_SAS_TOKEN: %w", err)
+		}
+	}
+	client, err := newAzureContainerClient(containerURL, sas)
+	if err != nil {
+		return nil, err
+	}
+	store := &azureContainer{client: client, containerURL: containerURL, prefix: prefix}
+	return scanObjectStore(core.GetSession().Context, store)
+}
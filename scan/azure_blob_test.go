@@ -0,0 +1,156 @@
+package scan
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// Blob listed by List Blobs
+type azureBlob struct {
+	Name       string `xml:"Name"`
+	Properties struct {
+		ContentLength int64  `xml:"Content-Length"`
+		AccessTier    string `xml:"AccessTier,omitempty"`
+	} `xml:"Properties"`
+}
+
+// Page of the blobs of a container
+type azureEnumerationResults struct {
+	Blobs      []azureBlob `xml:"Blobs>Blob"`
+	NextMarker string      `xml:"NextMarker"`
+}
+
+// Fake Blob service serving the blobs of a container with pages of pageSize blobs, to the requests with the
+// shared access signature sig=test
+func fakeAzureBlob(t *testing.T, containerPath string, contents map[string]string, archived map[string]bool,
+	pageSize int) *httptest.Server {
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "test" || r.Header.Get("X-Ms-Version") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>AuthenticationFailed</Code>" +
+				"<Message>Server failed to authenticate the request.\nRequestId:1</Message></Error>"))
+			return
+		}
+		if r.URL.Path == containerPath && r.URL.Query().Get("comp") == "list" {
+			var page azureEnumerationResults
+			for _, name := range names {
+				if !strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					continue
+				}
+				if marker := r.URL.Query().Get("marker"); marker != "" && name <= marker {
+					continue
+				}
+				if len(page.Blobs) == pageSize {
+					page.NextMarker = page.Blobs[pageSize-1].Name
+					break
+				}
+				blob := azureBlob{Name: name}
+				blob.Properties.ContentLength = int64(len(contents[name]))
+				if archived[name] {
+					blob.Properties.AccessTier = "Archive"
+				}
+				page.Blobs = append(page.Blobs, blob)
+			}
+			data, _ := xml.Marshal(struct {
+				XMLName xml.Name `xml:"EnumerationResults"`
+				azureEnumerationResults
+			}{azureEnumerationResults: page})
+			w.Write(data)
+			return
+		}
+		body, ok := contents[strings.TrimPrefix(r.URL.Path, containerPath+"/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.</Message></Error>"))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func Test_ScanAzureBlob(t *testing.T) {
+	session := testSession(t)
+	server := fakeAzureBlob(t, "/devstoreaccount1/artifacts", map[string]string{
+		"app/config/app settings.properties": testLayerSecret,
+		"app/archive/old.properties":         testLayerSecret,
+		"app/deploy/prod.properties":         testLayerSecret,
+		"app/deploy/readme.txt":              "nothing to see",
+		"other/app.properties":               testLayerSecret,
+	}, map[string]bool{"app/archive/old.properties": true}, 2)
+	defer server.Close()
+
+	containerURL, prefix, sas, err := parseAzureBlobURL(server.URL + "/devstoreaccount1/artifacts/app/?sv=2021-08-06&sig=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(sas url.Values) *container.Client {
+		client, err := newAzureContainerClient(containerURL, sas)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	store := &azureContainer{client: newClient(sas), containerURL: containerURL, prefix: prefix}
+	secrets, err := scanObjectStore(session.Context, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, secret := range secrets {
+		names = append(names, secret.CompleteFilename)
+	}
+	expected := []string{"app/config/app settings.properties", "app/deploy/prod.properties"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("secrets found in %v, want %v", names, expected)
+	}
+
+	// A wrong signature fails the listing, and is not in the error
+	sas.Set("sig", "wrong")
+	store.client = newClient(sas)
+	if _, err = scanObjectStore(session.Context, store); err == nil ||
+		!strings.Contains(err.Error(), "403 Forbidden: AuthenticationFailed") ||
+		strings.Contains(err.Error(), "wrong") {
+		t.Errorf("listing with a wrong signature: %v", err)
+	}
+}
+
+func Test_ParseAzureBlobURL(t *testing.T) {
+	for _, test := range []struct {
+		url       string
+		valid     bool
+		container string
+		prefix    string
+	}{
+		{"https://account.blob.core.windows.net/artifacts/app/config", true,
+			"https://account.blob.core.windows.net/artifacts", "app/config"},
+		{"https://account.blob.core.windows.net/artifacts?sv=2021-08-06&sig=abc", true,
+			"https://account.blob.core.windows.net/artifacts", ""},
+		{"http://127.0.0.1:10000/devstoreaccount1/artifacts/app", true,
+			"http://127.0.0.1:10000/devstoreaccount1/artifacts", "app"},
+		{"https://account.blob.core.windows.net/", false, "", ""},
+		{"http://localhost:10000/devstoreaccount1", false, "", ""},
+		{"gs://bucket/app", false, "", ""},
+	} {
+		if err := CheckAzureBlob(test.url); (err == nil) != test.valid {
+			t.Errorf("CheckAzureBlob(%s) = %v, want valid %v", test.url, err, test.valid)
+		}
+		container, prefix, _, _ := parseAzureBlobURL(test.url)
+		if test.valid && (container != test.container || prefix != test.prefix) {
+			t.Errorf("container %q and prefix %q of %s", container, prefix, test.url)
+		}
+	}
+}
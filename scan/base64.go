@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const (
+	// Encoded strings shorter than this are not decoded by --decode-base64, they decode to less than 18 bytes
+	minBase64EncodedLength = 24
+	// Reported as the Encoding of the secrets found in decoded strings
+	base64Encoding = "base64"
+)
+
+// Runs of characters of the standard and URL-safe base64 alphabets, with their padding
+var base64Candidate = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_-]{%d,}={0,2}`, minBase64EncodedLength))
+
+// decodeBase64 Decode a base64 string with the alphabet and padding it uses. The string must be the encoding of the
+// decoded bytes, and the bytes must be printable text, so that random strings of the base64 alphabet are not decoded
+// @returns
+// []byte - Decoded text
+// bool - false if the string is not the base64 encoding of text
+func decodeBase64(encoded string) ([]byte, bool) {
+	var encoding *base64.Encoding
+	urlSafe := strings.ContainsAny(encoded, "-_")
+	switch {
+	case urlSafe && strings.ContainsAny(encoded, "+/"):
+		return nil, false
+	case urlSafe && strings.HasSuffix(encoded, "="):
+		encoding = base64.URLEncoding
+	case urlSafe:
+		encoding = base64.RawURLEncoding
+	case strings.HasSuffix(encoded, "="):
+		encoding = base64.StdEncoding
+	default:
+		encoding = base64.RawStdEncoding
+	}
+	decoded, err := encoding.Strict().DecodeString(encoded)
+	if err != nil || encoding.EncodeToString(decoded) != encoded {
+		return nil, false
+	}
+	for _, b := range decoded {
+		if !isPrintable(b) && b != '\n' && b != '\r' {
+			return nil, false
+		}
+	}
+	return decoded, true
+}
+
+// matchBase64Encoded Match the signatures of the contents, and the high entropy strings, against the base64-encoded
+// strings of the contents, once decoded. The secrets are located at the encoded strings, with the Encoding base64
+// @parameters
+// ctx - Context of the scan
+// contents - Contents of the file
+// relPath - Path of the file reported
+// layer - layer ID, if the file is in a container image
+// numSecrets - Number of secrets found so far, updated with the secrets found
+// matchedRuleSet - Matches of each rule in the file so far, updated with the secrets found
+// @returns
+// []output.SecretFound - Secrets found in the decoded strings
+// Error - Errors if any. Otherwise, returns nil
+func matchBase64Encoded(ctx context.Context, contents []byte, relPath, layer string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	maxSecrets := *options.MaxSecrets
+	var secretsFound []output.SecretFound
+	for _, loc := range base64Candidate.FindAllIndex(contents, -1) {
+		if *numSecrets >= maxSecrets {
+			break
+		}
+		decoded, ok := decodeBase64(string(contents[loc[0]:loc[1]]))
+		if !ok {
+			continue
+		}
+		secrets, err := signature.MatchContentsSignatures(ctx, decoded, relPath, layer, numSecrets, matchedRuleSet)
+		if err != nil {
+			return secretsFound, err
+		}
+		if !*options.NoEntropy {
+			secrets = append(secrets, signature.MatchHighEntropyStrings(ctx, decoded, relPath, layer, secrets, numSecrets,
+				matchedRuleSet)...)
+		}
+		for i := range secrets {
+			secrets[i].Encoding = base64Encoding
+			secrets[i].PrintBufferStartIndex = loc[0]
+			secrets[i].MatchFromByte, secrets[i].MatchToByte = 0, loc[1]-loc[0]
+		}
+		secretsFound = append(secretsFound, secrets...)
+	}
+	return secretsFound, nil
+}
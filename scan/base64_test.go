@@ -0,0 +1,73 @@
+package scan
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_DecodeBase64(t *testing.T) {
+	for encoded, expected := range map[string]string{
+		base64.StdEncoding.EncodeToString([]byte("password: hunter2 hunter2")):    "password: hunter2 hunter2",
+		base64.RawURLEncoding.EncodeToString([]byte("token=abc?def>ghi~jkl~mno")): "token=abc?def>ghi~jkl~mno",
+		// Random strings of the alphabet decode to binary bytes
+		"J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa": "",
+		// Bits after the last byte are set, so the string is not the encoding of its decoded bytes
+		"c2VjcmV0LXZhbHVlLWZvci10ZXN0aW5nIQ=": "",
+		"c2VjcmV0LXZhbHVl-_/+Zm9yLXRlc3Rpbmc": "",
+	} {
+		decoded, ok := decodeBase64(encoded)
+		if ok != (expected != "") || string(decoded) != expected {
+			t.Errorf("decodeBase64(%s) = %q, %v, want %q", encoded, decoded, ok, expected)
+		}
+	}
+}
+
+func Test_DecodeBase64Secrets(t *testing.T) {
+	options := testSession(t).Options
+	defer func(decode, multipleMatch bool) {
+		*options.DecodeBase64, *options.MultipleMatch = decode, multipleMatch
+	}(*options.DecodeBase64, *options.MultipleMatch)
+	// The encoded string is itself a high entropy string, matched first
+	*options.MultipleMatch = true
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(testLayerSecret))
+	contents := "name: payments\n  config: " + encoded + "\n"
+	dir := t.TempDir()
+	writeTestFile(t, dir, "values.txt", []byte(contents))
+
+	for _, decode := range []bool{false, true} {
+		*options.DecodeBase64 = decode
+		numSecrets := uint(0)
+		secrets, err := scanFile(testSession(t).Context, filepath.Join(dir, "values.txt"), "values.txt", "values.txt",
+			".txt", "", &numSecrets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, secret := range secrets {
+			if secret.Encoding != base64Encoding {
+				continue
+			}
+			found = true
+			if secret.RuleName != signature.GenericHighEntropyRuleName {
+				t.Errorf("decoded secret matched by %s, want %s", secret.RuleName, signature.GenericHighEntropyRuleName)
+			}
+			start := secret.PrintBufferStartIndex + secret.MatchFromByte
+			if start != strings.Index(contents, encoded) || secret.MatchToByte-secret.MatchFromByte != len(encoded) {
+				t.Errorf("decoded secret reported at [%d, %d), want the encoded string at %d", start,
+					secret.PrintBufferStartIndex+secret.MatchToByte, strings.Index(contents, encoded))
+			}
+			if secret.LineNumber != 2 || secret.ColumnNumber != 11 {
+				t.Errorf("decoded secret at line %d column %d, want line 2 column 11", secret.LineNumber,
+					secret.ColumnNumber)
+			}
+		}
+		if found != decode {
+			t.Errorf("secret found in the base64 string %v with --decode-base64 %v", found, decode)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const (
+	// Bytes at the start of a file checked for a NUL byte to detect binary files, like git does
+	binarySniffSize = 8000
+	// Printable runs shorter than this are not extracted from binary files, like strings -n 8
+	minBinaryStringLength = 8
+)
+
+// Executables and libraries scanned with --scan-binaries even if blacklisted_extensions skips their extension
+var binaryExtensions = []string{".exe", ".dll", ".so", ".dylib", ".bin", ".o", ".a", ".class", ".wasm", ".pyc"}
+
+// Media files, whose printable runs are noise rather than embedded strings. They are matched as before
+var mediaExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".ico", ".webp", ".psd",
+	".xcf", ".mp3", ".mp4", ".avi", ".mov", ".mkv", ".wav", ".ogg", ".flac", ".ttf", ".otf", ".woff", ".woff2"}
+
+// Checks if a file with a blacklisted extension is still scanned as an executable or a library with --scan-binaries
+func isScannedBinary(path string) bool {
+	return *core.GetSession().Options.ScanBinaries && core.HasExtension(path, binaryExtensions) &&
+		core.IsIncludedFileExtension(path)
+}
+
+// Checks if the strings of a file are extracted when it is binary
+func extractsBinaryStrings(fileName string) bool {
+	return *core.GetSession().Options.ScanBinaries && !core.HasExtension(fileName, mediaExtensions)
+}
+
+// peekBinary Checks if contents are binary, i.e. have a NUL byte in their first binarySniffSize bytes
+// @returns
+// io.Reader - Reader of the whole contents, including the bytes checked
+// bool - true if the contents are binary
+func peekBinary(r io.Reader) (io.Reader, bool) {
+	br := bufio.NewReaderSize(r, binarySniffSize)
+	head, _ := br.Peek(binarySniffSize)
+	return br, bytes.IndexByte(head, 0) >= 0
+}
+
+// Run of printable bytes of a binary file, at textStart in the extracted text and binaryStart in the file
+type stringRun struct {
+	textStart   int
+	binaryStart int
+}
+
+func isPrintable(b byte) bool {
+	return b == '\t' || (b >= 0x20 && b < 0x7f)
+}
+
+// extractStrings Extract the runs of printable ASCII bytes of binary contents, one per line, like strings does
+// @parameters
+// data - Binary contents
+// minLength - Minimum length of the runs extracted
+// @returns
+// []byte - Runs extracted, each followed by a newline
+// []stringRun - Offsets of the runs in the text and in the contents, by offset
+func extractStrings(data []byte, minLength int) ([]byte, []stringRun) {
+	var text []byte
+	var runs []stringRun
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && isPrintable(data[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLength {
+			runs = append(runs, stringRun{textStart: len(text), binaryStart: start})
+			text = append(text, data[start:i]...)
+			text = append(text, '\n')
+		}
+		start = -1
+	}
+	return text, runs
+}
+
+// Offset in the binary contents of an offset in the text extracted from them
+func binaryOffset(runs []stringRun, textOffset int) int {
+	i := sort.Search(len(runs), func(i int) bool { return runs[i].textStart > textOffset }) - 1
+	if i < 0 {
+		return textOffset
+	}
+	return runs[i].binaryStart + textOffset - runs[i].textStart
+}
+
+// scanBinaryFile Match the signatures against the strings of a file if it is binary
+// @returns
+// []output.SecretFound - Secrets found in the binary file
+// bool - false if the file is not binary, or could not be read, it is then scanned as text
+// Error - Errors if any. Otherwise, returns nil
+func scanBinaryFile(ctx context.Context, filePath, relPath, fileName, fileExtension, layer string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, false, nil
+	}
+	defer file.Close()
+	r, binary := peekBinary(file)
+	if !binary {
+		return nil, false, nil
+	}
+	if info, err := file.Stat(); err == nil {
+		metrics.BytesScanned.Add(info.Size())
+	}
+	secrets, err := scanBinary(ctx, r, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	return secrets, true, err
+}
+
+// scanBinary Match the signatures against the printable strings of binary contents, at most the maximum file
+// size of the file. The secrets are reported with their offset in the contents, without lines and columns
+// @parameters
+// r - Binary contents
+// @returns
+// []output.SecretFound - Secrets found, with PrintBufferStartIndex set to the offset of the match
+// Error - Errors if any. Otherwise, returns nil
+func scanBinary(ctx context.Context, r io.Reader, relPath, fileName, fileExtension, layer string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	limit := options.MaxFileSizes.Limit(relPath, *options.MaximumFileSize*1024)
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	text, runs := extractStrings(data, minBinaryStringLength)
+	secrets, err := scanContents(ctx, text, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	for i := range secrets {
+		secret := &secrets[i]
+		if secret.PartToMatch != signature.ContentsPart {
+			continue
+		}
+		from := secret.PrintBufferStartIndex + secret.MatchFromByte
+		secret.PrintBufferStartIndex = binaryOffset(runs, from)
+		secret.MatchFromByte, secret.MatchToByte = 0, secret.MatchToByte-secret.MatchFromByte
+		secret.LineNumber, secret.ColumnNumber, secret.EndLineNumber, secret.EndColumnNumber = 0, 0, 0, 0
+	}
+	return secrets, err
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ELF-like blob: a header, NUL padding and code bytes around a string table embedding the secret
+func elfBlob(secret string) []byte {
+	blob := append([]byte("\x7fELF\x02\x01\x01\x00"), make([]byte, 56)...)
+	blob = append(blob, 0xe8, 0x1f, 0x90, 0xc3, 0x00)
+	blob = append(blob, []byte("GLIBC_2.34\x00short\x00")...)
+	blob = append(blob, []byte(secret)...)
+	return append(blob, 0x00, 0x48, 0x89, 0xe5, 0x00)
+}
+
+func Test_ExtractStrings(t *testing.T) {
+	data := []byte("\x00\x01printable run\x00abc\x00\x7f\tsecond one\xff")
+	text, runs := extractStrings(data, 8)
+	if string(text) != "printable run\n\tsecond one\n" {
+		t.Errorf("extractStrings() = %q", text)
+	}
+	if !reflect.DeepEqual(runs, []stringRun{{0, 2}, {14, 21}}) {
+		t.Errorf("runs = %v", runs)
+	}
+	for textOffset, expected := range map[int]int{0: 2, 10: 12, 14: 21, 16: 23} {
+		if offset := binaryOffset(runs, textOffset); offset != expected {
+			t.Errorf("binaryOffset(%d) = %d, want %d", textOffset, offset, expected)
+		}
+	}
+}
+
+func Test_ScanBinaries(t *testing.T) {
+	options := testSession(t).Options
+	defer func(scanBinaries bool) { *options.ScanBinaries = scanBinaries }(*options.ScanBinaries)
+
+	dir := t.TempDir()
+	blob := elfBlob(testLayerSecret)
+	writeTestFile(t, dir, "server", blob)
+	writeTestFile(t, dir, "libapp.so", blob)
+	writeTestFile(t, dir, "logo.png", blob)
+
+	scanDir := func(scanBinaries bool) map[string]int {
+		*options.ScanBinaries = scanBinaries
+		isFirstSecret := true
+		secrets, err := ScanSecretsInDir("", dir, dir, &isFirstSecret, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets := map[string]int{}
+		for _, secret := range secrets {
+			offsets[filepath.Base(secret.CompleteFilename)] = secret.PrintBufferStartIndex + secret.MatchFromByte
+			if secret.LineNumber != 0 && scanBinaries {
+				t.Errorf("secret of binary %s reported at line %d", secret.CompleteFilename, secret.LineNumber)
+			}
+		}
+		return offsets
+	}
+
+	// Executables and libraries are skipped by their blacklisted extension by default
+	if _, found := scanDir(false)["libapp.so"]; found {
+		t.Error("secret found in libapp.so without --scan-binaries")
+	}
+	offsets := scanDir(true)
+	secretOffset := bytes.Index(blob, []byte(testLayerSecret))
+	for _, name := range []string{"server", "libapp.so"} {
+		offset, found := offsets[name]
+		if !found {
+			t.Errorf("no secret found in %s with --scan-binaries", name)
+		} else if offset < secretOffset || offset >= secretOffset+len(testLayerSecret) {
+			t.Errorf("secret of %s at offset %d, want within [%d, %d)", name, offset, secretOffset,
+				secretOffset+len(testLayerSecret))
+		}
+	}
+	// Images keep their blacklisted extension
+	if _, found := offsets["logo.png"]; found {
+		t.Error("secret found in logo.png")
+	}
+}
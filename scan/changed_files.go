@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Files scanned instead of walking the --local directory, with --changed-files-from. nil to walk it whole
+var changedFiles *changedFileList
+
+// Files of a directory to scan, e.g. the files changed by a commit
+type changedFileList struct {
+	root  string
+	paths []string // Paths of the files in root, joined to root like the walk does, sorted like the walk visits them
+}
+
+// EnableChangedFiles Restrict the scan of a directory to the files listed in a file, one per line, e.g. the output
+// of git diff --name-only. Relative paths are relative to the current directory. Paths outside the directory are
+// ignored with a warning
+// @parameters
+// root - Directory scanned, --local
+// listFile - File listing the files to scan
+// @returns
+// Error - Errors if the list can't be read
+func EnableChangedFiles(root string, listFile string) error {
+	data, err := os.ReadFile(listFile)
+	if err != nil {
+		return err
+	}
+	changedFiles, err = parseChangedFiles(root, data)
+	return err
+}
+
+func parseChangedFiles(root string, data []byte) (*changedFileList, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	list := &changedFileList{root: root}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		name := strings.TrimRight(line, "\r")
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		path, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		relPath, err := filepath.Rel(absRoot, path)
+		if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			log.Warnf("--changed-files-from: %s is not in %s, ignored", name, root)
+			continue
+		}
+		if relPath == "." || seen[relPath] {
+			continue
+		}
+		seen[relPath] = true
+		list.paths = append(list.paths, filepath.Join(root, relPath))
+	}
+	sort.Strings(list.paths)
+	return list, nil
+}
+
+// List of the files to scan in a directory, nil if the directory is walked whole
+func changedFilesIn(fullDir string, layer string) *changedFileList {
+	if changedFiles == nil || layer != "" || filepath.Clean(fullDir) != filepath.Clean(changedFiles.root) {
+		return nil
+	}
+	return changedFiles
+}
+
+// walk Visit the files of the list as filepath.WalkDir would, and their parent dirs first, so that the files of
+// dirs skipped by the visit, e.g. excluded or ignored, are skipped too. Files that don't exist anymore, deleted by
+// the changes, and dirs are not visited
+// @parameters
+// visit - Function visiting the files and dirs of the walk
+// @returns
+// Error - First error returned by visit, other than filepath.SkipDir
+func (l *changedFileList) walk(visit fs.WalkDirFunc) error {
+	skippedDirs := map[string]bool{}
+	// Visits the dirs from the root to the parent of path, returns true if one of them is skipped
+	visitParents := func(path string) (bool, error) {
+		relDir, _ := filepath.Rel(l.root, filepath.Dir(path))
+		dir := l.root
+		parts := []string{"."}
+		if relDir != "." {
+			parts = append(parts, strings.Split(relDir, string(filepath.Separator))...)
+		}
+		for _, part := range parts {
+			dir = filepath.Join(dir, part)
+			skipped, visited := skippedDirs[dir]
+			if !visited {
+				info, err := os.Lstat(dir)
+				if err != nil {
+					return true, nil
+				}
+				err = visit(dir, fs.FileInfoToDirEntry(info), nil)
+				if err != nil && err != filepath.SkipDir {
+					return true, err
+				}
+				skipped = err == filepath.SkipDir
+				skippedDirs[dir] = skipped
+			}
+			if skipped {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	for _, path := range l.paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			log.Debugf("--changed-files-from: %s", err)
+			continue
+		}
+		if info.IsDir() {
+			log.Warnf("--changed-files-from: %s is a directory, only files are scanned", path)
+			continue
+		}
+		skipped, err := visitParents(path)
+		if err != nil {
+			return err
+		}
+		if skipped {
+			continue
+		}
+		if err = visit(path, fs.FileInfoToDirEntry(info), nil); err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
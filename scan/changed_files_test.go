@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParseChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	list := strings.Join([]string{
+		filepath.Join(root, "b.txt"),
+		"",
+		filepath.Join(root, "app", "a.txt") + "\r",
+		filepath.Join(root, "b.txt"),
+		filepath.Join(filepath.Dir(root), "outside.txt"),
+		root,
+	}, "\n")
+	changed, err := parseChangedFiles(root, []byte(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(root, "app", "a.txt"), filepath.Join(root, "b.txt")}
+	if !reflect.DeepEqual(changed.paths, expected) {
+		t.Errorf("changed files %v, want %v", changed.paths, expected)
+	}
+	if changedFilesIn(root+string(filepath.Separator), "") != nil || changedFilesIn(root, "layer-1") != nil {
+		t.Error("changed files applied while not enabled")
+	}
+}
+
+func Test_ScanChangedFiles(t *testing.T) {
+	options := testSession(t).Options
+	dir := writeTestTree(t, 20)
+	defer func(respect bool) { *options.RespectGitignore = respect }(*options.RespectGitignore)
+	*options.RespectGitignore = true
+	writeTestFile(t, dir, ".gitignore", []byte("app3/\n"))
+
+	list := strings.Join([]string{
+		filepath.Join(dir, "app1", "config11.txt"),
+		filepath.Join(dir, "app3", "config3.txt"),
+		filepath.Join(dir, "app2", "deleted.txt"),
+		filepath.Join(dir, "app0", "config0.txt"),
+	}, "\n")
+	var err error
+	if changedFiles, err = parseChangedFiles(dir, []byte(list)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { changedFiles = nil }()
+
+	// Only the listed files are scanned, ignored files are still skipped
+	expected := []string{filepath.Join("app0", "config0.txt") + ":-1", filepath.Join("app1", "config11.txt") + ":-1"}
+	found := scanTestTree(t, dir, 1, 1000)
+	for i := range found {
+		found[i] = strings.TrimPrefix(found[i], dir+string(filepath.Separator))
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("secrets found in the changed files %v, want %v", found, expected)
+	}
+}
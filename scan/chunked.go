@@ -0,0 +1,168 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+var (
+	// Files larger than this are matched window by window, to bound the memory used per file
+	streamFileThreshold int64 = 4 << 20
+	streamWindowSize          = 1 << 20
+	// Bytes shared by consecutive windows: secrets up to this long are never split between two windows
+	streamWindowOverlap = 16 << 10
+)
+
+// Bytes kept before the first match reported by a window, to display the contents before it
+const streamWindowContext = 64
+
+// Matches the signatures against a window of the contents of a file
+type windowMatcher func(window []byte, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error)
+
+// scanFileWindows Scans a large file window by window, with the same results as scanFile
+func scanFileWindows(ctx context.Context, filePath, relPath, fileName, fileExtension, layer string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return scanReaderWindows(ctx, file, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+}
+
+// scanReaderWindows Scans large contents window by window as they are read, e.g. from a layer tar
+func scanReaderWindows(ctx context.Context, r io.Reader, relPath, fileName, fileExtension, layer string,
+	numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	// The path, filename and extension are matched once, without contents
+	secrets, err := signature.MatchPatternSignatures(ctx, nil, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	if err != nil {
+		return nil, err
+	}
+	secrets = allowed(secrets, numSecrets, matchedRuleSet)
+
+	options := core.GetSession().Options
+	found, err := scanWindows(r, streamWindowSize, streamWindowOverlap, *options.MaxSecrets, numSecrets, matchedRuleSet,
+		func(window []byte, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+			// Large files stop between windows once the scan is cancelled
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			windowSecrets, err := signature.MatchContentsSignatures(ctx, window, relPath, layer, numSecrets, matchedRuleSet)
+			if err != nil {
+				return nil, err
+			}
+			if !*options.NoEntropy {
+				windowSecrets = append(windowSecrets, signature.MatchHighEntropyStrings(ctx, window, relPath, layer,
+					windowSecrets, numSecrets, matchedRuleSet)...)
+			}
+			if *options.DecodeBase64 {
+				decoded, err := matchBase64Encoded(ctx, window, relPath, layer, numSecrets, matchedRuleSet)
+				windowSecrets = append(windowSecrets, decoded...)
+				if err != nil {
+					return nil, err
+				}
+			}
+			// The allowed secrets must not count as matches of their rule in the next windows
+			return allowed(windowSecrets, numSecrets, matchedRuleSet), nil
+		})
+	return append(secrets, found...), err
+}
+
+// scanWindows Matches the contents read from r in overlapping windows, so that the memory used does
+// not depend on the size of the contents. Each window only reports the matches starting before its
+// overlap with the next window, which reports the others in full. windowSize must be larger than
+// overlap + streamWindowContext
+// @parameters
+// r - Contents to scan
+// windowSize - Bytes matched at once
+// overlap - Bytes shared by consecutive windows, the maximum length of the secrets found in full
+// maxSecrets - Number of secrets after which the scan stops
+// numSecrets - Number of secrets found so far, updated with the secrets reported
+// matchedRuleSet - Matches of each rule so far, updated with the secrets reported
+// match - Matcher applied to each window
+// @returns
+// []output.SecretFound - Secrets found, with offsets, lines and columns in the whole contents
+// Error - Errors if any. Otherwise, returns nil
+func scanWindows(r io.Reader, windowSize int, overlap int, maxSecrets uint, numSecrets *uint,
+	matchedRuleSet map[uint]uint, match windowMatcher) ([]output.SecretFound, error) {
+	var secretsFound []output.SecretFound
+	window := make([]byte, 0, windowSize)
+	windowStart := 0
+	// Lines before the window, and bytes of the line of the first byte of the window before it
+	lines, column := 0, 0
+
+	for {
+		n, err := io.ReadFull(r, window[len(window):windowSize])
+		window = window[:len(window)+n]
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			return secretsFound, err
+		}
+		if len(window) == 0 {
+			return secretsFound, nil
+		}
+
+		// Matches deferred to the next window must not count as matches of their rule yet
+		windowRules := make(map[uint]uint, len(matchedRuleSet))
+		for id, count := range matchedRuleSet {
+			windowRules[id] = count
+		}
+		found, err := match(window, numSecrets, windowRules)
+		if err != nil {
+			return secretsFound, err
+		}
+
+		// Matches starting in the context were reported by the previous window
+		keepFrom, keepBefore := 0, len(window)-overlap
+		if windowStart > 0 {
+			keepFrom = streamWindowContext
+		}
+		if last {
+			keepBefore = len(window)
+		}
+		locateSecrets(window, found)
+		for _, secret := range found {
+			start := secret.PrintBufferStartIndex + secret.MatchFromByte
+			if secret.PartToMatch == signature.ContentsPart && (start < keepFrom || start >= keepBefore) {
+				*numSecrets--
+				continue
+			}
+			if secret.Severity != output.SUPPRESSED {
+				matchedRuleSet[uint(secret.RuleID)]++
+			}
+			if secret.LineNumber == 1 {
+				secret.ColumnNumber += column
+			}
+			if secret.EndLineNumber == 1 {
+				secret.EndColumnNumber += column
+			}
+			if secret.LineNumber > 0 {
+				secret.LineNumber += lines
+				secret.EndLineNumber += lines
+			}
+			secret.PrintBufferStartIndex += windowStart
+			secretsFound = append(secretsFound, secret)
+		}
+		if last || *numSecrets >= maxSecrets {
+			return secretsFound, nil
+		}
+
+		// Slide the window, keeping its overlap with the next one and the context before it
+		consumed := len(window) - overlap - streamWindowContext
+		if newlines := bytes.Count(window[:consumed], []byte("\n")); newlines > 0 {
+			lines += newlines
+			column = consumed - bytes.LastIndexByte(window[:consumed], '\n') - 1
+		} else {
+			column += consumed
+		}
+		windowStart += consumed
+		window = window[:copy(window, window[consumed:])]
+	}
+}
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+var (
+	everyMatchRegex = regexp.MustCompile(`SECRET[0-9]{4}`)
+	firstMatchRegex = regexp.MustCompile(`KEY_[a-z]{6}`)
+)
+
+// Matcher reporting every match of rule 1, and only the first match of rule 2 like hyperscan without multi-match
+func fakeMatcher(window []byte, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	var secrets []output.SecretFound
+	report := func(ruleID int, loc []int) {
+		start := loc[0] - 3
+		if start < 0 {
+			start = 0
+		}
+		secrets = append(secrets, output.SecretFound{RuleID: ruleID, PartToMatch: signature.ContentsPart,
+			PrintBufferStartIndex: start, MatchFromByte: loc[0] - start, MatchToByte: loc[1] - start,
+			MatchedContents: string(window[start:loc[1]])})
+		*numSecrets++
+	}
+	for _, loc := range everyMatchRegex.FindAllIndex(window, -1) {
+		report(1, loc)
+	}
+	for _, loc := range firstMatchRegex.FindAllIndex(window, -1) {
+		if _, exists := matchedRuleSet[2]; exists {
+			break
+		}
+		matchedRuleSet[2] = 1
+		report(2, loc)
+	}
+	return secrets, nil
+}
+
+func Test_ScanWindows(t *testing.T) {
+	var contents bytes.Buffer
+	for i := 0; i < 40; i++ {
+		contents.WriteString(strings.Repeat("filler ", i%5))
+		if i%3 == 0 {
+			contents.WriteString("\n\n")
+		}
+		contents.WriteString("token=SECRET" + strings.Repeat(string(rune('0'+i%10)), 4) + " ")
+		if i == 17 {
+			contents.WriteString("KEY_abcdef and KEY_ghijkl\n")
+		}
+	}
+	data := contents.Bytes()
+
+	numSecrets := uint(0)
+	expected, err := scanWindows(bytes.NewReader(data), len(data)+1, 16, 1000, &numSecrets, map[uint]uint{}, fakeMatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 41 || numSecrets != 41 {
+		t.Fatalf("expected 40 secrets of rule 1 and the first of rule 2, got %d (%d counted)", len(expected), numSecrets)
+	}
+
+	for _, windowSize := range []int{81, 97, 128, 256, 1000} {
+		numSecrets := uint(0)
+		matchedRuleSet := map[uint]uint{}
+		actual, err := scanWindows(bytes.NewReader(data), windowSize, 16, 1000, &numSecrets, matchedRuleSet, fakeMatcher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.SortSecrets(actual)
+		sorted := append([]output.SecretFound(nil), expected...)
+		output.SortSecrets(sorted)
+		if !reflect.DeepEqual(actual, sorted) {
+			t.Errorf("window size %d: secrets differ from the whole contents scan\nActual: %+v\nExpected: %+v",
+				windowSize, actual, sorted)
+		}
+		if numSecrets != 41 || matchedRuleSet[2] != 1 {
+			t.Errorf("window size %d: %d secrets counted, rule 2 matched %d times", windowSize, numSecrets, matchedRuleSet[2])
+		}
+	}
+}
+
+func Test_ScanWindowsMaxSecrets(t *testing.T) {
+	data := []byte(strings.Repeat("SECRET1234 padding padding\n", 100))
+	numSecrets := uint(0)
+	secrets, err := scanWindows(bytes.NewReader(data), 128, 16, 5, &numSecrets, map[uint]uint{}, fakeMatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) >= 100 || numSecrets < 5 {
+		t.Errorf("scan should stop once the max secrets are found, got %d secrets", len(secrets))
+	}
+}
@@ -0,0 +1,171 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+// Fields of docker and podman inspect read to find the root filesystem of a container, separated by |.
+// The merged dir is only set by the overlay graph drivers
+const containerInspectFormat = `{{.State.Pid}}|{{.State.Status}}|{{if .GraphDriver.Data}}{{index .GraphDriver.Data "MergedDir"}}{{end}}`
+
+// State of a container, read from its runtime to scan its root filesystem in place
+type containerState struct {
+	pid       int
+	status    string // e.g. running, paused or exited
+	mergedDir string // Merged dir of the overlay mount of the container, empty if unknown
+}
+
+// Parse the output of docker or podman inspect with containerInspectFormat
+// @parameters
+// out - Output of inspect
+// @returns
+// containerState - State of the container
+// Error - Errors if the output is not the one expected. Otherwise, returns nil
+func parseContainerInspect(out string) (containerState, error) {
+	fields := strings.Split(strings.TrimSpace(out), "|")
+	if len(fields) != 3 {
+		return containerState{}, fmt.Errorf("unexpected output of inspect: %q", out)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return containerState{}, fmt.Errorf("unexpected pid of container: %q", fields[0])
+	}
+	return containerState{pid: pid, status: strings.ToLower(fields[1]), mergedDir: fields[2]}, nil
+}
+
+// Parse the output of ctr task ls, the task of a containerd container has the ID of the container
+// @parameters
+// out - Output of ctr task ls
+// containerID - ID of the container
+// @returns
+// containerState - State of the container, without merged dir
+// Error - Errors if the container has no task. Otherwise, returns nil
+func parseContainerTasks(out string, containerID string) (containerState, error) {
+	// TASK PID STATUS
+	for _, line := range strings.Split(out, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != containerID {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return containerState{}, fmt.Errorf("unexpected pid of container %s: %q", containerID, fields[1])
+		}
+		return containerState{pid: pid, status: strings.ToLower(fields[2])}, nil
+	}
+	return containerState{}, fmt.Errorf("container %s has no running task", containerID)
+}
+
+// Read the state of a container from its runtime: containerd if a namespace is set, otherwise docker or podman
+// @parameters
+// containerID - ID of the container
+// namespace - containerd namespace of the container, empty for docker and podman
+// @returns
+// containerState - State of the container
+// Error - Errors if any. Otherwise, returns nil
+func inspectContainer(containerID string, namespace string) (containerState, error) {
+	if namespace != "" {
+		address := containerdAddress()
+		if err := checkContainerd(address); err != nil {
+			return containerState{}, err
+		}
+		stdout, stderr, exitCode := runCommand("ctr", "--address", address, "-n", namespace, "task", "ls")
+		if exitCode != 0 {
+			return containerState{}, fmt.Errorf("ctr task ls: %s", strings.TrimSpace(stderr))
+		}
+		return parseContainerTasks(stdout, containerID)
+	}
+
+	runtime, err := sessionRuntime()
+	if err != nil {
+		return containerState{}, err
+	}
+	stdout, stderr, exitCode := runCommand(runtime, "inspect", "--type", "container", "--format",
+		containerInspectFormat, containerID)
+	if exitCode != 0 {
+		return containerState{}, fmt.Errorf("%s inspect %s: %s", runtime, containerID, strings.TrimSpace(stderr))
+	}
+	return parseContainerInspect(stdout)
+}
+
+// Root filesystem of a container seen from SecretScanner: the merged dir of its overlay, or /proc/<pid>/root
+// which also shows its volumes. Both are only there while the container runs, paused or not
+// @parameters
+// containerID - ID of the container
+// state - State of the container
+// hostMountPath - Path the host is mounted at, empty if SecretScanner runs on the host
+// @returns
+// string - Directory to scan
+// Error - Errors if no root filesystem can be read. Otherwise, returns nil
+func containerRootfs(containerID string, state containerState, hostMountPath string) (string, error) {
+	if state.status != "running" && state.status != "paused" {
+		return "", fmt.Errorf("container %s is %s, only running or paused containers can be scanned in place",
+			containerID, state.status)
+	}
+
+	var candidates []string
+	if state.mergedDir != "" {
+		candidates = append(candidates, filepath.Join(hostMountPath, state.mergedDir))
+	}
+	if state.pid > 0 {
+		candidates = append(candidates, filepath.Join(hostMountPath, "/proc", strconv.Itoa(state.pid), "root"))
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("container %s is %s but its runtime reports neither its pid nor its overlay",
+			containerID, state.status)
+	}
+
+	var errs []string
+	denied := false
+	for _, dir := range candidates {
+		err := checkReadableDir(dir)
+		if err == nil {
+			return dir, nil
+		}
+		denied = denied || errors.Is(err, fs.ErrPermission)
+		errs = append(errs, err.Error())
+	}
+	if denied {
+		return "", fmt.Errorf("permission denied reading the root filesystem of %s container %s, run SecretScanner "+
+			"as root to scan it in place: %s", state.status, containerID, strings.Join(errs, "; "))
+	}
+	return "", fmt.Errorf("cannot read the root filesystem of %s container %s: %s", state.status, containerID,
+		strings.Join(errs, "; "))
+}
+
+// Checks that the entries of a directory can be listed
+func checkReadableDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	return nil
+}
+
+// Find the root filesystem of a running container, to scan it in place instead of exporting it
+// @parameters
+// containerID - ID of the container
+// namespace - containerd namespace of the container, empty for docker and podman
+// @returns
+// string - Directory to scan
+// Error - Errors if the container can't be scanned in place. Otherwise, returns nil
+func locateContainerRootfs(containerID string, namespace string) (string, error) {
+	state, err := inspectContainer(containerID, namespace)
+	if err != nil {
+		return "", err
+	}
+	return containerRootfs(containerID, state, *core.GetSession().Options.HostMountPath)
+}
@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParseContainerInspect(t *testing.T) {
+	state, err := parseContainerInspect("4242|running|/var/lib/docker/overlay2/abc/merged\n")
+	expected := containerState{pid: 4242, status: "running", mergedDir: "/var/lib/docker/overlay2/abc/merged"}
+	if err != nil || !reflect.DeepEqual(state, expected) {
+		t.Errorf("unexpected state %+v, %v", state, err)
+	}
+
+	// Graph drivers other than overlay have no merged dir
+	if state, err = parseContainerInspect("17|paused|\n"); err != nil || state.pid != 17 || state.mergedDir != "" {
+		t.Errorf("unexpected state %+v, %v", state, err)
+	}
+
+	for _, out := range []string{"", "running", "x|running|"} {
+		if _, err := parseContainerInspect(out); err == nil {
+			t.Errorf("output %q should be rejected", out)
+		}
+	}
+}
+
+func Test_ParseContainerTasks(t *testing.T) {
+	out := "TASK      PID     STATUS\nweb       3120    RUNNING\nworker    3188    PAUSED\n"
+	state, err := parseContainerTasks(out, "worker")
+	if err != nil || !reflect.DeepEqual(state, containerState{pid: 3188, status: "paused"}) {
+		t.Errorf("unexpected state %+v, %v", state, err)
+	}
+	if _, err = parseContainerTasks(out, "db"); err == nil {
+		t.Errorf("containers without task should be rejected")
+	}
+}
+
+func Test_ContainerRootfs(t *testing.T) {
+	host := t.TempDir()
+	merged := filepath.Join("var", "lib", "docker", "overlay2", "abc", "merged")
+	if err := os.MkdirAll(filepath.Join(host, merged, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The merged dir is found under the host mount path
+	rootfs, err := containerRootfs("abc", containerState{status: "paused", mergedDir: "/" + merged}, host)
+	if err != nil || rootfs != filepath.Join(host, merged) {
+		t.Errorf("unexpected rootfs %s, %v", rootfs, err)
+	}
+
+	// Stopped containers have no root filesystem mounted
+	_, err = containerRootfs("abc", containerState{status: "exited", mergedDir: "/" + merged}, host)
+	if err == nil || !strings.Contains(err.Error(), "is exited") {
+		t.Errorf("exited containers should not be scanned in place, got %v", err)
+	}
+
+	_, err = containerRootfs("abc", containerState{pid: 999999, status: "running"}, host)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(host, "proc", "999999", "root")) {
+		t.Errorf("missing root filesystem should be reported, got %v", err)
+	}
+}
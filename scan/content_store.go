@@ -0,0 +1,241 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Well known locations of the containerd content store, the second one is
+// used by docker when the containerd image store is enabled
+var contentStoreRoots = []string{
+	"/var/lib/containerd/io.containerd.content.v1.content",
+	"/var/lib/docker/containerd/daemon/io.containerd.content.v1.content",
+}
+
+const (
+	containerdDialTimeout = 5 * time.Second
+	defaultContentStoreNS = "default"
+	mediaTypeDockerList   = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Image manifest or image index, distinguished by which of the fields are set
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// Prepare the image for scanning directly from the content store: write a
+// docker save style manifest.json into the temp dir with the layers linked to
+// the compressed blobs, so nothing has to be saved or copied
+// @parameters
+// imageScan - Structure with details of the container image to scan
+// @returns
+// Error - Errors if the content store or the image is not accessible
+func (imageScan *ImageScan) loadFromContentStore() error {
+	root, err := findContentStore()
+	if err != nil {
+		return err
+	}
+
+	digest, err := resolveImageDigest(imageScan.imageName)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := readManifest(root, digest, imageScan.platform)
+	if err != nil {
+		return err
+	}
+
+	if err = writeLayoutManifest(root, manifest, imageScan.imageName, imageScan.tempDir); err != nil {
+		return err
+	}
+	log.Infof("Image %s read from content store %s", imageScan.imageName, root)
+	return nil
+}
+
+// Write a docker save style manifest.json of an image manifest into the temp dir,
+// with the layers linked to their blobs, compressed or not
+// @parameters
+// root - Directory with the blobs of the image, a content store or an OCI image layout
+// manifest - Manifest of the image
+// imageName - Name of the image, listed as its tag
+// tempDir - Directory of the image scan
+// @returns
+// Error - Errors if a blob is missing. Otherwise, returns nil
+func writeLayoutManifest(root string, manifest ociManifest, imageName string, tempDir string) error {
+	item := manifestItem{
+		Config:   digestHex(manifest.Config.Digest) + ".json",
+		RepoTags: []string{imageName},
+	}
+	for _, layer := range manifest.Layers {
+		blob, err := blobPath(root, layer.Digest)
+		if err != nil {
+			return err
+		}
+		layerDir := filepath.Join(tempDir, digestHex(layer.Digest))
+		if err = os.MkdirAll(layerDir, 0755); err != nil {
+			return err
+		}
+		if err = os.Symlink(blob, filepath.Join(layerDir, "layer.tar")); err != nil {
+			return err
+		}
+		item.Layers = append(item.Layers, digestHex(layer.Digest)+"/layer.tar")
+	}
+
+	data, err := json.Marshal([]manifestItem{item})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(tempDir, "manifest.json"), data, 0600)
+}
+
+func findContentStore() (string, error) {
+	session := core.GetSession()
+	roots := contentStoreRoots
+	if *session.Options.ContentStorePath != "" {
+		roots = []string{*session.Options.ContentStorePath}
+	}
+
+	for _, root := range roots {
+		if *session.Options.HostMountPath != "" {
+			root = filepath.Join(*session.Options.HostMountPath, root)
+		}
+		if core.PathExists(filepath.Join(root, "blobs")) {
+			return root, nil
+		}
+	}
+	return "", errors.New("content store not accessible")
+}
+
+// Digest of the image manifest (or index) from a reference. References pinned
+// by digest are used as is, tags are looked up in containerd
+func resolveImageDigest(imageName string) (string, error) {
+	if strings.HasPrefix(imageName, "sha256:") {
+		return imageName, nil
+	}
+	if i := strings.LastIndex(imageName, "@"); i >= 0 {
+		return imageName[i+1:], nil
+	}
+
+	ns := *core.GetSession().Options.ContainerNS
+	if ns == "" {
+		ns = defaultContentStoreNS
+	}
+	address := containerdAddress()
+	if err := checkContainerd(address); err != nil {
+		return "", err
+	}
+	stdout, stderr, exitCode := runCommand("ctr", "--address", address, "-n", ns, "images", "ls", "name=="+imageName)
+	if exitCode != 0 {
+		return "", fmt.Errorf("could not resolve %s: %s", imageName, stderr)
+	}
+	// REF TYPE DIGEST SIZE PLATFORMS LABELS
+	for _, line := range strings.Split(stdout, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == imageName {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("image %s not found in namespace %s", imageName, ns)
+}
+
+// Socket of containerd, under the host mount path when running in a container
+func containerdAddress() string {
+	session := core.GetSession()
+	address := *session.Options.ContainerdAddress
+	if *session.Options.HostMountPath != "" {
+		address = filepath.Join(*session.Options.HostMountPath, address)
+	}
+	return address
+}
+
+// Check that containerd listens on its socket, so that tags can be resolved
+// @parameters
+// address - Path of the containerd socket
+// @returns
+// Error - Errors if the socket is not reachable. Otherwise, returns nil
+func checkContainerd(address string) error {
+	conn, err := net.DialTimeout("unix", address, containerdDialTimeout)
+	if err != nil {
+		return fmt.Errorf("containerd socket %s is not reachable, set --containerd-address: %w", address, err)
+	}
+	return conn.Close()
+}
+
+// Read the image manifest for the digest, resolving an index to the manifest
+// of the platform requested, or of the host platform if nil
+func readManifest(root string, digest string, platform *ociPlatform) (ociManifest, error) {
+	var manifest ociManifest
+	path, err := blobPath(root, digest)
+	if err != nil {
+		return manifest, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return manifest, err
+	}
+
+	if !isManifestIndex(manifest) {
+		return manifest, nil
+	}
+	desc, err := platformManifest(manifest, platform)
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %w", digest, err)
+	}
+	return readManifest(root, desc.Digest, platform)
+}
+
+// Indicates if the manifest is an index of the manifests of several platforms
+func isManifestIndex(manifest ociManifest) bool {
+	return manifest.MediaType == mediaTypeDockerList || manifest.MediaType == mediaTypeOCIIndex || len(manifest.Manifests) > 0
+}
+
+func blobPath(root string, digest string) (string, error) {
+	algo, hex, found := strings.Cut(digest, ":")
+	if !found || algo == "" || hex == "" || strings.ContainsAny(hex, "/.") {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	path := filepath.Join(root, "blobs", algo, hex)
+	if !core.PathExists(path) {
+		return "", fmt.Errorf("blob %s not found in %s", digest, root)
+	}
+	return path, nil
+}
+
+func digestHex(digest string) string {
+	_, hex, found := strings.Cut(digest, ":")
+	if !found {
+		return digest
+	}
+	return hex
+}
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_CheckContainerd(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, t.TempDir() may be longer
+	dir, err := os.MkdirTemp("", "ctrd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	address := filepath.Join(dir, "containerd.sock")
+
+	err = checkContainerd(address)
+	if err == nil || !strings.Contains(err.Error(), "--containerd-address") {
+		t.Errorf("missing socket should be reported with the option to set, got %v", err)
+	}
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := checkContainerd(address); err != nil {
+		t.Errorf("listening socket should be reachable: %s", err)
+	}
+}
+
+// Write a blob of a content store, returning its digest
+func writeTestBlob(t *testing.T, root string, data []byte) string {
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	writeTestFile(t, filepath.Join(root, "blobs", "sha256"), digestHex(digest), data)
+	return digest
+}
+
+func Test_LoadFromContentStore(t *testing.T) {
+	options := testSession(t).Options
+	defer func(contentStorePath, hostMountPath string) {
+		*options.ContentStorePath, *options.HostMountPath = contentStorePath, hostMountPath
+	}(*options.ContentStorePath, *options.HostMountPath)
+	root := t.TempDir()
+	*options.ContentStorePath, *options.HostMountPath = root, ""
+	if err := os.MkdirAll(filepath.Join(root, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := writeTestBlob(t, root, []byte(`{"architecture": "amd64", "os": "linux"}`))
+	layer := writeTestBlob(t, root, []byte("layer"))
+	manifest, err := json.Marshal(ociManifest{MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Config: ociDescriptor{Digest: config}, Layers: []ociDescriptor{{Digest: layer}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pinned by digest, the image is not looked up in containerd
+	imageName := "app@" + writeTestBlob(t, root, manifest)
+
+	imageScan := ImageScan{imageName: imageName, tempDir: t.TempDir()}
+	if err := imageScan.loadFromContentStore(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(imageScan.tempDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []manifestItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		t.Fatal(err)
+	}
+	expected := []manifestItem{{Config: digestHex(config) + ".json", RepoTags: []string{imageName},
+		Layers: []string{digestHex(layer) + "/layer.tar"}}}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("manifest %+v, want %+v", items, expected)
+	}
+	// The layer is read from its blob, not copied
+	target, err := os.Readlink(filepath.Join(imageScan.tempDir, digestHex(layer), "layer.tar"))
+	if err != nil || target != filepath.Join(root, "blobs", "sha256", digestHex(layer)) {
+		t.Errorf("layer linked to %q (%v), want its blob", target, err)
+	}
+
+	// A missing blob fails the load, the image is saved instead
+	if err := os.Remove(filepath.Join(root, "blobs", "sha256", digestHex(layer))); err != nil {
+		t.Fatal(err)
+	}
+	imageScan.tempDir = t.TempDir()
+	err = imageScan.loadFromContentStore()
+	if err == nil || !strings.Contains(err.Error(), "blob "+layer+" not found") {
+		t.Errorf("missing layer blob should be reported, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Coverage collects which files a scan looked at, for --coverage-report.
+// It is nil, and recording is a no-op, unless EnableCoverage was called
+var Coverage *CoverageReport
+
+type CoverageEntry struct {
+	Path    string `json:"Path"`
+	Layer   string `json:"Layer,omitempty"`
+	Reason  string `json:"Reason,omitempty"`
+	Error   string `json:"Error,omitempty"`
+	Secrets int    `json:"Secrets,omitempty"`
+}
+
+type CoverageSummary struct {
+	FilesScanned int `json:"Files Scanned"`
+	FilesSkipped int `json:"Files Skipped"`
+	FilesErrored int `json:"Files Errored"`
+	SecretsFound int `json:"Secrets Found"`
+}
+
+type CoverageReport struct {
+	sync.Mutex
+	Scanned []CoverageEntry `json:"Scanned"`
+	Skipped []CoverageEntry `json:"Skipped"`
+	Errored []CoverageEntry `json:"Errored"`
+	Summary CoverageSummary `json:"Summary"`
+}
+
+func EnableCoverage() {
+	Coverage = &CoverageReport{
+		Scanned: []CoverageEntry{},
+		Skipped: []CoverageEntry{},
+		Errored: []CoverageEntry{},
+	}
+}
+
+// Record a file which was scanned along with the number of secrets found in it
+func (c *CoverageReport) AddScanned(path, layer string, secrets int) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.Scanned = append(c.Scanned, CoverageEntry{Path: path, Layer: layer, Secrets: secrets})
+	c.Summary.FilesScanned++
+	c.Summary.SecretsFound += secrets
+}
+
+// Record a file or dir which was not scanned
+func (c *CoverageReport) AddSkipped(path, layer, reason string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.Skipped = append(c.Skipped, CoverageEntry{Path: path, Layer: layer, Reason: reason})
+	c.Summary.FilesSkipped++
+}
+
+// Record a file which could not be scanned completely, secrets may still have
+// been found from its name
+func (c *CoverageReport) AddErrored(path, layer string, err error, secrets int) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.Errored = append(c.Errored, CoverageEntry{Path: path, Layer: layer, Error: err.Error(), Secrets: secrets})
+	c.Summary.FilesErrored++
+	c.Summary.SecretsFound += secrets
+}
+
+// Write the report as JSON to the given path
+func (c *CoverageReport) Write(path string) error {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_CoverageDisabled(t *testing.T) {
+	// Recording without --coverage-report is a no-op
+	var coverage *CoverageReport
+	coverage.AddScanned("a.txt", "", 1)
+	coverage.AddSkipped("b.bin", "", skipBlacklistedExt)
+	coverage.AddErrored("c.txt", "", errors.New("permission denied"), 0)
+	path := filepath.Join(t.TempDir(), "coverage.json")
+	if err := coverage.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("coverage report written while disabled: %v", err)
+	}
+}
+
+func Test_CoverageReport(t *testing.T) {
+	defer func(coverage *CoverageReport) { Coverage = coverage }(Coverage)
+	EnableCoverage()
+	Coverage.AddScanned("a.txt", "layer1", 2)
+	Coverage.AddSkipped("b.bin", "", skipBlacklistedExt)
+	Coverage.AddErrored("c.txt", "", errors.New("permission denied"), 1)
+
+	path := filepath.Join(t.TempDir(), "reports", "coverage.json")
+	if err := Coverage.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report CoverageReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	expected := CoverageReport{
+		Scanned: []CoverageEntry{{Path: "a.txt", Layer: "layer1", Secrets: 2}},
+		Skipped: []CoverageEntry{{Path: "b.bin", Reason: skipBlacklistedExt}},
+		Errored: []CoverageEntry{{Path: "c.txt", Error: "permission denied", Secrets: 1}},
+		// The secrets found in the errored files count towards the summary
+		Summary: CoverageSummary{FilesScanned: 1, FilesSkipped: 1, FilesErrored: 1, SecretsFound: 3},
+	}
+	if !reflect.DeepEqual(report.Scanned, expected.Scanned) || !reflect.DeepEqual(report.Skipped, expected.Skipped) ||
+		!reflect.DeepEqual(report.Errored, expected.Errored) || report.Summary != expected.Summary {
+		t.Errorf("coverage report %+v, want %+v", &report, &expected)
+	}
+}
@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_ScanEnvFiles(t *testing.T) {
+	session := testSession(t)
+	options := session.Options
+	defer func(scanEnvFiles bool) { *options.ScanEnvFiles = scanEnvFiles }(*options.ScanEnvFiles)
+
+	contents := "LOG_LEVEL=debug\n" +
+		"ENCRYPTION_SEED=J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n" +
+		"export DB_PASSWORD=\"hunter2\"\n" +
+		"API_URL=https://example.com\n" +
+		"BUILD_SALT=9f3b7c1e5a0d8246bf19c3e7a5d02f6b8e4c1a97\n" +
+		"SESSION_KEY=${VAULT_SESSION_KEY}\n"
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".env.production", []byte(contents))
+
+	defer signature.SelectRules(0, nil, nil)
+	for _, test := range []struct {
+		scanEnvFiles bool
+		disable      []string
+	}{{false, nil}, {true, nil}, {true, []string{strconv.Itoa(signature.EnvFileRuleID)}}} {
+		*options.ScanEnvFiles = test.scanEnvFiles
+		signature.SelectRules(len(session.Config.Signatures), nil, test.disable)
+		numSecrets := uint(0)
+		secrets, err := scanFile(testSession(t).Context, filepath.Join(dir, ".env.production"), ".env.production",
+			".env.production", ".production", "", &numSecrets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := map[string]string{}
+		for _, secret := range secrets {
+			found[secret.KeyPath] = secret.RuleName
+			if secret.RuleID == signature.EnvFileRuleID && secret.LineNumber != 3 && secret.LineNumber != 5 {
+				t.Errorf("%s found at line %d", secret.KeyPath, secret.LineNumber)
+			}
+		}
+		// The first high entropy string is reported by its own rule, with the variable name
+		expected := map[string]string{"": signature.GenericHighEntropyRuleName}
+		if test.scanEnvFiles && test.disable == nil {
+			expected = map[string]string{"ENCRYPTION_SEED": signature.GenericHighEntropyRuleName,
+				"DB_PASSWORD": signature.EnvFileRuleName, "BUILD_SALT": signature.EnvFileRuleName}
+		} else if test.scanEnvFiles {
+			// The secrets found by other rules still get the variable name with the rule disabled
+			expected = map[string]string{"ENCRYPTION_SEED": signature.GenericHighEntropyRuleName}
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("secrets found with --scan-env-files %v, rules %v disabled: %v, want %v", test.scanEnvFiles,
+				test.disable, found, expected)
+		}
+		if int(numSecrets) != len(secrets) {
+			t.Errorf("%d secrets counted, %d found", numSecrets, len(secrets))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package scan
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScanSecretsInFile Scans a single file, without walking its directory. Like in directory scans, files larger
+// than --maximum-file-size or with a skipped extension are not scanned, unless forced for the extension
+// @parameters
+// path - Path of the file, the secrets are reported in this path
+// forceExtension - Scan the file even if its extension is blacklisted or not in --include-extensions
+// @returns
+// []output.SecretFound - List of all secrets found
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInFile(path string, forceExtension bool) ([]output.SecretFound, error) {
+	session := core.GetSession()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := core.NewMatchFile(path)
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+	reason := skipEntry(path, fs.FileInfoToDirEntry(info), "", "", maxFileSize)
+	if reason == skipBlacklistedExt && forceExtension {
+		reason = ""
+	}
+	if reason != "" {
+		log.Warnf("scanSecretsInFile: %s not scanned: %s", path, reason)
+		Coverage.AddSkipped(file.Path, "", reason)
+		return nil, nil
+	}
+
+	numSecrets := uint(0)
+	Stats.AddFile()
+	secrets, err := scanFile(session.Context, path, file.Path, file.Filename, file.Extension, "", &numSecrets)
+	if err != nil {
+		Coverage.AddErrored(file.Path, "", err, len(secrets))
+		return secrets, err
+	}
+	secrets = append(secrets, allowed(signature.MatchSimpleSignatures(session.Context, file.Path, file.Filename,
+		file.Extension, "", &numSecrets), &numSecrets, nil)...)
+	Coverage.AddScanned(file.Path, "", len(secrets))
+	return secrets, nil
+}
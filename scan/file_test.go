@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ScanSecretsInFile(t *testing.T) {
+	options := testSession(t).Options
+	defer func(maximumFileSize uint) { *options.MaximumFileSize = maximumFileSize }(*options.MaximumFileSize)
+	defer func(coverage *CoverageReport) { Coverage = coverage }(Coverage)
+	*options.MaximumFileSize = 1
+
+	dir := t.TempDir()
+	settings := writeTestFile(t, dir, "settings.conf", []byte(testLayerSecret))
+	large := writeTestFile(t, dir, "large.conf", []byte(strings.Repeat("#\n", 1024)+testLayerSecret))
+	// .exe is a blacklisted extension of the config
+	tool := writeTestFile(t, dir, "tool.exe", []byte(testLayerSecret))
+
+	for _, test := range []struct {
+		path           string
+		forceExtension bool
+		secrets        int
+		skipped        string
+	}{
+		{settings, false, 1, ""},
+		{large, false, 0, skipMaxFileSize},
+		{tool, false, 0, skipBlacklistedExt},
+		// Forced for its extension only, still larger files are not scanned
+		{tool, true, 1, ""},
+		{large, true, 0, skipMaxFileSize},
+	} {
+		EnableCoverage()
+		secrets, err := ScanSecretsInFile(test.path, test.forceExtension)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(test.path)
+		if len(secrets) != test.secrets {
+			t.Errorf("%s (forced %v): %d secrets found, want %d", name, test.forceExtension, len(secrets),
+				test.secrets)
+		}
+		for _, secret := range secrets {
+			if secret.CompleteFilename != test.path {
+				t.Errorf("%s: secret reported in %s", name, secret.CompleteFilename)
+			}
+		}
+		skipped := ""
+		if len(Coverage.Skipped) == 1 {
+			skipped = Coverage.Skipped[0].Reason
+		}
+		if skipped != test.skipped {
+			t.Errorf("%s (forced %v): skipped for %q, want %q", name, test.forceExtension, skipped, test.skipped)
+		}
+	}
+}
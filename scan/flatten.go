@@ -0,0 +1,157 @@
+package scan
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Dir of ExtractedImageFilesDir where the layers are overlaid by --flatten
+const flattenedRootfsDir = "rootfs"
+
+// Root filesystem of an image, built by overlaying its layers in order
+type flattenedRootfs struct {
+	root   string         // Absolute path of the merged dir
+	owners map[string]int // Path relative to root -> index of the top-most layer which wrote it
+}
+
+// Overlay the layers of an image in order into a single dir, applying their whiteouts
+// @parameters
+// imageManifestPath - Complete path of directory where manifest of image has been extracted
+// layerPaths - Paths of the layer tarballs relative to imageManifestPath, from the lowest layer
+// root - Dir where the layers are merged
+// budget - Budget of the extraction of the image
+// @returns
+// *flattenedRootfs - The merged root filesystem
+// Error - Errors if any. Otherwise, returns nil
+func flattenLayers(imageManifestPath string, layerPaths []string, root string,
+	budget *extractBudget) (*flattenedRootfs, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(absRoot, 0755); err != nil {
+		return nil, err
+	}
+
+	rootfs := &flattenedRootfs{root: absRoot, owners: map[string]int{}}
+	for i, layerPath := range layerPaths {
+		completeLayerPath := path.Join(imageManifestPath, layerPath)
+		err = untarFiltered(completeLayerPath, absRoot, budget, rootfs.overlay(i))
+		if errors.Is(err, extractBudgetExceeded) {
+			return nil, fmt.Errorf("layer %s: %w", layerPath, err)
+		}
+		if err != nil {
+			log.Errorf("flattenLayers: Unable to extract image layer %s: %s", layerPath, err)
+			// Don't stop. Print error and continue with the other layers
+		}
+	}
+	return rootfs, nil
+}
+
+// Filter of untarFiltered applying the whiteouts of a layer and recording the layer of the other entries
+func (rootfs *flattenedRootfs) overlay(layer int) func(hdr *tar.Header, name string) (bool, error) {
+	return func(hdr *tar.Header, name string) (bool, error) {
+		dir, base := path.Split(name)
+		parent, err := resolveInRoot(rootfs.root, dir)
+		if err != nil {
+			return false, err
+		}
+		target := filepath.Join(parent, base)
+		rel, err := filepath.Rel(rootfs.root, target)
+		if err != nil {
+			return false, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case base == whiteoutOpaque:
+			rootfs.removeLower(path.Dir(rel), layer)
+			return false, nil
+		case strings.HasPrefix(base, whiteoutPrefix):
+			rootfs.remove(path.Join(path.Dir(rel), strings.TrimPrefix(base, whiteoutPrefix)))
+			return false, nil
+		}
+
+		// A dir replacing a file of a lower layer or the reverse, the dir may only be implied by the entry
+		for p := path.Dir(rel); p != "."; p = path.Dir(p) {
+			if info, err := os.Lstat(filepath.Join(rootfs.root, filepath.FromSlash(p))); err == nil && info.Mode().IsRegular() {
+				rootfs.remove(p)
+			}
+		}
+		if info, err := os.Lstat(target); err == nil {
+			if hdr.Typeflag == tar.TypeDir && !info.IsDir() {
+				rootfs.remove(rel)
+			} else if hdr.Typeflag != tar.TypeDir && info.IsDir() {
+				rootfs.remove(rel)
+			}
+		}
+		rootfs.owners[rel] = layer
+		return true, nil
+	}
+}
+
+// Remove a path deleted by a whiteout, with everything below it
+func (rootfs *flattenedRootfs) remove(rel string) {
+	if rel == "." || rel == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(rootfs.root, filepath.FromSlash(rel))); err != nil {
+		log.Warnf("flattenLayers: Unable to apply whiteout of %s: %s", rel, err)
+	}
+	for name := range rootfs.owners {
+		if name == rel || strings.HasPrefix(name, rel+"/") {
+			delete(rootfs.owners, name)
+		}
+	}
+}
+
+// Remove the contents of a dir written by the layers below an opaque whiteout of the layer
+// @returns
+// bool - true if nothing of the layer is left in the dir
+func (rootfs *flattenedRootfs) removeLower(rel string, layer int) bool {
+	if rel == "." {
+		rel = ""
+	}
+	entries, err := os.ReadDir(filepath.Join(rootfs.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return true
+	}
+	empty := true
+	for _, entry := range entries {
+		child := path.Join(rel, entry.Name())
+		owner, ok := rootfs.owners[child]
+		lower := !ok || owner < layer
+		if entry.IsDir() && !rootfs.removeLower(child, layer) {
+			empty = false
+			continue
+		}
+		if lower {
+			rootfs.remove(child)
+		} else {
+			empty = false
+		}
+	}
+	return empty
+}
+
+// ID of the top-most layer providing a file of the merged dir
+// @parameters
+// name - Path of the file relative to the merged dir, as reported in the secrets
+// layerIDs - IDs of the layers, from the lowest layer
+// @returns
+// string - Layer ID, empty if unknown
+func (rootfs *flattenedRootfs) layerOf(name string, layerIDs []string) string {
+	// Secrets found inside an archive are in the file of the archive
+	name, _, _ = strings.Cut(name, archivePathSeparator)
+	if layer, ok := rootfs.owners[layerPath(name)]; ok && layer < len(layerIDs) {
+		return layerIDs[layer]
+	}
+	return ""
+}
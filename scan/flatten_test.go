@@ -0,0 +1,73 @@
+package scan
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FlattenLayers(t *testing.T) {
+	dir := t.TempDir()
+	layers := []string{"0", "1", "2"}
+	for i, headers := range [][]tar.Header{
+		{
+			{Name: "app/.env", Typeflag: tar.TypeReg, Linkname: "TOKEN=lower"},
+			{Name: "app/keep.txt", Typeflag: tar.TypeReg, Linkname: "kept"},
+			{Name: "opt/cache/old.txt", Typeflag: tar.TypeReg, Linkname: "hidden"},
+			{Name: "etc/ssh/id_rsa", Typeflag: tar.TypeReg, Linkname: "lower key"},
+			{Name: "srv", Typeflag: tar.TypeReg, Linkname: "file replaced by a dir"},
+		},
+		{
+			{Name: "opt/cache/new.txt", Typeflag: tar.TypeReg, Linkname: "new"},
+			{Name: "app/.wh..env", Typeflag: tar.TypeReg},
+			{Name: "opt/cache/.wh..wh..opq", Typeflag: tar.TypeReg},
+			{Name: "etc/ssh/id_rsa", Typeflag: tar.TypeReg, Linkname: "upper key"},
+			{Name: "srv/config", Typeflag: tar.TypeReg, Linkname: "in dir"},
+		},
+		{
+			{Name: "bin", Typeflag: tar.TypeSymlink, Linkname: "usr/bin"},
+			{Name: "bin/tool", Typeflag: tar.TypeReg, Linkname: "tool"},
+		},
+	} {
+		layerDir := filepath.Join(dir, layers[i])
+		if err := os.Mkdir(layerDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		layerTar(t, layerDir, headers...)
+	}
+
+	root := filepath.Join(dir, "rootfs")
+	rootfs, err := flattenLayers(dir, []string{"0/layer.tar", "1/layer.tar", "2/layer.tar"}, root,
+		newExtractBudget(1<<20, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, deleted := range []string{"app/.env", "opt/cache/old.txt", "app/.wh..env", "opt/cache/.wh..wh..opq"} {
+		if _, err := os.Lstat(filepath.Join(root, deleted)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be in the flattened rootfs", deleted)
+		}
+	}
+	assertExtracted(t, filepath.Join(root, "app", "keep.txt"), "kept")
+	assertExtracted(t, filepath.Join(root, "opt", "cache", "new.txt"), "new")
+	assertExtracted(t, filepath.Join(root, "etc", "ssh", "id_rsa"), "upper key")
+	assertExtracted(t, filepath.Join(root, "srv", "config"), "in dir")
+	assertExtracted(t, filepath.Join(root, "usr", "bin", "tool"), "tool")
+
+	layerIDs := []string{"sha-0", "sha-1", "sha-2"}
+	for name, expected := range map[string]string{
+		"app/keep.txt":                  "sha-0",
+		"opt/cache/new.txt":             "sha-1",
+		"etc/ssh/id_rsa":                "sha-1",
+		"usr/bin/tool":                  "sha-2",
+		"/app/keep.txt":                 "sha-0",
+		"srv/config":                    "sha-1",
+		"etc/ssh/id_rsa.tgz!/inner.txt": "",
+		"missing":                       "",
+	} {
+		if actual := rootfs.layerOf(name, layerIDs); actual != expected {
+			t.Errorf("layerOf(%q) = %q, want %q", name, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// Objects listed per page
+const gcsPageSize = 1000
+
+// Objects of a GCS bucket under a prefix, read with the Cloud Storage client. Unlike S3, GCS reads the objects of
+// every storage class, archive included
+type gcsBucket struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// Bucket and prefix of a gs://bucket/prefix URL
+func parseGCSURL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "gs" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("--gcs must be a gs://bucket/prefix URL, got %q", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// CheckGCS Check the value of --gcs
+// @parameters
+// rawURL - gs://bucket/prefix URL to scan
+// @returns
+// Error - Errors if the URL is not a gs:// URL
+func CheckGCS(rawURL string) error {
+	_, _, err := parseGCSURL(rawURL)
+	return err
+}
+
+func (b *gcsBucket) list(ctx context.Context, token string) ([]storeObject, string, error) {
+	query := &storage.Query{Prefix: b.prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size"}); err != nil {
+		return nil, "", err
+	}
+	var page []*storage.ObjectAttrs
+	next, err := iterator.NewPager(b.client.Bucket(b.bucket).Objects(ctx, query), gcsPageSize, token).NextPage(&page)
+	if err != nil {
+		return nil, "", fmt.Errorf("list gs://%s/%s: %w", b.bucket, b.prefix, err)
+	}
+	objects := make([]storeObject, 0, len(page))
+	for _, object := range page {
+		objects = append(objects, storeObject{Key: object.Name, Size: object.Size})
+	}
+	return objects, next, nil
+}
+
+func (b *gcsBucket) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.client.Bucket(b.bucket).Object(key).NewReader(ctx)
+}
+
+func (b *gcsBucket) url(key string) string {
+	return "gs://" + b.bucket + "/" + key
+}
+
+// newGCSClient Create a client of Cloud Storage with the application default credentials of Google, or without
+// credentials for the emulator of STORAGE_EMULATOR_HOST. The requests, token requests included, go through --proxy
+// or the proxy of the environment
+// @parameters
+// ctx - Context of the token requests
+// @returns
+// *storage.Client - Client of Cloud Storage
+// Error - Errors if no credentials are found
+func newGCSClient(ctx context.Context) (*storage.Client, error) {
+	client := objectStoreClient()
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		return storage.NewClient(ctx, option.WithHTTPClient(client))
+	}
+	credentials, err := google.FindDefaultCredentials(context.WithValue(ctx, oauth2.HTTPClient, client),
+		storage.ScopeReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewClient(ctx, option.WithHTTPClient(&http.Client{
+		Transport: &oauth2.Transport{Source: credentials.TokenSource, Base: client.Transport}}))
+}
+
+// ScanSecretsInGCS Scans the objects of a GCS bucket under a prefix, with the application default credentials of
+// Google. Objects are read from GCS as they are matched, without being written to disk, and the ones larger than
+// their maximum file size are skipped
+// @parameters
+// rawURL - gs://bucket/prefix URL of the objects to scan
+// @returns
+// []output.SecretFound - List of all secrets found, in the names of their objects
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInGCS(rawURL string) ([]output.SecretFound, error) {
+	bucket, prefix, err := parseGCSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx := core.GetSession().Context
+	client, err := newGCSClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return scanObjectStore(ctx, &gcsBucket{client: client, bucket: bucket, prefix: prefix})
+}
@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+// Fake GCS serving the objects of a bucket with pages of pageSize objects
+func fakeGCS(t *testing.T, bucket string, contents map[string]string, pageSize int) *httptest.Server {
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"code": 401, "message": "Invalid Credentials"}}`))
+			return
+		}
+		objectsPath := "/storage/v1/b/" + bucket + "/o"
+		if r.URL.EscapedPath() == objectsPath {
+			var items []map[string]string
+			next := ""
+			for _, name := range names {
+				if !strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					continue
+				}
+				if token := r.URL.Query().Get("pageToken"); token != "" && name <= token {
+					continue
+				}
+				if len(items) == pageSize {
+					next = items[pageSize-1]["name"]
+					break
+				}
+				items = append(items, map[string]string{"name": name, "size": strconv.Itoa(len(contents[name]))})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "nextPageToken": next})
+			return
+		}
+		// Objects are read with the XML API
+		name := strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")
+		body, ok := contents[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": 404, "message": "No such object: ` + bucket + "/" + name + `"}}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func Test_ScanGCS(t *testing.T) {
+	session := testSession(t)
+	server := fakeGCS(t, "secrets-bucket", map[string]string{
+		"app/config/app settings.properties": testLayerSecret,
+		"app/config/empty.txt":               "",
+		"app/deploy/prod.properties":         testLayerSecret,
+		"app/deploy/readme.txt":              "nothing to see",
+		"other/app.properties":               testLayerSecret,
+	}, 2)
+	defer server.Close()
+
+	newClient := func(token string) *storage.Client {
+		client, err := storage.NewClient(session.Context, option.WithEndpoint(server.URL+"/storage/v1/"),
+			option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	store := &gcsBucket{client: newClient("ya29.token"), bucket: "secrets-bucket", prefix: "app/"}
+	secrets, err := scanObjectStore(session.Context, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, secret := range secrets {
+		names = append(names, secret.CompleteFilename)
+	}
+	expected := []string{"app/config/app settings.properties", "app/deploy/prod.properties"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("secrets found in %v, want %v", names, expected)
+	}
+
+	// Wrong credentials fail the listing
+	store.client = newClient("expired")
+	if _, err = scanObjectStore(session.Context, store); err == nil || !strings.Contains(err.Error(), "Invalid Credentials") {
+		t.Errorf("listing with wrong credentials: %v", err)
+	}
+}
+
+func Test_CheckGCS(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		valid  bool
+		bucket string
+		prefix string
+	}{
+		{"gs://bucket/app/config", true, "bucket", "app/config"},
+		{"gs://bucket", true, "bucket", ""},
+		{"s3://bucket/app", false, "", ""},
+		{"gs:///app", false, "", ""},
+		{"gs://bucket/app?generation=1", false, "", ""},
+	} {
+		if err := CheckGCS(test.url); (err == nil) != test.valid {
+			t.Errorf("CheckGCS(%s) = %v, want valid %v", test.url, err, test.valid)
+		}
+		if bucket, prefix, _ := parseGCSURL(test.url); test.valid && (bucket != test.bucket || prefix != test.prefix) {
+			t.Errorf("bucket %q and prefix %q of %s", bucket, prefix, test.url)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Relative dates of --since, e.g. 6 months ago
+var relativeDateRegex = regexp.MustCompile(`^(\d+)\s*(second|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// A blob added or modified by a commit
+type historyBlob struct {
+	Hash plumbing.Hash
+	Path string
+}
+
+// ParseSince Parse the date of --since: an RFC 3339 date and time, a YYYY-MM-DD date, or a relative date such as
+// "6 months ago"
+// @parameters
+// since - Date of --since
+// now - Time the relative dates are relative to
+// @returns
+// time.Time - Date parsed
+// Error - Errors if the date is not in one of these formats
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	since = strings.TrimSpace(since)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if date, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	match := relativeDateRegex.FindStringSubmatch(strings.ToLower(since))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("--since must be a date such as 2024-01-31 or \"6 months ago\", got %q", since)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch match[2] {
+	case "year":
+		return now.AddDate(-n, 0, 0), nil
+	case "month":
+		return now.AddDate(0, -n, 0), nil
+	case "week":
+		return now.AddDate(0, 0, -7*n), nil
+	case "day":
+		return now.AddDate(0, 0, -n), nil
+	}
+	unit := map[string]time.Duration{"hour": time.Hour, "minute": time.Minute, "second": time.Second}[match[2]]
+	return now.Add(-time.Duration(n) * unit), nil
+}
+
+// Blobs of the regular files added or modified by a commit, compared to its parent. Like git log, merge commits
+// are not compared to their parents
+func commitBlobs(commit *object.Commit) ([]historyBlob, error) {
+	if commit.NumParents() > 1 {
+		return nil, nil
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() == 1 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+	var blobs []historyBlob
+	for _, change := range changes {
+		// Deleted files, symlinks and submodules
+		entry := change.To.TreeEntry
+		if change.To.Name == "" || (entry.Mode != filemode.Regular && entry.Mode != filemode.Executable &&
+			entry.Mode != filemode.Deprecated) {
+			continue
+		}
+		blobs = append(blobs, historyBlob{Hash: entry.Hash, Path: change.To.Name})
+	}
+	return blobs, nil
+}
+
+// Contents of a blob, nil if it is larger than maxSize bytes
+func readBlob(repo *git.Repository, hash plumbing.Hash, maxSize int64) ([]byte, error) {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Size > maxSize {
+		return nil, nil
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// Set the commit a secret is reported with
+func setCommit(secret *output.SecretFound, commit *object.Commit) {
+	secret.Commit = commit.Hash.String()
+	secret.CommitAuthor = commit.Author.Name + " <" + commit.Author.Email + ">"
+	secret.CommitDate = commit.Author.When.Format(time.RFC3339)
+}
+
+// ScanGitHistory Scans the files added or modified by every commit of a git repository, on all its branches and
+// tags. The commits are read one by one with go-git, from the most recent one, and each blob is scanned once. A
+// secret kept unchanged by later commits is only reported once, with the oldest commit adding it, the commit
+// introducing it
+// @parameters
+// repoDir - Directory inside the git repository
+// since - Only scan the commits more recent than this date, in a format of ParseSince. All commits if empty
+// @returns
+// []output.SecretFound - List of all secrets found, with the commit introducing them
+// Error - Errors if any. Otherwise, returns nil
+func ScanGitHistory(repoDir string, since string) ([]output.SecretFound, error) {
+	logOptions := &git.LogOptions{All: true, Order: git.LogOrderCommitterTime}
+	if since != "" {
+		date, err := ParseSince(since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		logOptions.Since = &date
+	}
+	repo, err := git.PlainOpenWithOptions(repoDir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", repoDir, err)
+	}
+	commits, err := repo.Log(logOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	session := core.GetSession()
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+	var secretsFound []output.SecretFound
+	// Secrets of each blob scanned, and secret reported for each rule, path and value, by index in secretsFound
+	scannedBlobs := map[plumbing.Hash][]int{}
+	reported := map[string]int{}
+	numSecrets := uint(0)
+
+	err = commits.ForEach(func(commit *object.Commit) error {
+		blobs, err := commitBlobs(commit)
+		if err != nil {
+			return fmt.Errorf("commit %s: %w", commit.Hash, err)
+		}
+		for _, blob := range blobs {
+			if err := session.Context.Err(); err != nil {
+				return err
+			}
+			// The commits are read from the most recent one: an older commit adding the same blob introduced its
+			// secrets
+			if indexes, scanned := scannedBlobs[blob.Hash]; scanned {
+				for _, i := range indexes {
+					setCommit(&secretsFound[i], commit)
+				}
+				continue
+			}
+			scannedBlobs[blob.Hash] = nil
+			if core.IsSkippableFileExtension(blob.Path) {
+				continue
+			}
+
+			contents, err := readBlob(repo, blob.Hash, int64(session.Options.MaxFileSizes.Limit(blob.Path, maxFileSize)))
+			if err != nil {
+				return fmt.Errorf("%s in %s: %w", blob.Path, commit.Hash, err)
+			}
+			if contents == nil {
+				log.Debugf("ScanGitHistory: %s in %s is larger than the maximum file size", blob.Path, commit.Hash)
+				continue
+			}
+
+			matchFile := core.NewMatchFile(blob.Path)
+			matchedRuleSet := map[uint]uint{}
+			Stats.AddFile()
+			secrets, err := scanContents(session.Context, contents, blob.Path, matchFile.Filename, matchFile.Extension, "",
+				&numSecrets, matchedRuleSet)
+			if err != nil {
+				log.Errorf("ScanGitHistory: %s in %s: %s", blob.Path, commit.Hash, err)
+			}
+			secrets = append(secrets, allowed(signature.MatchSimpleSignatures(session.Context, blob.Path, matchFile.Filename,
+				matchFile.Extension, "", &numSecrets), &numSecrets, nil)...)
+
+			var indexes []int
+			for _, secret := range secrets {
+				key := strconv.Itoa(secret.RuleID) + "\x00" + secret.CompleteFilename + "\x00" + output.MatchedValue(secret)
+				if i, found := reported[key]; found {
+					// Found again in an older commit, which introduced it
+					numSecrets--
+					setCommit(&secretsFound[i], commit)
+					indexes = append(indexes, i)
+					continue
+				}
+				setCommit(&secret, commit)
+				reported[key] = len(secretsFound)
+				indexes = append(indexes, len(secretsFound))
+				secretsFound = append(secretsFound, secret)
+			}
+			scannedBlobs[blob.Hash] = indexes
+
+			if numSecrets >= *session.Options.MaxSecrets {
+				log.Warnf("ScanGitHistory: %s", maxSecretsExceeded)
+				return storer.ErrStop
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return secretsFound, err
+	}
+	return secretsFound, nil
+}
@@ -0,0 +1,103 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func Test_ScanGitHistory(t *testing.T) {
+	testSession(t)
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	commit := func(day int, files map[string]string, removed ...string) string {
+		for name, contents := range files {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range removed {
+			if _, err := worktree.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hash, err := worktree.Commit("change", &git.CommitOptions{
+			Author: &object.Signature{Name: "Dev", Email: "dev@example.com", When: start.AddDate(0, 0, day)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+	introduced := commit(0, map[string]string{"config/app.properties": testLayerSecret, "readme.txt": "app"})
+	commit(1, map[string]string{"readme.txt": "app, configured"})
+	commit(2, nil, "config/app.properties")
+	readded := commit(3, map[string]string{"config/app.properties": testLayerSecret})
+
+	// The secret deleted and added again is reported once, with the commit introducing it
+	secrets, err := ScanGitHistory(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 {
+		t.Fatalf("%d secrets found, want 1: %+v", len(secrets), secrets)
+	}
+	if secret := secrets[0]; secret.Commit != introduced || secret.CommitAuthor != "Dev <dev@example.com>" ||
+		secret.CommitDate != "2024-01-02T10:00:00Z" || secret.CompleteFilename != "config/app.properties" {
+		t.Errorf("unexpected secret %+v, want it in commit %s", secret, introduced)
+	}
+
+	// Older commits are not scanned with --since
+	secrets, err = ScanGitHistory(dir, "2024-01-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].Commit != readded {
+		t.Errorf("secrets %+v found since 2024-01-03, want the one of commit %s", secrets, readded)
+	}
+
+	if _, err = ScanGitHistory(t.TempDir(), ""); err == nil {
+		t.Error("expected an error for a directory without repository")
+	}
+}
+
+func Test_ParseSince(t *testing.T) {
+	now := time.Date(2024, 7, 31, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		since    string
+		expected time.Time
+	}{
+		{"2024-01-31T08:00:00Z", time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)},
+		{"6 months ago", time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)},
+		{"2 weeks ago", time.Date(2024, 7, 17, 12, 0, 0, 0, time.UTC)},
+		{"1 year ago", time.Date(2023, 7, 31, 12, 0, 0, 0, time.UTC)},
+		{"90 minutes ago", time.Date(2024, 7, 31, 10, 30, 0, 0, time.UTC)},
+	} {
+		if date, err := ParseSince(test.since, now); err != nil || !date.Equal(test.expected) {
+			t.Errorf("ParseSince(%q) = %s, %v, want %s", test.since, date, err, test.expected)
+		}
+	}
+	if date, err := ParseSince("2024-01-31", now); err != nil || date.Format("2006-01-02 15:04") != "2024-01-31 00:00" {
+		t.Errorf("ParseSince(2024-01-31) = %s, %v", date, err)
+	}
+	if _, err := ParseSince("last tuesday", now); err == nil {
+		t.Error("expected an error for an unsupported date")
+	}
+}
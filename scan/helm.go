@@ -0,0 +1,305 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+// Release the charts are rendered for, the defaults of helm template
+var helmRelease = chartutil.ReleaseOptions{Name: "release-name", Namespace: "default", Revision: 1, IsInstall: true}
+
+// CheckHelm Check the values of --helm and --helm-values
+// @parameters
+// chartDir - Directory of the chart to scan
+// valuesFiles - Values files to render the chart with
+// @returns
+// Error - Errors if the directory has no Chart.yaml or a values file can't be read
+func CheckHelm(chartDir string, valuesFiles []string) error {
+	if _, err := chartutil.IsChartDir(chartDir); err != nil {
+		return fmt.Errorf("--helm must be the directory of a chart, with a Chart.yaml: %w", err)
+	}
+	for _, valuesFile := range valuesFiles {
+		if _, err := os.Stat(valuesFile); err != nil {
+			return fmt.Errorf("--helm-values: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadHelmChart Load a chart and its subcharts, and the values it is rendered with, as helm template does: the
+// values files are merged over the values of the chart in their order, and the subcharts disabled by their condition
+// or tags are left out
+// @parameters
+// chartDir - Directory of the chart
+// valuesFiles - Values files of --helm-values
+// @returns
+// *chart.Chart - Chart loaded
+// chartutil.Values - Values of the templates, with .Values, .Chart, .Release and .Capabilities
+// Error - Errors if the chart or a values file can't be loaded
+func loadHelmChart(chartDir string, valuesFiles []string) (*chart.Chart, chartutil.Values, error) {
+	helmChart, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := map[string]interface{}{}
+	for _, valuesFile := range valuesFiles {
+		fileValues, err := chartutil.ReadValuesFile(valuesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--helm-values: %w", err)
+		}
+		// The last values file wins
+		values = chartutil.MergeTables(fileValues, values)
+	}
+	if err = chartutil.ProcessDependenciesWithMerge(helmChart, values); err != nil {
+		return nil, nil, err
+	}
+	renderValues, err := chartutil.ToRenderValues(helmChart, values, helmRelease, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, nil, err
+	}
+	return helmChart, renderValues, nil
+}
+
+// renderHelmChart Render the templates of a chart and of its subcharts with the engine of Helm, without cluster:
+// lookup finds no resource
+// @parameters
+// chartDir - Directory of the chart
+// helmChart - Chart loaded
+// values - Values of the templates
+// @returns
+// map[string][]byte - Manifests rendered, by the path of their template on disk, e.g. <chartDir>/templates/secret.yaml.
+// Partials, whose name starts with _, are not rendered
+// Error - Errors if a template fails to parse or to render
+func renderHelmChart(chartDir string, helmChart *chart.Chart, values chartutil.Values) (map[string][]byte, error) {
+	manifests, err := engine.Render(helmChart, values)
+	if err != nil {
+		return nil, err
+	}
+	rendered := make(map[string][]byte, len(manifests))
+	for name, manifest := range manifests {
+		// Templates are named from the chart, e.g. app/charts/cache/templates/secret.yaml
+		_, relPath, _ := strings.Cut(name, "/")
+		rendered[filepath.Join(chartDir, filepath.FromSlash(relPath))] = []byte(manifest)
+	}
+	return rendered, nil
+}
+
+// scanHelmContents Find secrets in a manifest rendered from a template, reported in the path of the template, or in a
+// values file
+// @parameters
+// relPath - Path of the template or values file reported, e.g. app/templates/secret.yaml
+// contents - Manifest rendered or values
+// numSecrets - Number of secrets found so far, updated with the secrets of the contents
+// @returns
+// []output.SecretFound - Secrets found, at their line in the contents
+func scanHelmContents(ctx context.Context, relPath string, contents []byte, numSecrets *uint) []output.SecretFound {
+	fileName, fileExtension := path.Base(relPath), path.Ext(relPath)
+	metrics.BytesScanned.Add(int64(len(contents)))
+	secrets, err := scanContents(ctx, contents, relPath, fileName, fileExtension, "", numSecrets, map[uint]uint{})
+	if err != nil {
+		log.Errorf("scanHelmChart: %s: %s", relPath, err)
+		secrets = nil
+	}
+	secrets = append(secrets, allowed(signature.MatchSimpleSignatures(ctx, relPath, fileName, fileExtension, "",
+		numSecrets), numSecrets, nil)...)
+
+	Stats.AddFile()
+	if err != nil {
+		Coverage.AddErrored(relPath, "", err, len(secrets))
+	} else {
+		Coverage.AddScanned(relPath, "", len(secrets))
+	}
+	return secrets
+}
+
+// Find secrets in a values file of --helm-values, reported in its path as given
+func scanHelmValuesFile(ctx context.Context, valuesFile string, numSecrets *uint) []output.SecretFound {
+	relPath := filepath.ToSlash(valuesFile)
+	contents, err := os.ReadFile(valuesFile)
+	if err == nil && uint(len(contents)) > core.GetSession().Options.MaxFileSizes.Limit(relPath,
+		*core.GetSession().Options.MaximumFileSize*1024) {
+		Coverage.AddSkipped(relPath, "", skipMaxFileSize)
+		return nil
+	}
+	if err != nil {
+		log.Errorf("scanHelmChart: %s", err)
+		Coverage.AddErrored(relPath, "", err, 0)
+		return nil
+	}
+	return scanHelmContents(ctx, relPath, contents, numSecrets)
+}
+
+// ScanSecretsInHelmChart Scans a chart rendered with its values and the values files, as helm template does. The
+// secrets of the manifests are reported in the path of their template, e.g. app/templates/secret.yaml, at their
+// line in the manifest. When the chart fails to render, its templates are scanned raw, like the other files of the
+// chart and the values files
+// @parameters
+// chartDir - Directory of the chart
+// valuesFiles - Values files of --helm-values, merged over the values of the chart in their order
+// @returns
+// []output.SecretFound - List of all secrets found
+// Error - Errors if the chart or a values file can't be loaded, or any other error. Otherwise, returns nil
+func ScanSecretsInHelmChart(chartDir string, valuesFiles []string) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	ctx := core.GetSession().Context
+	maxFileSize := *options.MaximumFileSize * 1024
+	maxSecrets := *options.MaxSecrets
+	excludePaths := options.ExcludePath.Values()
+	chartDir = filepath.Clean(chartDir)
+	// Paths are reported from the parent of the chart, e.g. app/templates/secret.yaml
+	baseDir := filepath.Dir(chartDir)
+	helmChart, values, err := loadHelmChart(chartDir, valuesFiles)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderHelmChart(chartDir, helmChart, values)
+	if err != nil {
+		log.Warnf("scanHelmChart: chart not rendered, scanning the raw templates: %s", err)
+	}
+
+	var secretsFound []output.SecretFound
+	var numSecrets atomic.Uint64
+	pool := newFilePool(ctx, *options.WorkersPerScan, func(result fileResult) bool {
+		found := uint(numSecrets.Load())
+		if left := maxSecrets - found; uint(len(result.secrets)) > left {
+			result.secrets = result.secrets[:left]
+		}
+		found += result.counted
+		numSecrets.Store(uint64(found))
+		secretsFound = append(secretsFound, result.secrets...)
+		return found < maxSecrets
+	})
+	scan := func(scanFiles func(numSecrets *uint) []output.SecretFound) func() fileResult {
+		return func() fileResult {
+			start := uint(numSecrets.Load())
+			n := start
+			secrets := scanFiles(&n)
+			return fileResult{secrets: secrets, counted: n - start}
+		}
+	}
+
+	// Scan of a manifest, nil if it is larger than its maximum file size
+	scanManifest := func(relPath string, manifest []byte) func(numSecrets *uint) []output.SecretFound {
+		if uint64(len(manifest)) > uint64(options.MaxFileSizes.Limit(relPath, maxFileSize)) {
+			Coverage.AddSkipped(relPath, "", skipMaxFileSize)
+			return nil
+		}
+		return func(numSecrets *uint) []output.SecretFound {
+			return scanHelmContents(ctx, relPath, manifest, numSecrets)
+		}
+	}
+
+	visit := func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		relPath := filepath.ToSlash(relativePath(baseDir, "", file))
+		if file != chartDir && isExcludedRelPath(relPath, excludePaths) {
+			Coverage.AddSkipped(relPath, "", skipExcludedPath)
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var scanFiles func(numSecrets *uint) []output.SecretFound
+		if manifest, ok := rendered[file]; ok {
+			delete(rendered, file)
+			scanFiles = scanManifest(relPath, manifest)
+			if scanFiles == nil {
+				return nil
+			}
+		} else if recursiveArchive(file, entry, 0) {
+			// Packaged subcharts
+			scanFiles = func(numSecrets *uint) []output.SecretFound {
+				secrets, archiveErr := scanNestedArchive(ctx, file, relPath, "", 1, newScanExtractLimits(), numSecrets)
+				if archiveErr != nil {
+					log.Errorf("scanHelmChart: archive %s: %s", relPath, archiveErr)
+					Coverage.AddErrored(relPath, "", archiveErr, len(secrets))
+				}
+				return secrets
+			}
+		} else {
+			if reason := skipEntry(file, entry, "", "", maxFileSize); reason != "" {
+				Coverage.AddSkipped(relPath, "", reason)
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			scanFiles = func(numSecrets *uint) []output.SecretFound {
+				return scanDirFile(ctx, file, baseDir, "", numSecrets)
+			}
+		}
+		if !pool.submit(scan(scanFiles)) {
+			return maxSecretsExceeded
+		}
+		return nil
+	}
+	walkErr := filepath.WalkDir(chartDir, visit)
+	// The manifests of the templates which are not in the chart directory, those of packaged subcharts
+	manifestFiles := make([]string, 0, len(rendered))
+	for file := range rendered {
+		manifestFiles = append(manifestFiles, file)
+	}
+	sort.Strings(manifestFiles)
+	for _, file := range manifestFiles {
+		if walkErr != nil {
+			break
+		}
+		relPath := filepath.ToSlash(relativePath(baseDir, "", file))
+		if isExcludedRelPath(relPath, excludePaths) {
+			Coverage.AddSkipped(relPath, "", skipExcludedPath)
+			continue
+		}
+		if scanFiles := scanManifest(relPath, rendered[file]); scanFiles != nil && !pool.submit(scan(scanFiles)) {
+			walkErr = maxSecretsExceeded
+		}
+	}
+	// The values files out of the chart, its own ones being scanned with its other files
+	absChart, _ := filepath.Abs(chartDir)
+	for _, valuesFile := range valuesFiles {
+		absValues, _ := filepath.Abs(valuesFile)
+		if walkErr != nil || strings.HasPrefix(absValues, absChart+string(filepath.Separator)) {
+			continue
+		}
+		valuesFile := valuesFile
+		if !pool.submit(scan(func(numSecrets *uint) []output.SecretFound {
+			return scanHelmValuesFile(ctx, valuesFile, numSecrets)
+		})) {
+			walkErr = maxSecretsExceeded
+		}
+	}
+
+	if !pool.wait() && walkErr == nil {
+		walkErr = maxSecretsExceeded
+	}
+	if walkErr == maxSecretsExceeded {
+		log.Warnf("scanHelmChart: %s", walkErr)
+		walkErr = nil
+	}
+	return secretsFound, walkErr
+}
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Write the files of a chart under dir
+func writeChart(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_ScanHelmChart(t *testing.T) {
+	options := testSession(t).Options
+	// The packaged subchart is rendered, and extracted to scan its raw files
+	defer func(recursive bool) { *options.RecursiveArchives = recursive }(*options.RecursiveArchives)
+	*options.RecursiveArchives = true
+	dir := filepath.Join(t.TempDir(), "app")
+	writeChart(t, dir, map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: app\nversion: 1.0.0\ndependencies:\n" +
+			"  - name: cache\n    condition: cache.enabled\n  - name: queue\n    condition: queue.enabled\n" +
+			"  - name: worker\n    version: 1.0.0\n",
+		"values.yaml":            "image: app\napiKey: \"\"\ncache:\n  enabled: true\nqueue:\n  enabled: false\n",
+		"templates/_helpers.tpl": "{{- define \"app.fullname\" -}}\n{{ .Release.Name }}-{{ .Chart.Name }}\n{{- end }}\n",
+		"templates/config.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n" +
+			"  name: {{ include \"app.fullname\" . }}\ndata:\n  app.properties: |\n" +
+			"{{- if .Capabilities.APIVersions.Has \"apps/v1\" }}\n" +
+			"    api_key = '{{ required \"apiKey is required\" .Values.apiKey }}'\n{{- end }}\n",
+		// Rendered without secret
+		"templates/plain.yaml":                  "# {{ .Values.image }}\n{{ if false }}" + testLayerSecret + "{{ end }}\n",
+		"charts/cache/Chart.yaml":               "apiVersion: v2\nname: cache\nversion: 1.0.0\n",
+		"charts/cache/values.yaml":              "token: \"\"\n",
+		"charts/cache/templates/secret.yaml":    "\n\napi_key = '{{ .Values.token }}{{ .Values.global.suffix }}'\n",
+		"charts/queue/Chart.yaml":               "apiVersion: v2\nname: queue\nversion: 1.0.0\n",
+		"charts/queue/templates/configmap.yaml": "{{ fail \"queue is disabled\" }}\n",
+	})
+	// Packaged subchart
+	workerDir := filepath.Join(t.TempDir(), "worker")
+	writeChart(t, workerDir, map[string]string{
+		"Chart.yaml":            "apiVersion: v2\nname: worker\nversion: 1.0.0\n",
+		"templates/secret.yaml": "api_key = '{{ .Values.token }}'\n",
+	})
+	worker, err := loader.Load(workerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chartutil.Save(worker, filepath.Join(dir, "charts")); err != nil {
+		t.Fatal(err)
+	}
+	valuesFile := filepath.Join(t.TempDir(), "prod.yaml")
+	if err := os.WriteFile(valuesFile, []byte("apiKey: J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n"+
+		"cache:\n  token: J8fK2mQ9xL4vR7\nglobal:\n  suffix: tB1nZ6cW3yH5pD0sGa\n"+
+		"worker:\n  token: J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := helmSecretsFound(t, dir, valuesFile)
+	// Secrets of the manifests are at their rendered line
+	expected := []string{valuesFile + ":1", "app/charts/cache/templates/secret.yaml:3",
+		"app/charts/worker/templates/secret.yaml:1", "app/templates/config.yaml:7"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("secrets found at %v, want %v", found, expected)
+	}
+
+	// Without the values file, the required value fails the rendering of the chart, whose templates are scanned raw
+	helmChart, values, err := loadHelmChart(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = renderHelmChart(dir, helmChart, values); err == nil {
+		t.Errorf("chart rendered without its required value")
+	}
+	found = helmSecretsFound(t, dir)
+	expected = []string{"app/templates/plain.yaml:2"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("secrets found at %v in the raw templates, want %v", found, expected)
+	}
+
+	if _, err = ScanSecretsInHelmChart(dir, []string{filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("expected an error for a values file which can't be read")
+	}
+}
+
+// Locations of the secrets found in a chart
+func helmSecretsFound(t *testing.T, dir string, valuesFiles ...string) []string {
+	secrets, err := ScanSecretsInHelmChart(dir, valuesFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, secret := range secrets {
+		found = append(found, fmt.Sprintf("%s:%d", secret.CompleteFilename, secret.LineNumber))
+	}
+	sort.Strings(found)
+	return found
+}
+
+func Test_RenderHelmChartFunctions(t *testing.T) {
+	testSession(t)
+	dir := filepath.Join(t.TempDir(), "app")
+	writeChart(t, dir, map[string]string{
+		"Chart.yaml":           "apiVersion: v2\nname: app\nversion: 1.0.0\n",
+		"values.yaml":          "db:\n  password: J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n",
+		"templates/_certs.tpl": "{{- define \"app.ca\" -}}\n{{ (genCA \"app\" 365).Cert }}\n{{- end }}\n",
+		"templates/tls.yaml":   "{{ include \"app.ca\" . }}\n",
+		"templates/secret.yaml": "{{- $ports := tuple 80 443 80 | uniq }}\n" +
+			"password: {{ dig \"db\" \"password\" \"\" .Values.AsMap | substr 0 32 }}\nports: {{ $ports }}\n" +
+			"checksum: {{ sha1sum \"app\" }}\nyear: {{ now | date \"2006\" | regexFind \"[0-9]+\" }}\n",
+	})
+
+	helmChart, values, err := loadHelmChart(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := renderHelmChart(dir, helmChart, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rendered[filepath.Join(dir, "templates", "_certs.tpl")]; ok {
+		t.Errorf("partial rendered")
+	}
+	if tls := string(rendered[filepath.Join(dir, "templates", "tls.yaml")]); !strings.HasPrefix(tls,
+		"-----BEGIN CERTIFICATE-----") {
+		t.Errorf("certificate not generated: %q", tls)
+	}
+	manifest := string(rendered[filepath.Join(dir, "templates", "secret.yaml")])
+	expected := fmt.Sprintf("\npassword: J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\nports: [80 443]\n"+
+		"checksum: 7d1043473d55bfa90e8530d35801d4e381bc69f0\nyear: %d\n", time.Now().Year())
+	if manifest != expected {
+		t.Errorf("manifest %q, want %q", manifest, expected)
+	}
+}
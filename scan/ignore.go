@@ -0,0 +1,204 @@
+package scan
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+// Names of the files listing the paths to ignore with --respect-gitignore
+const (
+	gitignoreFile    = ".gitignore"
+	dockerignoreFile = ".dockerignore"
+)
+
+// Rule of a .gitignore or .dockerignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool // Re-includes the paths matched, pattern starting with !
+	dirOnly  bool // Only matches dirs, pattern ending with /
+	anchored bool // Matched against the path relative to the ignore file, otherwise against the name only
+}
+
+// Parse the rules of a .gitignore file
+// @parameters
+// data - Contents of the file
+// @returns
+// []ignoreRule - Rules in the order of the file
+func parseGitignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		// Trailing spaces are ignored unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A slash at the beginning or in the middle anchors the pattern to the dir of the file
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Parse the rules of a .dockerignore file, all matched against the path relative to the build context
+// @parameters
+// data - Contents of the file
+// @returns
+// []ignoreRule - Rules in the order of the file
+func parseDockerignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{anchored: true}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = strings.TrimSpace(line[1:])
+		}
+		rule.pattern = strings.TrimPrefix(path.Clean("/"+line), "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Checks if a rule matches a path
+// @parameters
+// relPath - Slash separated path relative to the dir of the ignore file
+// isDir - true if the path is a dir
+// @returns
+// bool - true if the rule matches the path
+func (r ignoreRule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return core.MatchGlob(r.pattern, relPath)
+	}
+	return core.MatchGlob(r.pattern, path.Base(relPath))
+}
+
+// Paths of a directory ignored by its .gitignore files, nested ones included, and by its .dockerignore.
+// Like git, the rules of deeper .gitignore files take precedence and the last rule matching a path wins
+type ignoreMatcher struct {
+	root         string
+	gitignores   map[string][]ignoreRule // Rules of the .gitignore of each dir, by path relative to root, "" for root
+	dockerignore []ignoreRule
+	// Dirs matched by the .dockerignore are walked if a rule may re-include some of their files
+	dockerExceptions bool
+}
+
+// Create the matcher of the ignore files of a directory. The .gitignore files of the dirs are read
+// when the dirs are checked, so the dirs have to be checked before their contents, as filepath.WalkDir does
+// @parameters
+// root - Directory walked
+// @returns
+// *ignoreMatcher - Matcher of the paths of the directory
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	m := &ignoreMatcher{root: root, gitignores: map[string][]ignoreRule{}}
+	if data, err := os.ReadFile(filepath.Join(root, dockerignoreFile)); err == nil {
+		m.dockerignore = parseDockerignore(data)
+		for _, rule := range m.dockerignore {
+			m.dockerExceptions = m.dockerExceptions || rule.negate
+		}
+	}
+	return m
+}
+
+// Checks if a file or dir visited while walking the directory is ignored. The contents of ignored
+// dirs are ignored too and don't need to be walked
+// @parameters
+// fullPath - Complete path of the file or dir
+// isDir - true if the path is a dir
+// @returns
+// bool - true if the path has to be skipped
+func (m *ignoreMatcher) ignored(fullPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	relPath, err := filepath.Rel(m.root, fullPath)
+	if err != nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." {
+		m.loadGitignore("")
+		return false
+	}
+
+	if m.gitignored(relPath, isDir) || m.dockerignored(relPath, isDir) {
+		return true
+	}
+	if isDir {
+		m.loadGitignore(relPath)
+	}
+	return false
+}
+
+func (m *ignoreMatcher) loadGitignore(relDir string) {
+	if data, err := os.ReadFile(filepath.Join(m.root, filepath.FromSlash(relDir), gitignoreFile)); err == nil {
+		m.gitignores[relDir] = parseGitignore(data)
+	}
+}
+
+// Checks the path against the .gitignore files of its parent dirs, from the root to the deepest one
+func (m *ignoreMatcher) gitignored(relPath string, isDir bool) bool {
+	ignored := false
+	dir := ""
+	subPath := relPath
+	for {
+		for _, rule := range m.gitignores[dir] {
+			if rule.matches(subPath, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		i := strings.Index(subPath, "/")
+		if i < 0 {
+			return ignored
+		}
+		dir = path.Join(dir, subPath[:i])
+		subPath = subPath[i+1:]
+	}
+}
+
+// Checks the path against the .dockerignore. Like docker, a rule matching a parent dir matches the path
+func (m *ignoreMatcher) dockerignored(relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.dockerignore {
+		for parent := relPath; parent != "."; parent = path.Dir(parent) {
+			if rule.matches(parent, true) {
+				ignored = !rule.negate
+				break
+			}
+		}
+	}
+	// Walk into the dir to check the files a rule may re-include
+	if ignored && isDir && m.dockerExceptions {
+		return false
+	}
+	return ignored
+}
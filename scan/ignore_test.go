@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_ParseGitignore(t *testing.T) {
+	rules := parseGitignore([]byte("# build output\n\n*.log  \r\n/dist\nbuild/\n!keep.log\n\\#notes\nlogs/**/*.tmp\n"))
+	expected := []ignoreRule{
+		{pattern: "*.log"},
+		{pattern: "dist", anchored: true},
+		{pattern: "build", dirOnly: true},
+		{pattern: "keep.log", negate: true},
+		{pattern: "#notes"},
+		{pattern: "logs/**/*.tmp", anchored: true},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("parseGitignore = %+v, want %+v", rules, expected)
+	}
+}
+
+func Test_IgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "*.log\nbuild/\n/secret.txt\n",
+		".dockerignore":       "docs\n!docs/keep.md\n",
+		"app.go":              "",
+		"app.log":             "",
+		"secret.txt":          "",
+		"build/out.env":       "",
+		"docs/guide.md":       "",
+		"docs/keep.md":        "",
+		"sub/.gitignore":      "!debug.log\n/local.env\n",
+		"sub/debug.log":       "",
+		"sub/error.log":       "",
+		"sub/local.env":       "",
+		"sub/secret.txt":      "",
+		"sub/deep/local.env":  "",
+		"sub/build/artifact":  "",
+		"other/build.txt":     "",
+		"other/build/out.env": "",
+	}
+	for name, contents := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		os.WriteFile(file, []byte(contents), 0644)
+	}
+
+	matcher := newIgnoreMatcher(root)
+	var scanned []string
+	err := filepath.WalkDir(root, func(path string, f os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if matcher.ignored(path, f.IsDir()) {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.IsDir() {
+			relPath, _ := filepath.Rel(root, path)
+			scanned = append(scanned, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{".dockerignore", ".gitignore", "app.go", "docs/keep.md", "other/build.txt",
+		"sub/.gitignore", "sub/debug.log", "sub/deep/local.env", "sub/secret.txt"}
+	if !reflect.DeepEqual(scanned, expected) {
+		t.Errorf("scanned %v, want %v", scanned, expected)
+	}
+
+	var nilMatcher *ignoreMatcher
+	if nilMatcher.ignored(filepath.Join(root, "app.log"), false) {
+		t.Errorf("nothing should be ignored without --respect-gitignore")
+	}
+}
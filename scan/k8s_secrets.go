@@ -0,0 +1,151 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Value of the data or stringData of a Kubernetes Secret
+type k8sSecretValue struct {
+	field  string // data or stringData
+	key    string
+	value  string
+	line   int // 1-based position of the value in the file
+	column int
+	plain  bool // The value is written as is, not quoted or folded
+}
+
+// Key path of the value in the Secret, e.g. data.password
+func (v k8sSecretValue) keyPath() string {
+	return v.field + "." + v.key
+}
+
+// Values of the data and stringData of a Kubernetes Secret
+// @parameters
+// document - Document of the Secret
+// firstLine - Line of the file the document starts at
+// @returns
+// []k8sSecretValue - Scalar values of data and stringData, in the order of the document
+func k8sSecretValues(document yamlDocument, firstLine int) []k8sSecretValue {
+	var root yaml.Node
+	if err := yaml.Unmarshal(document.Contents, &root); err != nil || len(root.Content) == 0 {
+		return nil
+	}
+	resource := root.Content[0]
+	if resource.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var values []k8sSecretValue
+	for i := 0; i+1 < len(resource.Content); i += 2 {
+		field, entries := resource.Content[i].Value, resource.Content[i+1]
+		if (field != "data" && field != "stringData") || entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(entries.Content); j += 2 {
+			key, value := entries.Content[j], entries.Content[j+1]
+			if value.Kind != yaml.ScalarNode || value.Value == "" {
+				continue
+			}
+			values = append(values, k8sSecretValue{field: field, key: key.Value, value: value.Value,
+				line: firstLine + value.Line - 1, column: value.Column, plain: value.Style == 0})
+		}
+	}
+	return values
+}
+
+// Match the signatures against the base64-decoded data of the Kubernetes Secrets of a YAML file, and
+// report the plaintext values of their stringData. The secrets are located at the values in the file
+// @parameters
+// ctx - Context of the scan
+// contents - Contents of the YAML file
+// relPath - Path of the file reported
+// fileName - Name of the file
+// fileExtension - Extension of the file
+// layer - layer ID, if the file is in a container image
+// numSecrets - Number of secrets found so far, updated with the secrets found
+// @returns
+// []output.SecretFound - Secrets found in the Secrets
+// Error - Errors if any. Otherwise, returns nil
+func scanK8sSecrets(ctx context.Context, contents []byte, relPath, fileName, fileExtension, layer string,
+	numSecrets *uint) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	var secretsFound []output.SecretFound
+
+	for _, document := range splitYAMLDocuments(contents) {
+		if document.Kind != "Secret" {
+			continue
+		}
+		firstLine := 1 + bytes.Count(contents[:document.Start], []byte("\n"))
+		for _, value := range k8sSecretValues(document, firstLine) {
+			if *numSecrets >= *options.MaxSecrets {
+				return secretsFound, nil
+			}
+
+			var secrets []output.SecretFound
+			if value.field == "stringData" {
+				if !signature.RuleSelectedFor(ctx, signature.K8sStringDataRuleID) {
+					continue
+				}
+				secrets = []output.SecretFound{{
+					LayerID:          layer,
+					RuleID:           signature.K8sStringDataRuleID,
+					RuleName:         signature.K8sStringDataRuleName,
+					PartToMatch:      signature.ContentsPart,
+					Severity:         output.MEDIUM,
+					SeverityScore:    5.0,
+					CompleteFilename: relPath,
+					MatchToByte:      len(value.value),
+					MatchedContents:  value.value,
+				}}
+				*numSecrets++
+			} else {
+				// Values may be folded over several lines
+				decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value.value), ""))
+				if err != nil {
+					log.Debugf("scanK8sSecrets: %s: %s of %s is not base64: %s", relPath, value.keyPath(),
+						document.resource(), err)
+					continue
+				}
+				// Each value is matched on its own, like a file
+				matchedRuleSet := map[uint]uint{}
+				secrets, err = signature.MatchPatternSignatures(ctx, decoded, relPath, fileName, fileExtension, layer,
+					numSecrets, matchedRuleSet)
+				if err != nil {
+					return secretsFound, err
+				}
+				if !*options.NoEntropy {
+					secrets = append(secrets, signature.MatchHighEntropyStrings(ctx, decoded, relPath, layer, secrets,
+						numSecrets, matchedRuleSet)...)
+				}
+			}
+
+			secrets = allowed(secrets, numSecrets, nil)
+			for i := range secrets {
+				locateK8sSecretValue(&secrets[i], document, value)
+			}
+			secretsFound = append(secretsFound, secrets...)
+		}
+	}
+	return secretsFound, nil
+}
+
+// Locate a secret found in a value of a Kubernetes Secret at the value in the file
+func locateK8sSecretValue(secret *output.SecretFound, document yamlDocument, value k8sSecretValue) {
+	secret.KeyPath = value.keyPath()
+	secret.DocumentIndex = document.Index
+	secret.ResourceName = document.resource()
+	secret.LineNumber, secret.ColumnNumber = value.line, value.column
+	secret.EndLineNumber, secret.EndColumnNumber = value.line, value.column
+	if value.plain {
+		secret.EndColumnNumber += len(value.value) - 1
+	}
+}
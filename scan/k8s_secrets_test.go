@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const k8sSecretManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: c2VjcmV0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+  namespace: prod
+type: Opaque
+data:
+  config: YXBpX2tleSA9ICdKOGZLMm1ROXhMNHZSN3RCMW5aNmNXM3lINXBEMHNHYScK
+  broken: "not base64!"
+stringData:
+  password: "hunter2"
+`
+
+func Test_K8sSecretValues(t *testing.T) {
+	documents := splitYAMLDocuments([]byte(k8sSecretManifest))
+	if len(documents) != 2 || documents[1].Kind != "Secret" {
+		t.Fatalf("unexpected documents %+v", documents)
+	}
+	var keys []string
+	for _, value := range k8sSecretValues(documents[1], 8) {
+		keys = append(keys, value.keyPath())
+	}
+	if expected := []string{"data.config", "data.broken", "stringData.password"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("values %v, want %v", keys, expected)
+	}
+}
+
+func Test_ScanK8sSecrets(t *testing.T) {
+	session := testSession(t)
+	numSecrets := uint(0)
+	secrets, err := scanK8sSecrets(context.Background(), []byte(k8sSecretManifest), "deploy/secrets.yaml", "secrets.yaml",
+		".yaml", "", &numSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type location struct {
+		rule     string
+		keyPath  string
+		resource string
+		line     int
+		column   int
+	}
+	var found []location
+	for _, secret := range secrets {
+		found = append(found, location{secret.RuleName, secret.KeyPath, secret.ResourceName, secret.LineNumber, secret.ColumnNumber})
+	}
+	// The decoded config holds a high entropy key, the ConfigMap and the value which is not base64 are ignored
+	expected := []location{
+		{signature.GenericHighEntropyRuleName, "data.config", "Secret/prod/api", 15, 11},
+		{signature.K8sStringDataRuleName, "stringData.password", "Secret/prod/api", 18, 13},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("secrets found at %+v, want %+v", found, expected)
+	}
+	if len(secrets) == 2 && (secrets[1].Severity != output.MEDIUM || secrets[1].MatchedContents != "hunter2") {
+		t.Errorf("unexpected stringData secret %+v", secrets[1])
+	}
+	if numSecrets != 2 {
+		t.Errorf("%d secrets counted, want 2", numSecrets)
+	}
+	// The stringData values are not reported with their rule disabled
+	signature.SelectRules(len(session.Config.Signatures), nil, []string{strconv.Itoa(signature.K8sStringDataRuleID)})
+	defer signature.SelectRules(0, nil, nil)
+	numSecrets = 0
+	secrets, err = scanK8sSecrets(context.Background(), []byte(k8sSecretManifest), "deploy/secrets.yaml", "secrets.yaml",
+		".yaml", "", &numSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].RuleName != signature.GenericHighEntropyRuleName || numSecrets != 1 {
+		t.Errorf("secrets %+v found with stringData disabled, want the high entropy one", secrets)
+	}
+}
@@ -0,0 +1,194 @@
+package scan
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of the files parsed to report the key path of their secrets. JSON is parsed as YAML, which it is a
+// subset of
+var structuredExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true}
+
+// Keys written as is in key paths, others are quoted in brackets, e.g. metadata.annotations["example.com/key"]
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Range of the contents of a structured file spanned by a key and its value, or by a value of a sequence
+type keyPathRange struct {
+	start int
+	end   int // Offset after the last byte of the value, -1 until known for block and multi-line scalars
+	path  string
+}
+
+// Key path of a value of a mapping
+func mappingKeyPath(path, key string) string {
+	if !plainKey.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Offset of the 1-based line and column of a YAML node, the column counting characters
+func nodeOffset(contents []byte, offsets []int, node *yaml.Node) int {
+	if node.Line < 1 || node.Line > len(offsets) {
+		return -1
+	}
+	offset := offsets[node.Line-1]
+	for column := 1; column < node.Column && offset < len(contents) && contents[offset] != '\n'; column++ {
+		_, size := utf8.DecodeRune(contents[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// Offset after a scalar starting at start, -1 if it can't be told from the scalar alone
+func scalarEnd(contents []byte, start int, node *yaml.Node) int {
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(contents); i++ {
+			if contents[i] == '\\' {
+				i++
+			} else if contents[i] == '"' {
+				return i + 1
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(contents); i++ {
+			if contents[i] != '\'' {
+				continue
+			}
+			if i+1 < len(contents) && contents[i+1] == '\'' {
+				i++
+			} else {
+				return i + 1
+			}
+		}
+	case 0, yaml.FlowStyle:
+		if bytes.HasPrefix(contents[start:], []byte(node.Value)) && !strings.Contains(node.Value, "\n") {
+			return start + len(node.Value)
+		}
+	}
+	return -1
+}
+
+// keyPathRanges Ranges of the values of a JSON or YAML file with their key paths, e.g. spec.template.env[3].value.
+// The documents of a YAML stream are parsed until the first one that is not valid
+// @parameters
+// contents - Contents of the file
+// @returns
+// []keyPathRange - Ranges of the keys and values, by start offset
+func keyPathRanges(contents []byte) []keyPathRange {
+	offsets := lineOffsets(contents)
+	var ranges []keyPathRange
+
+	// Adds the ranges of a node and its children, returns the offset after the node, -1 if unknown
+	var walk func(node *yaml.Node, path string) int
+	walk = func(node *yaml.Node, path string) int {
+		end := -1
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				childPath := mappingKeyPath(path, key.Value)
+				index := len(ranges)
+				ranges = append(ranges, keyPathRange{start: nodeOffset(contents, offsets, key), path: childPath})
+				ranges[index].end = walk(value, childPath)
+				end = ranges[index].end
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				end = walk(item, path+"["+strconv.Itoa(i)+"]")
+			}
+		case yaml.ScalarNode:
+			if path == "" {
+				return -1
+			}
+			start := nodeOffset(contents, offsets, node)
+			if start < 0 {
+				return -1
+			}
+			end = scalarEnd(contents, start, node)
+			ranges = append(ranges, keyPathRange{start: start, end: end, path: path})
+		}
+		return end
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		var document yaml.Node
+		// Files, or the rest of the stream, that are not valid are located with their lines only
+		if err := decoder.Decode(&document); err != nil {
+			break
+		}
+		walk(&document, "")
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	// Block and multi-line scalars span the lines up to the next key or value
+	for i := range ranges {
+		if ranges[i].end >= 0 {
+			continue
+		}
+		ranges[i].end = len(contents)
+		for _, next := range ranges[i+1:] {
+			if next.start > ranges[i].start {
+				ranges[i].end = next.start
+				break
+			}
+		}
+	}
+	return ranges
+}
+
+// Key path of the innermost value spanning the byte at offset, empty if none does
+func keyPathAt(ranges []keyPathRange, offset int) string {
+	path := ""
+	for _, r := range ranges {
+		if r.start > offset {
+			break
+		}
+		if r.start >= 0 && offset < r.end {
+			path = r.path
+		}
+	}
+	return path
+}
+
+// annotateKeyPaths Set the key path of the secrets found in the contents of a JSON or YAML file, e.g.
+// spec.template.env[3].value, from the value or the key they start in. Secrets of files that can't be parsed keep
+// their lines only
+// @parameters
+// contents - Contents of the file the secrets were found in
+// extension - Extension of the file
+// secrets - Secrets found in the file, updated in place
+func annotateKeyPaths(contents []byte, extension string, secrets []output.SecretFound) {
+	if !structuredExtensions[strings.ToLower(extension)] || len(secrets) == 0 {
+		return
+	}
+
+	var ranges []keyPathRange
+	parsed := false
+	for i := range secrets {
+		if secrets[i].PartToMatch != signature.ContentsPart || secrets[i].KeyPath != "" {
+			continue
+		}
+		if !parsed {
+			ranges, parsed = keyPathRanges(contents), true
+		}
+		secrets[i].KeyPath = keyPathAt(ranges, secrets[i].PrintBufferStartIndex+secrets[i].MatchFromByte)
+	}
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const keyPathManifest = `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    metadata:
+      annotations:
+        example.com/token: 'tok''en-1'
+    spec:
+      env:
+        - name: A
+          value: plain-2
+        - {name: B, value: "flow-3"}
+      script: |
+        echo start
+        export KEY=block-4
+---
+kind: ConfigMap
+data:
+  café: utf8-5 # comment-6
+`
+
+// Key paths of the secrets starting at each of the given strings of the contents
+func keyPathsOf(contents, extension string, matches ...string) []string {
+	var secrets []output.SecretFound
+	for _, match := range matches {
+		secrets = append(secrets, output.SecretFound{PartToMatch: signature.ContentsPart,
+			PrintBufferStartIndex: strings.Index(contents, match), MatchToByte: len(match)})
+	}
+	annotateKeyPaths([]byte(contents), extension, secrets)
+	var paths []string
+	for _, secret := range secrets {
+		paths = append(paths, secret.KeyPath)
+	}
+	return paths
+}
+
+func Test_AnnotateKeyPaths(t *testing.T) {
+	for _, test := range []struct {
+		contents  string
+		extension string
+		matches   []string
+		expected  []string
+	}{
+		{keyPathManifest, ".yaml",
+			[]string{"en-1", "plain-2", "flow-3", "block-4", "utf8-5", "comment-6", "env:", "kind: Deployment"},
+			[]string{`spec.template.metadata.annotations["example.com/token"]`, "spec.template.spec.env[0].value",
+				"spec.template.spec.env[1].value", "spec.template.spec.script", `data["café"]`, "", "spec.template.spec.env",
+				"kind"}},
+		{`{"auth": {"keys": [{"id": 1, "secret": "json-1"}]}, "url": "json-2"}`, ".JSON",
+			[]string{"json-1", "json-2", `"id"`}, []string{"auth.keys[0].secret", "url", "auth.keys[0].id"}},
+		// Files that are not valid are located by line only
+		{"password: [not-1\n", ".yml", []string{"not-1"}, []string{""}},
+		{"password: other-1\n", ".txt", []string{"other-1"}, []string{""}},
+	} {
+		if paths := keyPathsOf(test.contents, test.extension, test.matches...); strings.Join(paths, "|") !=
+			strings.Join(test.expected, "|") {
+			t.Errorf("key paths of %v in %s: %q, want %q", test.matches, test.extension, paths, test.expected)
+		}
+	}
+}
+
+func Test_AnnotateKeyPathsKeepsK8sSecrets(t *testing.T) {
+	secrets := []output.SecretFound{{PartToMatch: signature.ContentsPart, KeyPath: "data.password"},
+		{PartToMatch: signature.ExtPart}}
+	annotateKeyPaths([]byte("data:\n  password: aHVudGVyMg==\n"), ".yaml", secrets)
+	if secrets[0].KeyPath != "data.password" || secrets[1].KeyPath != "" {
+		t.Errorf("key paths overwritten: %q, %q", secrets[0].KeyPath, secrets[1].KeyPath)
+	}
+}
@@ -0,0 +1,173 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/khulnasoft-lab/SecretScanner/allowlist"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Version of the layout of the cache entries, to be raised when layerCacheEntry or output.SecretFound change
+const layerCacheSchema = 1
+
+// On-disk cache of the secrets found in image layers, by layer ID. Entries written with other signatures
+// or settings are ignored and replaced. Entries are written atomically, so concurrent scans can share it
+type layerCache struct {
+	dir     string
+	version string // Digest of the signatures and settings the secrets were found with
+}
+
+// Entry of the layer cache, one file per layer
+type layerCacheEntry struct {
+	Version string
+	LayerID string
+	Secrets []output.SecretFound
+}
+
+// Create a layer cache
+// @parameters
+// dir - Directory of the cache entries, created if missing
+// version - Digest of the signatures and settings of the scan
+// @returns
+// *layerCache - Layer cache
+// Error - Errors if any. Otherwise, returns nil
+func newLayerCache(dir string, version string) (*layerCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &layerCache{dir: dir, version: version}, nil
+}
+
+// Layer cache of the current session. The entries hold the secrets found, so the cache is only used in the
+// --cache-dir given
+// @returns
+// *layerCache - Layer cache, nil without --cache-dir, with --no-cache, when coverage is reported or if unavailable
+func sessionLayerCache() *layerCache {
+	options := core.GetSession().Options
+	if *options.CacheDir == "" || *options.NoCache || Coverage != nil {
+		return nil
+	}
+	cache, err := newLayerCache(*options.CacheDir, layerCacheVersion())
+	if err != nil {
+		log.Warnf("Layer cache disabled: %s", err)
+		return nil
+	}
+	return cache
+}
+
+// Digest of everything the secrets found in a layer depend on besides its files: the layout of the entries, the
+// version of SecretScanner, the signatures, the allowlist, the config and the options of the scan
+func layerCacheVersion() string {
+	session := core.GetSession()
+	options := session.Options
+	var allowed []allowlist.Entry
+	if current := allowlist.Current(); current != nil {
+		allowed = current.Entries
+	}
+
+	digest := sha256.New()
+	err := json.NewEncoder(digest).Encode(struct {
+		Schema           int
+		ScannerVersion   string
+		Signatures       string
+		Allowlist        []allowlist.Entry
+		BlacklistStrings []string
+		BlacklistExts    []string
+		BlacklistPaths   []string
+		ExcludePaths     []string
+		EntropyExts      []string
+		Options          []interface{}
+	}{
+		Schema:           layerCacheSchema,
+		ScannerVersion:   core.Version,
+		Signatures:       signature.Version(),
+		Allowlist:        allowed,
+		BlacklistStrings: session.Config.BlacklistedStrings,
+		BlacklistExts:    session.Config.BlacklistedExtensions,
+		BlacklistPaths:   session.Config.BlacklistedPaths,
+		ExcludePaths:     session.Config.ExcludePaths,
+		EntropyExts:      session.Config.BlacklistedEntropyExtensions,
+		Options: []interface{}{*options.MaximumFileSize, options.MaxFileSizes.String(), *options.MaxSecrets, *options.MultipleMatch,
+			*options.MaxMultiMatch, *options.MinSeverity, *options.CountAll, *options.EntropyThreshold, *options.EntropyMinLength,
+			*options.NoEntropy, *options.ShowSuppressed, *options.ScanPackages, *options.DecodeK8sSecrets,
+			*options.RecursiveArchives, *options.MaxArchiveDepth, options.EnableRule.Values(), options.DisableRule.Values(),
+			options.ExcludePath.Values(), *options.IncludeExtensions, *options.RespectGitignore, *options.StreamLayers,
+			*options.ScanBinaries, *options.DecodeBase64, *options.ScanEnvFiles, *options.ReportCertificates},
+	})
+	if err != nil {
+		log.Warnf("Unable to compute the version of the layer cache: %s", err)
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// Path of the entry of a layer
+func (c *layerCache) path(layerID string) string {
+	key := sha256.Sum256([]byte(layerID))
+	return filepath.Join(c.dir, hex.EncodeToString(key[:])+".json")
+}
+
+// Get Returns the secrets found in a layer by an earlier scan with the same signatures and settings
+// @parameters
+// layerID - ID of the layer, the digest of its contents
+// @returns
+// []output.SecretFound - Secrets found in the layer
+// bool - Indicates if the layer is in the cache
+func (c *layerCache) Get(layerID string) ([]output.SecretFound, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(layerID))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Unable to read the layer cache of %s: %s", layerID, err)
+		}
+		return nil, false
+	}
+	var entry layerCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Warnf("Ignoring invalid layer cache of %s: %s", layerID, err)
+		return nil, false
+	}
+	if entry.Version != c.version || entry.LayerID != layerID {
+		return nil, false
+	}
+	return entry.Secrets, true
+}
+
+// Put Store the secrets found in a layer, replacing any previous entry
+// @parameters
+// layerID - ID of the layer, the digest of its contents
+// secrets - Secrets found in the layer
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func (c *layerCache) Put(layerID string, secrets []output.SecretFound) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(layerCacheEntry{Version: c.version, LayerID: layerID, Secrets: secrets})
+	if err != nil {
+		return err
+	}
+	// Readers see either the previous entry or the whole new one
+	tmp, err := os.CreateTemp(c.dir, ".layer-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(layerID))
+}
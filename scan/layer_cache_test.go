@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+func Test_LayerCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "layers")
+	cache, err := newLayerCache(dir, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerID := "blobs/sha256/0123456789abcdef"
+	secrets := []output.SecretFound{{LayerID: layerID, RuleID: 3, CompleteFilename: "app/.env", MatchedContents: "TOKEN=abc"}}
+
+	if _, ok := cache.Get(layerID); ok {
+		t.Errorf("empty cache should miss")
+	}
+	if err := cache.Put(layerID, secrets); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := cache.Get(layerID)
+	if !ok || !reflect.DeepEqual(cached, secrets) {
+		t.Errorf("cached secrets %+v, want %+v", cached, secrets)
+	}
+
+	// A layer without secrets is cached too
+	if err := cache.Put("empty", nil); err != nil {
+		t.Fatal(err)
+	}
+	if cached, ok := cache.Get("empty"); !ok || len(cached) != 0 {
+		t.Errorf("empty layer should be cached without secrets, got %+v %v", cached, ok)
+	}
+
+	updated, err := newLayerCache(dir, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated.Get(layerID); ok {
+		t.Errorf("entries of other signatures should be ignored")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected one file per layer and no temporary files, got %d", len(entries))
+	}
+	if info, _ := entries[0].Info(); info.Mode().Perm() != 0600 {
+		t.Errorf("cache entries hold secrets and should only be readable by their owner, mode %v", info.Mode())
+	}
+
+	var none *layerCache
+	if _, ok := none.Get(layerID); ok || none.Put(layerID, secrets) != nil {
+		t.Errorf("nil cache should miss and ignore writes")
+	}
+}
+
+func Test_LayerCacheConcurrent(t *testing.T) {
+	cache, err := newLayerCache(t.TempDir(), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []output.SecretFound{{RuleID: 1, CompleteFilename: "a", MatchedContents: "key"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := cache.Put("layer", secrets); err != nil {
+					t.Error(err)
+				}
+				// Readers never see a partially written entry
+				if cached, ok := cache.Get("layer"); ok && !reflect.DeepEqual(cached, secrets) {
+					t.Errorf("unexpected cached secrets %+v", cached)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_SessionLayerCache(t *testing.T) {
+	options := testSession(t).Options
+	defer func(cacheDir string) { *options.CacheDir = cacheDir }(*options.CacheDir)
+
+	// The entries hold secrets, so nothing is cached unless asked for
+	*options.CacheDir = ""
+	if cache := sessionLayerCache(); cache != nil {
+		t.Errorf("layer cache enabled in %s without --cache-dir", cache.dir)
+	}
+	*options.CacheDir = t.TempDir()
+	cache := sessionLayerCache()
+	if cache == nil || cache.dir != *options.CacheDir {
+		t.Fatalf("layer cache %+v, want one in --cache-dir", cache)
+	}
+
+	// Entries of other versions of SecretScanner are ignored
+	defer func(version string) { core.Version = version }(core.Version)
+	core.Version = "0.0.0-test"
+	if version := layerCacheVersion(); version == cache.version {
+		t.Errorf("layer cache version %s unchanged by the version of SecretScanner", version)
+	}
+}
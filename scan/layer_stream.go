@@ -0,0 +1,240 @@
+package scan
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
+	log "github.com/sirupsen/logrus"
+)
+
+// Checks if the layers of the images are read entry by entry with --stream-layers rather than extracted. The
+// .gitignore files of --respect-gitignore are only read from extracted layers
+func streamLayers() bool {
+	options := core.GetSession().Options
+	return *options.StreamLayers && !*options.RespectGitignore
+}
+
+// Temp dir of the entries of a streamed layer which have to be written to disk: the archives extracted by
+// --scan-packages and --recursive-archives. Created for the first one
+type layerEntries struct {
+	dir string
+}
+
+// Write an entry to a temp file, whose name ends with the name of the entry for its kind of archive
+func (e *layerEntries) write(name string, r io.Reader) (string, error) {
+	if e.dir == "" {
+		dir, err := core.MkdirTemp("layer-")
+		if err != nil {
+			return "", err
+		}
+		e.dir = dir
+	}
+	file, err := os.CreateTemp(e.dir, "*-"+path.Base(name))
+	if err != nil {
+		return "", err
+	}
+	// Written even above --max-file-size, like the archives of the extracted layers
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return file.Name(), err
+}
+
+func (e *layerEntries) remove() {
+	if e.dir != "" {
+		core.DeleteTmpDir(e.dir)
+	}
+}
+
+// Find secrets in one layer of the container image by reading its tarball entry by entry, with the same skip
+// rules and limits as scanLayer. The files are matched in memory, only the archives scanned with --scan-packages
+// and --recursive-archives are written to disk. Hard links are not scanned, their target is
+// @parameters
+// layerTarPath - Complete path of the layer tarball
+// extractPath - Base directory the layers would be extracted to, the blacklisted paths are relative to it
+// layerID - ID of the layer
+// @returns
+// layerResult - Secrets found in the layer, with an error if the layer could not be read at all
+func (imageScan *ImageScan) streamLayer(layerTarPath, extractPath, layerID string,
+	scanCtx *tasks.ScanContext) layerResult {
+	log.Debugf("Streaming layer %s from %s", layerID, layerTarPath)
+	tarFile, err := os.Open(layerTarPath)
+	if err != nil {
+		return layerResult{err: err}
+	}
+	defer tarFile.Close()
+	r, closeReader, err := decompressReader(layerTarPath, bufio.NewReader(tarFile))
+	if err != nil {
+		return layerResult{err: err}
+	}
+	defer closeReader()
+
+	session := core.GetSession()
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+	excludePaths := session.Options.ExcludePath.Values()
+	ctx := scanContext(scanCtx)
+	entries := &layerEntries{}
+	defer entries.remove()
+
+	var secretsFound []output.SecretFound
+	numSecrets := uint(0)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return layerResult{secrets: secretsFound}
+		}
+		if err != nil {
+			// Like a layer which can't be extracted, the files read before are reported
+			log.Errorf("ProcessImageLayers: Unable to read image layer. Reason = %s", err.Error())
+			return layerResult{secrets: secretsFound, partial: true}
+		}
+		size := int64(0)
+		if hdr.Typeflag == tar.TypeReg {
+			size = hdr.Size
+		}
+		if err := imageScan.budget.charge(hdr.Name, size); err != nil {
+			return layerResult{err: fmt.Errorf("layer %s: %w", layerID, err)}
+		}
+		if err := scanCtx.Checkpoint("reading layer entries"); err != nil {
+			return layerResult{secrets: secretsFound, partial: true}
+		}
+		if err := ctx.Err(); err != nil {
+			return layerResult{secrets: secretsFound, partial: true}
+		}
+
+		secrets, err := scanLayerEntry(ctx, tr, hdr, extractPath, layerID, maxFileSize, excludePaths, entries,
+			&numSecrets)
+		secretsFound = append(secretsFound, secrets...)
+		if err != nil {
+			log.Errorf("scanSecretsInDir: %s", err)
+		}
+		// Don't report secrets if number of secrets exceeds MAX value
+		if numSecrets >= *session.Options.MaxSecrets {
+			log.Warnf("streamLayer: %s", maxSecretsExceeded)
+			return layerResult{secrets: secretsFound}
+		}
+	}
+}
+
+// Find secrets in an entry of a layer tarball, as ScanSecretsInDir does in the extracted file
+// @parameters
+// ctx - Context of the scan
+// tr - Reader of the layer, at the contents of the entry
+// hdr - Header of the entry
+// extractPath - Base directory the layers would be extracted to
+// layerID - ID of the layer
+// maxFileSize - Maximum size in bytes of the files to scan, unless --max-file-size sets the size of their extension
+// excludePaths - Patterns of --exclude-path
+// entries - Temp dir of the archives to extract
+// numSecrets - Number of secrets found so far, updated with the secrets of the entry
+// @returns
+// []output.SecretFound - Secrets found in the entry
+// Error - Errors if the entry could not be scanned. Otherwise, returns nil
+func scanLayerEntry(ctx context.Context, tr io.Reader, hdr *tar.Header, extractPath string, layerID string,
+	maxFileSize uint, excludePaths []string, entries *layerEntries, numSecrets *uint) ([]output.SecretFound, error) {
+	relPath := layerPath(hdr.Name)
+	// Dirs have no contents, their files are checked against the paths of the dirs
+	if relPath == "" || hdr.Typeflag == tar.TypeDir || strings.HasPrefix(path.Base(relPath), whiteoutPrefix) {
+		return nil, nil
+	}
+	for p := relPath; p != "."; p = path.Dir(p) {
+		if isExcludedRelPath(p, excludePaths) {
+			Coverage.AddSkipped(relPath, layerID, skipExcludedPath)
+			return nil, nil
+		}
+	}
+	if core.IsSkippableDir(path.Join("/", path.Dir(relPath)), extractPath) {
+		Coverage.AddSkipped(relPath, layerID, skipBlacklistedPath)
+		return nil, nil
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		Coverage.AddSkipped(relPath, layerID, skipNotRegularFile)
+		return nil, nil
+	}
+
+	f := fs.FileInfoToDirEntry(hdr.FileInfo())
+	if *core.GetSession().Options.ScanPackages && packageKind(relPath) != "" {
+		return scanLayerArchive(tr, relPath, layerID, entries, func(archivePath string) ([]output.SecretFound, error) {
+			return scanPackage(ctx, archivePath, relPath, layerID, numSecrets)
+		})
+	}
+	if recursiveArchive(relPath, f, 0) {
+		return scanLayerArchive(tr, relPath, layerID, entries, func(archivePath string) ([]output.SecretFound, error) {
+			return scanNestedArchive(ctx, archivePath, relPath, layerID, 1, newScanExtractLimits(), numSecrets)
+		})
+	}
+	if reason := skipEntry(relPath, f, "", "", maxFileSize); reason != "" {
+		Coverage.AddSkipped(relPath, layerID, reason)
+		return nil, nil
+	}
+
+	file := core.NewMatchFile(relPath)
+	secrets, scanErr := scanReader(ctx, tr, hdr.Size, relPath, file.Filename, file.Extension, layerID, numSecrets)
+	if scanErr != nil {
+		secrets = nil
+	}
+	secrets = append(secrets, allowed(signature.MatchSimpleSignatures(ctx, relPath, file.Filename, file.Extension,
+		layerID, numSecrets), numSecrets, nil)...)
+	Stats.AddFile()
+	if scanErr != nil {
+		Coverage.AddErrored(relPath, layerID, scanErr, len(secrets))
+		return secrets, fmt.Errorf("%s: %w", relPath, scanErr)
+	}
+	Coverage.AddScanned(relPath, layerID, len(secrets))
+	return secrets, nil
+}
+
+// Write an archive of a layer to a temp file to extract and scan it
+func scanLayerArchive(tr io.Reader, relPath string, layerID string, entries *layerEntries,
+	scan func(archivePath string) ([]output.SecretFound, error)) ([]output.SecretFound, error) {
+	archivePath, err := entries.write(relPath, tr)
+	if archivePath != "" {
+		defer os.Remove(archivePath)
+	}
+	var secrets []output.SecretFound
+	if err == nil {
+		secrets, err = scan(archivePath)
+	}
+	if err != nil {
+		Coverage.AddErrored(relPath, layerID, err, len(secrets))
+		return secrets, fmt.Errorf("archive %s: %w", relPath, err)
+	}
+	return secrets, nil
+}
+
+// scanReader Match the signatures against contents read from r, like scanFile does for a file
+// @parameters
+// size - Size of the contents, larger contents are matched window by window
+func scanReader(ctx context.Context, r io.Reader, size int64, relPath, fileName, fileExtension, layer string,
+	numSecrets *uint) ([]output.SecretFound, error) {
+	matchedRuleSet := map[uint]uint{}
+	metrics.BytesScanned.Add(size)
+	if extractsBinaryStrings(relPath) {
+		var binary bool
+		if r, binary = peekBinary(r); binary {
+			return scanBinary(ctx, r, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+		}
+	}
+	if size > streamFileThreshold {
+		return scanReaderWindows(ctx, r, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	}
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return scanContents(ctx, contents, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+}
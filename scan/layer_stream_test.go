@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testLayerSecret = "api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa'\n"
+
+// Paths of the files the secrets were found in, sorted
+func secretPaths(result layerResult) []string {
+	var paths []string
+	for _, secret := range result.secrets {
+		paths = append(paths, secret.CompleteFilename)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Scan a layer tar extracted or streamed, with --stream-layers set to stream
+func scanTestLayer(t testing.TB, dir string, layerTarPath string, stream bool) layerResult {
+	options := testSession(t).Options
+	defer func(streamLayers bool) { *options.StreamLayers = streamLayers }(*options.StreamLayers)
+	*options.StreamLayers = stream
+
+	imageScan := &ImageScan{budget: newExtractBudget(1<<30, 1<<20)}
+	extractPath := filepath.Join(dir, fmt.Sprintf("extracted-%v", stream))
+	return imageScan.scanLayer(dir, extractPath, filepath.Base(layerTarPath), "layer-1", nil)
+}
+
+func Test_StreamLayer(t *testing.T) {
+	options := testSession(t).Options
+	defer func(recursive bool) { *options.RecursiveArchives = recursive }(*options.RecursiveArchives)
+	*options.RecursiveArchives = true
+
+	dir := t.TempDir()
+	nested := zipBytes(t, map[string]string{"app.properties": testLayerSecret})
+	layer := layerTar(t, dir,
+		tar.Header{Name: "app/", Typeflag: tar.TypeDir},
+		tar.Header{Name: "app/config.txt", Typeflag: tar.TypeReg, Linkname: testLayerSecret},
+		tar.Header{Name: "./app/bundles/bundle.jar", Typeflag: tar.TypeReg, Linkname: string(nested)},
+		tar.Header{Name: "usr/lib/blacklisted.txt", Typeflag: tar.TypeReg, Linkname: testLayerSecret},
+		tar.Header{Name: "app/config.png", Typeflag: tar.TypeReg, Linkname: testLayerSecret},
+		tar.Header{Name: "app/.wh.deleted.txt", Typeflag: tar.TypeReg},
+		tar.Header{Name: "app/link.txt", Typeflag: tar.TypeSymlink, Linkname: "config.txt"},
+	)
+
+	extracted, streamed := scanTestLayer(t, dir, layer, false), scanTestLayer(t, dir, layer, true)
+	if extracted.err != nil || streamed.err != nil {
+		t.Fatalf("scanLayer() errors: extracted %v, streamed %v", extracted.err, streamed.err)
+	}
+	expected := []string{"app/bundles/bundle.jar!/app.properties", "app/config.txt"}
+	if paths := secretPaths(streamed); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("secrets streamed in %v, want %v", paths, expected)
+	}
+	if streamedPaths, extractedPaths := secretPaths(streamed), secretPaths(extracted); !reflect.DeepEqual(
+		streamedPaths, extractedPaths) {
+		t.Errorf("secrets streamed in %v, extracted in %v", streamedPaths, extractedPaths)
+	}
+	// Only the extracted layer is written to disk
+	if _, err := os.Stat(filepath.Join(dir, "extracted-true")); !os.IsNotExist(err) {
+		t.Errorf("streamed layer written to disk: %v", err)
+	}
+}
+
+func Test_StreamLayerBudget(t *testing.T) {
+	testSession(t)
+	dir := t.TempDir()
+	layer := layerTar(t, dir, tar.Header{Name: "app/config.txt", Typeflag: tar.TypeReg,
+		Linkname: strings.Repeat("x", 2048)})
+	imageScan := &ImageScan{budget: newExtractBudget(1024, 1<<20)}
+	result := imageScan.streamLayer(layer, filepath.Join(dir, "extracted"), "layer-1", nil)
+	if result.err == nil || !strings.Contains(result.err.Error(), "max-extract-size") {
+		t.Errorf("streamLayer() = %v, want --max-extract-size exceeded", result.err)
+	}
+}
+
+// Extracting then walking a layer against reading it entry by entry, with the bytes written to disk per scan
+func Benchmark_ScanLayer(b *testing.B) {
+	dir := b.TempDir()
+	var headers []tar.Header
+	for i := 0; i < 200; i++ {
+		headers = append(headers, tar.Header{Name: fmt.Sprintf("app/%d/config.txt", i), Typeflag: tar.TypeReg,
+			Linkname: strings.Repeat("key = value\n", 2000)})
+	}
+	layer := layerTar(b, dir, headers...)
+
+	for _, stream := range []bool{false, true} {
+		b.Run(fmt.Sprintf("stream=%v", stream), func(b *testing.B) {
+			written := int64(0)
+			for i := 0; i < b.N; i++ {
+				if result := scanTestLayer(b, dir, layer, stream); result.err != nil {
+					b.Fatal(result.err)
+				}
+				written += dirSize(filepath.Join(dir, fmt.Sprintf("extracted-%v", stream)))
+				os.RemoveAll(filepath.Join(dir, fmt.Sprintf("extracted-%v", stream)))
+			}
+			b.ReportMetric(float64(written)/float64(b.N), "disk-bytes/op")
+		})
+	}
+}
+
+func dirSize(dir string) int64 {
+	size := int64(0)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of archives which can be scanned without unpacking them first
+const (
+	zipArchive = "zip"
+	tarArchive = "tar"
+)
+
+// Kind of archive from its file name, empty if not a supported archive
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarArchive
+	}
+	return ""
+}
+
+// IsArchive Checks if a path is a regular file of an archive kind that ScanSecretsInArchive supports
+func IsArchive(path string) bool {
+	if archiveKind(path) == "" {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// ScanSecretsInArchive Extracts a zip, tar or tar.gz archive to a temp dir and scans the files in it
+// @parameters
+// archivePath - Complete path of the archive
+// @returns
+// []output.SecretFound - List of all secrets found, with paths relative to the root of the archive
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInArchive(archivePath string) ([]output.SecretFound, error) {
+	session := core.GetSession()
+
+	tempDir, err := core.MkdirTemp("archive-")
+	if err != nil {
+		return nil, err
+	}
+	defer core.DeleteTmpDir(tempDir)
+
+	limits := newScanExtractLimits()
+	switch archiveKind(archivePath) {
+	case zipArchive:
+		err = extractZipFileTo(archivePath, tempDir, limits)
+	case tarArchive:
+		err = extractTarFileTo(archivePath, tempDir, limits)
+	default:
+		err = fmt.Errorf("%s is not a zip, tar or tar.gz archive", archivePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("ScanSecretsInArchive: extracted %s to %s", archivePath, tempDir)
+
+	numSecrets := uint(0)
+	return scanExtractedFiles(session.Context, tempDir, "", "", 1, limits, &numSecrets)
+}
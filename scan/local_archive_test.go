@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ArchiveKind(t *testing.T) {
+	for name, expected := range map[string]string{
+		"bundle.zip":      zipArchive,
+		"BUNDLE.ZIP":      zipArchive,
+		"rootfs.tar":      tarArchive,
+		"rootfs.tar.gz":   tarArchive,
+		"deps.tgz":        tarArchive,
+		"config.yaml":     "",
+		"bundle.zip.sha1": "",
+	} {
+		if actual := archiveKind(name); actual != expected {
+			t.Errorf("archiveKind(%q) = %q, want %q", name, actual, expected)
+		}
+	}
+}
+
+func Test_IsArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, dir, "bundle.zip", []byte{})
+	if !IsArchive(archive) {
+		t.Errorf("%s should be scanned as an archive", archive)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "vendor.zip"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if IsArchive(filepath.Join(dir, "vendor.zip")) {
+		t.Errorf("directories should be scanned as directories")
+	}
+	if IsArchive(filepath.Join(dir, "missing.zip")) {
+		t.Errorf("missing files are not archives")
+	}
+}
+
+func Test_ExtractZipNestedAndZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range map[string]string{
+		"deps/":                         "",
+		"deps/lib/internal/settings.py": "API_KEY = 'secret'",
+		"../../evil.sh":                 "echo escaped",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents))
+	}
+	zw.Close()
+	archive := writeTestFile(t, dir, "bundle.zip", buf.Bytes())
+
+	dest := filepath.Join(dir, "out")
+	if err := extractZipFileTo(archive, dest, newExtractLimits(1024)); err != nil {
+		t.Fatal(err)
+	}
+	assertExtracted(t, filepath.Join(dest, "deps", "lib", "internal", "settings.py"), "API_KEY = 'secret'")
+	assertExtracted(t, filepath.Join(dest, "evil.sh"), "echo escaped")
+	if _, err := os.Stat(filepath.Join(dir, "..", "evil.sh")); !os.IsNotExist(err) {
+		t.Errorf("zip entry written outside of the extraction dir")
+	}
+}
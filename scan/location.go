@@ -0,0 +1,48 @@
+package scan
+
+import (
+	"sort"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+// Start offsets of the lines of the contents, the first line starting at 0
+func lineOffsets(contents []byte) []int {
+	offsets := []int{0}
+	for i, b := range contents {
+		if b == '\n' && i+1 < len(contents) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// 1-based line and column of the byte at offset, given the start offsets of the lines
+func lineColumn(offsets []int, offset int) (int, int) {
+	line := sort.Search(len(offsets), func(i int) bool { return offsets[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+	return line + 1, offset - offsets[line] + 1
+}
+
+// locateSecrets Set the line and column range of the secrets matched in the contents of a file
+// @parameters
+// contents - Contents of the file the secrets were found in
+// secrets - Secrets found in the file, updated in place
+func locateSecrets(contents []byte, secrets []output.SecretFound) {
+	var offsets []int
+	for i := range secrets {
+		if secrets[i].PartToMatch != signature.ContentsPart || secrets[i].MatchToByte <= secrets[i].MatchFromByte {
+			continue
+		}
+		if offsets == nil {
+			offsets = lineOffsets(contents)
+		}
+		from := secrets[i].PrintBufferStartIndex + secrets[i].MatchFromByte
+		to := secrets[i].PrintBufferStartIndex + secrets[i].MatchToByte
+		secrets[i].LineNumber, secrets[i].ColumnNumber = lineColumn(offsets, from)
+		secrets[i].EndLineNumber, secrets[i].EndColumnNumber = lineColumn(offsets, to-1)
+	}
+}
@@ -0,0 +1,82 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Kind of archive extracted with --recursive-archives from its file name, empty if not such an archive.
+// Java archives are zips
+func nestedArchiveKind(name string) string {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".jar") || strings.HasSuffix(lower, ".war") || strings.HasSuffix(lower, ".ear") {
+		return zipArchive
+	}
+	return archiveKind(lower)
+}
+
+// Checks if a file found during a scan is an archive to extract and scan with --recursive-archives
+// @parameters
+// path - Complete path of the file
+// f - Entry of the file
+// depth - Nesting depth of the archive the file is in, 0 if it is not in an archive
+// @returns
+// bool - true if the file is an archive and --max-archive-depth is not reached
+func recursiveArchive(path string, f os.DirEntry, depth int) bool {
+	options := core.GetSession().Options
+	return *options.RecursiveArchives && depth < *options.MaxArchiveDepth && f.Type().IsRegular() &&
+		nestedArchiveKind(path) != ""
+}
+
+// Limits of the extraction of an archive and of the archives nested in it. Entries are skipped by the size of
+// their extension once extracted, nested archives are kept with --recursive-archives. The total size is shared by
+// all the archives nested in the first one, to stop archive bombs
+func newScanExtractLimits() *extractLimits {
+	options := core.GetSession().Options
+	maxFileSize := *options.MaximumFileSize * 1024
+	limits := newExtractLimits(int64(options.MaxFileSizes.Largest(maxFileSize)))
+	limits.maxTotalSize = min(limits.maxTotalSize, int64(*options.MaxExtractSize)*1024)
+	limits.maxEntries = min(limits.maxEntries, int64(*options.MaxExtractEntries))
+	limits.keepArchives = *options.RecursiveArchives
+	return limits
+}
+
+// Extract an archive found during a scan to a temp dir and scan the files in it, and the archives nested in them
+// @parameters
+// ctx - Context of the scan
+// archivePath - Complete path of the archive
+// relPath - Path of the archive reported in the secrets
+// layer - layer ID, if we are scanning directory inside container image
+// depth - Nesting depth of the archive, 1 if it is not in another archive
+// limits - Size limits of the extraction, shared with the archive it is in
+// numSecrets - Number of secrets found so far, updated with the secrets of the archive
+// @returns
+// []output.SecretFound - Secrets found, with paths like outer.tar!/inner.jar!/app.properties
+// Error - Errors if any. Otherwise, returns nil
+func scanNestedArchive(ctx context.Context, archivePath string, relPath string, layer string, depth int,
+	limits *extractLimits, numSecrets *uint) ([]output.SecretFound, error) {
+	tempDir, err := core.MkdirTemp("nested-")
+	if err != nil {
+		return nil, err
+	}
+	defer core.DeleteTmpDir(tempDir)
+
+	switch nestedArchiveKind(archivePath) {
+	case zipArchive:
+		err = extractZipFileTo(archivePath, tempDir, limits)
+	case tarArchive:
+		err = extractTarFileTo(archivePath, tempDir, limits)
+	default:
+		err = fmt.Errorf("%s is not a tar, zip, jar, war or ear archive", archivePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExtractedFiles(ctx, tempDir, relPath+archivePathSeparator, layer, depth, limits, numSecrets)
+}
@@ -0,0 +1,102 @@
+package scan
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_NestedArchiveKind(t *testing.T) {
+	for name, expected := range map[string]string{
+		"outer.tar":       tarArchive,
+		"app-1.0.tgz":     tarArchive,
+		"lib/guava.JAR":   zipArchive,
+		"ROOT.war":        zipArchive,
+		"bundle.zip":      zipArchive,
+		"app.properties":  "",
+		"archive.tar.bz2": "",
+	} {
+		if actual := nestedArchiveKind(name); actual != expected {
+			t.Errorf("nestedArchiveKind(%q) = %q, want %q", name, actual, expected)
+		}
+	}
+}
+
+func Test_ScanNestedArchive(t *testing.T) {
+	options := testSession(t).Options
+	recursive, depth := *options.RecursiveArchives, *options.MaxArchiveDepth
+	defer func() { *options.RecursiveArchives, *options.MaxArchiveDepth = recursive, depth }()
+	*options.RecursiveArchives = true
+
+	dir := t.TempDir()
+	inner := zipBytes(t, map[string]string{"app.properties": "api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa'\n"})
+	outer := writeTestFile(t, dir, "outer.tar", tarBytes(t, map[string]string{"inner.jar": string(inner)}, false))
+
+	for maxDepth, expected := range map[int][]string{
+		1: nil,
+		2: {"outer.tar!/inner.jar!/app.properties"},
+	} {
+		*options.MaxArchiveDepth = maxDepth
+		numSecrets := uint(0)
+		secrets, err := scanNestedArchive(context.Background(), outer, "outer.tar", "", 1, newScanExtractLimits(),
+			&numSecrets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, secret := range secrets {
+			paths = append(paths, secret.CompleteFilename)
+		}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("secrets found in %v with --max-archive-depth %d, want %v", paths, maxDepth, expected)
+		}
+	}
+}
+
+func Test_ExtractKeepsNestedArchives(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, dir, "outer.tar", tarBytes(t, map[string]string{
+		"lib/inner.jar": strings.Repeat("j", 64),
+		"big.txt":       strings.Repeat("t", 64),
+	}, false))
+
+	limits := newExtractLimits(16)
+	limits.keepArchives = true
+	dest := filepath.Join(dir, "out")
+	if err := extractTarFileTo(archive, dest, limits); err != nil {
+		t.Fatal(err)
+	}
+	assertExtracted(t, filepath.Join(dest, "lib", "inner.jar"), strings.Repeat("j", 64))
+	if _, err := os.Stat(filepath.Join(dest, "big.txt")); !os.IsNotExist(err) {
+		t.Errorf("entries larger than the maximum file size should not be extracted")
+	}
+
+	// The total size is shared by the archives nested in the first one
+	limits.maxTotalSize = limits.written + 32
+	if err := extractTarFileTo(archive, filepath.Join(dir, "out2"), limits); err != archiveTooLarge {
+		t.Errorf("expected the total size limit to stop the extraction, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reported as the coverage skip reason of the objects in archive storage classes or tiers, which can't be read until
+// restored
+const skipArchivedObject = "archived storage class"
+
+// Object of a bucket or container listed for a scan
+type storeObject struct {
+	Key      string // Path of the object in its bucket, the secrets are reported in
+	Size     int64
+	Archived bool // In an archive storage class or tier, restored before it can be read
+}
+
+// Bucket or container of a cloud object storage, S3, GCS or Azure Blob, scanned object by object
+type objectStore interface {
+	// list Page of the objects to scan, with the token of the next page, empty after the last page
+	list(ctx context.Context, token string) ([]storeObject, string, error)
+	// open Contents of an object, to be closed
+	open(ctx context.Context, key string) (io.ReadCloser, error)
+	// url URL of an object in the logs
+	url(key string) string
+}
+
+// Client of the GCS and Azure Blob SDKs, through --proxy or the proxy of the environment
+func objectStoreClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = core.ProxyFunc()
+	return &http.Client{Transport: transport}
+}
+
+// Reason to skip an object listed, empty if it has to be scanned
+func skipStoreObject(object storeObject, excludePaths []string) string {
+	options := core.GetSession().Options
+	switch {
+	case isExcludedRelPath(object.Key, excludePaths):
+		return skipExcludedPath
+	case object.Archived:
+		return skipArchivedObject
+	case uint64(object.Size) > uint64(options.MaxFileSizes.Limit(object.Key, *options.MaximumFileSize*1024)):
+		return skipMaxFileSize
+	case core.IsSkippableFileExtension(object.Key) && !isScannedBinary(object.Key):
+		return skipBlacklistedExt
+	}
+	return ""
+}
+
+// scanStoreObject Find secrets in an object, read as it is matched. Objects larger than streamFileThreshold are
+// matched window by window
+// @parameters
+// store - Bucket or container of the object
+// object - Object listed
+// numSecrets - Number of secrets found so far, updated with the secrets of the object
+// @returns
+// []output.SecretFound - Secrets found in the object, reported in its key
+func scanStoreObject(ctx context.Context, store objectStore, object storeObject,
+	numSecrets *uint) []output.SecretFound {
+	key := object.Key
+	fileName, fileExtension := path.Base(key), path.Ext(key)
+	options := core.GetSession().Options
+	maxSize := int64(options.MaxFileSizes.Limit(key, *options.MaximumFileSize*1024))
+
+	var secrets []output.SecretFound
+	body, err := store.open(ctx, key)
+	if err == nil {
+		// The object may have grown since it was listed
+		contents := io.LimitReader(body, maxSize)
+		matchedRuleSet := map[uint]uint{}
+		if object.Size > streamFileThreshold {
+			metrics.BytesScanned.Add(object.Size)
+			secrets, err = scanReaderWindows(ctx, contents, key, fileName, fileExtension, "", numSecrets,
+				matchedRuleSet)
+		} else {
+			var data []byte
+			if data, err = io.ReadAll(contents); err == nil {
+				metrics.BytesScanned.Add(int64(len(data)))
+				secrets, err = scanContents(ctx, data, key, fileName, fileExtension, "", numSecrets, matchedRuleSet)
+			}
+		}
+		body.Close()
+	}
+	if err != nil {
+		log.Errorf("scanObjectStore: %s: %s", store.url(key), err)
+		secrets = nil
+	}
+	secrets = append(secrets, allowed(signature.MatchSimpleSignatures(ctx, key, fileName, fileExtension, "", numSecrets),
+		numSecrets, nil)...)
+
+	Stats.AddFile()
+	if err != nil {
+		Coverage.AddErrored(key, "", err, len(secrets))
+	} else {
+		Coverage.AddScanned(key, "", len(secrets))
+	}
+	return secrets
+}
+
+// scanObjectStore List the objects of a bucket or container, page by page, and scan them with --workers-per-scan
+// workers. The secrets are returned in the order of the listing, and at most --max-secrets of them
+// @parameters
+// store - Bucket or container to scan
+// @returns
+// []output.SecretFound - Secrets found, reported in the keys of their objects
+// Error - Errors of the listing, the objects which can't be read are reported in the coverage and logged
+func scanObjectStore(ctx context.Context, store objectStore) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	maxSecrets := *options.MaxSecrets
+	excludePaths := options.ExcludePath.Values()
+
+	var secretsFound []output.SecretFound
+	var numSecrets atomic.Uint64
+	pool := newFilePool(ctx, *options.WorkersPerScan, func(result fileResult) bool {
+		found := uint(numSecrets.Load())
+		if left := maxSecrets - found; uint(len(result.secrets)) > left {
+			result.secrets = result.secrets[:left]
+		}
+		found += result.counted
+		numSecrets.Store(uint64(found))
+		secretsFound = append(secretsFound, result.secrets...)
+		return found < maxSecrets
+	})
+
+	var listErr error
+	token := ""
+	for listing := true; listing; {
+		var objects []storeObject
+		if objects, token, listErr = store.list(ctx, token); listErr != nil {
+			break
+		}
+		for _, object := range objects {
+			// Folders created by the consoles
+			if strings.HasSuffix(object.Key, "/") && object.Size == 0 {
+				continue
+			}
+			if reason := skipStoreObject(object, excludePaths); reason != "" {
+				Coverage.AddSkipped(object.Key, "", reason)
+				continue
+			}
+			object := object
+			if !pool.submit(func() fileResult {
+				start := uint(numSecrets.Load())
+				n := start
+				secrets := scanStoreObject(ctx, store, object, &n)
+				return fileResult{secrets: secrets, counted: n - start}
+			}) {
+				listing = false
+				break
+			}
+		}
+		listing = listing && token != ""
+	}
+	if !pool.wait() {
+		log.Warnf("scanObjectStore: %s", maxSecretsExceeded)
+	}
+	return secretsFound, listErr
+}
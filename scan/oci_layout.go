@@ -0,0 +1,174 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ociLayoutFileName = "oci-layout"
+	ociIndexFileName  = "index.json"
+	// Annotation of the index naming the image of a manifest, e.g. its tag
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// IsOCILayout Indicates if the directory is an OCI image layout, as written by
+// skopeo, buildah or docker buildx with --output type=oci
+// @parameters
+// dir - Path of the directory
+// @returns
+// bool - Indicates if the directory has the oci-layout marker and an index
+func IsOCILayout(dir string) bool {
+	return core.PathExists(filepath.Join(dir, ociLayoutFileName)) && core.PathExists(filepath.Join(dir, ociIndexFileName))
+}
+
+// Read the manifest of the image of an OCI image layout. The index may list a single image,
+// or the images of several platforms, resolved to the platform requested
+// @parameters
+// dir - Path of the OCI image layout
+// platform - Platform requested, nil for the host platform
+// @returns
+// ociManifest - Manifest of the image
+// string - Name of the image in the index, empty if it has none
+// Error - Errors if the layout is invalid or doesn't have an image for the platform. Otherwise, returns nil
+func readOCILayout(dir string, platform *ociPlatform) (ociManifest, string, error) {
+	var index ociManifest
+	data, err := os.ReadFile(filepath.Join(dir, ociIndexFileName))
+	if err != nil {
+		return index, "", err
+	}
+	if err = json.Unmarshal(data, &index); err != nil {
+		return index, "", fmt.Errorf("invalid %s: %w", ociIndexFileName, err)
+	}
+
+	var desc ociDescriptor
+	switch len(index.Manifests) {
+	case 0:
+		return index, "", errors.New("no image in the OCI image layout")
+	case 1:
+		desc = index.Manifests[0]
+	default:
+		if desc, err = platformManifest(index, platform); err != nil {
+			return index, "", fmt.Errorf("%s lists %d images: %w", ociIndexFileName, len(index.Manifests), err)
+		}
+	}
+
+	manifest, err := readManifest(dir, desc.Digest, platform)
+	return manifest, desc.Annotations[ociRefNameAnnotation], err
+}
+
+// Prepare the image of an OCI image layout for scanning, linking its layer blobs into the temp dir
+// @parameters
+// dir - Path of the OCI image layout
+// @returns
+// Error - Errors if the layout can't be read. Otherwise, returns nil
+func (imageScan *ImageScan) loadFromOCILayout(dir string) error {
+	manifest, refName, err := readOCILayout(dir, imageScan.platform)
+	if err != nil {
+		return err
+	}
+	imageName := imageScan.imageName
+	if refName != "" {
+		imageName = refName
+	}
+	if err = writeLayoutManifest(dir, manifest, imageName, imageScan.tempDir); err != nil {
+		return err
+	}
+	log.Infof("Image %s read from OCI image layout %s", imageName, dir)
+	return nil
+}
+
+// Prepare an image scan of an OCI image layout
+func newOCILayoutScan(dir string) (*ImageScan, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	tempDir, err := core.GetTmpDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	imageScan := &ImageScan{imageName: dir, imageId: "", tempDir: tempDir, budget: newSessionExtractBudget()}
+	if imageScan.platform, err = sessionPlatform(); err != nil {
+		core.DeleteTmpDir(tempDir)
+		return nil, err
+	}
+	if err = imageScan.loadFromOCILayout(dir); err != nil {
+		core.DeleteTmpDir(tempDir)
+		return nil, fmt.Errorf("OCI image layout %s: %w", dir, err)
+	}
+	// The layers are linked in place, nothing has to be extracted
+	if imageScan.imageManifest, err = extractDetailsFromManifest(tempDir, imageScan.platform); err != nil {
+		core.DeleteTmpDir(tempDir)
+		return nil, err
+	}
+	imageScan.imageId = strings.TrimSuffix(imageScan.imageManifest.Config, ".json")
+	return imageScan, nil
+}
+
+// ExtractAndScanOCILayout Scan the image of an OCI image layout directory layer by layer
+// @parameters
+// dir - Path of the OCI image layout
+// scanCtx - Context of the scan, nil if it can't be stopped
+// @returns
+// *ImageExtractionResult - Secrets found and ID of the image
+// Error - Errors if any. Otherwise, returns nil
+func ExtractAndScanOCILayout(dir string, scanCtx *tasks.ScanContext) (*ImageExtractionResult, error) {
+	imageScan, err := newOCILayoutScan(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := imageScan.scan(scanCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// The secrets found before the --timeout are still reported
+		return &ImageExtractionResult{ImageId: imageScan.imageId, Secrets: secrets}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ImageExtractionResult{ImageId: imageScan.imageId, Secrets: secrets}, nil
+}
+
+// ExtractAndScanOCILayoutStream Scan the image of an OCI image layout directory, sending the secrets as they are found
+// @parameters
+// dir - Path of the OCI image layout
+// scanCtx - Context of the scan, nil if it can't be stopped
+// @returns
+// chan output.SecretFound - Secrets found, closed at the end of the scan
+// *StreamStatus - Status of the scan, complete once the channel is closed
+// Error - Errors if any. Otherwise, returns nil
+func ExtractAndScanOCILayoutStream(dir string, scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
+	imageScan, err := newOCILayoutScan(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stream, status, err := imageScan.scanStream(scanCtx)
+	if err != nil {
+		core.DeleteTmpDir(imageScan.tempDir)
+		return nil, nil, err
+	}
+
+	res := make(chan output.SecretFound, secret_pipeline_size)
+	go func() {
+		// The temp dir is deleted before the end of the stream, the process may exit right after it
+		defer close(res)
+		defer core.DeleteTmpDir(imageScan.tempDir)
+		for i := range stream {
+			res <- i
+		}
+	}()
+	return res, status, nil
+}
@@ -0,0 +1,157 @@
+package scan
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Write a blob into the OCI image layout and return its digest
+func writeBlob(t *testing.T, dir string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hexDigest := hex.EncodeToString(sum[:])
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(blobs, hexDigest), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + hexDigest
+}
+
+func writeJSONBlob(t *testing.T, dir string, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeBlob(t, dir, data)
+}
+
+// Layer blob with a single file, gzipped or not
+func layerBlob(t *testing.T, name string, contents string, compress bool) []byte {
+	data, err := os.ReadFile(layerTar(t, t.TempDir(), tar.Header{Name: name, Typeflag: tar.TypeReg, Linkname: contents}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compress {
+		return data
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err = zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return gz.Bytes()
+}
+
+// OCI image layout with an image of two layers, one compressed, and an index listing it
+func writeOCILayout(t *testing.T, index func(manifest ociDescriptor) ociManifest) (string, ociManifest) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ociLayoutFileName), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := ociManifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Config:    ociDescriptor{Digest: writeJSONBlob(t, dir, map[string]string{"architecture": runtime.GOARCH})},
+		Layers: []ociDescriptor{
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: writeBlob(t, dir, layerBlob(t, "etc/app.conf", "password=gzipped", true))},
+			{MediaType: "application/vnd.oci.image.layer.v1.tar", Digest: writeBlob(t, dir, layerBlob(t, "app/.env", "TOKEN=plain", false))},
+		},
+	}
+	desc := ociDescriptor{MediaType: manifest.MediaType, Digest: writeJSONBlob(t, dir, manifest)}
+	data, err := json.Marshal(index(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ociIndexFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, manifest
+}
+
+func Test_OCILayout(t *testing.T) {
+	dir, manifest := writeOCILayout(t, func(desc ociDescriptor) ociManifest {
+		desc.Annotations = map[string]string{ociRefNameAnnotation: "app:1.0"}
+		return ociManifest{Manifests: []ociDescriptor{desc}}
+	})
+	if !IsOCILayout(dir) || IsOCILayout(t.TempDir()) {
+		t.Errorf("only directories with an oci-layout file and an index are OCI image layouts")
+	}
+
+	read, refName, err := readOCILayout(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, manifest) || refName != "app:1.0" {
+		t.Errorf("read manifest %+v of %s, want %+v of app:1.0", read, refName, manifest)
+	}
+
+	tempDir := t.TempDir()
+	if err := writeLayoutManifest(dir, read, refName, tempDir); err != nil {
+		t.Fatal(err)
+	}
+	item, err := extractDetailsFromManifest(tempDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedIds := []string{digestHex(manifest.Layers[0].Digest), digestHex(manifest.Layers[1].Digest)}
+	if !reflect.DeepEqual(item.LayerIds, expectedIds) || item.Config != digestHex(manifest.Config.Digest)+".json" {
+		t.Errorf("manifest.json %+v, want layers %v", item, expectedIds)
+	}
+
+	// Both compressed and uncompressed layer blobs are extracted
+	expectedFiles := map[string]string{"etc/app.conf": "password=gzipped", "app/.env": "TOKEN=plain"}
+	for i, layer := range item.Layers {
+		target := filepath.Join(tempDir, "extracted", item.LayerIds[i])
+		if _, err := extractTarFile("", filepath.Join(tempDir, layer), target, newExtractBudget(1<<20, 100)); err != nil {
+			t.Fatal(err)
+		}
+		for name, contents := range expectedFiles {
+			if data, err := os.ReadFile(filepath.Join(target, name)); err == nil && string(data) != contents {
+				t.Errorf("%s of layer %d is %q, want %q", name, i, data, contents)
+			} else if err == nil {
+				delete(expectedFiles, name)
+			}
+		}
+	}
+	if len(expectedFiles) != 0 {
+		t.Errorf("files not extracted from the layers: %v", expectedFiles)
+	}
+}
+
+func Test_OCILayoutPlatforms(t *testing.T) {
+	platform := func(os, arch string) ociDescriptor {
+		return ociDescriptor{MediaType: "application/vnd.oci.image.manifest.v1+json", Digest: "sha256:missing",
+			Platform: &ociPlatform{Architecture: arch, OS: os}}
+	}
+
+	dir, manifest := writeOCILayout(t, func(desc ociDescriptor) ociManifest {
+		host := platform(runtime.GOOS, runtime.GOARCH)
+		host.Digest = desc.Digest
+		return ociManifest{MediaType: mediaTypeOCIIndex, Manifests: []ociDescriptor{platform("plan9", "mips"), host}}
+	})
+	read, _, err := readOCILayout(dir, nil)
+	if err != nil || !reflect.DeepEqual(read, manifest) {
+		t.Errorf("manifest of the host platform should be read, got %+v, %v", read, err)
+	}
+
+	dir, _ = writeOCILayout(t, func(desc ociDescriptor) ociManifest {
+		return ociManifest{Manifests: []ociDescriptor{platform("plan9", "mips"), platform("plan9", "arm")}}
+	})
+	if _, _, err := readOCILayout(dir, nil); err == nil || !strings.Contains(err.Error(), "lists 2 images") {
+		t.Errorf("index without an image for the host platform should be rejected, got %v", err)
+	}
+}
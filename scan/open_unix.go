@@ -0,0 +1,20 @@
+//go:build !windows
+
+package scan
+
+import (
+	"os"
+	"syscall"
+)
+
+// createNoFollow Create or truncate a file of an archive entry, failing instead of writing through a symlink in its
+// place
+// @parameters
+// name - Path of the file
+// perm - Permissions of the file if created
+// @returns
+// *os.File - File opened for writing
+// Error - Errors if the path is a symlink or can't be opened
+func createNoFollow(name string, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, perm)
+}
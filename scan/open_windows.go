@@ -0,0 +1,21 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+)
+
+// createNoFollow Create or truncate a file of an archive entry, failing instead of writing through a symlink in its
+// place. Windows has no O_NOFOLLOW, the path is checked with Lstat before it is opened
+// @parameters
+// name - Path of the file
+// perm - Permissions of the file if created
+// @returns
+// *os.File - File opened for writing
+// Error - Errors if the path is a symlink or can't be opened
+func createNoFollow(name string, perm os.FileMode) (*os.File, error) {
+	if info, err := os.Lstat(name); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("open %s: refusing to write through a symlink", name)
+	}
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+}
@@ -0,0 +1,216 @@
+package scan
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of published package archives
+const (
+	npmPackage   = "npm"
+	wheelPackage = "wheel"
+	sdistPackage = "sdist"
+	gemPackage   = "gem"
+)
+
+// Separator between the path of an archive and the path of a file inside it
+const archivePathSeparator = "!/"
+
+// Kind of package archive from its file name, empty if not a package
+func packageKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tgz"):
+		return npmPackage
+	case strings.HasSuffix(name, ".whl"):
+		return wheelPackage
+	case strings.HasSuffix(name, ".tar.gz"):
+		return sdistPackage
+	case strings.HasSuffix(name, ".gem"):
+		return gemPackage
+	}
+	return ""
+}
+
+// Extract a package archive and find the root of the package files in it
+// @parameters
+// archivePath - Complete path of the package archive
+// kind - Kind of package, see packageKind
+// dest - Extraction dir
+// limits - Size limits of the extraction
+// @returns
+// string - Root of the package files: package/ for npm, the single top dir of sdists
+// Error - Errors if any. Otherwise, returns nil
+func extractPackage(archivePath string, kind string, dest string, limits *extractLimits) (string, error) {
+	switch kind {
+	case npmPackage:
+		if err := extractTarFileTo(archivePath, dest, limits); err != nil {
+			return "", err
+		}
+		if fi, err := os.Stat(filepath.Join(dest, "package")); err == nil && fi.IsDir() {
+			return filepath.Join(dest, "package"), nil
+		}
+		return dest, nil
+	case wheelPackage:
+		return dest, extractZipFileTo(archivePath, dest, limits)
+	case sdistPackage:
+		if err := extractTarFileTo(archivePath, dest, limits); err != nil {
+			return "", err
+		}
+		// sdists have a single name-version/ top dir
+		entries, err := os.ReadDir(dest)
+		if err == nil && len(entries) == 1 && entries[0].IsDir() {
+			return filepath.Join(dest, entries[0].Name()), nil
+		}
+		return dest, nil
+	case gemPackage:
+		return dest, extractGemData(archivePath, dest, limits)
+	}
+	return "", fmt.Errorf("unknown package kind %s", kind)
+}
+
+// Gems are tars of metadata.gz and data.tar.gz, the files of the gem are in data.tar.gz
+func extractGemData(gemPath string, dest string, limits *extractLimits) error {
+	file, err := os.Open(gemPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("data.tar.gz not found in gem %s", gemPath)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name != "data.tar.gz" {
+			continue
+		}
+		gz, err := gzip.NewReader(tr)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTarStream(gz, dest, limits)
+	}
+}
+
+// Extract a package archive found during the walk and scan the files in it
+// @parameters
+// ctx - Context of the scan
+// archivePath - Complete path of the package archive
+// relPath - Path of the archive reported in the secrets
+// layer - layer ID, if we are scanning directory inside container image
+// numSecrets - Number of secrets found so far, updated with the secrets of the package
+// @returns
+// []output.SecretFound - Secrets found, with paths like relPath!/package-relative/path
+// Error - Errors if any. Otherwise, returns nil
+func scanPackage(ctx context.Context, archivePath string, relPath string, layer string,
+	numSecrets *uint) ([]output.SecretFound, error) {
+	tempDir, err := core.MkdirTemp("package-")
+	if err != nil {
+		return nil, err
+	}
+	defer core.DeleteTmpDir(tempDir)
+
+	limits := newScanExtractLimits()
+	root, err := extractPackage(archivePath, packageKind(archivePath), tempDir, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExtractedFiles(ctx, root, relPath+archivePathSeparator, layer, 1, limits, numSecrets)
+}
+
+// Scan the files extracted from an archive
+// @parameters
+// ctx - Context of the scan
+// root - Dir where the archive files were extracted
+// pathPrefix - Prefix of the paths of the files reported in the secrets, their path relative to root follows it
+// layer - layer ID, if we are scanning directory inside container image
+// depth - Nesting depth of the archive, archives nested deeper than --max-archive-depth are not extracted
+// limits - Size limits of the extraction of the archive, shared with the archives nested in it
+// numSecrets - Number of secrets found so far, updated with the secrets of the archive
+// @returns
+// []output.SecretFound - Secrets found
+// Error - Errors if any. Otherwise, returns nil
+func scanExtractedFiles(ctx context.Context, root string, pathPrefix string, layer string, depth int,
+	limits *extractLimits, numSecrets *uint) ([]output.SecretFound, error) {
+	session := core.GetSession()
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+
+	var secretsFound []output.SecretFound
+	walkErr := filepath.WalkDir(root, func(path string, f os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		// System paths are not blacklisted inside archives, only the files are filtered
+		if f.IsDir() {
+			return nil
+		}
+
+		innerPath := pathPrefix + filepath.ToSlash(relativePath(root, "", path))
+		if recursiveArchive(path, f, depth) {
+			secrets, archiveErr := scanNestedArchive(ctx, path, innerPath, layer, depth+1, limits, numSecrets)
+			if archiveErr != nil {
+				log.Errorf("scanExtractedFiles: archive %s: %s", innerPath, archiveErr)
+				Coverage.AddErrored(innerPath, layer, archiveErr, len(secrets))
+			}
+			secretsFound = append(secretsFound, secrets...)
+			if *numSecrets >= *session.Options.MaxSecrets {
+				return maxSecretsExceeded
+			}
+			return nil
+		}
+		if reason := skipEntry(path, f, "", root, maxFileSize); reason != "" {
+			Coverage.AddSkipped(innerPath, layer, reason)
+			return nil
+		}
+
+		file := core.NewMatchFile(path)
+		secrets, scanErr := scanFile(ctx, file.Path, innerPath, file.Filename, file.Extension, layer, numSecrets)
+		if scanErr != nil {
+			log.Errorf("scanExtractedFiles: %s: %s", innerPath, scanErr)
+		}
+		fileSecrets := len(secrets)
+		secretsFound = append(secretsFound, secrets...)
+
+		secrets = allowed(signature.MatchSimpleSignatures(ctx, innerPath, file.Filename, file.Extension, layer, numSecrets),
+			numSecrets, nil)
+		fileSecrets += len(secrets)
+		secretsFound = append(secretsFound, secrets...)
+
+		Stats.AddFile()
+		if scanErr != nil {
+			Coverage.AddErrored(innerPath, layer, scanErr, fileSecrets)
+		} else {
+			Coverage.AddScanned(innerPath, layer, fileSecrets)
+		}
+
+		if *numSecrets >= *session.Options.MaxSecrets {
+			return maxSecretsExceeded
+		}
+		return nil
+	})
+	if walkErr != nil && walkErr != maxSecretsExceeded {
+		return secretsFound, walkErr
+	}
+	return secretsFound, nil
+}
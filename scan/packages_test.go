@@ -0,0 +1,164 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tarBytes(t *testing.T, files map[string]string, compress bool) []byte {
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeTestFile(t testing.TB, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func assertExtracted(t *testing.T, path, expected string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected %s to be extracted: %s", path, err)
+	}
+	if string(data) != expected {
+		t.Errorf("unexpected contents of %s: %q", path, data)
+	}
+}
+
+func Test_PackageKind(t *testing.T) {
+	for name, expected := range map[string]string{
+		"left-pad-1.3.0.tgz":                     npmPackage,
+		"requests-2.31.0-py3-none-any.whl":       wheelPackage,
+		"requests-2.31.0.tar.gz":                 sdistPackage,
+		"rails-7.1.0.GEM":                        gemPackage,
+		"index.js":                               "",
+		"dist/requests-2.31.0.tar.gz/readme.txt": "",
+	} {
+		if actual := packageKind(name); actual != expected {
+			t.Errorf("packageKind(%q) = %q, want %q", name, actual, expected)
+		}
+	}
+}
+
+func Test_ExtractNpmPackage(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, dir, "app-1.0.0.tgz", tarBytes(t, map[string]string{
+		"package/package.json": "{}",
+		"package/lib/.npmrc":   "//registry.npmjs.org/:_authToken=secret",
+	}, true))
+
+	dest := filepath.Join(dir, "out")
+	root, err := extractPackage(archive, npmPackage, dest, newExtractLimits(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != filepath.Join(dest, "package") {
+		t.Errorf("npm package root should be package/, got %s", root)
+	}
+	assertExtracted(t, filepath.Join(root, "lib", ".npmrc"), "//registry.npmjs.org/:_authToken=secret")
+}
+
+func Test_ExtractGemPackage(t *testing.T) {
+	dir := t.TempDir()
+	data := tarBytes(t, map[string]string{"lib/config.rb": "TOKEN = 'secret'"}, true)
+	var gem bytes.Buffer
+	tw := tar.NewWriter(&gem)
+	for name, contents := range map[string][]byte{"metadata.gz": {}, "data.tar.gz": data} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(contents)
+	}
+	tw.Close()
+	archive := writeTestFile(t, dir, "app-1.0.0.gem", gem.Bytes())
+
+	dest := filepath.Join(dir, "out")
+	root, err := extractPackage(archive, gemPackage, dest, newExtractLimits(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertExtracted(t, filepath.Join(root, "lib", "config.rb"), "TOKEN = 'secret'")
+}
+
+func Test_ExtractWheelPackage(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("app/settings.py")
+	w.Write([]byte("API_KEY = 'secret'"))
+	zw.Close()
+	archive := writeTestFile(t, dir, "app-1.0.0-py3-none-any.whl", buf.Bytes())
+
+	dest := filepath.Join(dir, "out")
+	root, err := extractPackage(archive, wheelPackage, dest, newExtractLimits(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertExtracted(t, filepath.Join(root, "app", "settings.py"), "API_KEY = 'secret'")
+}
+
+func Test_ExtractLimits(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, dir, "app.tgz", tarBytes(t, map[string]string{
+		"package/big.txt":   "0123456789",
+		"package/small.txt": "0123",
+	}, true))
+
+	dest := filepath.Join(dir, "out")
+	if _, err := extractPackage(archive, npmPackage, dest, newExtractLimits(5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "package", "big.txt")); !os.IsNotExist(err) {
+		t.Errorf("entries larger than the maximum file size should not be extracted")
+	}
+	assertExtracted(t, filepath.Join(dest, "package", "small.txt"), "0123")
+
+	limits := newExtractLimits(1024)
+	limits.maxTotalSize = 12
+	if _, err := extractPackage(archive, npmPackage, filepath.Join(dir, "out2"), limits); err != archiveTooLarge {
+		t.Errorf("expected the total size limit to stop the extraction, got %v", err)
+	}
+}
+
+func Test_SafeJoin(t *testing.T) {
+	dest := "/tmp/extract"
+	if target, err := safeJoin(dest, "package/../../etc/passwd"); err != nil || target != "/tmp/extract/etc/passwd" {
+		t.Errorf("relative escapes should stay inside the dir, got %s %v", target, err)
+	}
+	if target, err := safeJoin(dest, "/etc/passwd"); err != nil || target != "/tmp/extract/etc/passwd" {
+		t.Errorf("absolute names should stay inside the dir, got %s %v", target, err)
+	}
+}
@@ -0,0 +1,84 @@
+package scan
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_ScanPEMBlocks(t *testing.T) {
+	session := testSession(t)
+	options := session.Options
+	defer func(noEntropy, reportCertificates bool) {
+		*options.NoEntropy, *options.ReportCertificates = noEntropy, reportCertificates
+	}(*options.NoEntropy, *options.ReportCertificates)
+	*options.NoEntropy = true
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "example.com"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := append(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})...)
+
+	type key struct {
+		rule      string
+		severity  string
+		line      int
+		keyType   string
+		encrypted bool
+		bits      int
+	}
+	scanKeys := func() []key {
+		numSecrets := uint(0)
+		secrets, err := scanContents(context.Background(), contents, "tls/server.pem", "server.pem", ".pem", "",
+			&numSecrets, map[uint]uint{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []key
+		for _, secret := range secrets {
+			keys = append(keys, key{secret.RuleName, secret.Severity, secret.LineNumber, secret.KeyType,
+				secret.KeyEncrypted, secret.KeyBits})
+		}
+		return keys
+	}
+
+	privateKeyFound := key{signature.PEMPrivateKeyRuleName, output.HIGH, 1, "ED25519", false, 256}
+	if keys, expected := scanKeys(), []key{privateKeyFound}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("keys %+v, want %+v", keys, expected)
+	}
+	// The certificates are only reported with --report-certificates
+	*options.ReportCertificates = true
+	expected := []key{privateKeyFound, {signature.PEMCertificateRuleName, output.LOW, 4, "ED25519", false, 256}}
+	if keys := scanKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("keys %+v, want %+v", keys, expected)
+	}
+	// The private keys are not reported with their rule disabled
+	signature.SelectRules(len(session.Config.Signatures), nil, []string{strconv.Itoa(signature.PEMPrivateKeyRuleID)})
+	defer signature.SelectRules(0, nil, nil)
+	if keys := scanKeys(); !reflect.DeepEqual(keys, expected[1:]) {
+		t.Errorf("keys %+v with the private keys disabled, want %+v", keys, expected[1:])
+	}
+}
@@ -0,0 +1,173 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+// Platform of the manifests of multi-arch images not meant to be run, e.g. attestations
+const unknownPlatform = "unknown"
+
+// String Returns the platform as os/architecture[/variant], the format of --platform
+func (p ociPlatform) String() string {
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// Indicates if an image of the platform can be selected for the requested one.
+// The variant is only compared when one is requested
+func (p ociPlatform) matches(requested ociPlatform) bool {
+	return p.OS == requested.OS && p.Architecture == requested.Architecture &&
+		(requested.Variant == "" || p.Variant == requested.Variant)
+}
+
+func hostPlatform() ociPlatform {
+	return ociPlatform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+}
+
+// Parse a platform such as linux/amd64 or linux/arm/v7
+// @parameters
+// platform - Platform as os/architecture[/variant]
+// @returns
+// ociPlatform - Platform parsed
+// Error - Errors if the platform is not in that format. Otherwise, returns nil
+func parsePlatform(platform string) (ociPlatform, error) {
+	parts := strings.Split(platform, "/")
+	for _, part := range parts {
+		if part == "" {
+			parts = nil
+			break
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return ociPlatform{OS: parts[0], Architecture: parts[1]}, nil
+	case 3:
+		return ociPlatform{OS: parts[0], Architecture: parts[1], Variant: parts[2]}, nil
+	}
+	return ociPlatform{}, fmt.Errorf("invalid platform %q, expected os/architecture[/variant], e.g. linux/amd64", platform)
+}
+
+// Platform requested by --platform, nil if not set to scan the image of the host platform
+func sessionPlatform() (*ociPlatform, error) {
+	platform := *core.GetSession().Options.Platform
+	if platform == "" {
+		return nil, nil
+	}
+	parsed, err := parsePlatform(platform)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// Platform to select among the images of several platforms: the one requested, otherwise the host platform
+func selectedPlatform(requested *ociPlatform) ociPlatform {
+	if requested != nil {
+		return *requested
+	}
+	return hostPlatform()
+}
+
+// Error of a multi-arch image without an image for the platform, listing the platforms it has
+func platformNotFound(platform ociPlatform, available []string) error {
+	if len(available) == 0 {
+		return fmt.Errorf("no image for platform %s", platform)
+	}
+	return fmt.Errorf("no image for platform %s, available platforms: %s, select one with --platform",
+		platform, strings.Join(available, ", "))
+}
+
+// Descriptor of the manifest of a platform in an index
+// @parameters
+// index - Index of the manifests of several platforms
+// requested - Platform requested, nil for the host platform
+// @returns
+// ociDescriptor - Descriptor of the manifest of the platform
+// Error - Errors listing the platforms of the index if it has none for the platform. Otherwise, returns nil
+func platformManifest(index ociManifest, requested *ociPlatform) (ociDescriptor, error) {
+	platform := selectedPlatform(requested)
+	var available []string
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil || desc.Platform.OS == unknownPlatform {
+			continue
+		}
+		if desc.Platform.matches(platform) {
+			return desc, nil
+		}
+		available = append(available, desc.Platform.String())
+	}
+	return ociDescriptor{}, platformNotFound(platform, available)
+}
+
+// Image of the platform among the images of a docker save style manifest.json. The platform of
+// each image is read from its config. A single image is used as is unless a platform is requested
+// @parameters
+// path - Directory of the manifest.json and of the image configs
+// items - Images of the manifest.json
+// requested - Platform requested, nil for the host platform
+// @returns
+// manifestItem - Image of the platform
+// Error - Errors listing the platforms of the images if none is for the platform. Otherwise, returns nil
+func platformManifestItem(path string, items []manifestItem, requested *ociPlatform) (manifestItem, error) {
+	if len(items) == 0 {
+		return manifestItem{}, errors.New("no image in manifest.json")
+	}
+	if len(items) == 1 && requested == nil {
+		return items[0], nil
+	}
+
+	platform := selectedPlatform(requested)
+	var available []string
+	for _, item := range items {
+		itemPlatform, err := configPlatform(filepath.Join(path, item.Config))
+		if err != nil {
+			// Nothing to choose from, the platform can't be checked
+			if len(items) == 1 {
+				return item, nil
+			}
+			continue
+		}
+		if itemPlatform.matches(platform) {
+			return item, nil
+		}
+		available = append(available, itemPlatform.String())
+	}
+	return manifestItem{}, platformNotFound(platform, available)
+}
+
+// Platform of an image, from its config
+func configPlatform(configPath string) (ociPlatform, error) {
+	var platform ociPlatform
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return platform, err
+	}
+	if err = json.Unmarshal(data, &platform); err != nil {
+		return platform, err
+	}
+	if platform.OS == "" || platform.Architecture == "" {
+		return platform, fmt.Errorf("no platform in image config %s", configPath)
+	}
+	return platform, nil
+}
+
+// ValidateImageOptions Check the options selecting how images are read, before scanning
+// @returns
+// Error - Errors if --platform or --runtime is invalid. Otherwise, returns nil
+func ValidateImageOptions() error {
+	if _, err := sessionPlatform(); err != nil {
+		return err
+	}
+	_, err := selectRuntime(*core.GetSession().Options.Runtime, true, true)
+	return err
+}
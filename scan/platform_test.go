@@ -0,0 +1,89 @@
+package scan
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		expected ociPlatform
+		valid    bool
+	}{
+		{"linux/amd64", ociPlatform{OS: "linux", Architecture: "amd64"}, true},
+		{"linux/arm/v7", ociPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{"linux", ociPlatform{}, false},
+		{"linux/", ociPlatform{}, false},
+		{"linux/arm/v7/extra", ociPlatform{}, false},
+	}
+	for _, test := range tests {
+		platform, err := parsePlatform(test.platform)
+		if (err == nil) != test.valid || platform != test.expected {
+			t.Errorf("parsePlatform(%q) = %+v, %v", test.platform, platform, err)
+		}
+		if test.valid && platform.String() != test.platform {
+			t.Errorf("platform %q printed as %q", test.platform, platform.String())
+		}
+	}
+}
+
+func Test_PlatformManifest(t *testing.T) {
+	index := ociManifest{MediaType: mediaTypeOCIIndex, Manifests: []ociDescriptor{
+		{Digest: "sha256:amd64", Platform: &ociPlatform{OS: "linux", Architecture: "amd64"}},
+		{Digest: "sha256:arm64", Platform: &ociPlatform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{Digest: "sha256:attestation", Platform: &ociPlatform{OS: unknownPlatform, Architecture: unknownPlatform}},
+	}}
+
+	for platform, digest := range map[string]string{"linux/amd64": "sha256:amd64", "linux/arm64": "sha256:arm64", "linux/arm64/v8": "sha256:arm64"} {
+		requested, _ := parsePlatform(platform)
+		desc, err := platformManifest(index, &requested)
+		if err != nil || desc.Digest != digest {
+			t.Errorf("manifest of %s is %s, %v, want %s", platform, desc.Digest, err, digest)
+		}
+	}
+
+	_, err := platformManifest(index, &ociPlatform{OS: "linux", Architecture: "s390x"})
+	if err == nil || !strings.Contains(err.Error(), "available platforms: linux/amd64, linux/arm64/v8, select") {
+		t.Errorf("missing platform should be reported with the available ones, got %v", err)
+	}
+}
+
+func Test_PlatformManifestItem(t *testing.T) {
+	dir := t.TempDir()
+	items := []manifestItem{{Config: "amd64.json"}, {Config: "arm.json"}}
+	configs := map[string]ociPlatform{
+		"amd64.json": {OS: "linux", Architecture: "amd64"},
+		"arm.json":   {OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	for name, platform := range configs {
+		data, _ := json.Marshal(platform)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item, err := platformManifestItem(dir, items, &ociPlatform{OS: "linux", Architecture: "arm"})
+	if err != nil || !reflect.DeepEqual(item, items[1]) {
+		t.Errorf("image of linux/arm is %+v, %v", item, err)
+	}
+	_, err = platformManifestItem(dir, items, &ociPlatform{OS: "windows", Architecture: "amd64"})
+	if err == nil || !strings.Contains(err.Error(), "linux/amd64, linux/arm/v7") {
+		t.Errorf("missing platform should be reported with the available ones, got %v", err)
+	}
+
+	// A single image is scanned as before, unless another platform is requested
+	if item, err := platformManifestItem(dir, items[1:], nil); err != nil || !reflect.DeepEqual(item, items[1]) {
+		t.Errorf("single image should be used, got %+v, %v", item, err)
+	}
+	if _, err := platformManifestItem(dir, items[1:], &ociPlatform{OS: "linux", Architecture: "amd64"}); err == nil {
+		t.Errorf("single image of another platform should be rejected")
+	}
+	if _, err := platformManifestItem(dir, nil, nil); err == nil {
+		t.Errorf("manifest.json without images should be rejected")
+	}
+}
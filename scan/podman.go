@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Container runtimes saving images, selected by --runtime
+const (
+	autoRuntimeName   = ""
+	dockerRuntimeName = "docker"
+	podmanRuntimeName = "podman"
+)
+
+// Socket of the docker daemon, podman is used when it is absent
+const dockerSocketPath = "/var/run/docker.sock"
+
+// Runtime saving the image: the one requested, otherwise podman on hosts without docker
+// @parameters
+// requested - Runtime requested by --runtime, empty to auto-detect
+// dockerAvailable - Indicates if the docker daemon can be reached
+// podmanInstalled - Indicates if the podman CLI is installed
+// @returns
+// string - Runtime to save the image with
+// Error - Errors if the runtime requested is not supported. Otherwise, returns nil
+func selectRuntime(requested string, dockerAvailable bool, podmanInstalled bool) (string, error) {
+	switch requested {
+	case dockerRuntimeName, podmanRuntimeName:
+		return requested, nil
+	case autoRuntimeName:
+		if !dockerAvailable && podmanInstalled {
+			return podmanRuntimeName, nil
+		}
+		return dockerRuntimeName, nil
+	}
+	return "", fmt.Errorf("unsupported runtime %q, expected %s or %s", requested, dockerRuntimeName, podmanRuntimeName)
+}
+
+// Runtime saving the images of the session
+func sessionRuntime() (string, error) {
+	session := core.GetSession()
+	socket := dockerSocketPath
+	if *session.Options.HostMountPath != "" {
+		socket = filepath.Join(*session.Options.HostMountPath, socket)
+	}
+	_, err := os.Stat(socket)
+	dockerAvailable := err == nil || os.Getenv("DOCKER_HOST") != ""
+	_, err = exec.LookPath("podman")
+	return selectRuntime(*session.Options.Runtime, dockerAvailable, err == nil)
+}
+
+// Save the container image with the podman CLI, which works on rootless hosts without the podman API service.
+// The docker-archive format has the single image manifest.json read by extractDetailsFromManifest
+// @parameters
+// imageName - Name of the image to save
+// outputParam - Path of the tarball to write
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func savePodmanImage(imageName string, outputParam string) error {
+	_, stderr, exitCode := runCommand("podman", "save", "--format", "docker-archive", "-o", outputParam, imageName)
+	if exitCode != 0 {
+		return fmt.Errorf("podman save %s: %s", imageName, strings.TrimSpace(stderr))
+	}
+	log.Debugf("Image %s saved with podman", imageName)
+	return nil
+}
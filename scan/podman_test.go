@@ -0,0 +1,27 @@
+package scan
+
+import "testing"
+
+func Test_SelectRuntime(t *testing.T) {
+	for _, test := range []struct {
+		requested       string
+		dockerAvailable bool
+		podmanInstalled bool
+		runtime         string
+	}{
+		{"", true, true, dockerRuntimeName},
+		{"", false, true, podmanRuntimeName},
+		{"", false, false, dockerRuntimeName},
+		{"podman", true, false, podmanRuntimeName},
+		{"docker", false, true, dockerRuntimeName},
+	} {
+		runtime, err := selectRuntime(test.requested, test.dockerAvailable, test.podmanInstalled)
+		if err != nil || runtime != test.runtime {
+			t.Errorf("%+v: runtime %q, error %v, want %q", test, runtime, err, test.runtime)
+		}
+	}
+
+	if _, err := selectRuntime("rkt", true, true); err == nil {
+		t.Errorf("unsupported runtime should be rejected")
+	}
+}
@@ -2,7 +2,7 @@ package scan
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"strings"
 
 	"github.com/khulnasoft-lab/vessel"
@@ -19,7 +19,7 @@ import (
 
 type ContainerScan struct {
 	containerId string
-	tempDir     string
+	tempDir     string // Filesystem of the container: exported to a temp dir, or its root filesystem when scanned in place
 	namespace   string
 	numSecrets  uint
 }
@@ -47,8 +47,7 @@ func (containerScan *ContainerScan) extractFileSystem() error {
 		containerRuntimeInterface = podmanRuntime.New(endpoint)
 	}
 	if containerRuntimeInterface == nil {
-		log.Error("Error: Could not detect container runtime")
-		os.Exit(1)
+		return fmt.Errorf("could not detect container runtime %s", containerRuntime)
 	}
 	err = containerRuntimeInterface.ExtractFileSystemContainer(
 		containerScan.containerId, containerScan.namespace,
@@ -95,18 +94,18 @@ func (containerScan *ContainerScan) scan(scanCtx *tasks.ScanContext) ([]output.S
 // @returns
 // []output.SecretFound - List of all secrets found
 // Error - Errors, if any. Otherwise, returns nil
-func (containerScan *ContainerScan) scanStream(scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+func (containerScan *ContainerScan) scanStream(scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
 	var isFirstSecret bool = true
 
-	stream, err := ScanSecretsInDirStream("", containerScan.tempDir,
+	stream, status, err := ScanSecretsInDirStream("", containerScan.tempDir,
 		containerScan.tempDir, &isFirstSecret, scanCtx)
 
 	if err != nil {
 		log.Errorf("findSecretsInContainer: %s", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return stream, nil
+	return stream, status, nil
 }
 
 type ContainerExtractionResult struct {
@@ -114,9 +113,28 @@ type ContainerExtractionResult struct {
 	ContainerId string
 }
 
+// Scan the root filesystem of a container in place when it can be read, otherwise export it to a temp dir first
+// @parameters
+// containerId - ID of the container
+// namespace - containerd namespace of the container, empty for docker and podman
+// @returns
+// *ContainerExtractionResult - Secrets found in the container
+// Error - Errors if any. Otherwise, returns nil
 func ExtractAndScanContainer(containerId string, namespace string,
 	scanCtx *tasks.ScanContext) (*ContainerExtractionResult, error) {
 
+	rootfs, inPlaceErr := locateContainerRootfs(containerId, namespace)
+	if inPlaceErr == nil {
+		log.Debugf("Scanning container %s in place at %s", containerId, rootfs)
+		containerScan := ContainerScan{containerId: containerId, tempDir: rootfs, namespace: namespace}
+		secrets, err := containerScan.scan(scanCtx)
+		if err != nil {
+			return nil, err
+		}
+		return &ContainerExtractionResult{ContainerId: containerId, Secrets: secrets}, nil
+	}
+	log.Infof("Exporting the filesystem of container %s, it can't be scanned in place: %s", containerId, inPlaceErr)
+
 	tempDir, err := core.GetTmpDir(containerId)
 	if err != nil {
 		return nil, err
@@ -127,7 +145,7 @@ func ExtractAndScanContainer(containerId string, namespace string,
 	err = containerScan.extractFileSystem()
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w (scanning in place failed: %s)", err, inPlaceErr)
 	}
 
 	secrets, err := containerScan.scan(scanCtx)
@@ -138,11 +156,27 @@ func ExtractAndScanContainer(containerId string, namespace string,
 	return &ContainerExtractionResult{ContainerId: containerScan.containerId, Secrets: secrets}, nil
 }
 
+// Stream the secrets of a container, scanned in place when its root filesystem can be read
+// @parameters
+// containerId - ID of the container
+// namespace - containerd namespace of the container, empty for docker and podman
+// @returns
+// chan output.SecretFound - Channel of the secrets found
+// *StreamStatus - Status of the scan, to be read once the channel is closed
+// Error - Errors if any. Otherwise, returns nil
 func ExtractAndScanContainerStream(containerId string, namespace string,
-	scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+	scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
+	rootfs, inPlaceErr := locateContainerRootfs(containerId, namespace)
+	if inPlaceErr == nil {
+		log.Debugf("Scanning container %s in place at %s", containerId, rootfs)
+		containerScan := ContainerScan{containerId: containerId, tempDir: rootfs, namespace: namespace}
+		return containerScan.scanStream(scanCtx)
+	}
+	log.Infof("Exporting the filesystem of container %s, it can't be scanned in place: %s", containerId, inPlaceErr)
+
 	tempDir, err := core.GetTmpDir(containerId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	containerScan := ContainerScan{containerId: containerId, tempDir: tempDir, namespace: namespace}
@@ -150,25 +184,26 @@ func ExtractAndScanContainerStream(containerId string, namespace string,
 
 	if err != nil {
 		core.DeleteTmpDir(tempDir)
-		return nil, err
+		return nil, nil, fmt.Errorf("%w (scanning in place failed: %s)", err, inPlaceErr)
 	}
 
-	stream, err := containerScan.scanStream(scanCtx)
+	stream, status, err := containerScan.scanStream(scanCtx)
 
 	if err != nil {
 		core.DeleteTmpDir(tempDir)
-		return nil, err
+		return nil, nil, err
 	}
 
 	res := make(chan output.SecretFound, secret_pipeline_size)
 
 	go func() {
-		defer core.DeleteTmpDir(tempDir)
+		// The temp dir is deleted before the end of the stream, the process may exit right after it
 		defer close(res)
+		defer core.DeleteTmpDir(tempDir)
 		for i := range stream {
 			res <- i
 		}
 	}()
 
-	return res, nil
+	return res, status, nil
 }
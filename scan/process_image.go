@@ -4,7 +4,7 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,10 +14,14 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/khulnasoft-lab/vessel"
+	"github.com/khulnasoft-lab/SecretScanner/allowlist"
 	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/metrics"
 	"github.com/khulnasoft-lab/SecretScanner/output"
 	"github.com/khulnasoft-lab/SecretScanner/signature"
 	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
@@ -35,6 +39,8 @@ type manifestItem struct {
 var (
 	imageTarFileName   = "save-output.tar"
 	maxSecretsExceeded = errors.New("number of secrets exceeded max-secrets")
+	gzipMagic          = []byte{0x1f, 0x8b}
+	zstdMagic          = []byte{0x28, 0xb5, 0x2f, 0xfd}
 )
 
 const (
@@ -47,6 +53,10 @@ type ImageScan struct {
 	tempDir       string
 	imageManifest manifestItem
 	numSecrets    uint
+	// Platform of the image scanned in multi-arch images, nil for the host platform
+	platform *ociPlatform
+	// Budget of the extraction of the image tar and its layers
+	budget *extractBudget
 }
 
 // Function to retrieve contents of container images layer by layer
@@ -58,22 +68,57 @@ func (imageScan *ImageScan) extractImage(saveImage bool) error {
 	imageName := imageScan.imageName
 	tempDir := imageScan.tempDir
 	imageScan.numSecrets = 0
+	imageScan.budget = newSessionExtractBudget()
+	platform, err := sessionPlatform()
+	if err != nil {
+		return err
+	}
+	imageScan.platform = platform
+
+	// Layout of a saved image already written in the temp dir, without saving a tar
+	prepared := false
+	// Images of a containerd namespace are exported from its content store, without docker
+	containerNS := *core.GetSession().Options.ContainerNS
+	if saveImage && (*core.GetSession().Options.FromContentStore || containerNS != "") {
+		err := imageScan.loadFromContentStore()
+		if err != nil && containerNS != "" {
+			// Docker doesn't see the images of containerd namespaces, don't scan another image of the same name
+			log.Errorf("scanImage: Could not read image %s from containerd namespace %s: %s", imageName, containerNS, err)
+			return fmt.Errorf("image %s in containerd namespace %s: %w", imageName, containerNS, err)
+		} else if err != nil {
+			log.Warnf("scanImage: Could not read image from content store: %s. Falling back to image save", err)
+		} else {
+			prepared = true
+		}
+	}
 
-	if saveImage {
-		err := imageScan.saveImageData()
+	// Pull from the registry when it has credentials or when asked to, else save with the container runtime
+	if saveImage && !prepared && imageScan.pullsFromRegistry() {
+		err := imageScan.pullFromRegistry()
 		if err != nil {
-			log.Errorf("scanImage: Could not save container image: %s. Check if the image name is correct.", err)
-			return err
+			log.Warnf("scanImage: Could not pull image from registry: %s. Falling back to image save", err)
+		} else {
+			prepared = true
 		}
 	}
 
-	_, err := extractTarFile(imageName, path.Join(tempDir, imageTarFileName), tempDir)
-	if err != nil {
-		log.Errorf("scanImage: Could not extract image tar file: %s", err)
-		return err
+	if !prepared {
+		if saveImage {
+			err := imageScan.saveImageData()
+			if err != nil {
+				log.Errorf("scanImage: Could not save container image: %s. Check if the image name is correct.", err)
+				return err
+			}
+		}
+
+		_, err := extractTarFile(imageName, path.Join(tempDir, imageTarFileName), tempDir, imageScan.budget)
+		if err != nil {
+			log.Errorf("scanImage: Could not extract image tar file: %s", err)
+			return err
+		}
 	}
 
-	imageManifest, err := extractDetailsFromManifest(tempDir)
+	imageManifest, err := extractDetailsFromManifest(tempDir, platform)
 	if err != nil {
 		log.Errorf("ProcessImageLayers: Could not get image's history: %s,"+
 			" please specify repo:tag and check disk space", err.Error())
@@ -112,42 +157,148 @@ func (imageScan *ImageScan) scan(scanCtx *tasks.ScanContext) ([]output.SecretFou
 // @returns
 // []output.SecretFound - List of all secrets found
 // Error - Errors, if any. Otherwise, returns nil
-func (imageScan *ImageScan) scanStream(scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+func (imageScan *ImageScan) scanStream(scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
 	return imageScan.processImageLayersStream(imageScan.tempDir, scanCtx)
 }
 
+// readFile Returns the raw contents of a file, so that the offsets, lines and columns of the matches
+// are the ones of the file, whatever its line endings and line lengths
 func readFile(path string) ([]byte, error) {
-	var content string
-	file, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+	return os.ReadFile(path)
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) == 0 {
-			continue
+// scanFile Match the signatures against a file. The matches of each rule are counted for this file only, so
+// that --max-multi-match and max_matches limit the matches per file
+func scanFile(ctx context.Context, filePath, relPath, fileName, fileExtension, layer string,
+	numSecrets *uint) ([]output.SecretFound, error) {
+	matchedRuleSet := map[uint]uint{}
+	if extractsBinaryStrings(relPath) {
+		if secrets, binary, err := scanBinaryFile(ctx, filePath, relPath, fileName, fileExtension, layer, numSecrets,
+			matchedRuleSet); binary {
+			return secrets, err
 		}
-		content += scanner.Text() + "\n"
 	}
-	return []byte(content), nil
-}
-
-func scanFile(filePath, relPath, fileName, fileExtension, layer string, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	if info, err := os.Stat(filePath); err == nil && info.Size() > streamFileThreshold {
+		metrics.BytesScanned.Add(info.Size())
+		return scanFileWindows(ctx, filePath, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	}
 	contents, err := readFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	metrics.BytesScanned.Add(int64(len(contents)))
+	return scanContents(ctx, contents, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+}
+
+// scanContents Match the pattern and high entropy signatures against the contents of a file
+func scanContents(ctx context.Context, contents []byte, relPath, fileName, fileExtension, layer string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
 	// fmt.Println(relPath, file.Filename, file.Extension, layer)
-	secrets, err := signature.MatchPatternSignatures(contents, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
+	// The values of the Terraform states and plans are matched one by one, and reported with their address
+	if isTerraformJSON(fileName, fileExtension, contents) {
+		if secrets, parsed, err := scanTerraformJSON(ctx, contents, relPath, fileName, fileExtension, layer, numSecrets,
+			matchedRuleSet); parsed || err != nil {
+			return secrets, err
+		}
+	}
+	secrets, err := signature.MatchPatternSignatures(ctx, contents, relPath, fileName, fileExtension, layer, numSecrets, matchedRuleSet)
 	if err != nil {
 		return nil, err
 	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !*core.GetSession().Options.NoEntropy {
+		secrets = append(secrets, signature.MatchHighEntropyStrings(ctx, contents, relPath, layer, secrets, numSecrets, matchedRuleSet)...)
+	}
+	if *core.GetSession().Options.DecodeBase64 {
+		decoded, err := matchBase64Encoded(ctx, contents, relPath, layer, numSecrets, matchedRuleSet)
+		secrets = append(secrets, decoded...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *core.GetSession().Options.ScanEnvFiles && signature.IsEnvFile(fileName) {
+		secrets = append(secrets, signature.MatchEnvFile(ctx, contents, relPath, layer, secrets, numSecrets)...)
+	}
+	secrets = append(secrets, signature.MatchPEMBlocks(ctx, contents, relPath, layer, secrets, numSecrets)...)
+	secrets = allowed(secrets, numSecrets, matchedRuleSet)
+	locateSecrets(contents, secrets)
+	annotateYAMLDocuments(contents, fileExtension, secrets)
+	annotateKeyPaths(contents, fileExtension, secrets)
+
+	if *core.GetSession().Options.DecodeK8sSecrets && yamlExtensions[strings.ToLower(fileExtension)] {
+		k8sSecrets, err := scanK8sSecrets(ctx, contents, relPath, fileName, fileExtension, layer, numSecrets)
+		secrets = append(secrets, k8sSecrets...)
+		if err != nil {
+			return secrets, err
+		}
+	}
 	return secrets, nil
 }
 
+// allowed Drops the secrets matching the allowlist, which then don't count towards the max secrets, nor towards the
+// matches of their rule
+// @parameters
+// secrets - Secrets found in a file
+// numSecrets - Number of secrets found so far, including the given secrets
+// matchedRuleSet - Matches of each rule in the file so far, including the given secrets, nil if not counted
+// @returns
+// []output.SecretFound - Secrets to report
+func allowed(secrets []output.SecretFound, numSecrets *uint, matchedRuleSet map[uint]uint) []output.SecretFound {
+	current := allowlist.Current()
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		if !current.Allows(secret) {
+			kept = append(kept, secret)
+			continue
+		}
+		*numSecrets--
+		// Suppressed matches don't count as matches of their rule
+		if id := uint(secret.RuleID); secret.Severity != output.SUPPRESSED && matchedRuleSet[id] > 0 {
+			if matchedRuleSet[id]--; matchedRuleSet[id] == 0 {
+				delete(matchedRuleSet, id)
+			}
+		}
+	}
+	return aboveMinSeverity(kept, numSecrets)
+}
+
+// aboveMinSeverity Drops the secrets below --min-severity. Unless --count-all is set, they
+// don't count towards the max secrets either
+// @parameters
+// secrets - Secrets found in a file
+// numSecrets - Number of secrets found so far, including the given secrets
+// @returns
+// []output.SecretFound - Secrets of the minimum severity or a higher one
+func aboveMinSeverity(secrets []output.SecretFound, numSecrets *uint) []output.SecretFound {
+	options := core.GetSession().Options
+	if *options.MinSeverity == "" {
+		return secrets
+	}
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		if output.SeverityAtLeast(secret.Severity, *options.MinSeverity) {
+			kept = append(kept, secret)
+		}
+	}
+	filtered := len(secrets) - len(kept)
+	Stats.AddFiltered(filtered)
+	if !*options.CountAll {
+		*numSecrets -= uint(filtered)
+	}
+	return kept
+}
+
+// Context of a scan, done when the scan is stopped. Scans without a ScanContext, e.g. the ones
+// of the CLI, use the context of the session
+func scanContext(scanCtx *tasks.ScanContext) context.Context {
+	if scanCtx != nil && scanCtx.Context != nil {
+		return scanCtx.Context
+	}
+	return core.GetSession().Context
+}
+
 // ScanSecretsInDir Scans a given directory recursively to find all secrets inside any file in the dir
 // @parameters
 // layer - layer ID, if we are scanning directory inside container image
@@ -160,101 +311,15 @@ func scanFile(filePath, relPath, fileName, fileExtension, layer string, numSecre
 func ScanSecretsInDir(layer string, baseDir string, fullDir string,
 	isFirstSecret *bool, scanCtx *tasks.ScanContext) ([]output.SecretFound, error) {
 	var secretsFound []output.SecretFound
-	matchedRuleSet := map[uint]uint{}
-
-	session := core.GetSession()
 
 	if layer != "" {
 		core.UpdateDirsPermissionsRW(fullDir)
 	}
 
-	maxFileSize := *session.Options.MaximumFileSize * 1024
-	numSecrets := uint(0)
-
-	walkErr := filepath.WalkDir(fullDir, func(path string, f os.DirEntry, err error) error {
-		if err != nil {
-			log.Debugf("Error in filepath.Walk: %s", err)
-			return err
-		}
-
-		err = scanCtx.Checkpoint("walking in directories")
-		if err != nil {
-			return err
-		}
-
-		var scanDirPath string
-		if layer != "" {
-			scanDirPath = strings.TrimPrefix(path, baseDir+"/"+layer)
-			if scanDirPath == "" {
-				scanDirPath = "/"
-			}
-		} else {
-			scanDirPath = path
-		}
-
-		if f.IsDir() {
-			if core.IsSkippableDir(scanDirPath, baseDir) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// No need to scan sym links. This avoids hangs when scanning stderr, stdour or special file descriptors
-		// Also, the pointed files will anyway be scanned directly
-		if !f.Type().IsRegular() {
-			return nil
-		}
-
-		finfo, err := f.Info()
-		if err != nil {
-			log.Warnf("Skipping %v as info could not be retrieved: %v", path, err)
-			return nil
-		}
-
-		if uint(finfo.Size()) > maxFileSize || core.IsSkippableFileExtension(path) {
-			return nil
-		}
-
-		file := core.NewMatchFile(path)
-
-		relPath, err := filepath.Rel(filepath.Join(baseDir, layer), file.Path)
-		if err != nil {
-			log.Warnf("scanSecretsInDir: Couldn't remove prefix of path: %s %s %s",
-				baseDir, layer, file.Path)
-			relPath = file.Path
-		}
-
-		// Add RW permissions for reading and deleting contents of containers, not for regular file system
-		if layer != "" {
-			err = os.Chmod(file.Path, 0600)
-			if err != nil {
-				log.Errorf("scanSecretsInDir changine file permission: %s", err)
-			}
-		}
-
-		log.Debugf("attempting scanFile on: %+v, relPath: %s", file, relPath)
-
-		secrets, err := scanFile(file.Path, relPath, file.Filename, file.Extension, layer, &numSecrets, matchedRuleSet)
-		if err != nil {
-			log.Infof("relPath: %s, Filename: %s, Extension: %s, layer: %s", relPath, file.Filename, file.Extension, layer)
-			log.Errorf("scanSecretsInDir: %s", err)
-		} else {
-			if len(secrets) > 0 {
-				secretsFound = append(secretsFound, secrets...)
-			}
-		}
-
-		secrets = signature.MatchSimpleSignatures(relPath, file.Filename, file.Extension, layer, &numSecrets)
+	walkErr := walkDirSecrets(layer, baseDir, fullDir, scanCtx, func(secrets []output.SecretFound) bool {
 		secretsFound = append(secretsFound, secrets...)
-
-		log.Debugf("scan completed for file: %+v, numSecrets: %d", file, numSecrets)
-
-		// Don't report secrets if number of secrets exceeds MAX value
-		if numSecrets >= *session.Options.MaxSecrets {
-			return maxSecretsExceeded
-		}
-		return nil
-	})
+		return true
+	}, func(err error) {})
 
 	if walkErr != nil {
 		if walkErr == maxSecretsExceeded {
@@ -274,15 +339,14 @@ func ScanSecretsInDir(layer string, baseDir string, fullDir string,
 // fullDir - Complete path of the directory to be scanned
 // isFirstSecret - indicates if some secrets are already printed, used to properly format json
 // @returns
-// chan output.SecretFound - Channel of all secrets found
+// chan output.SecretFound - Channel of all secrets found, at most --max-secrets
+// *StreamStatus - Status of the scan, to be read once the channel is closed
 // Error - Errors if any. Otherwise, returns nil
 func ScanSecretsInDirStream(layer string, baseDir string, fullDir string,
-	isFirstSecret *bool, scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+	isFirstSecret *bool, scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
 
 	res := make(chan output.SecretFound, secret_pipeline_size)
-
-	matchedRuleSet := map[uint]uint{}
-	numSecrets := uint(0)
+	sink := newSecretSink(res, *core.GetSession().Options.MaxSecrets)
 
 	if layer != "" {
 		core.UpdateDirsPermissionsRW(fullDir)
@@ -291,103 +355,217 @@ func ScanSecretsInDirStream(layer string, baseDir string, fullDir string,
 	go func() {
 
 		defer close(res)
-		session := core.GetSession()
-		maxFileSize := *session.Options.MaximumFileSize * 1024
-
-		walkErr := filepath.WalkDir(fullDir, func(path string, f os.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-
-			err = scanCtx.Checkpoint("walking in directories")
-			if err != nil {
-				return err
+		walkErr := walkDirSecrets(layer, baseDir, fullDir, scanCtx, func(secrets []output.SecretFound) bool {
+			for i := range secrets {
+				sink.send(secrets[i])
 			}
-
-			var scanDirPath string
-			if layer != "" {
-				scanDirPath = strings.TrimPrefix(path, baseDir+"/"+layer)
-				if scanDirPath == "" {
-					scanDirPath = "/"
-				}
+			// The walk stops by itself once it drops a secret past --max-secrets
+			return !sink.status.Truncated
+		}, sink.cancel)
+		if walkErr != nil {
+			if walkErr == maxSecretsExceeded {
+				sink.status.Truncated = true
+				log.Warnf("filepath.Walk: %s", walkErr)
 			} else {
-				scanDirPath = path
+				log.Errorf("Error in filepath.Walk: %s", walkErr)
 			}
+		}
+	}()
+	return res, sink.status, nil
+}
 
-			if f.IsDir() {
-				if core.IsSkippableDir(scanDirPath, baseDir) {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+// Result of the extraction and scan of one image layer
+type layerResult struct {
+	secrets []output.SecretFound
+	err     error
+	partial bool // Some files of the layer could not be extracted
+}
 
-			// No need to scan sym links. This avoids hangs when scanning stderr, stdour or special file descriptors
-			// Also, the pointed files will anyway be scanned directly
-			if !f.Type().IsRegular() {
-				return nil
-			}
+// Number of times the layers are scanned: once per layer, or once for the whole image with --flatten
+func (imageScan *ImageScan) layersToScan() int {
+	if *core.GetSession().Options.Flatten {
+		return 1
+	}
+	return len(imageScan.imageManifest.LayerIds)
+}
 
-			finfo, err := f.Info()
-			if err != nil {
-				log.Warnf("Skipping %v as info could not be retrieved: %v", path, err)
-				return nil
-			}
+// Extract and scan the layers of the container image with up to --workers-per-scan layers at a time
+// @parameters
+// imageScan - Structure with details of the container image to scan
+// imageManifestPath - Complete path of directory where manifest of image has been extracted
+// handle - Called with the result of each layer, in the order of the layers, last is set for the top-most one.
+// Layers not started yet are skipped once it returns false. With --flatten, it is called once for the whole image
+func (imageScan *ImageScan) scanLayers(imageManifestPath string, scanCtx *tasks.ScanContext,
+	handle func(layerID string, secrets []output.SecretFound, err error, last bool) bool) {
 
-			if uint(finfo.Size()) > maxFileSize || core.IsSkippableFileExtension(path) {
-				return nil
-			}
+	// extractPath - Base directory where all the layers should be extracted to
+	extractPath := path.Join(imageManifestPath, core.ExtractedImageFilesDir)
+	layerIDs := imageScan.imageManifest.LayerIds
+	layerPaths := imageScan.imageManifest.Layers
 
-			file := core.NewMatchFile(path)
+	if *core.GetSession().Options.Flatten {
+		secrets, err := imageScan.scanFlattened(imageManifestPath, extractPath, scanCtx)
+		handle(flattenedRootfsDir, secrets, err, true)
+		return
+	}
 
-			relPath, err := filepath.Rel(filepath.Join(baseDir, layer), file.Path)
+	// Files deleted by a higher layer are not in the image, the whiteouts of all the layers are needed
+	// before the secrets of the lowest one can be reported
+	whiteouts := make([]*layerWhiteouts, len(layerPaths))
+	if !*core.GetSession().Options.NoWhiteout {
+		for i := range layerPaths {
+			var err error
+			whiteouts[i], err = readWhiteouts(path.Join(imageManifestPath, layerPaths[i]))
 			if err != nil {
-				log.Warnf("scanSecretsInDir: Couldn't remove prefix of path: %s %s %s",
-					baseDir, layer, file.Path)
-				relPath = file.Path
+				log.Warnf("ProcessImageLayers: Unable to read the whiteouts of layer %s: %s", layerIDs[i], err)
 			}
+		}
+	}
 
-			// Add RW permissions for reading and deleting contents of containers, not for regular file system
-			if layer != "" {
-				err = os.Chmod(file.Path, 0600)
-				if err != nil {
-					log.Errorf("scanSecretsInDir changine file permission: %s", err)
-				}
+	// Layers shared with images scanned before are neither extracted nor matched again
+	cache := sessionLayerCache()
+	maxSecrets := *core.GetSession().Options.MaxSecrets
+	runInOrder(len(layerPaths), *core.GetSession().Options.WorkersPerScan,
+		func(i int) layerResult {
+			if secrets, ok := cache.Get(layerIDs[i]); ok {
+				log.Debugf("ProcessImageLayers: secrets of layer %s found in the cache", layerIDs[i])
+				return layerResult{secrets: secrets}
 			}
-			secrets, err := scanFile(file.Path, relPath, file.Filename, file.Extension, layer, &numSecrets, matchedRuleSet)
-
-			if err != nil {
-				log.Infof("relPath: %s, Filename: %s, Extension: %s, layer: %s", relPath, file.Filename, file.Extension, layer)
-				log.Errorf("scanSecretsInDir: %s", err)
-			} else {
-				if len(secrets) > 0 {
-					for i := range secrets {
-						res <- secrets[i]
-					}
+			result := imageScan.scanLayer(imageManifestPath, extractPath, layerPaths[i], layerIDs[i], scanCtx)
+			// Only cache complete scans of the layer
+			if result.err == nil && !result.partial && uint(len(result.secrets)) < maxSecrets &&
+				scanCtx.Checkpoint("caching layer") == nil {
+				if err := cache.Put(layerIDs[i], result.secrets); err != nil {
+					log.Warnf("ProcessImageLayers: Unable to cache the secrets of layer %s: %s", layerIDs[i], err)
 				}
 			}
-
-			secrets = signature.MatchSimpleSignatures(relPath, file.Filename, file.Extension, layer, &numSecrets)
-			for i := range secrets {
-				res <- secrets[i]
-			}
-			// Don't report secrets if number of secrets exceeds MAX value
-			if numSecrets >= *session.Options.MaxSecrets {
-				return maxSecretsExceeded
-			}
-			return nil
+			return result
+		},
+		func(i int, result layerResult) bool {
+			return handle(layerIDs[i], applyWhiteouts(result.secrets, whiteouts[i+1:]), result.err, i == len(layerPaths)-1)
 		})
-		if walkErr != nil {
-			if walkErr == maxSecretsExceeded {
-				log.Warnf("filepath.Walk: %s", walkErr)
-			} else {
-				log.Errorf("Error in filepath.Walk: %s", walkErr)
+}
+
+// Overlay the layers of the container image into a single rootfs and find secrets in it once
+// @parameters
+// imageManifestPath - Complete path of directory where manifest of image has been extracted
+// extractPath - Base directory where the rootfs is extracted to
+// @returns
+// []output.SecretFound - Secrets found, with the ID of the top-most layer providing their file
+// Error - Errors if any. Otherwise, returns nil
+func (imageScan *ImageScan) scanFlattened(imageManifestPath, extractPath string,
+	scanCtx *tasks.ScanContext) ([]output.SecretFound, error) {
+	targetDir := path.Join(extractPath, flattenedRootfsDir)
+	rootfs, err := flattenLayers(imageManifestPath, imageScan.imageManifest.Layers, targetDir, imageScan.budget)
+	if err != nil {
+		log.Errorf("ProcessImageLayers: Unable to flatten image layers... %s", err)
+		return nil, err
+	}
+
+	log.Debugf("Analyzing dir: %s", targetDir)
+	var isFirstSecret bool = true
+	secrets, err := ScanSecretsInDir(flattenedRootfsDir, extractPath, targetDir, &isFirstSecret, scanCtx)
+	for i := range secrets {
+		secrets[i].LayerID = rootfs.layerOf(secrets[i].CompleteFilename, imageScan.imageManifest.LayerIds)
+	}
+	return secrets, err
+}
+
+// Run tasks concurrently while handling their results sequentially, in the order of the tasks
+// @parameters
+// n - Number of tasks
+// workers - Maximum number of tasks run at a time, at least 1
+// run - Runs the task i
+// handle - Called with the result of each task, in order. Tasks not started yet are skipped once it returns false
+func runInOrder(n int, workers int, run func(i int) layerResult, handle func(i int, result layerResult) bool) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var stopped atomic.Bool
+	results := make([]chan layerResult, n)
+	for i := range results {
+		results[i] = make(chan layerResult, 1)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if stopped.Load() {
+					results[i] <- layerResult{}
+					continue
+				}
+				results[i] <- run(i)
 			}
+		}()
+	}
+	// At most workers tasks are running or waiting to be handled, so results are not piling up in memory
+	pending := make(chan struct{}, workers)
+	go func() {
+		for i := 0; i < n; i++ {
+			pending <- struct{}{}
+			next <- i
 		}
+		close(next)
 	}()
-	return res, nil
+
+	// Tasks are started in order, so waiting for them in order never blocks a worker
+	for i := range results {
+		result := <-results[i]
+		<-pending
+		if !stopped.Load() && !handle(i, result) {
+			stopped.Store(true)
+		}
+	}
+	wg.Wait()
+}
+
+// Extract one layer of the container image and find secrets in it
+// @parameters
+// imageManifestPath - Complete path of directory where manifest of image has been extracted
+// extractPath - Base directory where all the layers are extracted to
+// layerPath - Path of the layer tarball, relative to imageManifestPath
+// layerID - ID of the layer
+// @returns
+// layerResult - Secrets found in the layer, with an error if the layer could not be extracted at all
+func (imageScan *ImageScan) scanLayer(imageManifestPath, extractPath, layerPath, layerID string,
+	scanCtx *tasks.ScanContext) layerResult {
+	// Layers are not extracted anymore once the scan is cancelled
+	if err := scanContext(scanCtx).Err(); err != nil {
+		return layerResult{err: err}
+	}
+	log.Debugf("Analyzing layer path: %s", layerPath)
+	log.Debugf("Analyzing layer: %s", layerID)
+	completeLayerPath := path.Join(imageManifestPath, layerPath)
+	if streamLayers() {
+		return imageScan.streamLayer(completeLayerPath, extractPath, layerID, scanCtx)
+	}
+	targetDir := path.Join(extractPath, layerID)
+	log.Debugf("Complete layer path: %s", completeLayerPath)
+	log.Debugf("Extracted to directory: %s", targetDir)
+	if err := core.CreateRecursiveDir(targetDir); err != nil {
+		log.Errorf("ProcessImageLayers: Unable to create target directory to extract image layers... %s", err)
+		return layerResult{err: err}
+	}
+
+	_, error := extractTarFile("", completeLayerPath, targetDir, imageScan.budget)
+	if errors.Is(error, extractBudgetExceeded) {
+		return layerResult{err: fmt.Errorf("layer %s: %w", layerID, error)}
+	}
+	if error != nil {
+		log.Errorf("ProcessImageLayers: Unable to extract image layer. Reason = %s", error.Error())
+		// Don't stop. Print error and continue with remaning extracted files and other layers
+	}
+	log.Debugf("Analyzing dir: %s", targetDir)
+	var isFirstSecret bool = true
+	secrets, err := ScanSecretsInDir(layerID, extractPath, targetDir, &isFirstSecret, scanCtx)
+	return layerResult{secrets: secrets, err: err, partial: error != nil}
 }
 
-// Extract all the layers of the container image and then find secrets in each layer one by one
+// Extract all the layers of the container image and then find secrets in each layer
 // @parameters
 // imageScan - Structure with details of the container image to scan
 // imageManifestPath - Complete path of directory where manifest of image has been extracted
@@ -399,120 +577,93 @@ func (imageScan *ImageScan) processImageLayers(imageManifestPath string,
 
 	var tempSecretsFound []output.SecretFound
 	var err error
-	var isFirstSecret bool = true
+	maxSecrets := *core.GetSession().Options.MaxSecrets
 
-	// extractPath - Base directory where all the layers should be extracted to
-	extractPath := path.Join(imageManifestPath, core.ExtractedImageFilesDir)
-	layerIDs := imageScan.imageManifest.LayerIds
-	layerPaths := imageScan.imageManifest.Layers
-
-	loopCntr := len(layerPaths)
-	var secrets []output.SecretFound
-	for i := 0; i < loopCntr; i++ {
-		log.Debugf("Analyzing layer path: %s", layerPaths[i])
-		log.Debugf("Analyzing layer: %s", layerIDs[i])
-		// savelayerID = layerIDs[i]
-		completeLayerPath := path.Join(imageManifestPath, layerPaths[i])
-		targetDir := path.Join(extractPath, layerIDs[i])
-		log.Debugf("Complete layer path: %s", completeLayerPath)
-		log.Debugf("Extracted to directory: %s", targetDir)
-		err = core.CreateRecursiveDir(targetDir)
-		if err != nil {
-			log.Errorf("ProcessImageLayers: Unable to create target directory to extract image layers... %s", err)
-			return tempSecretsFound, err
+	layersDone, layers := 0, imageScan.layersToScan()
+	imageScan.scanLayers(imageManifestPath, scanCtx, func(layerID string, secrets []output.SecretFound, layerErr error, last bool) bool {
+		if layerErr != nil {
+			err = layerErr
+			return false
 		}
-
-		_, error := extractTarFile("", completeLayerPath, targetDir)
-		if error != nil {
-			log.Errorf("ProcessImageLayers: Unable to extract image layer. Reason = %s", error.Error())
-			// Don't stop. Print error and continue with remaning extracted files and other layers
-			// return tempSecretsFound, error
-		}
-		log.Debugf("Analyzing dir: %s", targetDir)
-		secrets, err = ScanSecretsInDir(layerIDs[i], extractPath, targetDir,
-			&isFirstSecret, scanCtx)
-
+		layersDone++
+		Progress.LayerDone(layerID, layersDone, layers)
+		Stats.AddLayer()
+		metrics.LayersScanned.Inc()
 		imageScan.numSecrets += uint(len(secrets))
 		tempSecretsFound = append(tempSecretsFound, secrets...)
-		if err != nil {
-			log.Errorf("ProcessImageLayers: %s", err)
-			// return tempSecretsFound, err
-		}
 
 		// Don't report secrets if number of secrets exceeds MAX value
-		if imageScan.numSecrets >= *core.GetSession().Options.MaxSecrets {
-			return tempSecretsFound, nil
-		}
+		return imageScan.numSecrets < maxSecrets
+	})
+
+	// The same file is often copied unchanged through several layers
+	if !*core.GetSession().Options.NoDedupe {
+		tempSecretsFound = output.DedupeSecrets(tempSecretsFound)
 	}
 
-	return tempSecretsFound, nil
+	return tempSecretsFound, err
 }
 
-// Extract all the layers of the container image and then stream secrets in each layer one by one
+// Extract all the layers of the container image and then stream secrets in each layer
 // @parameters
 // imageScan - Structure with details of the container image to scan
 // imageManifestPath - Complete path of directory where manifest of image has been extracted
 // @returns
-// []output.SecretFound - List of all secrets found
+// chan output.SecretFound - Channel of all secrets found, at most --max-secrets
+// *StreamStatus - Status of the scan, to be read once the channel is closed
 // Error - Errors if any. Otherwise, returns nil
 func (imageScan *ImageScan) processImageLayersStream(imageManifestPath string,
-	scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+	scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
 	res := make(chan output.SecretFound, secret_pipeline_size)
+	sink := newSecretSink(res, *core.GetSession().Options.MaxSecrets)
+	sink.status.ImageID = imageScan.imageId
 
-	go func() {
-		var err error
-		var isFirstSecret bool = true
+	var deduper *output.Deduper
+	if !*core.GetSession().Options.NoDedupe {
+		deduper = output.NewDeduper()
+	}
 
+	go func() {
 		defer close(res)
 
-		// extractPath - Base directory where all the layers should be extracted to
-		extractPath := path.Join(imageManifestPath, core.ExtractedImageFilesDir)
-		layerIDs := imageScan.imageManifest.LayerIds
-		layerPaths := imageScan.imageManifest.Layers
-
-		loopCntr := len(layerPaths)
-		var secrets []output.SecretFound
-		for i := 0; i < loopCntr; i++ {
-			log.Debugf("Analyzing layer path: %s", layerPaths[i])
-			log.Debugf("Analyzing layer: %s", layerIDs[i])
-			// savelayerID = layerIDs[i]
-			completeLayerPath := path.Join(imageManifestPath, layerPaths[i])
-			targetDir := path.Join(extractPath, layerIDs[i])
-			log.Infof("Complete layer path: %s", completeLayerPath)
-			log.Infof("Extracted to directory: %s", targetDir)
-			err = core.CreateRecursiveDir(targetDir)
-			if err != nil {
-				log.Error("ProcessImageLayers: Unable to create target directory extract image layers... %v", err)
-				continue
+		layersDone, layers := 0, imageScan.layersToScan()
+		imageScan.scanLayers(imageManifestPath, scanCtx, func(layerID string, secrets []output.SecretFound, err error, last bool) bool {
+			if err == nil {
+				layersDone++
+				Progress.LayerDone(layerID, layersDone, layers)
+				Stats.AddLayer()
+				metrics.LayersScanned.Inc()
 			}
 
-			_, error := extractTarFile("", completeLayerPath, targetDir)
-			if error != nil {
-				log.Errorf("ProcessImageLayers: Unable to extract image layer. Reason = %s", error.Error())
-				// Don't stop. Print error and continue with remaning extracted files and other layers
-				continue
+			// The layer scan stops early when cancelled, still deliver what it found
+			if cpErr := scanCtx.Checkpoint("scanning image layers"); cpErr != nil {
+				sink.cancel(cpErr)
 			}
-			log.Debugf("Analyzing dir: %s", targetDir)
-			secrets, err = ScanSecretsInDir(layerIDs[i], extractPath,
-				targetDir, &isFirstSecret, scanCtx)
 
+			// Each layer is capped separately, so only deliver what is left of the cap of the image
 			imageScan.numSecrets += uint(len(secrets))
 			for i := range secrets {
-				res <- secrets[i]
+				// Secrets already delivered from a lower layer are not delivered again
+				if deduper == nil || deduper.First(&secrets[i]) {
+					sink.send(secrets[i])
+				}
 			}
+			// Like the scans that aren't streamed, a failed layer stops the scan and fails it
 			if err != nil {
 				log.Errorf("ProcessImageLayers: %s", err)
-				continue
+				sink.cancel(err)
 			}
-
-			// Don't report secrets if number of secrets exceeds MAX value
-			if imageScan.numSecrets >= *core.GetSession().Options.MaxSecrets {
-				break
+			if sink.status.Err != nil {
+				log.Warnf("ProcessImageLayers: stopped after layer %s: %s", layerID, sink.status.Err)
+				return false
 			}
-		}
+
+			// Once the cap is reached, the next layers are still scanned for a secret the cap drops
+			return !sink.status.Truncated
+		})
 	}()
 
-	return res, nil
+	return res, sink.status, nil
 }
 
 // Save container image as tar file in specified directory
@@ -523,12 +674,21 @@ func (imageScan *ImageScan) processImageLayersStream(imageManifestPath string,
 func (imageScan *ImageScan) saveImageData() error {
 	imageName := imageScan.imageName
 	outputParam := path.Join(imageScan.tempDir, imageTarFileName)
-	drun, err := vessel.NewRuntime()
+	containerRuntime, err := sessionRuntime()
 	if err != nil {
 		return err
 	}
 	log.Infof("Scanning image %s for secrets...", outputParam)
-	_, err = drun.Save(imageName, outputParam)
+	if containerRuntime == podmanRuntimeName {
+		err = savePodmanImage(imageName, outputParam)
+	} else {
+		var drun vessel.Runtime
+		drun, err = vessel.NewRuntime()
+		if err != nil {
+			return err
+		}
+		_, err = drun.Save(imageName, outputParam)
+	}
 
 	if err != nil {
 		return err
@@ -542,16 +702,17 @@ func (imageScan *ImageScan) saveImageData() error {
 // imageName - Name of the container image to save
 // imageTarPath - Complete path where tarball of the image is stored
 // extractPath - Complete path of directory where contents of image are to be extracted
+// budget - Budget of the extraction of the image
 // @returns
 // string - directory where contents of image are extracted
 // Error - Errors, if any. Otherwise, returns nil
-func extractTarFile(imageName, imageTarPath string, extractPath string) (string, error) {
+func extractTarFile(imageName, imageTarPath string, extractPath string, budget *extractBudget) (string, error) {
 	log.Debugf("Started extracting tar file %s", imageTarPath)
 
 	path := extractPath
 
 	// Extract the contents of image from tar file
-	if err := untar(imageTarPath, path); err != nil {
+	if err := untar(imageTarPath, path, budget); err != nil {
 		log.Error(err)
 		return "", err
 	}
@@ -566,13 +727,31 @@ func extractTarFile(imageName, imageTarPath string, extractPath string) (string,
 // @returns
 // manifestItem - The manifestItem containing details about image layers
 // Error - Errors, if any. Otherwise, returns nil
-func untar(tarName string, xpath string) (err error) {
+func untar(tarName string, xpath string, budget *extractBudget) (err error) {
+	return untarFiltered(tarName, xpath, budget, nil)
+}
+
+// Extract a tar file like untar, letting a filter handle or skip each entry
+// @parameters
+// tarName - Complete path of the tar file
+// xpath - Extraction dir
+// budget - Budget of the extraction, shared with the other tars of the image. The extraction is aborted once it
+// is exceeded
+// filter - Called before an entry is written, with its path relative to the extraction dir. The entry is
+// skipped if it returns false. May be nil
+// @returns
+// Error - Errors, if any. Otherwise, returns nil
+func untarFiltered(tarName string, xpath string, budget *extractBudget,
+	filter func(hdr *tar.Header, name string) (bool, error)) (err error) {
 	tarFile, err := os.Open(tarName)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err = tarFile.Close()
+		// Errors of the extraction are not overwritten by the one of Close
+		if closeErr := tarFile.Close(); err == nil {
+			err = closeErr
+		}
 	}()
 
 	absPath, err := filepath.Abs(xpath)
@@ -580,15 +759,13 @@ func untar(tarName string, xpath string) (err error) {
 		return err
 	}
 
-	tr := tar.NewReader(tarFile)
-	if strings.HasSuffix(tarName, ".gz") || strings.HasSuffix(tarName, ".gzip") {
-		gz, err := gzip.NewReader(tarFile)
-		if err != nil {
-			return err
-		}
-		defer gz.Close()
-		tr = tar.NewReader(gz)
+	// Layers read from a content store have no suffix, so also check the magic bytes
+	r, closeReader, err := decompressReader(tarName, bufio.NewReader(tarFile))
+	if err != nil {
+		return err
 	}
+	defer closeReader()
+	tr := tar.NewReader(r)
 
 	// untar each segment
 	for {
@@ -599,39 +776,70 @@ func untar(tarName string, xpath string) (err error) {
 		if err != nil {
 			return err
 		}
+		// Sizes in headers can be trusted, the tar reader never reads past them
+		size := int64(0)
+		if hdr.Typeflag == tar.TypeReg {
+			size = hdr.Size
+		}
+		if err := budget.charge(hdr.Name, size); err != nil {
+			return err
+		}
 
-		// determine proper file path info
+		// determine proper file path info, resolving the parent dirs inside the extraction dir so that
+		// symlinks extracted earlier can never be used to write outside of it
 		finfo := hdr.FileInfo()
-		fileName := hdr.Name
-		if filepath.IsAbs(fileName) {
-			fileName, err = filepath.Rel("/", fileName)
-			if err != nil {
+		fileName := filepath.Clean(string(os.PathSeparator) + hdr.Name)
+		if fileName == string(os.PathSeparator) {
+			continue
+		}
+		if filter != nil {
+			if write, err := filter(hdr, layerPath(hdr.Name)); err != nil {
 				return err
+			} else if !write {
+				continue
 			}
 		}
-
-		absFileName := filepath.Join(absPath, fileName)
-		if strings.Contains(fileName, "/") {
-			relPath := strings.Split(fileName, "/")
-			var absDirPath string
-			if len(relPath) > 1 {
-				dirs := relPath[0 : len(relPath)-1]
-				absDirPath = filepath.Join(absPath, strings.Join(dirs, "/"))
-			}
-			if err := os.MkdirAll(absDirPath, 0755); err != nil {
-				log.Error(err)
-			}
+		absDirPath, err := resolveInRoot(absPath, filepath.Dir(fileName))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(absDirPath, 0755); err != nil {
+			log.Error(err)
 		}
+		absFileName := filepath.Join(absDirPath, filepath.Base(fileName))
 
-		if finfo.Mode().IsDir() {
-			if err := os.MkdirAll(absFileName, 0755); err != nil {
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			resolved, err := resolveInRoot(absPath, fileName)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(resolved, 0755); err != nil {
 				return err
 			}
 			continue
+		case tar.TypeSymlink:
+			if err := extractSymlink(absPath, absFileName, hdr.Linkname); err != nil {
+				log.Warnf("untar: skipping symlink %s: %s", hdr.Name, err)
+			}
+			continue
+		case tar.TypeLink:
+			if err := extractHardlink(absPath, absFileName, hdr.Linkname); err != nil {
+				log.Warnf("untar: skipping hard link %s: %s", hdr.Name, err)
+			}
+			continue
+		case tar.TypeReg:
+			// written below
+		default:
+			log.Debugf("untar: skipping %s of type %c", hdr.Name, hdr.Typeflag)
+			continue
+		}
+		if err := removeExisting(absFileName); err != nil {
+			return err
 		}
 
 		// create new file with original file mode
-		file, err := os.OpenFile(absFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, finfo.Mode().Perm())
+		file, err := createNoFollow(absFileName, finfo.Mode().Perm())
 		if err != nil {
 			log.Error(err)
 			return err
@@ -640,7 +848,7 @@ func untar(tarName string, xpath string) (err error) {
 		n, cpErr := io.Copy(file, tr)
 		if closeErr := file.Close(); closeErr != nil { // close file immediately
 			log.Error("closeErr:" + closeErr.Error())
-			return err
+			return closeErr
 		}
 		if cpErr != nil {
 			log.Error("copyErr:" + cpErr.Error())
@@ -656,10 +864,11 @@ func untar(tarName string, xpath string) (err error) {
 // Extract all the details from image manifest
 // @parameters
 // path - Complete path where image contents are extracted
+// platform - Platform of the image to select when the manifest lists several, nil for the host platform
 // @returns
 // manifestItem - The manifestItem containing details about image layers
 // Error - Errors, if any. Otherwise, returns nil
-func extractDetailsFromManifest(path string) (manifestItem, error) {
+func extractDetailsFromManifest(path string, platform *ociPlatform) (manifestItem, error) {
 	mf, err := os.Open(path + "/manifest.json")
 	if err != nil {
 		return manifestItem{}, err
@@ -669,19 +878,20 @@ func extractDetailsFromManifest(path string) (manifestItem, error) {
 	var manifest []manifestItem
 	if err = json.NewDecoder(mf).Decode(&manifest); err != nil {
 		return manifestItem{}, err
-	} else if len(manifest) != 1 {
+	}
+	item, err := platformManifestItem(path, manifest, platform)
+	if err != nil {
 		return manifestItem{}, err
 	}
 	var layerIds []string
-	for _, layer := range manifest[0].Layers {
+	for _, layer := range item.Layers {
 		trimmedLayerId := strings.TrimSuffix(layer, "/layer.tar")
 		// manifests saved by some versions of skopeo has .tar extentions
 		trimmedLayerId = strings.TrimSuffix(trimmedLayerId, ".tar")
 		layerIds = append(layerIds, trimmedLayerId)
 	}
-	manifest[0].LayerIds = layerIds
-	// ImageScan.imageManifest = manifest[0]
-	return manifest[0], nil
+	item.LayerIds = layerIds
+	return item, nil
 }
 
 // Execute the specified command and return the output
@@ -695,7 +905,8 @@ func extractDetailsFromManifest(path string) (manifestItem, error) {
 func runCommand(name string, args ...string) (stdout string, stderr string, exitCode int) {
 	var defaultFailedCode = 1
 	var outbuf, errbuf bytes.Buffer
-	cmd := exec.Command(name, args...)
+	// Commands are killed once the scan is cancelled or times out
+	cmd := exec.CommandContext(core.GetSession().Context, name, args...)
 	cmd.Stdout = &outbuf
 	cmd.Stderr = &errbuf
 
@@ -743,21 +954,27 @@ func ExtractAndScanImage(image string) (*ImageExtractionResult, error) {
 	err = imageScan.extractImage(true)
 
 	if err != nil {
+		// The image may be partly saved, the next image of the scan needs the disk space
+		core.DeleteTmpDir(tempDir)
 		return nil, err
 	}
 
 	secrets, err := imageScan.scan(nil)
 
+	if errors.Is(err, context.DeadlineExceeded) {
+		// The secrets found before the --timeout are still reported
+		return &ImageExtractionResult{ImageId: imageScan.imageId, Secrets: secrets}, err
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &ImageExtractionResult{ImageId: imageScan.imageId, Secrets: secrets}, nil
 }
 
-func ExtractAndScanImageStream(image string, scanCtx *tasks.ScanContext) (chan output.SecretFound, error) {
+func ExtractAndScanImageStream(image string, scanCtx *tasks.ScanContext) (chan output.SecretFound, *StreamStatus, error) {
 	tempDir, err := core.GetTmpDir(image)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	imageScan := ImageScan{imageName: image, imageId: "", tempDir: tempDir}
@@ -765,27 +982,28 @@ func ExtractAndScanImageStream(image string, scanCtx *tasks.ScanContext) (chan o
 
 	if err != nil {
 		core.DeleteTmpDir(tempDir)
-		return nil, err
+		return nil, nil, err
 	}
 
-	stream, err := imageScan.scanStream(scanCtx)
+	stream, status, err := imageScan.scanStream(scanCtx)
 
 	if err != nil {
 		core.DeleteTmpDir(tempDir)
-		return nil, err
+		return nil, nil, err
 	}
 
 	res := make(chan output.SecretFound, secret_pipeline_size)
 
 	go func() {
-		defer core.DeleteTmpDir(tempDir)
+		// The temp dir is deleted before the end of the stream, the process may exit right after it
 		defer close(res)
+		defer core.DeleteTmpDir(tempDir)
 		for i := range stream {
 			res <- i
 		}
 	}()
 
-	return res, nil
+	return res, status, nil
 
 }
 
@@ -0,0 +1,233 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/allowlist"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_RunInOrder(t *testing.T) {
+	var running, maxRunning int32
+	var handled []int
+	runInOrder(8, 3,
+		func(i int) layerResult {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			// Later layers finish first
+			time.Sleep(time.Duration(8-i) * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return layerResult{secrets: []output.SecretFound{{LineNumber: i}}}
+		},
+		func(i int, result layerResult) bool {
+			if result.secrets[0].LineNumber != i {
+				t.Errorf("result of layer %d handled as layer %d", result.secrets[0].LineNumber, i)
+			}
+			handled = append(handled, i)
+			return true
+		})
+
+	if !reflect.DeepEqual(handled, []int{0, 1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("results not handled in order: %v", handled)
+	}
+	if maxRunning > 3 {
+		t.Errorf("%d layers scanned at a time, at most 3 expected", maxRunning)
+	}
+}
+
+func Test_RunInOrderStops(t *testing.T) {
+	var started int32
+	var handled []int
+	runInOrder(20, 2,
+		func(i int) layerResult {
+			atomic.AddInt32(&started, 1)
+			return layerResult{}
+		},
+		func(i int, result layerResult) bool {
+			handled = append(handled, i)
+			// Stop once the cap is reached in the second layer
+			return i < 1
+		})
+
+	if !reflect.DeepEqual(handled, []int{0, 1}) {
+		t.Errorf("expected to stop after the second layer, handled %v", handled)
+	}
+	if started > 5 {
+		t.Errorf("%d layers scanned after the cap was reached", started)
+	}
+}
+
+// Session of the scans with the default options and the config.yaml of the repository
+func testSession(t testing.TB) *core.Session {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	return core.GetSession()
+}
+
+func Test_MatchesLimitedPerFile(t *testing.T) {
+	if *testSession(t).Options.MultipleMatch {
+		t.Skip("multiple matches are reported with --multi-match")
+	}
+
+	// Same high entropy secret in two files, and a second one in the first file
+	dir := t.TempDir()
+	first := "api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa'\nsecond = 'Qz7Lw2Xe9Rt4Yu1Io6Pa3Sd8Fg5Hj0Kl'\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	isFirstSecret := true
+	filesBefore := Stats.Files()
+	secrets, err := ScanSecretsInDir("", "", dir, &isFirstSecret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := Stats.Files() - filesBefore; files != 2 {
+		t.Errorf("%d files counted as scanned, want 2", files)
+	}
+	var found []string
+	for _, secret := range secrets {
+		if secret.RuleID == signature.GenericHighEntropyRuleID {
+			found = append(found, filepath.Base(secret.CompleteFilename))
+		}
+	}
+	sort.Strings(found)
+	// The first file reports the first match of the rule only, the second file its own match
+	if expected := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(found, expected) {
+		t.Errorf("high entropy secrets found in %v, want %v", found, expected)
+	}
+}
+
+func Test_AboveMinSeverity(t *testing.T) {
+	options := testSession(t).Options
+	minSeverity, countAll := *options.MinSeverity, *options.CountAll
+	defer func() { *options.MinSeverity, *options.CountAll = minSeverity, countAll }()
+
+	found := func() []output.SecretFound {
+		return []output.SecretFound{{RuleID: 1, Severity: output.HIGH}, {RuleID: 2, Severity: output.LOW},
+			{RuleID: 3, Severity: output.MEDIUM}, {RuleID: 4, Severity: output.SUPPRESSED}}
+	}
+
+	*options.MinSeverity, *options.CountAll = "", false
+	numSecrets := uint(4)
+	if secrets := aboveMinSeverity(found(), &numSecrets); len(secrets) != 4 || numSecrets != 4 {
+		t.Errorf("without --min-severity all the secrets should be kept, got %+v and %d", secrets, numSecrets)
+	}
+
+	*options.MinSeverity = output.MEDIUM
+	filteredBefore := Stats.Filtered()
+	secrets := aboveMinSeverity(found(), &numSecrets)
+	var ids []int
+	for _, secret := range secrets {
+		ids = append(ids, secret.RuleID)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 3}) || numSecrets != 2 {
+		t.Errorf("kept rules %v and counted %d secrets, want [1 3] and 2", ids, numSecrets)
+	}
+	if filtered := Stats.Filtered() - filteredBefore; filtered != 2 {
+		t.Errorf("%d secrets counted as filtered, want 2", filtered)
+	}
+
+	// With --count-all the secrets dropped still count towards --max-secrets
+	*options.CountAll = true
+	numSecrets = 4
+	if secrets := aboveMinSeverity(found(), &numSecrets); len(secrets) != 2 || numSecrets != 4 {
+		t.Errorf("with --count-all %d secrets should be kept and 4 counted, got %d and %d", 2, len(secrets), numSecrets)
+	}
+}
+
+func Test_AllowedMatchesOfRule(t *testing.T) {
+	if *testSession(t).Options.MultipleMatch {
+		t.Skip("multiple matches are reported with --multi-match")
+	}
+	path := writeTestFile(t, t.TempDir(), "allowlist.yaml", []byte("allowlist:\n  - values: ['^J8fK2mQ9']\n"))
+	if err := allowlist.Enable(path); err != nil {
+		t.Fatal(err)
+	}
+	defer allowlist.Enable("")
+
+	// The values of the state share the matches of the file, the allowed token must not use up the match of its rule
+	state := `{
+  "version": 4,
+  "terraform_version": "1.6.6",
+  "resources": [
+    {"mode": "managed", "type": "null_resource", "name": "first",
+      "instances": [{"attributes": {"token": "J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa"}}]},
+    {"mode": "managed", "type": "null_resource", "name": "second",
+      "instances": [{"attributes": {"token": "Zq7Wm2Lp9Xc4Vb8Nk1Hj6Gf3Ds5Ra0Te"}}]}
+  ]
+}`
+	numSecrets := uint(0)
+	matchedRuleSet := map[uint]uint{}
+	secrets, err := scanContents(testSession(t).Context, []byte(state), "terraform.tfstate", "terraform.tfstate",
+		".tfstate", "", &numSecrets, matchedRuleSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].KeyPath != "null_resource.second.token" || numSecrets != 1 {
+		t.Errorf("secrets %+v found (%d counted), want the second token", secrets, numSecrets)
+	}
+	entropyRuleID := signature.GenericHighEntropyRuleID
+	if entropyMatches := matchedRuleSet[uint(entropyRuleID)]; entropyMatches != 1 {
+		t.Errorf("high entropy rule matched %d times, want 1", entropyMatches)
+	}
+}
+
+func Test_LockfilesNotSearchedForEntropy(t *testing.T) {
+	testSession(t)
+	contents := []byte("token = J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n")
+	for name, expected := range map[string]int{"go.sum": 0, "yarn.lock": 0, "settings.conf": 1} {
+		numSecrets := uint(0)
+		secrets, err := scanContents(testSession(t).Context, contents, "app/"+name, name, filepath.Ext(name), "",
+			&numSecrets, map[uint]uint{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(secrets) != expected {
+			t.Errorf("%s: %d high entropy strings found, want %d", name, len(secrets), expected)
+		}
+	}
+}
+
+func Test_RuleOverridesOfScan(t *testing.T) {
+	overrides, err := signature.ParseRuleOverrides([]byte(`{"disable": [-1]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := signature.WithRuleOverrides(testSession(t).Context, overrides)
+
+	// The rules turned off for the scan are neither reported nor counted
+	numSecrets := uint(0)
+	matchedRuleSet := map[uint]uint{}
+	secrets, err := scanContents(ctx, []byte("token = J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa\n"), "app/settings.conf",
+		"settings.conf", ".conf", "", &numSecrets, matchedRuleSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 || numSecrets != 0 || len(matchedRuleSet) != 0 {
+		t.Errorf("secrets %+v found (%d counted, rules %v) with the high entropy rule disabled", secrets, numSecrets,
+			matchedRuleSet)
+	}
+}
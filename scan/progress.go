@@ -0,0 +1,194 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Minimum time between two progress lines, so that progress doesn't flood the terminal
+const progressInterval = 2 * time.Second
+
+// Progress reports how far a scan is, for --progress.
+// It is nil, and reporting is a no-op, unless EnableProgress was called
+var Progress *ProgressReporter
+
+// ProgressReporter Writes periodic progress lines: files and bytes scanned out of the files extracted so far,
+// the layer being scanned and an estimate of the time left
+type ProgressReporter struct {
+	sync.Mutex
+	w     io.Writer
+	now   func() time.Time
+	start time.Time
+	last  time.Time // Time of the last progress line
+
+	totalFiles   int64
+	totalBytes   int64
+	scannedFiles int64
+	scannedBytes int64
+
+	layer       string // Layer being scanned, empty for directories
+	layersDone  int
+	layersTotal int
+}
+
+// EnableProgress Report the progress of the scans to w, which should not be the writer of the results
+func EnableProgress(w io.Writer) {
+	Progress = newProgressReporter(w, time.Now)
+}
+
+func newProgressReporter(w io.Writer, now func() time.Time) *ProgressReporter {
+	return &ProgressReporter{w: w, now: now, start: now(), last: now()}
+}
+
+// Count the files to scan in a directory once it is extracted, they are added to the total
+// @parameters
+// dir - Directory about to be scanned
+// layer - layer ID, if the directory is a layer of a container image
+func (p *ProgressReporter) AddDir(dir string, layer string) {
+	if p == nil {
+		return
+	}
+	var files, size int64
+	filepath.WalkDir(dir, func(path string, f fs.DirEntry, err error) error {
+		if err != nil || !f.Type().IsRegular() {
+			return nil
+		}
+		if info, err := f.Info(); err == nil {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	p.add(files, size, layer)
+}
+
+// Count the files to scan instead of the whole directory, with --changed-files-from
+// @parameters
+// paths - Paths of the files about to be scanned
+func (p *ProgressReporter) AddFiles(paths []string) {
+	if p == nil {
+		return
+	}
+	var files, size int64
+	for _, path := range paths {
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+	}
+	p.add(files, size, "")
+}
+
+func (p *ProgressReporter) add(files, size int64, layer string) {
+	p.Lock()
+	defer p.Unlock()
+	p.totalFiles += files
+	p.totalBytes += size
+	if layer != "" {
+		p.layer = layer
+	}
+}
+
+// Record a file of the directories added which was scanned or skipped, and report the progress
+// if the last report is old enough
+// @parameters
+// f - Directory entry of the file, dirs are not counted
+func (p *ProgressReporter) FileDone(f fs.DirEntry) {
+	if p == nil || !f.Type().IsRegular() {
+		return
+	}
+	var size int64
+	if info, err := f.Info(); err == nil {
+		size = info.Size()
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.scannedFiles++
+	p.scannedBytes += size
+	if p.now().Sub(p.last) >= progressInterval {
+		p.report()
+	}
+}
+
+// Record a layer of an image as scanned and report the progress, layers are reported in order
+// @parameters
+// layer - ID of the layer
+// done - Number of layers scanned, including this one
+// total - Number of layers of the image
+func (p *ProgressReporter) LayerDone(layer string, done int, total int) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.layersDone, p.layersTotal = done, total
+	fmt.Fprintf(p.w, "progress: layer %d/%d %s scanned, %s\n", done, total, shortLayerID(layer), p.status())
+	p.last = p.now()
+}
+
+// Report the final progress of the scan
+func (p *ProgressReporter) Finish() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	fmt.Fprintf(p.w, "progress: done, %d files, %s scanned in %s\n", p.scannedFiles, formatBytes(p.scannedBytes),
+		p.now().Sub(p.start).Round(time.Second))
+}
+
+func (p *ProgressReporter) report() {
+	layer := ""
+	if p.layer != "" {
+		if p.layersTotal > 0 {
+			layer = fmt.Sprintf("layer %d/%d ", p.layersDone+1, p.layersTotal)
+		}
+		layer += shortLayerID(p.layer) + ": "
+	}
+	fmt.Fprintf(p.w, "progress: %s%s\n", layer, p.status())
+	p.last = p.now()
+}
+
+// Files and bytes scanned, percent of the bytes to scan and estimated time left
+func (p *ProgressReporter) status() string {
+	elapsed := p.now().Sub(p.start)
+	status := fmt.Sprintf("%d/%d files, %s/%s", p.scannedFiles, p.totalFiles,
+		formatBytes(p.scannedBytes), formatBytes(p.totalBytes))
+	if p.totalBytes > 0 {
+		status += fmt.Sprintf(" (%d%%)", p.scannedBytes*100/p.totalBytes)
+	}
+	status += fmt.Sprintf(", elapsed %s", elapsed.Round(time.Second))
+	// Assume the bytes left are scanned as fast as the bytes scanned so far
+	if p.scannedBytes > 0 && p.scannedBytes < p.totalBytes {
+		left := time.Duration(float64(elapsed) * float64(p.totalBytes-p.scannedBytes) / float64(p.scannedBytes))
+		status += fmt.Sprintf(", ETA %s", left.Round(time.Second))
+	}
+	return status
+}
+
+// Size in bytes with a binary unit, e.g. 1.5 MB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// Layer IDs are digests, their first characters are enough to recognize them
+func shortLayerID(layer string) string {
+	if len(layer) > 12 {
+		return layer[:12]
+	}
+	return layer
+}
@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ProgressReporter(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.env"), bytes.Repeat([]byte("a"), 1024), 0644)
+	os.WriteFile(filepath.Join(dir, "b.env"), bytes.Repeat([]byte("b"), 3072), 0644)
+
+	clock := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	progress := newProgressReporter(&out, func() time.Time { return clock })
+	progress.AddDir(dir, "sha256abcdef0123456789")
+
+	entries, _ := os.ReadDir(dir)
+	clock = clock.Add(time.Second)
+	progress.FileDone(entries[0])
+	if out.Len() != 0 {
+		t.Errorf("progress reported before the interval: %q", out.String())
+	}
+
+	clock = clock.Add(3 * time.Second)
+	progress.LayerDone("sha256abcdef0123456789", 1, 2)
+	expected := "progress: layer 1/2 sha256abcdef scanned, 1/2 files, 1.0 KB/4.0 KB (25%), elapsed 4s, ETA 12s\n"
+	if out.String() != expected {
+		t.Errorf("unexpected layer progress %q, want %q", out.String(), expected)
+	}
+
+	out.Reset()
+	clock = clock.Add(4 * time.Second)
+	progress.FileDone(entries[1])
+	progress.Finish()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expectedLines := []string{
+		"progress: layer 2/2 sha256abcdef: 2/2 files, 4.0 KB/4.0 KB (100%), elapsed 8s",
+		"progress: done, 2 files, 4.0 KB scanned in 8s",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+		t.Errorf("unexpected progress %q, want %q", lines, expectedLines)
+	}
+
+	var disabled *ProgressReporter
+	disabled.AddDir(dir, "")
+	disabled.FileDone(entries[0])
+	disabled.Finish()
+}
+
+func Test_FormatBytes(t *testing.T) {
+	for size, expected := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 30: "5.0 GB"} {
+		if actual := formatBytes(size); actual != expected {
+			t.Errorf("formatBytes(%d) = %s, want %s", size, actual, expected)
+		}
+	}
+}
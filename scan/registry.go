@@ -0,0 +1,295 @@
+package scan
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Authentication of the registry pulls: the credentials of --registry-auth, or else the docker config in
+// DOCKER_CONFIG (~/.docker by default) with its credential helpers, anonymous for the registries it has no
+// credentials for
+func registryAuthOption(registryAuth string) (remote.Option, error) {
+	if registryAuth == "" {
+		return remote.WithAuthFromKeychain(authn.DefaultKeychain), nil
+	}
+	username, password, found := strings.Cut(registryAuth, ":")
+	if !found {
+		return nil, errors.New("--registry-auth must be username:password")
+	}
+	return remote.WithAuth(&authn.Basic{Username: username, Password: password}), nil
+}
+
+// Transport of the registry pulls skipping the TLS verification of the insecure hosts only
+type registryTransport struct {
+	secure        http.RoundTripper
+	insecure      http.RoundTripper
+	insecureHosts []string
+}
+
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if insecureRegistry(req.URL.Host, t.insecureHosts) {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
+
+// Checks if a host, with its port if any, is one of the hosts of --insecure-registry. A host without a port
+// in the list matches all its ports
+func insecureRegistry(host string, insecureHosts []string) bool {
+	hostname := host
+	if h, _, found := strings.Cut(host, ":"); found {
+		hostname = h
+	}
+	for _, insecure := range insecureHosts {
+		if insecure == host || insecure == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// newRegistryTransport Transport of the registry pulls, through the proxy of --proxy or of the environment, trusting
+// the CA certificates of --registry-ca along with the system ones and not verifying the certificates of the
+// --insecure-registry hosts. The TLS settings don't apply to the connection to the Khulnasoft console
+// @parameters
+// caFile - PEM file of the CA certificates, empty for the system ones only
+// insecureHosts - Hosts whose certificate is not verified
+// @returns
+// http.RoundTripper - Transport of the registry client
+// Error - Errors if the CA certificates could not be read
+func newRegistryTransport(caFile string, insecureHosts []string) (http.RoundTripper, error) {
+	secure := http.DefaultTransport.(*http.Transport).Clone()
+	secure.Proxy = core.ProxyFunc()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("--registry-ca: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("--registry-ca: no PEM certificate in %s", caFile)
+		}
+		secure.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	if len(insecureHosts) == 0 {
+		return secure, nil
+	}
+	insecure := secure.Clone()
+	insecure.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &registryTransport{secure: secure, insecure: insecure, insecureHosts: insecureHosts}, nil
+}
+
+// CheckRegistryTLS Check the --registry-ca file and warn that the certificates of the --insecure-registry hosts
+// are not verified
+// @returns
+// Error - Errors if the CA certificates could not be read
+func CheckRegistryTLS() error {
+	options := core.GetSession().Options
+	if _, err := newRegistryTransport(*options.RegistryCA, options.InsecureRegistry.Values()); err != nil {
+		return err
+	}
+	for _, host := range options.InsecureRegistry.Values() {
+		log.Warnf("TLS VERIFICATION DISABLED for registry %s (--insecure-registry): images and credentials pulled from it can be intercepted", host)
+	}
+	return nil
+}
+
+// Image of the reference, resolving an index to the image of the platform
+func registryImage(ref name.Reference, platform *ociPlatform, options ...remote.Option) (v1.Image, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest := ociManifest{MediaType: string(indexManifest.MediaType)}
+	for _, m := range indexManifest.Manifests {
+		entry := ociDescriptor{MediaType: string(m.MediaType), Digest: m.Digest.String(), Size: m.Size}
+		if m.Platform != nil {
+			entry.Platform = &ociPlatform{Architecture: m.Platform.Architecture, OS: m.Platform.OS,
+				Variant: m.Platform.Variant}
+		}
+		manifest.Manifests = append(manifest.Manifests, entry)
+	}
+	selected, err := platformManifest(manifest, platform)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	hash, err := v1.NewHash(selected.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return index.Image(hash)
+}
+
+// Write a blob to a file, failing once more than limit bytes are read. The digest of the blob is checked
+// by the reader once it is read to the end
+func writeRegistryBlob(blob io.ReadCloser, dest string, limit int64) (int64, error) {
+	defer blob.Close()
+	file, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, io.LimitReader(blob, limit+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = fmt.Errorf("%w: %s is larger than the %d bytes left to pull, raise --max-extract-size to pull more",
+			extractBudgetExceeded, filepath.Base(filepath.Dir(dest)), limit)
+	}
+	return written, err
+}
+
+// pullRegistryImage Pull an image into dir with the docker save layout: manifest.json, the config and a
+// <digest>/layer.tar per layer, so it is extracted like a saved image
+// @parameters
+// ref - Reference of the image
+// imageName - Name of the image in manifest.json
+// dir - Directory the image is written to
+// platform - Platform of the image pulled from a multi-arch index, nil for the host platform
+// maxSize - Maximum number of bytes pulled, for the config and all the layers
+// options - Transport, authentication and context of the registry requests
+// @returns
+// Error - Errors if the image could not be pulled, a digest doesn't match or more than maxSize is pulled
+func pullRegistryImage(ref name.Reference, imageName string, dir string, platform *ociPlatform, maxSize int64,
+	options ...remote.Option) error {
+	img, err := registryImage(ref, platform, options...)
+	if err != nil {
+		return err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	if size > maxSize {
+		return fmt.Errorf("%w: %s is %d bytes, more than %d, raise --max-extract-size to pull it",
+			extractBudgetExceeded, imageName, size, maxSize)
+	}
+
+	config, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	item := manifestItem{
+		Config:   manifest.Config.Digest.Hex + ".json",
+		RepoTags: []string{imageName},
+	}
+	if err = os.WriteFile(filepath.Join(dir, item.Config), config, 0600); err != nil {
+		return err
+	}
+	pulled := int64(len(config))
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		layerDir := filepath.Join(dir, digest.Hex)
+		if err = os.MkdirAll(layerDir, 0755); err != nil {
+			return err
+		}
+		log.Debugf("Pulling layer %s of %s", digest, imageName)
+		blob, err := layer.Compressed()
+		if err != nil {
+			return err
+		}
+		written, err := writeRegistryBlob(blob, filepath.Join(layerDir, "layer.tar"), maxSize-pulled)
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", digest, err)
+		}
+		pulled += written
+		item.Layers = append(item.Layers, digest.Hex+"/layer.tar")
+	}
+
+	data, err := json.Marshal([]manifestItem{item})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0600)
+}
+
+// Checks if the image is pulled from its registry rather than saved by the container runtime: with
+// --pull-from-registry, or when credentials are configured for its registry, by --registry-auth or the docker config
+// @parameters
+// imageScan - Structure with details of the container image to scan
+// @returns
+// bool - true to pull the image from its registry first
+func (imageScan *ImageScan) pullsFromRegistry() bool {
+	options := core.GetSession().Options
+	if *options.PullFromRegistry || *options.RegistryAuth != "" {
+		return true
+	}
+	ref, err := name.ParseReference(imageScan.imageName)
+	if err != nil {
+		return false
+	}
+	auth, err := authn.DefaultKeychain.Resolve(ref.Context())
+	return err == nil && auth != authn.Anonymous
+}
+
+// Pull the image from its registry into the temp dir, without a container runtime
+// @parameters
+// imageScan - Structure with details of the container image to scan
+// @returns
+// Error - Errors if the image could not be pulled
+func (imageScan *ImageScan) pullFromRegistry() error {
+	options := core.GetSession().Options
+	ref, err := name.ParseReference(imageScan.imageName)
+	if err != nil {
+		return err
+	}
+	transport, err := newRegistryTransport(*options.RegistryCA, options.InsecureRegistry.Values())
+	if err != nil {
+		return err
+	}
+	auth, err := registryAuthOption(*options.RegistryAuth)
+	if err != nil {
+		return err
+	}
+	registry := ref.Context().RegistryStr()
+	if insecureRegistry(registry, options.InsecureRegistry.Values()) {
+		log.Warnf("Pulling %s without verifying the TLS certificate of registry %s", imageScan.imageName, registry)
+	}
+	err = pullRegistryImage(ref, imageScan.imageName, imageScan.tempDir, imageScan.platform,
+		imageScan.budget.maxSize, remote.WithTransport(transport), auth, remote.WithContext(core.GetSession().Context))
+	if err != nil {
+		return err
+	}
+	log.Infof("Image %s pulled from registry %s", imageScan.imageName, registry)
+	return nil
+}
@@ -0,0 +1,340 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+func Test_RegistryAuth(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	config := fmt.Sprintf(`{"auths": {"registry.example.com": {"auth": %q}, "https://index.docker.io/v1/": {"username": "hub", "password": "hubpass"}}}`,
+		base64.StdEncoding.EncodeToString([]byte("ci:s3cret")))
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := func(registry string) *authn.AuthConfig {
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := authn.DefaultKeychain.Resolve(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config
+	}
+	if creds := resolve("registry.example.com"); creds.Username != "ci" || creds.Password != "s3cret" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+	if creds := resolve("docker.io"); creds.Username != "hub" {
+		t.Errorf("unexpected docker hub credentials %+v", creds)
+	}
+	if creds := resolve("other.example.com"); creds.Username != "" {
+		t.Errorf("expected no credentials for an unknown registry, got %+v", creds)
+	}
+	if _, err := registryAuthOption("flag"); err == nil {
+		t.Error("expected an error for a --registry-auth without password")
+	}
+
+	// Images are pulled from the registries with credentials, and saved by the container runtime otherwise
+	testSession(t)
+	if !(&ImageScan{imageName: "registry.example.com/app:1.2"}).pullsFromRegistry() {
+		t.Error("image of a registry with credentials not pulled from the registry")
+	}
+	if (&ImageScan{imageName: "other.example.com/app:1.2"}).pullsFromRegistry() {
+		t.Error("image of a registry without credentials pulled from the registry")
+	}
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func Test_RegistryPull(t *testing.T) {
+	layer := tarBytes(t, map[string]string{"app/.env": "TOKEN=secret"}, true)
+	config := []byte(`{"architecture":"amd64"}`)
+	manifest := testRegistryManifest(config, layer)
+	index := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "manifests": [{"mediaType": %q, "digest": %q, "size": 1, "platform": {"os": "windows", "architecture": "arm"}}, {"mediaType": %q, "digest": %q, "size": %d, "platform": {"os": %q, "architecture": %q}}]}`,
+		mediaTypeOCIIndex, string(types.OCIManifestSchema1), digestOf([]byte("other")), string(types.OCIManifestSchema1), digestOf(manifest),
+		len(manifest), runtime.GOOS, runtime.GOARCH)
+	blobs := map[string][]byte{digestOf(config): config, digestOf(layer): layer}
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, _ := r.BasicAuth(); user != "ci" || pass != "s3cret" || r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"token": "pull-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/team/app/manifests/1.2":
+			w.Header().Set("Content-Type", mediaTypeOCIIndex)
+			w.Write([]byte(index))
+		case r.URL.Path == "/v2/team/app/manifests/"+digestOf(manifest):
+			w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/team/app/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ref := testRegistryReference(t, server, "team/app:1.2")
+	auth, err := registryAuthOption("ci:s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pull is capped at the extraction budget
+	err = pullRegistryImage(ref, "app:1.2", t.TempDir(), nil, int64(len(config)+len(layer)-1),
+		remote.WithTransport(server.Client().Transport), auth)
+	if !errors.Is(err, extractBudgetExceeded) {
+		t.Errorf("pull over the budget returned %v, want %v", err, extractBudgetExceeded)
+	}
+
+	dir := t.TempDir()
+	if err = pullRegistryImage(ref, "app:1.2", dir, nil, int64(len(config)+len(layer)),
+		remote.WithTransport(server.Client().Transport), auth); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := extractDetailsFromManifest(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.LayerIds) != 1 || item.LayerIds[0] != digestHex(digestOf(layer)) {
+		t.Errorf("unexpected layers in manifest.json: %+v", item)
+	}
+	out := filepath.Join(dir, "out")
+	if err = extractTarFileTo(filepath.Join(dir, item.Layers[0]), out, newExtractLimits(1024)); err != nil {
+		t.Fatal(err)
+	}
+	assertExtracted(t, filepath.Join(out, "app", ".env"), "TOKEN=secret")
+}
+
+func Test_RegistryPullDigestMismatch(t *testing.T) {
+	config := []byte(`{"architecture":"amd64"}`)
+	manifest := testRegistryManifest(config, []byte("original"))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/team/app/manifests/1.2":
+			w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+			w.Write(manifest)
+		case r.URL.Path == "/v2/team/app/blobs/"+digestOf(config):
+			w.Write(config)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/blobs/"):
+			w.Write([]byte("tampered"))
+		}
+	}))
+	defer server.Close()
+
+	err := pullRegistryImage(testRegistryReference(t, server, "team/app:1.2"), "app:1.2", t.TempDir(), nil, 1024,
+		remote.WithTransport(server.Client().Transport), remote.WithAuth(authn.Anonymous))
+	if err == nil {
+		t.Errorf("expected a digest mismatch error")
+	}
+}
+
+func Test_RegistryPullFailureExitCode(t *testing.T) {
+	// The child process fails to pull and exits like main does, the parent checks its exit code
+	if os.Getenv("SECRETSCANNER_TEST_PULL") != "" {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+		err := pullRegistryImage(testRegistryReference(t, server, "team/missing:1.2"), "missing:1.2", t.TempDir(),
+			nil, 1024, remote.WithTransport(server.Client().Transport), remote.WithAuth(authn.Anonymous))
+		if err == nil {
+			os.Exit(0)
+		}
+		core.Fatal(fmt.Errorf("main: error while scanning image: %w", err))
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_RegistryPullFailureExitCode$")
+	cmd.Env = append(os.Environ(), "SECRETSCANNER_TEST_PULL=1")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != core.ExitScanError {
+		t.Errorf("pull failure exited with %v, want exit code %d", err, core.ExitScanError)
+	}
+}
+
+func Test_RegistryPullTimeout(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := pullRegistryImage(testRegistryReference(t, server, "team/app:1.2"), "app:1.2", t.TempDir(), nil, 1024,
+		remote.WithTransport(server.Client().Transport), remote.WithAuth(authn.Anonymous), remote.WithContext(ctx))
+	if err == nil {
+		t.Fatal("expected the pull to stop at the deadline")
+	}
+	if code := core.ExitCode(err); code != core.ExitTimeout {
+		t.Errorf("pull stopped by the deadline exits with %d, want %d: %s", code, core.ExitTimeout, err)
+	}
+}
+
+func Test_RegistryTransport(t *testing.T) {
+	testSession(t)
+	manifest := testRegistryManifest([]byte("{}"))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+		w.Write(manifest)
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+	caFile := writeTestFile(t, t.TempDir(), "ca.pem",
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	for _, test := range []struct {
+		name          string
+		caFile        string
+		insecureHosts []string
+		trusted       bool
+	}{
+		{"system CAs", "", nil, false},
+		{"other insecure host", "", []string{"registry.internal"}, false},
+		{"insecure host", "", []string{host.Host}, true},
+		{"insecure hostname", "", []string{host.Hostname()}, true},
+		{"registry CA", caFile, nil, true},
+	} {
+		transport, err := newRegistryTransport(test.caFile, test.insecureHosts)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		_, err = remote.Get(testRegistryReference(t, server, "team/app:1.2"), remote.WithTransport(transport),
+			remote.WithAuth(authn.Anonymous))
+		if (err == nil) != test.trusted {
+			t.Errorf("%s: manifest = %v, want trusted %v", test.name, err, test.trusted)
+		}
+	}
+
+	if _, err := newRegistryTransport(writeTestFile(t, t.TempDir(), "ca.pem", []byte("not a certificate")), nil); err == nil {
+		t.Error("expected an error for a --registry-ca without certificates")
+	}
+}
+
+func Test_RegistryProxy(t *testing.T) {
+	options := testSession(t).Options
+	defer func(proxy, noProxy string) { *options.Proxy, *options.NoProxy = proxy, noProxy }(*options.Proxy, *options.NoProxy)
+
+	manifest := testRegistryManifest([]byte("{}"))
+	var lock sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		lock.Unlock()
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+		w.Write(manifest)
+	}))
+	defer proxy.Close()
+
+	pull := func() error {
+		transport, err := newRegistryTransport("", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Plain HTTP, so that the proxy sees the requests
+		ref, err := name.ParseReference("registry.test:5000/team/app:1.2", name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = remote.Get(ref, remote.WithTransport(transport), remote.WithAuth(authn.Anonymous))
+		return err
+	}
+
+	*options.Proxy, *options.NoProxy = proxy.URL, ""
+	if err := pull(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "GET http://registry.test:5000/v2/team/app/manifests/1.2"
+	if !slices.Contains(proxied, expected) {
+		t.Errorf("requests through the proxy %v, want %s", proxied, expected)
+	}
+
+	// Hosts of --no-proxy are reached directly
+	proxied = nil
+	*options.NoProxy = ".test"
+	if err := pull(); err == nil {
+		t.Error("expected registry.test to be unreachable without the proxy")
+	}
+	if len(proxied) != 0 {
+		t.Errorf("requests to a --no-proxy host went through the proxy: %v", proxied)
+	}
+}
+
+// Image manifest of a config and its layers
+func testRegistryManifest(config []byte, layers ...[]byte) []byte {
+	manifest := ociManifest{
+		MediaType: string(types.OCIManifestSchema1),
+		Config:    ociDescriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf(config), Size: int64(len(config))},
+	}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, ociDescriptor{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest: digestOf(layer), Size: int64(len(layer))})
+	}
+	data, _ := json.Marshal(manifest)
+	return data
+}
+
+// Reference of a repository of a test registry
+func testRegistryReference(t *testing.T, server *httptest.Server, repository string) name.Reference {
+	host, _ := url.Parse(server.URL)
+	ref, err := name.ParseReference(host.Host + "/" + repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
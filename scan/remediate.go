@@ -0,0 +1,171 @@
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Remediation modes
+const (
+	RemediateReplace = "replace"
+)
+
+// Text written in place of the secrets
+const RemediationPlaceholder = "REDACTED_BY_SECRETSCANNER"
+
+// Suffix of the backups of the remediated files
+const remediationBackupSuffix = ".secretscanner.bak"
+
+// RemediationResult Outcome of replacing secrets in files
+type RemediationResult struct {
+	Replaced int      // Secrets replaced by the placeholder
+	Files    []string // Files modified, each backed up with the .secretscanner.bak suffix
+	Skipped  int      // Secrets which could not be located precisely, left untouched
+}
+
+// A secret to be replaced in a file
+type replacement struct {
+	offset int
+	match  string
+}
+
+// RemediableSecrets Secrets of a local scan which can be replaced in their files
+func RemediableSecrets(secrets []output.SecretFound) []output.SecretFound {
+	var remediable []output.SecretFound
+	for _, secret := range secrets {
+		// Only content matches have offsets, and secrets inside archives are not in a file on disk
+		if secret.PartToMatch != signature.ContentsPart || secret.LayerID != "" ||
+			strings.Contains(secret.CompleteFilename, archivePathSeparator) {
+			continue
+		}
+		remediable = append(remediable, secret)
+	}
+	return remediable
+}
+
+// Remediate Replace the secrets found by a local scan with a placeholder, backing up the files
+// @parameters
+// secrets - Secrets found by scanning a local directory
+// placeholder - Text written in place of each secret
+// @returns
+// RemediationResult - Secrets replaced and files modified
+// Error - Errors if any. Otherwise, returns nil
+func Remediate(secrets []output.SecretFound, placeholder string) (RemediationResult, error) {
+	var result RemediationResult
+
+	byFile := map[string][]replacement{}
+	for _, secret := range RemediableSecrets(secrets) {
+		from, to := secret.MatchFromByte, secret.MatchToByte
+		if from < 0 || to > len(secret.MatchedContents) || from >= to {
+			result.Skipped++
+			continue
+		}
+		byFile[secret.CompleteFilename] = append(byFile[secret.CompleteFilename], replacement{
+			offset: secret.PrintBufferStartIndex + from,
+			match:  secret.MatchedContents[from:to],
+		})
+	}
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		replaced, skipped, err := remediateFile(file, byFile[file], placeholder)
+		result.Skipped += skipped
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", file, err)
+		}
+		if replaced > 0 {
+			result.Replaced += replaced
+			result.Files = append(result.Files, file)
+		}
+	}
+	return result, nil
+}
+
+func remediateFile(path string, replacements []replacement, placeholder string) (int, int, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, len(replacements), err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, len(replacements), fmt.Errorf("not a regular file")
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return 0, len(replacements), err
+	}
+
+	// Locate every secret in the file, and only replace the ones found exactly where they were matched
+	type located struct{ start, end int }
+	var spans []located
+	skipped := 0
+	for _, r := range replacements {
+		// readFile returns the raw contents, the offsets of the matches are the offsets in the file
+		start := r.offset
+		if start < 0 || start > len(original) || !bytes.HasPrefix(original[start:], []byte(r.match)) {
+			log.Warnf("remediate: secret not found at its offset in %s, leaving it", path)
+			skipped++
+			continue
+		}
+		spans = append(spans, located{start, start + len(r.match)})
+	}
+	if len(spans) == 0 {
+		return 0, skipped, nil
+	}
+
+	// Replace from the end, so earlier offsets stay valid. Overlapping matches are replaced once
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	remediated := append([]byte(nil), original...)
+	replaced := 0
+	lastStart := len(remediated) + 1
+	for _, span := range spans {
+		if span.end > lastStart {
+			skipped++
+			continue
+		}
+		remediated = append(remediated[:span.start], append([]byte(placeholder), remediated[span.end:]...)...)
+		lastStart = span.start
+		replaced++
+	}
+
+	backup := path + remediationBackupSuffix
+	if _, err = os.Lstat(backup); err == nil {
+		return 0, len(replacements), fmt.Errorf("backup %s already exists", backup)
+	}
+	if err = os.WriteFile(backup, original, info.Mode().Perm()); err != nil {
+		return 0, len(replacements), err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".remediate-*")
+	if err != nil {
+		return 0, len(replacements), err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(remediated); err != nil {
+		tmp.Close()
+		return 0, len(replacements), err
+	}
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return 0, len(replacements), err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, len(replacements), err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, len(replacements), err
+	}
+	return replaced, skipped, nil
+}
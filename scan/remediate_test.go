@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+// Secret as matched in the contents returned by readFile
+func matchedSecret(t *testing.T, path, secret string) output.SecretFound {
+	contents, err := readFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := strings.Index(string(contents), secret)
+	if offset < 0 {
+		t.Fatalf("%s not in %s", secret, contents)
+	}
+	return output.SecretFound{
+		PartToMatch:           signature.ContentsPart,
+		CompleteFilename:      path,
+		PrintBufferStartIndex: offset,
+		MatchFromByte:         0,
+		MatchToByte:           len(secret),
+		MatchedContents:       secret,
+	}
+}
+
+func Test_RemediateCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.ini")
+	if err := os.WriteFile(path, []byte("a=1\r\n\r\n\r\nkey=abc\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Remediate([]output.SecretFound{matchedSecret(t, path, "abc")}, "XXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if result.Replaced != 1 || string(data) != "a=1\r\n\r\n\r\nkey=XXX\r\n" {
+		t.Errorf("secret not replaced in place: %+v %q", result, data)
+	}
+}
+
+func Test_Remediate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.env")
+	original := "# settings\n\n\nDB_PASSWORD=hunter2\n\nAPI_KEY=abc123 # same key below\nBACKUP_KEY=abc123\n"
+	if err := os.WriteFile(path, []byte(original), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []output.SecretFound{
+		matchedSecret(t, path, "hunter2"),
+		matchedSecret(t, path, "abc123"),
+		{PartToMatch: signature.ExtPart, CompleteFilename: path, MatchedContents: ".env", MatchToByte: 4},
+	}
+
+	result, err := Remediate(secrets, "XXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Replaced != 2 || len(result.Files) != 1 || result.Skipped != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	data, _ := os.ReadFile(path)
+	expected := "# settings\n\n\nDB_PASSWORD=XXX\n\nAPI_KEY=XXX # same key below\nBACKUP_KEY=abc123\n"
+	if string(data) != expected {
+		t.Errorf("unexpected remediated file\nActual: %q\nExpected: %q", data, expected)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("file mode not preserved: %s", info.Mode())
+	}
+	if backup, _ := os.ReadFile(path + remediationBackupSuffix); string(backup) != original {
+		t.Errorf("original not backed up, got %q", backup)
+	}
+
+	// The file changed, the secret is not where it was matched anymore
+	result, err = Remediate([]output.SecretFound{secrets[0]}, "XXX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Replaced != 0 || result.Skipped != 1 {
+		t.Errorf("moved secret should be skipped: %+v", result)
+	}
+}
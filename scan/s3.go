@@ -0,0 +1,215 @@
+package scan
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Region of the AWS requests when neither an option nor the AWS config sets one
+const defaultAWSRegion = "us-east-1"
+
+// Storage classes of the objects which have to be restored before being read
+var archivedStorageClasses = map[types.ObjectStorageClass]bool{
+	types.ObjectStorageClassGlacier:     true,
+	types.ObjectStorageClassDeepArchive: true,
+}
+
+// Bucket and prefix of an s3://bucket/prefix URL
+func parseS3URL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", fmt.Errorf("--s3 must be an s3://bucket/prefix URL, got %q", rawURL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Decode an SSE-C key, which must be 256 bits
+func decodeSSECustomerKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("--s3-sse-customer-key must be a base64 encoded 256-bit key")
+	}
+	return key, nil
+}
+
+// CheckS3 Check the values of --s3 and --s3-sse-customer-key
+// @parameters
+// rawURL - s3://bucket/prefix URL to scan
+// sseCustomerKey - Base64 key of the objects encrypted with SSE-C, empty for none
+// @returns
+// Error - Errors if the URL is not an s3:// URL or the key is not a base64 256-bit key
+func CheckS3(rawURL string, sseCustomerKey string) error {
+	if _, _, err := parseS3URL(rawURL); err != nil {
+		return err
+	}
+	if sseCustomerKey != "" {
+		if _, err := decodeSSECustomerKey(sseCustomerKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AWS config of the default credential chain of the AWS SDK, with the requests through --proxy or the proxy of
+// the environment
+func loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	client := awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		transport.Proxy = core.ProxyFunc()
+	})
+	return config.LoadDefaultConfig(ctx, config.WithHTTPClient(client), config.WithDefaultRegion(defaultAWSRegion))
+}
+
+// newS3Client Create a client of S3
+// @parameters
+// cfg - AWS config, with the credentials of the default credential chain
+// region - Region of the bucket, from --s3-region, empty for the one of the AWS config
+// @returns
+// *s3.Client - Client reaching AWS, or the endpoint of AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL with path-style URLs
+func newS3Client(cfg aws.Config, region string) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if region != "" {
+			o.Region = region
+		}
+		// S3 compatible stores, such as MinIO, don't have a host per bucket
+		o.UsePathStyle = o.BaseEndpoint != nil
+	})
+}
+
+// Region of a bucket, given by S3 even when asked in another region, empty if it can't be read
+func s3BucketRegion(ctx context.Context, client *s3.Client, bucket string) string {
+	out, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return aws.ToString(out.BucketRegion)
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.Response.Header.Get("X-Amz-Bucket-Region")
+	}
+	return ""
+}
+
+// Objects of an S3 bucket under a prefix
+type s3Bucket struct {
+	client *s3.Client
+	bucket string
+	prefix string
+	// Key of the objects encrypted with SSE-C, base64 encoded, and the base64 MD5 of the key. Empty for none
+	sseCustomerKey    string
+	sseCustomerKeyMD5 string
+	pages             *s3.ListObjectsV2Paginator
+}
+
+// newS3Bucket Objects of a bucket under a prefix, listed with ListObjectsV2 page by page
+// @parameters
+// client - Client of S3, in the region of the bucket
+// bucket - Name of the bucket
+// prefix - Prefix of the keys of the objects, empty for all of them
+// sseCustomerKey - Base64 key of the objects encrypted with SSE-C, empty for none
+// @returns
+// *s3Bucket - Objects of the bucket
+// Error - Errors if the key is not a base64 256-bit key
+func newS3Bucket(client *s3.Client, bucket string, prefix string, sseCustomerKey string) (*s3Bucket, error) {
+	b := &s3Bucket{client: client, bucket: bucket, prefix: prefix}
+	if sseCustomerKey != "" {
+		key, err := decodeSSECustomerKey(sseCustomerKey)
+		if err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(key)
+		b.sseCustomerKey = sseCustomerKey
+		b.sseCustomerKeyMD5 = base64.StdEncoding.EncodeToString(sum[:])
+	}
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	b.pages = s3.NewListObjectsV2Paginator(client, input)
+	return b, nil
+}
+
+// The continuation token is kept by the paginator, the one returned only tells if there is a next page
+func (b *s3Bucket) list(ctx context.Context, _ string) ([]storeObject, string, error) {
+	page, err := b.pages.NextPage(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("list s3://%s/%s: %w", b.bucket, b.prefix, err)
+	}
+	objects := make([]storeObject, 0, len(page.Contents))
+	for _, object := range page.Contents {
+		objects = append(objects, storeObject{Key: aws.ToString(object.Key), Size: aws.ToInt64(object.Size),
+			Archived: archivedStorageClasses[object.StorageClass]})
+	}
+	if !b.pages.HasMorePages() {
+		return objects, "", nil
+	}
+	return objects, aws.ToString(page.NextContinuationToken), nil
+}
+
+// The objects encrypted with SSE-S3 or SSE-KMS are decrypted by S3, the ones encrypted with SSE-C with
+// --s3-sse-customer-key
+func (b *s3Bucket) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)}
+	if b.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String("AES256")
+		input.SSECustomerKey = aws.String(b.sseCustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(b.sseCustomerKeyMD5)
+	}
+	out, err := b.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (b *s3Bucket) url(key string) string {
+	return "s3://" + b.bucket + "/" + key
+}
+
+// ScanSecretsInS3 Scans the objects of an S3 bucket under a prefix, with the AWS credentials of the default
+// credential chain. Objects are read from S3 as they are matched, without being written to disk, and the ones larger
+// than their maximum file size are skipped
+// @parameters
+// rawURL - s3://bucket/prefix URL of the objects to scan
+// @returns
+// []output.SecretFound - List of all secrets found, in the keys of their objects
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInS3(rawURL string) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	bucket, prefix, err := parseS3URL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx := core.GetSession().Context
+	cfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := newS3Client(cfg, *options.S3Region)
+	// The bucket may be in another region than the one of the AWS config
+	if *options.S3Region == "" && client.Options().BaseEndpoint == nil {
+		if region := s3BucketRegion(ctx, client, bucket); region != "" && region != client.Options().Region {
+			log.Debugf("scanSecretsInS3: bucket %s is in region %s", bucket, region)
+			client = newS3Client(cfg, region)
+		}
+	}
+	store, err := newS3Bucket(client, bucket, prefix, *options.S3SSECustomerKey)
+	if err != nil {
+		return nil, err
+	}
+	return scanObjectStore(ctx, store)
+}
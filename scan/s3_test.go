@@ -0,0 +1,262 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// Object listed by the fake S3
+type s3TestObject struct {
+	Key          string `xml:"Key"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// Fake S3 serving the objects of a bucket with ListObjectsV2 pages of pageSize objects, checking the signatures
+func fakeS3(t *testing.T, bucket string, objects map[string]s3TestObject, contents map[string]string, pageSize int,
+	sseKey string) *httptest.Server {
+	credentials := aws.Credentials{AccessKeyID: "AKIATEST", SecretAccessKey: "test-secret", SessionToken: "session"}
+	signer := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil || r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Sign the headers signed by the client again, with the expected credentials
+		_, signedHeaders, _ := strings.Cut(r.Header.Get("Authorization"), "SignedHeaders=")
+		signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+		expected, _ := http.NewRequest(r.Method, "http://"+r.Host+r.RequestURI, nil)
+		for _, name := range strings.Split(signedHeaders, ";") {
+			if name != "host" {
+				expected.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+			}
+		}
+		err = signer.SignHTTP(r.Context(), credentials, expected, r.Header.Get("X-Amz-Content-Sha256"), "s3",
+			"eu-west-1", date)
+		if err != nil || r.Header.Get("Authorization") != expected.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<Error><Code>SignatureDoesNotMatch</Code><Message>bad signature</Message></Error>"))
+			return
+		}
+
+		if r.URL.Path == "/"+bucket && r.URL.Query().Get("list-type") == "2" {
+			var page struct {
+				XMLName               xml.Name       `xml:"ListBucketResult"`
+				Contents              []s3TestObject `xml:"Contents"`
+				IsTruncated           bool           `xml:"IsTruncated"`
+				NextContinuationToken string         `xml:"NextContinuationToken"`
+			}
+			for _, key := range keys {
+				if !strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+					continue
+				}
+				if token := r.URL.Query().Get("continuation-token"); token != "" && key <= token {
+					continue
+				}
+				if len(page.Contents) == pageSize {
+					page.IsTruncated = true
+					page.NextContinuationToken = page.Contents[pageSize-1].Key
+					break
+				}
+				page.Contents = append(page.Contents, objects[key])
+			}
+			data, _ := xml.Marshal(page)
+			w.Write(data)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")
+		body, ok := contents[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+			return
+		}
+		if strings.Contains(key, "/encrypted/") && r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key") != sseKey {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("<Error><Code>InvalidRequest</Code><Message>The object was stored using a form of " +
+				"Server Side Encryption.</Message></Error>"))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func Test_ScanS3(t *testing.T) {
+	options := testSession(t).Options
+	defer func(workers int) { *options.WorkersPerScan = workers }(*options.WorkersPerScan)
+	sseKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+
+	large := testLayerSecret + strings.Repeat("a", int(*options.MaximumFileSize)*1024)
+	contents := map[string]string{
+		"app/config/app settings.properties": testLayerSecret,
+		"app/config/empty.txt":               "",
+		"app/deploy/prod.properties":         testLayerSecret,
+		"app/encrypted/db.properties":        testLayerSecret,
+		"app/large.txt":                      large,
+		"app/archive/old.properties":         testLayerSecret,
+		"other/app.properties":               testLayerSecret,
+	}
+	objects := map[string]s3TestObject{"app/config/": {Key: "app/config/"}}
+	for key, body := range contents {
+		objects[key] = s3TestObject{Key: key, Size: int64(len(body)), StorageClass: "STANDARD"}
+	}
+	objects["app/archive/old.properties"] = s3TestObject{Key: "app/archive/old.properties",
+		Size: int64(len(testLayerSecret)), StorageClass: "DEEP_ARCHIVE"}
+	delete(contents, "app/archive/old.properties")
+	// Listed but deleted since
+	objects["app/gone.properties"] = s3TestObject{Key: "app/gone.properties", Size: 10}
+
+	server := fakeS3(t, "secrets-bucket", objects, contents, 2, sseKey)
+	defer server.Close()
+	awsTestEnv(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	cfg, err := loadAWSConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		workers  int
+		sseKey   string
+		expected []string
+	}{
+		{1, "", []string{"app/config/app settings.properties", "app/deploy/prod.properties"}},
+		{4, sseKey, []string{"app/config/app settings.properties", "app/deploy/prod.properties",
+			"app/encrypted/db.properties"}},
+	} {
+		*options.WorkersPerScan = test.workers
+		store, err := newS3Bucket(newS3Client(cfg, "eu-west-1"), "secrets-bucket", "app/", test.sseKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets, err := scanObjectStore(testSession(t).Context, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, secret := range secrets {
+			keys = append(keys, secret.CompleteFilename)
+		}
+		if !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("secrets with %d workers found in %v, want %v", test.workers, keys, test.expected)
+		}
+	}
+
+	// Wrong credentials fail the listing
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wrong")
+	if cfg, err = loadAWSConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	store, err := newS3Bucket(newS3Client(cfg, "eu-west-1"), "secrets-bucket", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = scanObjectStore(testSession(t).Context, store); err == nil ||
+		!strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("listing with wrong credentials: %v", err)
+	}
+}
+
+func Test_S3Region(t *testing.T) {
+	awsTestEnv(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+	dir := t.TempDir()
+	config := "[default]\nregion = eu-west-1\n[profile ci]\nregion = ap-south-1\n"
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	for _, test := range []struct {
+		profile  string
+		env      string
+		option   string
+		expected string
+	}{
+		{"", "", "", "eu-west-1"},
+		{"ci", "", "", "ap-south-1"},
+		{"ci", "us-west-2", "", "us-west-2"},
+		{"ci", "us-west-2", "sa-east-1", "sa-east-1"},
+	} {
+		t.Setenv("AWS_PROFILE", test.profile)
+		t.Setenv("AWS_REGION", test.env)
+		cfg, err := loadAWSConfig(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if region := newS3Client(cfg, test.option).Options().Region; region != test.expected {
+			t.Errorf("profile %q, AWS_REGION %q and --s3-region %q: region %s, want %s", test.profile, test.env,
+				test.option, region, test.expected)
+		}
+	}
+
+	// us-east-1 without any region set
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "missing"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	if cfg, err := loadAWSConfig(context.Background()); err != nil || cfg.Region != defaultAWSRegion {
+		t.Errorf("default region %s, %v", cfg.Region, err)
+	}
+}
+
+// Clears the AWS environment for the test and points the shared files to a temp dir
+func awsTestEnv(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_S3",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func Test_CheckS3(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	for _, test := range []struct {
+		url    string
+		key    string
+		valid  bool
+		bucket string
+		prefix string
+	}{
+		{"s3://bucket/app/config", "", true, "bucket", "app/config"},
+		{"s3://bucket", key, true, "bucket", ""},
+		{"s3://bucket/", "", true, "bucket", ""},
+		{"https://bucket.s3.amazonaws.com/app", "", false, "", ""},
+		{"s3:///app", "", false, "", ""},
+		{"s3://bucket/app", "c2hvcnQ=", false, "", ""},
+	} {
+		if err := CheckS3(test.url, test.key); (err == nil) != test.valid {
+			t.Errorf("CheckS3(%s, %s) = %v, want valid %v", test.url, test.key, err, test.valid)
+		}
+		if bucket, prefix, _ := parseS3URL(test.url); test.valid && (bucket != test.bucket || prefix != test.prefix) {
+			t.Errorf("bucket %q and prefix %q of %s", bucket, prefix, test.url)
+		}
+	}
+}
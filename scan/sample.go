@@ -0,0 +1,50 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+// ReadSampleInput Read the input of --test-rule: the contents of a file, or the string itself if it is not a file
+// @parameters
+// input - Path of a file or sample string
+// @returns
+// []byte - Contents matched
+// string - Path matched by the path, filename and extension rules: the path of the file, or the string itself
+// Error - Errors reading the file if any. Otherwise, returns nil
+func ReadSampleInput(input string) ([]byte, string, error) {
+	if info, err := os.Stat(input); err != nil || !info.Mode().IsRegular() {
+		return []byte(input), input, nil
+	}
+	contents, err := os.ReadFile(input)
+	return contents, input, err
+}
+
+// MatchSample Match the rules selected against a sample input like a file of a scan, the allowlist and
+// baseline of the scans aside
+// @parameters
+// ctx - Context of the match, matching stops once it is done
+// contents - Contents of the sample
+// path - Path of the sample
+// @returns
+// []output.SecretFound - Matches found, with their lines and columns in the contents
+// Error - Errors if any. Otherwise, returns nil
+func MatchSample(ctx context.Context, contents []byte, path string) ([]output.SecretFound, error) {
+	var numSecrets uint
+	filename, extension := filepath.Base(path), filepath.Ext(path)
+	secrets := signature.MatchSimpleSignatures(ctx, path, filename, extension, "", &numSecrets)
+	patternSecrets, err := signature.MatchPatternSignatures(ctx, contents, path, filename, extension, "",
+		&numSecrets, make(map[uint]uint))
+	secrets = append(secrets, patternSecrets...)
+	if err != nil {
+		return secrets, err
+	}
+	secrets = append(secrets, signature.MatchHighEntropyStrings(ctx, contents, path, "", secrets, &numSecrets,
+		make(map[uint]uint))...)
+	locateSecrets(contents, secrets)
+	return secrets, nil
+}
@@ -0,0 +1,33 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ReadSampleInput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials.env")
+	if err := os.WriteFile(file, []byte("AWS_SECRET=abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		input    string
+		contents string
+		path     string
+	}{
+		{file, "AWS_SECRET=abc\n", file},
+		{"id_rsa", "id_rsa", "id_rsa"},
+		{"token: ghp_0123456789", "token: ghp_0123456789", "token: ghp_0123456789"},
+		{filepath.Dir(file), filepath.Dir(file), filepath.Dir(file)},
+	} {
+		contents, path, err := ReadSampleInput(test.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.input, err)
+		}
+		if string(contents) != test.contents || path != test.path {
+			t.Errorf("%s: read %q at %s, want %q at %s", test.input, contents, path, test.contents, test.path)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Modes of the tree entries which are not regular files: symlinks and submodules
+const (
+	gitSymlinkMode   = "120000"
+	gitSubmoduleMode = "160000"
+)
+
+// A file added or modified by the staged changes, with the blob of its staged version
+type stagedBlob struct {
+	Hash string
+	Path string
+}
+
+// Lines added to one file by the staged changes
+type stagedFile struct {
+	Path  string
+	Lines []stagedLine
+}
+
+type stagedLine struct {
+	Number int // 1-based line number in the new version of the file
+	Text   string
+}
+
+// ScanStagedChanges Scans only the lines added by the staged changes of a git repository
+// @parameters
+// repoDir - Directory inside the git repository
+// @returns
+// []output.SecretFound - List of all secrets found, with line numbers of the staged file
+// Error - Errors if any. Otherwise, returns nil
+func ScanStagedChanges(repoDir string) ([]output.SecretFound, error) {
+	stdout, stderr, exitCode := runCommand("git", "-C", repoDir, "diff", "--cached",
+		"--unified=0", "--no-color", "--no-ext-diff", "--no-prefix", "--diff-filter=ACMR")
+	if exitCode != 0 {
+		return nil, errors.New(stderr)
+	}
+
+	var secretsFound []output.SecretFound
+	numSecrets := uint(0)
+	for _, file := range parseStagedDiff(stdout) {
+		if core.IsSkippableFileExtension(file.Path) {
+			Coverage.AddSkipped(file.Path, "", skipBlacklistedExt)
+			continue
+		}
+		if len(file.Lines) == 0 {
+			continue
+		}
+
+		// Concatenate the added lines and remember where each one starts,
+		// to map the offset of a match back to its line number
+		var contents bytes.Buffer
+		offsets := make([]int, 0, len(file.Lines))
+		for _, line := range file.Lines {
+			offsets = append(offsets, contents.Len())
+			contents.WriteString(line.Text)
+			contents.WriteByte('\n')
+		}
+
+		matchFile := core.NewMatchFile(file.Path)
+		matchedRuleSet := map[uint]uint{}
+		Stats.AddFile()
+		secrets, err := signature.MatchPatternSignatures(core.GetSession().Context, contents.Bytes(), file.Path, matchFile.Filename,
+			matchFile.Extension, "", &numSecrets, matchedRuleSet)
+		if err != nil {
+			log.Errorf("ScanStagedChanges: %s: %s", file.Path, err)
+			Coverage.AddErrored(file.Path, "", err, 0)
+			continue
+		}
+		if !*core.GetSession().Options.NoEntropy {
+			secrets = append(secrets, signature.MatchHighEntropyStrings(core.GetSession().Context, contents.Bytes(), file.Path, "", secrets,
+				&numSecrets, matchedRuleSet)...)
+		}
+		secrets = allowed(secrets, &numSecrets, matchedRuleSet)
+		Coverage.AddScanned(file.Path, "", len(secrets))
+		for i := range secrets {
+			if secrets[i].PartToMatch != signature.ContentsPart {
+				continue
+			}
+			from := secrets[i].PrintBufferStartIndex + secrets[i].MatchFromByte
+			to := secrets[i].PrintBufferStartIndex + secrets[i].MatchToByte - 1
+			start, end := lineIndex(offsets, from), lineIndex(offsets, to)
+			secrets[i].LineNumber, secrets[i].ColumnNumber = file.Lines[start].Number, from-offsets[start]+1
+			secrets[i].EndLineNumber, secrets[i].EndColumnNumber = file.Lines[end].Number, to-offsets[end]+1
+		}
+		secretsFound = append(secretsFound, secrets...)
+
+		if numSecrets >= *core.GetSession().Options.MaxSecrets {
+			log.Warnf("ScanStagedChanges: %s", maxSecretsExceeded)
+			break
+		}
+	}
+
+	return secretsFound, nil
+}
+
+// ScanStagedFiles Scans the whole staged version of the files added or modified by the staged changes, read from
+// the index of the git repository with a single git process. The working tree is neither read nor modified
+// @parameters
+// repoDir - Directory inside the git repository
+// @returns
+// []output.SecretFound - List of all secrets found, with line numbers of the staged file
+// Error - Errors if any. Otherwise, returns nil
+func ScanStagedFiles(repoDir string) ([]output.SecretFound, error) {
+	stdout, stderr, exitCode := runCommand("git", "-C", repoDir, "diff", "--cached", "--raw", "--no-abbrev",
+		"--no-renames", "--diff-filter=ACM")
+	if exitCode != 0 {
+		return nil, errors.New(stderr)
+	}
+	blobs, err := newBlobReader(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	defer blobs.Close()
+
+	session := core.GetSession()
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+	var secretsFound []output.SecretFound
+	numSecrets := uint(0)
+	for _, line := range strings.Split(stdout, "\n") {
+		blob, ok := parseRawDiffLine(line)
+		if !ok {
+			continue
+		}
+		path := filepath.FromSlash(blob.Path)
+		if core.IsSkippableFileExtension(path) {
+			Coverage.AddSkipped(path, "", skipBlacklistedExt)
+			continue
+		}
+		contents, err := blobs.Read(blob.Hash, int64(session.Options.MaxFileSizes.Limit(path, maxFileSize)))
+		if err != nil {
+			return secretsFound, err
+		}
+		if contents == nil {
+			Coverage.AddSkipped(path, "", skipMaxFileSize)
+			continue
+		}
+
+		matchFile := core.NewMatchFile(path)
+		Stats.AddFile()
+		secrets, err := scanContents(session.Context, contents, path, matchFile.Filename, matchFile.Extension, "",
+			&numSecrets, map[uint]uint{})
+		if err != nil {
+			log.Errorf("ScanStagedFiles: %s: %s", path, err)
+			Coverage.AddErrored(path, "", err, 0)
+			continue
+		}
+		secrets = append(secrets, allowed(signature.MatchSimpleSignatures(session.Context, path, matchFile.Filename,
+			matchFile.Extension, "", &numSecrets), &numSecrets, nil)...)
+		Coverage.AddScanned(path, "", len(secrets))
+		secretsFound = append(secretsFound, secrets...)
+
+		if numSecrets >= *session.Options.MaxSecrets {
+			log.Warnf("ScanStagedFiles: %s", maxSecretsExceeded)
+			break
+		}
+	}
+	return secretsFound, nil
+}
+
+// Parse a zero context unified diff without path prefixes into the lines added per file
+func parseStagedDiff(diff string) []stagedFile {
+	var files []stagedFile
+	var current *stagedFile
+	lineNumber := 0
+	inHeader := false
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = nil
+			inHeader = true
+		case inHeader && strings.HasPrefix(line, "+++ "):
+			name := diffHeaderPath(strings.TrimPrefix(line, "+++ "))
+			if name == "/dev/null" {
+				current = nil
+				continue
+			}
+			files = append(files, stagedFile{Path: filepath.FromSlash(name)})
+			current = &files[len(files)-1]
+		case strings.HasPrefix(line, "@@ "):
+			inHeader = false
+			// @@ -old[,count] +new[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			lineNumber, _ = strconv.Atoi(start)
+		case inHeader || current == nil:
+			continue
+		case strings.HasPrefix(line, "+"):
+			current.Lines = append(current.Lines, stagedLine{Number: lineNumber, Text: line[1:]})
+			lineNumber++
+		case strings.HasPrefix(line, " "):
+			lineNumber++
+		}
+	}
+
+	return files
+}
+
+// Path of a file header of a diff. Git ends the paths with spaces with a tab, and quotes the paths with special
+// characters as C strings, the bytes of non-ASCII characters escaped in octal as Go strings do
+func diffHeaderPath(name string) string {
+	name = strings.TrimSuffix(name, "\t")
+	if strings.HasPrefix(name, `"`) {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			return unquoted
+		}
+	}
+	return name
+}
+
+// Index of the line containing the offset, given the sorted start offsets of the lines
+func lineIndex(offsets []int, offset int) int {
+	i := 0
+	for i+1 < len(offsets) && offsets[i+1] <= offset {
+		i++
+	}
+	return i
+}
+
+// Parse a line of the --raw output of git diff into the new blob of a file, false if it is not such a line or the
+// file is a symlink or a submodule
+func parseRawDiffLine(line string) (stagedBlob, bool) {
+	if !strings.HasPrefix(line, ":") {
+		return stagedBlob{}, false
+	}
+	// :<old mode> <new mode> <old hash> <new hash> <status>\t<path>
+	meta, path, found := strings.Cut(line[1:], "\t")
+	fields := strings.Fields(meta)
+	if !found || len(fields) != 5 || fields[1] == gitSymlinkMode || fields[1] == gitSubmoduleMode {
+		return stagedBlob{}, false
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil && strings.HasPrefix(path, `"`) {
+		path = unquoted
+	}
+	return stagedBlob{Hash: fields[3], Path: filepath.ToSlash(path)}, true
+}
+
+// Reads the blobs of a repository with a single git cat-file process
+type blobReader struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func newBlobReader(repoDir string) (*blobReader, error) {
+	cmd := exec.Command("git", "-C", repoDir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &blobReader{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// Read Returns the contents of a blob, nil if it is larger than maxSize bytes
+func (r *blobReader) Read(hash string, maxSize int64) ([]byte, error) {
+	if _, err := fmt.Fprintln(r.stdin, hash); err != nil {
+		return nil, err
+	}
+	// <hash> <type> <size>, or <hash> missing
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("cannot read blob %s: %s", hash, strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > maxSize {
+		_, err = r.stdout.Discard(int(size) + 1)
+		return nil, err
+	}
+	contents := make([]byte, size+1) // Followed by a newline
+	if _, err := io.ReadFull(r.stdout, contents); err != nil {
+		return nil, err
+	}
+	return contents[:size], nil
+}
+
+func (r *blobReader) Close() error {
+	r.stdin.Close()
+	return r.cmd.Wait()
+}
@@ -0,0 +1,44 @@
+package scan
+
+import "sync/atomic"
+
+// Stats counts the files and layers scanned, for the summary of the results.
+// Unlike Coverage and Progress it is always enabled
+var Stats ScanStats
+
+// ScanStats Number of files and image layers scanned so far, and of secrets filtered out by --min-severity
+type ScanStats struct {
+	files    atomic.Int64
+	layers   atomic.Int64
+	filtered atomic.Int64
+}
+
+// Record a file which was scanned, completely or not
+func (s *ScanStats) AddFile() {
+	s.files.Add(1)
+}
+
+// Record a layer of an image which was scanned
+func (s *ScanStats) AddLayer() {
+	s.layers.Add(1)
+}
+
+// Record secrets which are not reported as they are below --min-severity
+func (s *ScanStats) AddFiltered(secrets int) {
+	s.filtered.Add(int64(secrets))
+}
+
+// Files Returns the number of files scanned
+func (s *ScanStats) Files() int {
+	return int(s.files.Load())
+}
+
+// Layers Returns the number of image layers scanned
+func (s *ScanStats) Layers() int {
+	return int(s.layers.Load())
+}
+
+// Filtered Returns the number of secrets below --min-severity
+func (s *ScanStats) Filtered() int {
+	return int(s.filtered.Load())
+}
@@ -0,0 +1,53 @@
+package scan
+
+import (
+	"io"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Name of the file the secrets read from the standard input are reported in
+const StdinFilename = "<stdin>"
+
+// ScanSecretsInReader Scans contents read from a stream, such as the standard input, window by window so that
+// large inputs are never held in memory. Only the first --maximum-file-size KB are scanned
+// @parameters
+// r - Contents to scan
+// name - Name of the file the secrets are reported in
+// @returns
+// []output.SecretFound - List of all secrets found
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInReader(r io.Reader, name string) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	ctx := core.GetSession().Context
+	maxSize := int64(*options.MaximumFileSize) * 1024
+	limited := &io.LimitedReader{R: r, N: maxSize}
+	numSecrets := uint(0)
+
+	Stats.AddFile()
+	secrets, err := scanWindows(limited, streamWindowSize, streamWindowOverlap, *options.MaxSecrets, &numSecrets,
+		map[uint]uint{}, func(window []byte, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			windowSecrets, err := signature.MatchContentsSignatures(ctx, window, name, "", numSecrets, matchedRuleSet)
+			if err != nil {
+				return nil, err
+			}
+			if !*options.NoEntropy {
+				windowSecrets = append(windowSecrets, signature.MatchHighEntropyStrings(ctx, window, name, "",
+					windowSecrets, numSecrets, matchedRuleSet)...)
+			}
+			return allowed(windowSecrets, numSecrets, matchedRuleSet), nil
+		})
+	if err == nil && limited.N == 0 {
+		if n, _ := r.Read(make([]byte, 1)); n > 0 {
+			log.Warnf("%s: only the first %d KB were scanned, raise --maximum-file-size to scan more",
+				name, *options.MaximumFileSize)
+		}
+	}
+	return secrets, err
+}
@@ -0,0 +1,29 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ScanSecretsInReader(t *testing.T) {
+	options := testSession(t).Options
+	defer func(maximumFileSize uint) { *options.MaximumFileSize = maximumFileSize }(*options.MaximumFileSize)
+	*options.MaximumFileSize = 1
+
+	secrets, err := ScanSecretsInReader(strings.NewReader("name = app\n"+testLayerSecret), StdinFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].CompleteFilename != StdinFilename || secrets[0].LineNumber != 2 {
+		t.Fatalf("secrets %+v, want one in %s at line 2", secrets, StdinFilename)
+	}
+
+	// Only the first --maximum-file-size KB are scanned
+	secrets, err = ScanSecretsInReader(strings.NewReader(strings.Repeat("#\n", 1024)+testLayerSecret), StdinFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 {
+		t.Errorf("secrets %+v found past --maximum-file-size", secrets)
+	}
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Time given to the consumer to read the secrets already found once a scan is cancelled
+var drainTimeout = 5 * time.Second
+
+// StreamStatus Final status of a streaming scan. It is set before the channel of
+// secrets is closed, so it can be read once the channel has been drained
+type StreamStatus struct {
+	// The scan stopped at --max-secrets, the target may contain more secrets
+	Truncated bool
+	// Number of secrets delivered on the channel
+	Delivered uint
+	// Number of secrets found but not read by the consumer within the drain window
+	Dropped uint
+	// Why the scan stopped early, e.g. it was cancelled. Secrets found before are still delivered
+	Err error
+	// ID of the image scanned, empty for directories
+	ImageID string
+}
+
+// Delivers at most max secrets on the channel of a streaming scan
+type secretSink struct {
+	res      chan output.SecretFound
+	max      uint
+	status   *StreamStatus
+	deadline time.Time // Set once the scan is cancelled
+}
+
+func newSecretSink(res chan output.SecretFound, max uint) *secretSink {
+	return &secretSink{res: res, max: max, status: &StreamStatus{}}
+}
+
+// Deliver a secret, unless the maximum number of secrets has already been delivered.
+// Once the scan is cancelled, waits for the consumer until the end of the drain window
+// @returns
+// bool - false if the secret was dropped
+func (s *secretSink) send(secret output.SecretFound) bool {
+	if s.full() {
+		s.status.Truncated = true
+		return false
+	}
+
+	if s.deadline.IsZero() {
+		s.res <- secret
+	} else {
+		timer := time.NewTimer(time.Until(s.deadline))
+		defer timer.Stop()
+		select {
+		case s.res <- secret:
+		case <-timer.C:
+			s.status.Dropped++
+			return false
+		}
+	}
+	s.status.Delivered++
+	return true
+}
+
+// Indicates if the maximum number of secrets has been delivered
+func (s *secretSink) full() bool {
+	return s.status.Delivered >= s.max
+}
+
+// Record why the scan stops early and start the drain window of the secrets already found
+func (s *secretSink) cancel(err error) {
+	if s.status.Err != nil {
+		return
+	}
+	s.status.Err = err
+	s.deadline = time.Now().Add(drainTimeout)
+}
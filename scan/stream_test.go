@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+func Test_SecretSinkCap(t *testing.T) {
+	res := make(chan output.SecretFound, 10)
+	sink := newSecretSink(res, 3)
+
+	for i := 0; i < 5; i++ {
+		sink.send(output.SecretFound{RuleID: i})
+	}
+	close(res)
+
+	var delivered []int
+	for secret := range res {
+		delivered = append(delivered, secret.RuleID)
+	}
+	if len(delivered) != 3 || delivered[2] != 2 {
+		t.Errorf("expected exactly the first 3 secrets, got %v", delivered)
+	}
+	if !sink.status.Truncated || sink.status.Delivered != 3 {
+		t.Errorf("unexpected status: %+v", sink.status)
+	}
+}
+
+func Test_SecretSinkNotTruncated(t *testing.T) {
+	res := make(chan output.SecretFound, 10)
+	sink := newSecretSink(res, 3)
+
+	sink.send(output.SecretFound{})
+	sink.send(output.SecretFound{})
+
+	if sink.full() || sink.status.Truncated || sink.status.Delivered != 2 {
+		t.Errorf("unexpected status below the cap: %+v", sink.status)
+	}
+}
+
+func Test_SecretSinkDrainOnCancel(t *testing.T) {
+	drainTimeout = 10 * time.Millisecond
+	defer func() { drainTimeout = 5 * time.Second }()
+
+	// Room for a single secret and nobody reading: a cancelled scan must not block
+	res := make(chan output.SecretFound, 1)
+	sink := newSecretSink(res, 10)
+	sink.cancel(errors.New("cancelled"))
+
+	done := make(chan struct{})
+	go func() {
+		sink.send(output.SecretFound{RuleID: 1})
+		sink.send(output.SecretFound{RuleID: 2})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send blocked after the drain window")
+	}
+
+	if secret := <-res; secret.RuleID != 1 {
+		t.Errorf("secret found before the cancellation should be delivered, got %+v", secret)
+	}
+	if sink.status.Delivered != 1 || sink.status.Dropped != 1 || sink.status.Err == nil {
+		t.Errorf("unexpected status: %+v", sink.status)
+	}
+}
+
+func Test_ScanSecretsInDirStreamTruncated(t *testing.T) {
+	options := testSession(t).Options
+	defer func(maxSecrets uint) { *options.MaxSecrets = maxSecrets }(*options.MaxSecrets)
+	*options.MaxSecrets = 2
+
+	tokens := []string{"J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa", "Zq7Wm2Lp9Xc4Vb8Nk1Hj6Gf3Ds5Ra0Te",
+		"Mn4Bv7Cx1Zl8Kj5Hg2Fd9Sa6Qw3Er0Ty"}
+	for _, test := range []struct {
+		files     int
+		truncated bool
+	}{{1, false}, {2, false}, {3, true}} {
+		dir := t.TempDir()
+		for i := 0; i < test.files; i++ {
+			writeTestFile(t, dir, fmt.Sprintf("app%d.conf", i), []byte("token = "+tokens[i]+"\n"))
+		}
+		secrets, status, err := ScanSecretsInDirStream("", dir, dir, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivered := 0
+		for range secrets {
+			delivered++
+		}
+		// Exactly --max-secrets secrets found don't truncate the scan, one more does
+		expected := min(test.files, 2)
+		if delivered != expected || status.Truncated != test.truncated {
+			t.Errorf("%d files: %d secrets delivered, truncated %v, want %d and %v", test.files, delivered,
+				status.Truncated, expected, test.truncated)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bytes at the start of a JSON file searched for the terraform_version key of the states and plans
+const terraformHeadSize = 1024
+
+// State of Terraform, terraform.tfstate, or JSON plan or state of terraform show -json
+type terraformJSON struct {
+	// State file, version 4
+	Outputs   map[string]terraformOutput `json:"outputs"`
+	Resources []terraformStateResource   `json:"resources"`
+	// terraform show -json of a plan or a state
+	Variables     map[string]terraformOutput `json:"variables"`
+	Values        *terraformValues           `json:"values"`
+	PlannedValues *terraformValues           `json:"planned_values"`
+	PriorState    *struct {
+		Values *terraformValues `json:"values"`
+	} `json:"prior_state"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Before          interface{} `json:"before"`
+			BeforeSensitive interface{} `json:"before_sensitive"`
+			After           interface{} `json:"after"`
+			AfterSensitive  interface{} `json:"after_sensitive"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+	Configuration *struct {
+		ProviderConfig map[string]struct {
+			Expressions map[string]struct {
+				ConstantValue interface{} `json:"constant_value"`
+			} `json:"expressions"`
+		} `json:"provider_config"`
+		RootModule struct {
+			Variables map[string]struct {
+				Sensitive bool `json:"sensitive"`
+			} `json:"variables"`
+		} `json:"root_module"`
+	} `json:"configuration"`
+}
+
+// Output of a state or plan, or variable of a plan
+type terraformOutput struct {
+	Value     interface{} `json:"value"`
+	Sensitive bool        `json:"sensitive"`
+}
+
+// Resource of a state file, with its instances
+type terraformStateResource struct {
+	Module    string `json:"module"`
+	Mode      string `json:"mode"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Instances []struct {
+		IndexKey            interface{}       `json:"index_key"`
+		Attributes          interface{}       `json:"attributes"`
+		SensitiveAttributes []json.RawMessage `json:"sensitive_attributes"`
+	} `json:"instances"`
+}
+
+// Step of the path of a sensitive attribute of a state file
+type terraformPathStep struct {
+	Type  string          `json:"type"` // get_attr or index
+	Value json.RawMessage `json:"value"`
+}
+
+// Values of the modules of terraform show -json, and their outputs
+type terraformValues struct {
+	Outputs    map[string]terraformOutput `json:"outputs"`
+	RootModule *terraformModule           `json:"root_module"`
+}
+
+// Module of terraform show -json
+type terraformModule struct {
+	Resources []struct {
+		Address         string      `json:"address"`
+		Values          interface{} `json:"values"`
+		SensitiveValues interface{} `json:"sensitive_values"`
+	} `json:"resources"`
+	ChildModules []terraformModule `json:"child_modules"`
+}
+
+// String value of a state or plan
+type terraformValue struct {
+	resource  string // Address of the resource, output, variable or provider, e.g. aws_db_instance.main
+	keyPath   string // Address of the value, e.g. aws_db_instance.main.password
+	value     string
+	sensitive bool
+}
+
+// Values of a state or plan, in the order they are found, once each
+type terraformCollector struct {
+	values []terraformValue
+	index  map[string]int
+}
+
+// Whether a JSON file is a Terraform state or plan, from its name and the terraform_version key at its start
+func isTerraformJSON(fileName string, fileExtension string, contents []byte) bool {
+	extension := strings.ToLower(fileExtension)
+	if extension != ".tfstate" && extension != ".json" && !(extension == ".backup" &&
+		strings.Contains(strings.ToLower(fileName), ".tfstate")) {
+		return false
+	}
+	head := contents
+	if len(head) > terraformHeadSize {
+		head = head[:terraformHeadSize]
+	}
+	return bytes.Contains(head, []byte(`"terraform_version"`))
+}
+
+// Address of an attribute of a resource or of a value of an output, e.g. aws_db_instance.main.password
+func terraformKeyPath(address string, path string) string {
+	if path == "" || strings.HasPrefix(path, "[") {
+		return address + path
+	}
+	return address + "." + path
+}
+
+// Paths of the sensitive values of a structure mirroring the values, true for the sensitive ones
+func terraformSensitivePaths(path string, mirror interface{}, paths map[string]bool) {
+	switch mirror := mirror.(type) {
+	case bool:
+		if mirror {
+			paths[path] = true
+		}
+	case map[string]interface{}:
+		for key, value := range mirror {
+			terraformSensitivePaths(mappingKeyPath(path, key), value, paths)
+		}
+	case []interface{}:
+		for i, value := range mirror {
+			terraformSensitivePaths(path+"["+strconv.Itoa(i)+"]", value, paths)
+		}
+	}
+}
+
+// Path of a sensitive attribute of a state file, from its steps
+func terraformStatePath(raw json.RawMessage) (string, bool) {
+	var steps []terraformPathStep
+	if err := json.Unmarshal(raw, &steps); err != nil {
+		return "", false
+	}
+	path := ""
+	for _, step := range steps {
+		var index struct {
+			Value interface{} `json:"value"`
+		}
+		var name string
+		switch {
+		case step.Type == "get_attr" && json.Unmarshal(step.Value, &name) == nil:
+			path = mappingKeyPath(path, name)
+		case step.Type == "index" && json.Unmarshal(step.Value, &index) == nil:
+			// The keys of the maps are written like their attributes in the key paths
+			switch key := index.Value.(type) {
+			case string:
+				path = mappingKeyPath(path, key)
+			case float64:
+				path += "[" + strconv.Itoa(int(key)) + "]"
+			default:
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// Whether a path is sensitive, or one of its parents is
+func isTerraformSensitive(path string, sensitive map[string]bool) bool {
+	for sensitivePath, isSensitive := range sensitive {
+		if !isSensitive {
+			continue
+		}
+		if path == sensitivePath || strings.HasPrefix(path, sensitivePath+".") ||
+			strings.HasPrefix(path, sensitivePath+"[") || sensitivePath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the strings of a value of a resource, output, variable or provider
+// @parameters
+// resource - Address of the resource, output, variable or provider
+// value - Attributes of the resource, or value of the output, variable or provider setting
+// sensitive - Paths of the sensitive values, "" for the whole value
+func (v *terraformCollector) add(resource string, value interface{}, sensitive map[string]bool) {
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				return
+			}
+			keyPath := terraformKeyPath(resource, path)
+			if i, ok := v.index[keyPath+"\x00"+value]; ok {
+				v.values[i].sensitive = v.values[i].sensitive || isTerraformSensitive(path, sensitive)
+				return
+			}
+			v.index[keyPath+"\x00"+value] = len(v.values)
+			v.values = append(v.values, terraformValue{resource: resource, keyPath: keyPath, value: value,
+				sensitive: isTerraformSensitive(path, sensitive)})
+		case map[string]interface{}:
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(mappingKeyPath(path, key), value[key])
+			}
+		case []interface{}:
+			for i, item := range value {
+				walk(path+"["+strconv.Itoa(i)+"]", item)
+			}
+		}
+	}
+	walk("", value)
+}
+
+// Add the outputs and the resources of the modules of terraform show -json
+func (v *terraformCollector) addModules(values *terraformValues) {
+	if values == nil {
+		return
+	}
+	v.addOutputs(values.Outputs)
+	var walk func(module *terraformModule)
+	walk = func(module *terraformModule) {
+		for _, resource := range module.Resources {
+			sensitive := map[string]bool{}
+			terraformSensitivePaths("", resource.SensitiveValues, sensitive)
+			v.add(resource.Address, resource.Values, sensitive)
+		}
+		for i := range module.ChildModules {
+			walk(&module.ChildModules[i])
+		}
+	}
+	if values.RootModule != nil {
+		walk(values.RootModule)
+	}
+}
+
+// Add outputs, sensitive ones whole
+func (v *terraformCollector) addOutputs(outputs map[string]terraformOutput) {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.add("output."+name, outputs[name].Value, map[string]bool{"": outputs[name].Sensitive})
+	}
+}
+
+// terraformValuesOf String values of a Terraform state, or of a plan or state of terraform show -json: the attributes
+// of the resources, the outputs, and for the plans the variables and the settings of the providers
+// @parameters
+// contents - Contents of the JSON file
+// @returns
+// []terraformValue - Values with their address, once each
+// Error - Errors if the file is not valid JSON
+func terraformValuesOf(contents []byte) ([]terraformValue, error) {
+	var tf terraformJSON
+	if err := json.Unmarshal(contents, &tf); err != nil {
+		return nil, err
+	}
+	values := &terraformCollector{index: map[string]int{}}
+
+	values.addOutputs(tf.Outputs)
+	for _, resource := range tf.Resources {
+		address := resource.Type + "." + resource.Name
+		if resource.Mode == "data" {
+			address = "data." + address
+		}
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+		for _, instance := range resource.Instances {
+			instanceAddress := address
+			switch key := instance.IndexKey.(type) {
+			case float64:
+				instanceAddress += "[" + strconv.Itoa(int(key)) + "]"
+			case string:
+				instanceAddress += "[" + strconv.Quote(key) + "]"
+			}
+			sensitive := map[string]bool{}
+			for _, raw := range instance.SensitiveAttributes {
+				if path, ok := terraformStatePath(raw); ok {
+					sensitive[path] = true
+				}
+			}
+			values.add(instanceAddress, instance.Attributes, sensitive)
+		}
+	}
+
+	if tf.Configuration != nil {
+		names := make([]string, 0, len(tf.Variables))
+		for name := range tf.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			values.add("var."+name, tf.Variables[name].Value,
+				map[string]bool{"": tf.Configuration.RootModule.Variables[name].Sensitive})
+		}
+		providers := make([]string, 0, len(tf.Configuration.ProviderConfig))
+		for provider := range tf.Configuration.ProviderConfig {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+		for _, provider := range providers {
+			settings := map[string]interface{}{}
+			for name, expression := range tf.Configuration.ProviderConfig[provider].Expressions {
+				settings[name] = expression.ConstantValue
+			}
+			values.add("provider."+provider, settings, nil)
+		}
+	}
+	values.addModules(tf.Values)
+	values.addModules(tf.PlannedValues)
+	if tf.PriorState != nil {
+		values.addModules(tf.PriorState.Values)
+	}
+	for _, change := range tf.ResourceChanges {
+		sensitive := map[string]bool{}
+		terraformSensitivePaths("", change.Change.BeforeSensitive, sensitive)
+		values.add(change.Address, change.Change.Before, sensitive)
+		sensitive = map[string]bool{}
+		terraformSensitivePaths("", change.Change.AfterSensitive, sensitive)
+		values.add(change.Address, change.Change.After, sensitive)
+	}
+	return values.values, nil
+}
+
+// scanTerraformJSON Match the signatures against the values of a Terraform state or plan, each on its own like a
+// window of the file, and report the values Terraform marks sensitive which no rule matched. The secrets are reported
+// with the address of their value, e.g. aws_db_instance.main.password, instead of a line
+// @parameters
+// ctx - Context of the scan
+// contents - Contents of the JSON file
+// relPath - Path of the file reported
+// fileName - Name of the file
+// fileExtension - Extension of the file
+// layer - layer ID, if the file is in a container image
+// numSecrets - Number of secrets found so far, updated with the secrets found
+// matchedRuleSet - Matches of each rule in the file so far, shared by its values
+// @returns
+// []output.SecretFound - Secrets found in the values
+// bool - Whether the file was parsed, it has to be scanned as is otherwise
+// Error - Errors if any. Otherwise, returns nil
+func scanTerraformJSON(ctx context.Context, contents []byte, relPath, fileName, fileExtension, layer string,
+	numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, bool, error) {
+	options := core.GetSession().Options
+	values, err := terraformValuesOf(contents)
+	if err != nil {
+		log.Debugf("scanTerraformJSON: %s is not a Terraform state or plan: %s", relPath, err)
+		return nil, false, nil
+	}
+
+	// The path, filename and extension are matched once, without contents
+	secretsFound, err := signature.MatchPatternSignatures(ctx, nil, relPath, fileName, fileExtension, layer, numSecrets,
+		matchedRuleSet)
+	if err != nil {
+		return nil, true, err
+	}
+	secretsFound = allowed(secretsFound, numSecrets, matchedRuleSet)
+	for _, value := range values {
+		if *numSecrets >= *options.MaxSecrets {
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			return secretsFound, true, err
+		}
+		secrets, err := signature.MatchContentsSignatures(ctx, []byte(value.value), relPath, layer, numSecrets,
+			matchedRuleSet)
+		if err != nil {
+			return secretsFound, true, err
+		}
+		if !*options.NoEntropy {
+			secrets = append(secrets, signature.MatchHighEntropyStrings(ctx, []byte(value.value), relPath, layer, secrets,
+				numSecrets, matchedRuleSet)...)
+		}
+		if len(secrets) == 0 && value.sensitive && signature.RuleSelectedFor(ctx, signature.TerraformSensitiveRuleID) &&
+			!core.ContainsBlacklistedString([]byte(strings.ToLower(value.value))) {
+			secrets = []output.SecretFound{{
+				LayerID:          layer,
+				RuleID:           signature.TerraformSensitiveRuleID,
+				RuleName:         signature.TerraformSensitiveRuleName,
+				PartToMatch:      signature.ContentsPart,
+				Severity:         output.MEDIUM,
+				SeverityScore:    5.0,
+				CompleteFilename: relPath,
+				MatchToByte:      len(value.value),
+				MatchedContents:  value.value,
+			}}
+			*numSecrets++
+		}
+
+		secrets = allowed(secrets, numSecrets, matchedRuleSet)
+		for i := range secrets {
+			secrets[i].ResourceName = value.resource
+			secrets[i].KeyPath = value.keyPath
+		}
+		secretsFound = append(secretsFound, secrets...)
+	}
+	log.Debugf("scanTerraformJSON: %s: %d values, %d secrets", relPath, len(values), len(secretsFound))
+	return secretsFound, true, nil
+}
@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const terraformState = `{
+  "version": 4,
+  "terraform_version": "1.6.6",
+  "serial": 3,
+  "lineage": "5b6f1c1e-3d2a-4b8e-9f0a-7c1d2e3f4a5b",
+  "outputs": {
+    "db_endpoint": {"value": "main.example.internal:5432", "type": "string"},
+    "db_password": {"value": "hunter2", "type": "string", "sensitive": true}
+  },
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {
+          "schema_version": 2,
+          "attributes": {"identifier": "main", "username": "admin", "password": "hunter2", "port": 5432},
+          "sensitive_attributes": [[{"type": "get_attr", "value": "password"}]]
+        }
+      ]
+    },
+    {
+      "module": "module.app",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "worker",
+      "instances": [
+        {
+          "index_key": 0,
+          "attributes": {"triggers": {"config": "api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa'"}},
+          "sensitive_attributes": []
+        }
+      ]
+    }
+  ]
+}
+`
+
+func Test_ScanTerraformState(t *testing.T) {
+	session := testSession(t)
+	if !isTerraformJSON("terraform.tfstate", ".tfstate", []byte(terraformState)) {
+		t.Fatal("terraform.tfstate is not detected as a Terraform state")
+	}
+	numSecrets := uint(0)
+	secrets, err := scanContents(context.Background(), []byte(terraformState), "infra/terraform.tfstate",
+		"terraform.tfstate", ".tfstate", "", &numSecrets, map[uint]uint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type location struct {
+		rule     string
+		keyPath  string
+		resource string
+		line     int
+	}
+	var found []location
+	for _, secret := range secrets {
+		found = append(found, location{secret.RuleName, secret.KeyPath, secret.ResourceName, secret.LineNumber})
+	}
+	// The sensitive values are reported even if no rule matches them, the other ones only if a rule does
+	expected := []location{
+		{signature.TerraformSensitiveRuleName, "output.db_password", "output.db_password", 0},
+		{signature.TerraformSensitiveRuleName, "aws_db_instance.main.password", "aws_db_instance.main", 0},
+		{signature.GenericHighEntropyRuleName, "module.app.null_resource.worker[0].triggers.config",
+			"module.app.null_resource.worker[0]", 0},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("secrets found at %+v, want %+v", found, expected)
+	}
+	if len(secrets) == 3 && (secrets[1].Severity != output.MEDIUM || secrets[1].MatchedContents != "hunter2") {
+		t.Errorf("unexpected sensitive value %+v", secrets[1])
+	}
+	if numSecrets != 3 {
+		t.Errorf("%d secrets counted, want 3", numSecrets)
+	}
+
+	// The sensitive values are not reported with their rule disabled
+	signature.SelectRules(len(session.Config.Signatures), nil, []string{strconv.Itoa(signature.TerraformSensitiveRuleID)})
+	defer signature.SelectRules(0, nil, nil)
+	numSecrets = 0
+	secrets, err = scanContents(context.Background(), []byte(terraformState), "infra/terraform.tfstate",
+		"terraform.tfstate", ".tfstate", "", &numSecrets, map[uint]uint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].RuleName != signature.GenericHighEntropyRuleName {
+		t.Errorf("secrets %+v found with the sensitive values disabled, want the high entropy one", secrets)
+	}
+}
+
+func Test_TerraformPlanValues(t *testing.T) {
+	plan := `{
+  "format_version": "1.2",
+  "terraform_version": "1.6.6",
+  "variables": {"db_password": {"value": "hunter2"}, "region": {"value": "eu-west-1"}},
+  "planned_values": {"root_module": {"child_modules": [{"resources": [{
+    "address": "module.db.aws_db_instance.main",
+    "values": {"password": "hunter2", "tags": {"team": "data"}},
+    "sensitive_values": {"password": true, "tags": {}}
+  }]}]}},
+  "resource_changes": [{
+    "address": "module.db.aws_db_instance.main",
+    "change": {"before": null, "after": {"password": "hunter2", "tags": {"team": "data"}}, "after_sensitive": {"tags": {}}}
+  }],
+  "configuration": {
+    "provider_config": {"aws": {"name": "aws", "expressions": {"access_key": {"constant_value": "AKIAEXAMPLE"}}}},
+    "root_module": {"variables": {"db_password": {"sensitive": true}, "region": {}}}
+  }
+}`
+	values, err := terraformValuesOf([]byte(plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []terraformValue{
+		{"var.db_password", "var.db_password", "hunter2", true},
+		{"var.region", "var.region", "eu-west-1", false},
+		{"provider.aws", "provider.aws.access_key", "AKIAEXAMPLE", false},
+		{"module.db.aws_db_instance.main", "module.db.aws_db_instance.main.password", "hunter2", true},
+		{"module.db.aws_db_instance.main", "module.db.aws_db_instance.main.tags.team", "data", false},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("values %+v, want %+v", values, expected)
+	}
+}
+
+func Test_TerraformMatchesLimitedPerFile(t *testing.T) {
+	if *testSession(t).Options.MultipleMatch {
+		t.Skip("multiple matches are reported with --multi-match")
+	}
+	state := `{
+  "version": 4,
+  "terraform_version": "1.6.6",
+  "resources": [
+    {"mode": "managed", "type": "null_resource", "name": "first",
+      "instances": [{"attributes": {"token": "J8fK2mQ9xL4vR7tB1nZ6cW3yH5pD0sGa"}}]},
+    {"mode": "managed", "type": "null_resource", "name": "second",
+      "instances": [{"attributes": {"token": "Zq7Wm2Lp9Xc4Vb8Nk1Hj6Gf3Ds5Ra0Te"}}]}
+  ]
+}`
+	numSecrets := uint(0)
+	secrets, err := scanContents(context.Background(), []byte(state), "terraform.tfstate", "terraform.tfstate",
+		".tfstate", "", &numSecrets, map[uint]uint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The values share the matches of the file, a rule reports its first match only
+	if len(secrets) != 1 || secrets[0].KeyPath != "null_resource.first.token" {
+		t.Errorf("secrets %+v found, want the first token only", secrets)
+	}
+}
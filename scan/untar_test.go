@@ -0,0 +1,224 @@
+package scan
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Build a layer tar from headers written in order, regular files get the contents of their Linkname
+func layerTar(t testing.TB, dir string, headers ...tar.Header) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		var contents string
+		if hdr.Typeflag == tar.TypeReg {
+			contents, hdr.Linkname = hdr.Linkname, ""
+			hdr.Size = int64(len(contents))
+		}
+		hdr.Mode = 0644
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeTestFile(t, dir, "layer.tar", buf.Bytes())
+}
+
+// Checks that a link is written relative to its dir and stays inside the extraction dir
+func assertLinkInside(t *testing.T, root, link, expected string) {
+	target, err := os.Readlink(filepath.Join(root, link))
+	if err != nil {
+		t.Fatalf("expected %s to be a symlink: %s", link, err)
+	}
+	if filepath.IsAbs(target) {
+		t.Errorf("symlink %s has the absolute target %s", link, target)
+	}
+	resolved := filepath.Join(root, filepath.Dir(link), target)
+	if resolved != filepath.Join(root, expected) {
+		t.Errorf("symlink %s resolves to %s, want %s", link, resolved, filepath.Join(root, expected))
+	}
+}
+
+func Test_UntarSymlinkToEtcPasswd(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "layer")
+	layer := layerTar(t, dir,
+		tar.Header{Name: "app/passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		tar.Header{Name: "app/up", Typeflag: tar.TypeSymlink, Linkname: "../../../../../etc/passwd"},
+		tar.Header{Name: "etc/passwd", Typeflag: tar.TypeReg, Linkname: "root:x:0:0:layer:/root:/bin/sh\n"},
+	)
+
+	if err := untar(layer, root, newExtractBudget(1<<20, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertLinkInside(t, root, "app/passwd", "etc/passwd")
+	assertLinkInside(t, root, "app/up", "etc/passwd")
+	// Reading through the links reads the file of the layer, not the one of the host
+	assertExtracted(t, filepath.Join(root, "app", "passwd"), "root:x:0:0:layer:/root:/bin/sh\n")
+	assertExtracted(t, filepath.Join(root, "app", "up"), "root:x:0:0:layer:/root:/bin/sh\n")
+}
+
+func Test_UntarDoesNotWriteThroughSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "layer")
+	outside := writeTestFile(t, dir, "outside", []byte("untouched"))
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Link left on disk before the extraction
+	if err := os.Symlink(outside, filepath.Join(root, "existing")); err != nil {
+		t.Fatal(err)
+	}
+	layer := layerTar(t, dir,
+		tar.Header{Name: "existing", Typeflag: tar.TypeReg, Linkname: "from layer"},
+		tar.Header{Name: "rootdir", Typeflag: tar.TypeSymlink, Linkname: "/"},
+		tar.Header{Name: "rootdir/outside", Typeflag: tar.TypeReg, Linkname: "via absolute link"},
+		tar.Header{Name: "parent", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+		tar.Header{Name: "parent/escaped", Typeflag: tar.TypeReg, Linkname: "via relative link"},
+		tar.Header{Name: "a/b/c", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+		tar.Header{Name: "chained", Typeflag: tar.TypeSymlink, Linkname: "a/b/c/../../x"},
+		tar.Header{Name: "chained", Typeflag: tar.TypeReg, Linkname: "replaced link"},
+	)
+
+	if err := untar(layer, root, newExtractBudget(1<<20, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertExtracted(t, outside, "untouched")
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("file written outside of the extraction dir: %v", err)
+	}
+	assertExtracted(t, filepath.Join(root, "existing"), "from layer")
+	assertExtracted(t, filepath.Join(root, "outside"), "via absolute link")
+	assertExtracted(t, filepath.Join(root, "escaped"), "via relative link")
+	assertLinkInside(t, root, "a/b/c", "")
+	assertExtracted(t, filepath.Join(root, "chained"), "replaced link")
+	if info, _ := os.Lstat(filepath.Join(root, "chained")); info == nil || !info.Mode().IsRegular() {
+		t.Errorf("expected the link to be replaced by a regular file")
+	}
+}
+
+func Test_UntarHardlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "layer")
+	layer := layerTar(t, dir,
+		tar.Header{Name: "app/.env", Typeflag: tar.TypeReg, Linkname: "TOKEN=secret"},
+		tar.Header{Name: "app/.env.copy", Typeflag: tar.TypeLink, Linkname: "app/.env"},
+		tar.Header{Name: "app/escape", Typeflag: tar.TypeLink, Linkname: "../../outside"},
+	)
+
+	if err := untar(layer, root, newExtractBudget(1<<20, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertExtracted(t, filepath.Join(root, "app", ".env.copy"), "TOKEN=secret")
+	if _, err := os.Lstat(filepath.Join(root, "app", "escape")); !os.IsNotExist(err) {
+		t.Errorf("hard link to a missing file should be skipped: %v", err)
+	}
+}
+
+func Test_ResolveInRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Symlink("/etc", filepath.Join(root, "abs")); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"a/b":            "a/b",
+		"../../etc":      "etc",
+		"abs/passwd":     "etc/passwd",
+		"abs/../../../x": "x",
+	} {
+		actual, err := resolveInRoot(root, name)
+		if err != nil {
+			t.Errorf("resolveInRoot(%q): %s", name, err)
+			continue
+		}
+		if actual != filepath.Join(root, expected) || !strings.HasPrefix(actual, root) {
+			t.Errorf("resolveInRoot(%q) = %s, want %s", name, actual, filepath.Join(root, expected))
+		}
+	}
+
+	if err := os.Symlink("loop", filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveInRoot(root, "loop/x"); err == nil {
+		t.Errorf("expected an error for a symlink loop")
+	}
+}
+
+func Test_UntarZstdLayer(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(tarBytes(t, map[string]string{"app/.env": "AWS_SECRET_ACCESS_KEY=secret"}, false))
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Layers of a content store have no suffix, they are detected by their magic bytes
+	for _, name := range []string{"layer.tar", "layer.tar.zst"} {
+		layer := writeTestFile(t, dir, name, buf.Bytes())
+		root := filepath.Join(dir, name+".out")
+		if err = untar(layer, root, newExtractBudget(1<<20, 100)); err != nil {
+			t.Fatalf("untar %s: %s", name, err)
+		}
+		assertExtracted(t, filepath.Join(root, "app", ".env"), "AWS_SECRET_ACCESS_KEY=secret")
+	}
+}
+
+func Test_UntarBudget(t *testing.T) {
+	dir := t.TempDir()
+	layer := layerTar(t, dir,
+		tar.Header{Name: "app", Typeflag: tar.TypeDir},
+		tar.Header{Name: "app/a.txt", Typeflag: tar.TypeReg, Linkname: strings.Repeat("a", 40)},
+		tar.Header{Name: "app/b.txt", Typeflag: tar.TypeReg, Linkname: strings.Repeat("b", 40)},
+	)
+
+	if err := untar(layer, filepath.Join(dir, "fits"), newExtractBudget(80, 3)); err != nil {
+		t.Errorf("layer within the budget should be extracted, got %s", err)
+	}
+
+	// The budget is shared by all the tars of an image
+	budget := newExtractBudget(100, 100)
+	if err := untar(layer, filepath.Join(dir, "first"), budget); err != nil {
+		t.Fatal(err)
+	}
+	err := untar(layer, filepath.Join(dir, "second"), budget)
+	if !errors.Is(err, extractBudgetExceeded) || !strings.Contains(err.Error(), "--max-extract-size") {
+		t.Errorf("expected the size budget to abort the extraction, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "second", "app", "b.txt")); !os.IsNotExist(statErr) {
+		t.Errorf("entries over the budget should not be written")
+	}
+
+	err = untar(layer, filepath.Join(dir, "entries"), newExtractBudget(1<<20, 2))
+	if !errors.Is(err, extractBudgetExceeded) || !strings.Contains(err.Error(), "--max-extract-entries") {
+		t.Errorf("expected the entry budget to abort the extraction, got %v", err)
+	}
+}
+
+func Test_ExtractTooManyEntries(t *testing.T) {
+	dir := t.TempDir()
+	archive := writeTestFile(t, dir, "many.tar", tarBytes(t, map[string]string{"a": "1", "b": "2", "c": "3"}, false))
+	limits := newExtractLimits(1024)
+	limits.maxEntries = 2
+	if err := extractTarFileTo(archive, filepath.Join(dir, "out"), limits); err != archiveTooManyEntries {
+		t.Errorf("expected the entry limit to stop the extraction, got %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Most redirects followed when downloading --url
+	maxURLRedirects = 10
+	// Bytes of a download its kind is detected from, the tar magic is at offset 257
+	urlHeadSize = 512
+	// Kind of the gzip files which are not tars, scanned once decompressed
+	gzipFile = "gzip"
+	// Reported as the coverage skip reason of the downloads of images, audio, video and fonts
+	skipMediaType = "media content type"
+)
+
+var (
+	zipMagic    = []byte("PK\x03\x04")
+	tarMagic    = []byte("ustar")
+	urlTooLarge = errors.New("download exceeds maximum size")
+)
+
+// Media types of the downloads, by their Content-Type, for the downloads without magic bytes
+var archiveMediaTypes = map[string]string{
+	"application/zip":              zipArchive,
+	"application/x-zip-compressed": zipArchive,
+	"application/x-tar":            tarArchive,
+	"application/gzip":             gzipFile,
+	"application/x-gzip":           gzipFile,
+}
+
+// CheckURL Check the value of --url
+// @parameters
+// rawURL - URL to scan
+// @returns
+// Error - Errors if the URL is not an absolute http or https URL
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--url must be an http:// or https:// URL, got %q", rawURL)
+	}
+	return nil
+}
+
+// URLName Name of a URL in the reports and logs, without its credentials, query and fragment, which may hold
+// tokens such as the signatures of presigned URLs
+// @parameters
+// rawURL - URL scanned
+// @returns
+// string - URL without credentials, query and fragment
+func URLName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.User, u.RawQuery, u.ForceQuery, u.Fragment = nil, "", false, ""
+	return u.String()
+}
+
+// Path of a URL the secrets of its download are reported in, / for the root
+func urlReportPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// Client of the --url downloads, through the proxy of --proxy or of the environment, following at most
+// maxURLRedirects redirects
+func urlClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = core.ProxyFunc()
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxURLRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxURLRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirected to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// Kind of a download from its first bytes, or else from its Content-Type and then its name: zipArchive,
+// tarArchive, gzipFile, or empty for other files, scanned as they are
+func downloadKind(name string, contentType string, head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return zipArchive
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzipFile
+	case len(head) >= 257+len(tarMagic) && bytes.Equal(head[257:257+len(tarMagic)], tarMagic):
+		return tarArchive
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if kind, ok := archiveMediaTypes[mediaType]; ok {
+		return kind
+	}
+	return archiveKind(name)
+}
+
+// Checks if a Content-Type is the one of an image, audio, video or font, which hold no secrets
+func isMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if a gzip file is a compressed tar
+func isTarGzip(gzipPath string) (bool, error) {
+	file, err := os.Open(gzipPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+	head := make([]byte, urlHeadSize)
+	n, _ := io.ReadFull(gz, head)
+	return downloadKind("", "", head[:n]) == tarArchive, nil
+}
+
+// Decompress a gzip file other than a tar
+// @parameters
+// gzipPath - Path of the gzip file
+// target - Path of the decompressed file
+// maxSize - Size of the decompressed file above which it is not decompressed
+// @returns
+// bool - false if the decompressed file is larger than maxSize
+// Error - Errors if any. Otherwise, returns nil
+func gunzipFile(gzipPath string, target string, maxSize int64) (bool, error) {
+	file, err := os.Open(gzipPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return false, err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return false, err
+	}
+	n, cpErr := io.Copy(out, io.LimitReader(gz, maxSize+1))
+	closeErr := out.Close()
+	if cpErr != nil {
+		return false, cpErr
+	}
+	return n <= maxSize, closeErr
+}
+
+// Write the body of a response to a file, failing with urlTooLarge once it exceeds maxSize
+func downloadTo(target string, body io.Reader, maxSize int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	n, cpErr := io.Copy(file, io.LimitReader(body, maxSize+1))
+	closeErr := file.Close()
+	if cpErr != nil {
+		return cpErr
+	}
+	if n > maxSize {
+		return urlTooLarge
+	}
+	return closeErr
+}
+
+// ScanSecretsInURL Downloads a file over HTTP or HTTPS to a temp dir and scans it. Zip, tar and tar.gz archives
+// are extracted and their files scanned, other gzip files are decompressed, other files are scanned as they are.
+// The downloads of archives are limited by --max-extract-size, the others by their maximum file size. The temp dir
+// is deleted once scanned
+// @parameters
+// rawURL - URL of the file to scan
+// @returns
+// []output.SecretFound - List of all secrets found, in the path of the URL, e.g. /dist/app.tar.gz!/config/.env
+// Error - Errors if any. Otherwise, returns nil
+func ScanSecretsInURL(rawURL string) ([]output.SecretFound, error) {
+	if err := CheckURL(rawURL); err != nil {
+		return nil, err
+	}
+	session := core.GetSession()
+	return scanURL(session.Context, rawURL, urlClient())
+}
+
+func scanURL(ctx context.Context, rawURL string, client *http.Client) ([]output.SecretFound, error) {
+	options := core.GetSession().Options
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	name := URLName(rawURL)
+	reportPath := path.Clean(urlReportPath(u))
+	fileName := path.Base(reportPath)
+	if fileName == "/" || fileName == "." || fileName == ".." {
+		fileName = "index"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error holds the URL, with its query
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("GET %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", name, resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if isMediaType(contentType) {
+		log.Warnf("scanSecretsInURL: %s not scanned: %s %s", name, skipMediaType, contentType)
+		Coverage.AddSkipped(reportPath, "", skipMediaType)
+		return nil, nil
+	}
+	body := bufio.NewReaderSize(resp.Body, urlHeadSize)
+	head, _ := body.Peek(urlHeadSize)
+	kind := downloadKind(fileName, contentType, head)
+
+	limits := newScanExtractLimits()
+	maxFileSize := int64(options.MaxFileSizes.Limit(reportPath, *options.MaximumFileSize*1024))
+	maxSize := maxFileSize
+	if kind != "" {
+		maxSize = limits.maxTotalSize
+	}
+	tooLarge := func() ([]output.SecretFound, error) {
+		if kind != "" {
+			return nil, fmt.Errorf("GET %s: %w of %d bytes", name, urlTooLarge, maxSize)
+		}
+		log.Warnf("scanSecretsInURL: %s not scanned: %s", name, skipMaxFileSize)
+		Coverage.AddSkipped(reportPath, "", skipMaxFileSize)
+		return nil, nil
+	}
+	if resp.ContentLength > maxSize {
+		return tooLarge()
+	}
+
+	tempDir, err := core.MkdirTemp("url-")
+	if err != nil {
+		return nil, err
+	}
+	defer core.DeleteTmpDir(tempDir)
+	downloadDir := filepath.Join(tempDir, "download")
+	download := filepath.Join(downloadDir, fileName)
+	if err = downloadTo(download, body, maxSize); err == urlTooLarge {
+		return tooLarge()
+	} else if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", name, err)
+	}
+	log.Debugf("scanSecretsInURL: downloaded %s as %s", name, kind)
+
+	if kind == gzipFile {
+		isTar, err := isTarGzip(download)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if isTar {
+			kind = tarArchive
+		}
+	}
+
+	extractedDir := filepath.Join(tempDir, "extracted")
+	switch kind {
+	case zipArchive:
+		err = extractZipFileTo(download, extractedDir, limits)
+	case tarArchive:
+		err = extractTarFileTo(download, extractedDir, limits)
+	case gzipFile:
+		gunzipped := strings.TrimSuffix(strings.TrimSuffix(fileName, ".gz"), ".gzip")
+		var ok bool
+		ok, err = gunzipFile(download, filepath.Join(extractedDir, gunzipped), limits.maxFileSize)
+		if err == nil && !ok {
+			log.Warnf("scanSecretsInURL: %s not scanned: decompressed file %s", name, skipMaxFileSize)
+			Coverage.AddSkipped(reportPath+archivePathSeparator+gunzipped, "", skipMaxFileSize)
+			return nil, nil
+		}
+	default:
+		// The file is reported in the path of the URL
+		numSecrets := uint(0)
+		return scanExtractedFiles(ctx, downloadDir, strings.TrimSuffix(path.Dir(reportPath), "/")+"/", "", 1,
+			limits, &numSecrets)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	numSecrets := uint(0)
+	return scanExtractedFiles(ctx, extractedDir, reportPath+archivePathSeparator, "", 1, limits, &numSecrets)
+}
@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reasons for skipping a file or dir during the walk
+const (
+	skipBlacklistedPath = "blacklisted path"
+	skipNotRegularFile  = "not a regular file"
+	skipNoFileInfo      = "file info not available"
+	skipMaxFileSize     = "exceeds maximum file size"
+	skipBlacklistedExt  = "blacklisted extension"
+	skipExcludedPath    = "excluded path"
+	skipIgnoredPath     = "ignored by .gitignore or .dockerignore"
+)
+
+// Checks if a file or dir visited while walking a directory has to be skipped
+// @parameters
+// path - Complete path of the file or dir
+// f - Directory entry of the path
+// layer - layer ID, if we are scanning directory inside container image
+// baseDir - Parent directory
+// maxFileSize - Maximum size in bytes of the files to scan, unless --max-file-size sets the size of their extension
+// @returns
+// string - Reason to skip the path, empty if it has to be scanned
+func skipEntry(path string, f os.DirEntry, layer string, baseDir string, maxFileSize uint) string {
+	if f.IsDir() {
+		scanDirPath := path
+		if layer != "" {
+			scanDirPath = strings.TrimPrefix(path, baseDir+"/"+layer)
+			if scanDirPath == "" {
+				scanDirPath = "/"
+			}
+		} else if baseDir != "" {
+			// Container filesystems are checked from their root, the dir they are found in, such as the
+			// merged dir of an overlay under /var/lib/docker, may itself be blacklisted
+			scanDirPath = "/" + strings.TrimPrefix(strings.TrimPrefix(path, baseDir), "/")
+		}
+		if core.IsSkippableDir(scanDirPath, baseDir) {
+			return skipBlacklistedPath
+		}
+		return ""
+	}
+
+	// No need to scan sym links. This avoids hangs when scanning stderr, stdour or special file descriptors
+	// Also, the pointed files will anyway be scanned directly
+	if !f.Type().IsRegular() {
+		return skipNotRegularFile
+	}
+
+	finfo, err := f.Info()
+	if err != nil {
+		log.Warnf("Skipping %v as info could not be retrieved: %v", path, err)
+		return skipNoFileInfo
+	}
+
+	if uint(finfo.Size()) > core.GetSession().Options.MaxFileSizes.Limit(path, maxFileSize) {
+		return skipMaxFileSize
+	}
+	if core.IsSkippableFileExtension(path) && !isScannedBinary(path) {
+		return skipBlacklistedExt
+	}
+	return ""
+}
+
+// Checks if a file or dir visited while walking a directory matches an --exclude-path pattern
+// @parameters
+// root - Directory walked, the patterns are matched against the paths relative to it
+// path - Complete path of the file or dir
+// patterns - Glob patterns of the paths to exclude
+// @returns
+// bool - true if the path has to be skipped
+func isExcludedPath(root string, path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	relPath, err := filepath.Rel(root, path)
+	if err != nil || relPath == "." {
+		return false
+	}
+	return isExcludedRelPath(filepath.ToSlash(relPath), patterns)
+}
+
+// Checks if a path relative to the scanned dir, with slashes, matches an --exclude-path pattern
+func isExcludedRelPath(relPath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if core.MatchGlob(strings.TrimPrefix(pattern, "./"), relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Path of the file relative to the scanned layer, the path itself if that fails
+func relativePath(baseDir string, layer string, path string) string {
+	relPath, err := filepath.Rel(filepath.Join(baseDir, layer), path)
+	if err != nil {
+		log.Warnf("scanSecretsInDir: Couldn't remove prefix of path: %s %s %s",
+			baseDir, layer, path)
+		return path
+	}
+	return relPath
+}
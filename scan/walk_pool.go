@@ -0,0 +1,295 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
+	log "github.com/sirupsen/logrus"
+)
+
+// Result of the scan of one file, or of one package or archive, of a walked directory
+type fileResult struct {
+	secrets []output.SecretFound
+	counted uint // Secrets counted towards --max-secrets, with --count-all they include the ones dropped
+}
+
+type fileTask struct {
+	scan   func() fileResult
+	result chan fileResult
+}
+
+// Scans of the files of a directory run by a fixed number of workers while the directory is walked. Their
+// results are handled one at a time, in the order the files were submitted
+type filePool struct {
+	tasks   chan fileTask
+	pending chan chan fileResult
+	stopped atomic.Bool
+	workers sync.WaitGroup
+	handled chan struct{}
+}
+
+// newFilePool Start the workers of a pool
+// @parameters
+// ctx - Context of the scan, files are not scanned anymore once it is done
+// workers - Maximum number of files scanned at a time, at least 1
+// handle - Called with the result of each file, in order. Files not scanned yet are skipped once it returns false
+// @returns
+// *filePool - Pool to submit the files to, to be waited for once the walk is done
+func newFilePool(ctx context.Context, workers int, handle func(result fileResult) bool) *filePool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &filePool{
+		tasks:   make(chan fileTask),
+		pending: make(chan chan fileResult, workers),
+		handled: make(chan struct{}),
+	}
+	for w := 0; w < workers; w++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for task := range p.tasks {
+				if p.stopped.Load() || ctx.Err() != nil {
+					task.result <- fileResult{}
+					continue
+				}
+				task.result <- task.scan()
+			}
+		}()
+	}
+	// Files are picked up in order, so waiting for them in order never blocks a worker
+	go func() {
+		defer close(p.handled)
+		for result := range p.pending {
+			r := <-result
+			if !p.stopped.Load() && !handle(r) {
+				p.stopped.Store(true)
+			}
+		}
+	}()
+	return p
+}
+
+// submit Queue the scan of a file. Blocks while the workers are busy and while workers results are waiting to be
+// handled, so the walk doesn't get ahead of the scans
+// @returns
+// bool - false once the results are not handled anymore, the file is not scanned then
+func (p *filePool) submit(scan func() fileResult) bool {
+	if p.stopped.Load() {
+		return false
+	}
+	result := make(chan fileResult, 1)
+	p.pending <- result
+	p.tasks <- fileTask{scan: scan, result: result}
+	return true
+}
+
+// wait Wait for the files submitted to be scanned and their results handled
+// @returns
+// bool - false if the handling was stopped
+func (p *filePool) wait() bool {
+	close(p.tasks)
+	close(p.pending)
+	p.workers.Wait()
+	<-p.handled
+	return !p.stopped.Load()
+}
+
+// walkDirSecrets Walk a directory and scan its files with --workers-per-scan workers, for ScanSecretsInDir and
+// ScanSecretsInDirStream. The secrets are handled in the order of the walk, and at most --max-secrets of them. Only
+// the files of --changed-files-from are visited in the --local directory when it is set
+// @parameters
+// layer - layer ID, if we are scanning directory inside container image
+// baseDir - Parent directory
+// fullDir - Complete path of the directory to be scanned
+// handle - Called with the secrets of each file, in order. The walk stops once it returns false
+// cancel - Called with the error once the scan is stopped, after the secrets of the files scanned before are handled
+// @returns
+// Error - maxSecretsExceeded once more than --max-secrets are found or handle returns false, errors of the walk
+// otherwise
+func walkDirSecrets(layer string, baseDir string, fullDir string, scanCtx *tasks.ScanContext,
+	handle func(secrets []output.SecretFound) bool, cancel func(err error)) error {
+	session := core.GetSession()
+	maxFileSize := *session.Options.MaximumFileSize * 1024
+	maxSecrets := *session.Options.MaxSecrets
+	excludePaths := session.Options.ExcludePath.Values()
+	var ignores *ignoreMatcher
+	if *session.Options.RespectGitignore {
+		ignores = newIgnoreMatcher(fullDir)
+	}
+	ctx := scanContext(scanCtx)
+	changed := changedFilesIn(fullDir, layer)
+	if changed != nil {
+		Progress.AddFiles(changed.paths)
+	} else {
+		Progress.AddDir(fullDir, layer)
+	}
+
+	// Secrets handled so far. The files are scanned from one less than this count, so they never stop before the
+	// limit and look for one more secret, and the secrets of the file reaching it are trimmed to it. The walk only
+	// stops once a secret is dropped, not when exactly --max-secrets are found
+	var numSecrets atomic.Uint64
+	pool := newFilePool(ctx, *session.Options.WorkersPerScan, func(result fileResult) bool {
+		found := uint(numSecrets.Load())
+		left := uint(0)
+		if found < maxSecrets {
+			left = maxSecrets - found
+		}
+		dropped := uint(len(result.secrets)) > left
+		if dropped {
+			result.secrets = result.secrets[:left]
+		}
+		numSecrets.Store(uint64(found + result.counted))
+		return handle(result.secrets) && !dropped
+	})
+	scan := func(scanFiles func(numSecrets *uint) []output.SecretFound) func() fileResult {
+		return func() fileResult {
+			start := uint(numSecrets.Load())
+			if start > 0 {
+				start = min(start, maxSecrets) - 1
+			}
+			n := start
+			secrets := scanFiles(&n)
+			return fileResult{secrets: secrets, counted: n - start}
+		}
+	}
+
+	var cancelErr error
+	visit := func(path string, f os.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("Error in filepath.Walk: %s", err)
+			return err
+		}
+
+		err = scanCtx.Checkpoint("walking in directories")
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			cancelErr = err
+			return err
+		}
+		if !f.IsDir() {
+			defer Progress.FileDone(f)
+		}
+
+		if isExcludedPath(fullDir, path, excludePaths) {
+			Coverage.AddSkipped(relativePath(baseDir, layer, path), layer, skipExcludedPath)
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignores.ignored(path, f.IsDir()) {
+			Coverage.AddSkipped(relativePath(baseDir, layer, path), layer, skipIgnoredPath)
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var scanFiles func(numSecrets *uint) []output.SecretFound
+		if *session.Options.ScanPackages && f.Type().IsRegular() && packageKind(path) != "" {
+			relPath := relativePath(baseDir, layer, path)
+			scanFiles = func(numSecrets *uint) []output.SecretFound {
+				secrets, pkgErr := scanPackage(ctx, path, relPath, layer, numSecrets)
+				if pkgErr != nil {
+					log.Errorf("scanSecretsInDir: package %s: %s", relPath, pkgErr)
+					Coverage.AddErrored(relPath, layer, pkgErr, len(secrets))
+				}
+				return secrets
+			}
+		} else if recursiveArchive(path, f, 0) {
+			relPath := relativePath(baseDir, layer, path)
+			scanFiles = func(numSecrets *uint) []output.SecretFound {
+				secrets, archiveErr := scanNestedArchive(ctx, path, relPath, layer, 1, newScanExtractLimits(), numSecrets)
+				if archiveErr != nil {
+					log.Errorf("scanSecretsInDir: archive %s: %s", relPath, archiveErr)
+					Coverage.AddErrored(relPath, layer, archiveErr, len(secrets))
+				}
+				return secrets
+			}
+		} else {
+			if reason := skipEntry(path, f, layer, baseDir, maxFileSize); reason != "" {
+				Coverage.AddSkipped(relativePath(baseDir, layer, path), layer, reason)
+				if f.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if f.IsDir() {
+				return nil
+			}
+			scanFiles = func(numSecrets *uint) []output.SecretFound {
+				return scanDirFile(ctx, path, baseDir, layer, numSecrets)
+			}
+		}
+
+		// Don't report secrets if number of secrets exceeds MAX value
+		if !pool.submit(scan(scanFiles)) {
+			return maxSecretsExceeded
+		}
+		return nil
+	}
+	var walkErr error
+	if changed != nil {
+		walkErr = changed.walk(visit)
+	} else {
+		walkErr = filepath.WalkDir(fullDir, visit)
+	}
+
+	if !pool.wait() && walkErr == nil {
+		walkErr = maxSecretsExceeded
+	}
+	if cancelErr != nil {
+		cancel(cancelErr)
+	}
+	return walkErr
+}
+
+// scanDirFile Find secrets in a file of a walked directory
+// @parameters
+// path - Complete path of the file
+// numSecrets - Number of secrets found so far, updated with the secrets of the file
+// @returns
+// []output.SecretFound - Secrets found in the file
+func scanDirFile(ctx context.Context, path string, baseDir string, layer string,
+	numSecrets *uint) []output.SecretFound {
+	file := core.NewMatchFile(path)
+	relPath := relativePath(baseDir, layer, file.Path)
+
+	// Add RW permissions for reading and deleting contents of containers, not for regular file system
+	if layer != "" {
+		if err := os.Chmod(file.Path, 0600); err != nil {
+			log.Errorf("scanSecretsInDir changine file permission: %s", err)
+		}
+	}
+
+	log.Debugf("attempting scanFile on: %+v, relPath: %s", file, relPath)
+
+	secrets, scanErr := scanFile(ctx, file.Path, relPath, file.Filename, file.Extension, layer, numSecrets)
+	if scanErr != nil {
+		log.Infof("relPath: %s, Filename: %s, Extension: %s, layer: %s", relPath, file.Filename, file.Extension, layer)
+		log.Errorf("scanSecretsInDir: %s", scanErr)
+		secrets = nil
+	}
+	secrets = append(secrets, allowed(signature.MatchSimpleSignatures(ctx, relPath, file.Filename, file.Extension, layer,
+		numSecrets), numSecrets, nil)...)
+
+	Stats.AddFile()
+	if scanErr != nil {
+		Coverage.AddErrored(relPath, layer, scanErr, len(secrets))
+	} else {
+		Coverage.AddScanned(relPath, layer, len(secrets))
+	}
+
+	log.Debugf("scan completed for file: %+v, numSecrets: %d", file, *numSecrets)
+	return secrets
+}
@@ -0,0 +1,119 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Dir of files with one secret each, spread over subdirs
+func writeTestTree(t testing.TB, files int) string {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		if err := os.Mkdir(filepath.Join(dir, fmt.Sprintf("app%d", i)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < files; i++ {
+		writeTestFile(t, filepath.Join(dir, fmt.Sprintf("app%d", i%10)), fmt.Sprintf("config%d.txt", i),
+			[]byte(fmt.Sprintf("api_key = 'J8fK2mQ9xL4vR7tB1nZ6cW3y%06dsGa'\n", i)))
+	}
+	return dir
+}
+
+// Scan a dir with the given --workers-per-scan and --max-secrets
+func scanTestTree(t testing.TB, dir string, workers int, maxSecrets uint) []string {
+	options := testSession(t).Options
+	defer func(workers int, maxSecrets uint) {
+		*options.WorkersPerScan, *options.MaxSecrets = workers, maxSecrets
+	}(*options.WorkersPerScan, *options.MaxSecrets)
+	*options.WorkersPerScan, *options.MaxSecrets = workers, maxSecrets
+
+	isFirstSecret := true
+	secrets, err := ScanSecretsInDir("", dir, dir, &isFirstSecret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, secret := range secrets {
+		found = append(found, fmt.Sprintf("%s:%d", secret.CompleteFilename, secret.RuleID))
+	}
+	return found
+}
+
+func Test_ScanSecretsInDirWorkers(t *testing.T) {
+	dir := writeTestTree(t, 60)
+	sequential := scanTestTree(t, dir, 1, 1000)
+	if len(sequential) < 60 {
+		t.Fatalf("%d secrets found in 60 files", len(sequential))
+	}
+	// Secrets are reported in the order of the walk whatever the number of workers
+	if concurrent := scanTestTree(t, dir, 4, 1000); !reflect.DeepEqual(concurrent, sequential) {
+		t.Errorf("secrets found with 4 workers %v, with 1 worker %v", concurrent, sequential)
+	}
+	// Files scanned concurrently don't report more than --max-secrets
+	if concurrent := scanTestTree(t, dir, 4, 7); !reflect.DeepEqual(concurrent, sequential[:7]) {
+		t.Errorf("secrets found with --max-secrets 7 %v, want %v", concurrent, sequential[:7])
+	}
+}
+
+func Test_FilePoolStops(t *testing.T) {
+	var scanned int32
+	var handled []int
+	pool := newFilePool(context.Background(), 3, func(result fileResult) bool {
+		handled = append(handled, result.secrets[0].LineNumber)
+		return len(handled) < 2
+	})
+	for i := 0; i < 20; i++ {
+		i := i
+		if !pool.submit(func() fileResult {
+			atomic.AddInt32(&scanned, 1)
+			return fileResult{secrets: []output.SecretFound{{LineNumber: i}}}
+		}) {
+			break
+		}
+	}
+	if pool.wait() {
+		t.Error("wait() = true once handle returned false")
+	}
+	if !reflect.DeepEqual(handled, []int{0, 1}) {
+		t.Errorf("expected to stop after the second file, handled %v", handled)
+	}
+	if scanned > 6 {
+		t.Errorf("%d files scanned after the pool was stopped", scanned)
+	}
+
+	// Files are not scanned once the scan is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scanned = 0
+	pool = newFilePool(ctx, 3, func(result fileResult) bool { return true })
+	for i := 0; i < 5; i++ {
+		pool.submit(func() fileResult {
+			atomic.AddInt32(&scanned, 1)
+			return fileResult{}
+		})
+	}
+	pool.wait()
+	if scanned != 0 {
+		t.Errorf("%d files scanned once the scan was cancelled", scanned)
+	}
+}
+
+// Walking a tree of thousands of small files with one worker against several
+func Benchmark_ScanSecretsInDir(b *testing.B) {
+	dir := writeTestTree(b, 5000)
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanTestTree(b, dir, workers, 100000)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package scan
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_IsExcludedPath(t *testing.T) {
+	root := filepath.Join("tmp", "layers", "abc")
+	tests := []struct {
+		patterns []string
+		path     string
+		excluded bool
+	}{
+		{[]string{"**/test/**"}, "test", true},
+		{[]string{"**/test/**"}, "app/test", true},
+		{[]string{"**/test/**"}, "app/test/fixtures/key.pem", true},
+		{[]string{"**/test/**"}, "app/tests/key.pem", false},
+		{[]string{"config/prod.env"}, "config/prod.env", true},
+		{[]string{"./config/prod.env"}, "config/prod.env", true},
+		{[]string{"config/prod.env"}, "config/staging.env", false},
+		{[]string{"config/prod.env"}, "app/config/prod.env", false},
+		{[]string{"vendor/**", "*.md"}, "README.md", true},
+		{nil, "app/test/key.pem", false},
+		{[]string{"**"}, "", false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(root, filepath.FromSlash(test.path))
+		if excluded := isExcludedPath(root, path, test.patterns); excluded != test.excluded {
+			t.Errorf("isExcludedPath(%v, %q) = %v, want %v", test.patterns, test.path, excluded, test.excluded)
+		}
+	}
+}
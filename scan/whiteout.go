@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"archive/tar"
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Prefixes of the OCI whiteout files marking paths deleted by a layer
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Paths of the lower layers hidden by the whiteouts of one layer
+type layerWhiteouts struct {
+	deleted map[string]bool // Files and dirs deleted by the layer
+	opaque  map[string]bool // Dirs whose contents in the lower layers are hidden by the layer
+}
+
+// Read the whiteouts of a layer from the headers of its tarball
+// @parameters
+// tarPath - Complete path of the layer tarball
+// @returns
+// *layerWhiteouts - Paths hidden by the layer
+// Error - Errors if any. Otherwise, returns nil
+func readWhiteouts(tarPath string) (*layerWhiteouts, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r, closeReader, err := decompressReader(tarPath, bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader()
+
+	whiteouts := &layerWhiteouts{deleted: map[string]bool{}, opaque: map[string]bool{}}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return whiteouts, nil
+		}
+		if err != nil {
+			return whiteouts, err
+		}
+		name := layerPath(hdr.Name)
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		switch {
+		case base == whiteoutOpaque:
+			whiteouts.opaque[dir] = true
+		case strings.HasPrefix(base, whiteoutPrefix):
+			whiteouts.deleted[path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))] = true
+		}
+	}
+}
+
+// Path relative to the root of the layer, without leading slash
+func layerPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Indicates if a path of a lower layer is hidden by the whiteouts of this layer
+func (w *layerWhiteouts) hides(name string) bool {
+	name = layerPath(name)
+	for p := name; ; p = path.Dir(p) {
+		if p == "." || p == "/" {
+			p = ""
+		}
+		if w.deleted[p] {
+			return true
+		}
+		if p != name && w.opaque[p] {
+			return true
+		}
+		if p == "" {
+			return false
+		}
+	}
+}
+
+// Remove the secrets of a layer in files deleted by the whiteouts of the layers above it
+// @parameters
+// secrets - Secrets found in the layer
+// above - Whiteouts of the layers above, nil for layers whose whiteouts could not be read
+// @returns
+// []output.SecretFound - Secrets in files of the flattened image
+func applyWhiteouts(secrets []output.SecretFound, above []*layerWhiteouts) []output.SecretFound {
+	kept := secrets[:0]
+	for _, secret := range secrets {
+		// Secrets found inside an archive are in the file of the archive
+		name, _, _ := strings.Cut(secret.CompleteFilename, archivePathSeparator)
+		hidden := false
+		for _, whiteouts := range above {
+			if whiteouts != nil && whiteouts.hides(name) {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			kept = append(kept, secret)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"archive/tar"
+	"reflect"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+func Test_ReadWhiteouts(t *testing.T) {
+	dir := t.TempDir()
+	layer := layerTar(t, dir,
+		tar.Header{Name: "./app/.wh..env", Typeflag: tar.TypeReg},
+		tar.Header{Name: "etc/.wh.ssh", Typeflag: tar.TypeReg},
+		tar.Header{Name: "opt/cache/.wh..wh..opq", Typeflag: tar.TypeReg},
+		tar.Header{Name: "app/config.yaml", Typeflag: tar.TypeReg, Linkname: "token: value"},
+	)
+
+	whiteouts, err := readWhiteouts(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(whiteouts.deleted, map[string]bool{"app/.env": true, "etc/ssh": true}) {
+		t.Errorf("unexpected deleted paths %v", whiteouts.deleted)
+	}
+	if !reflect.DeepEqual(whiteouts.opaque, map[string]bool{"opt/cache": true}) {
+		t.Errorf("unexpected opaque dirs %v", whiteouts.opaque)
+	}
+
+	for name, expected := range map[string]bool{
+		"app/.env":           true,
+		"/app/.env":          true,
+		"app/.env.example":   false,
+		"etc/ssh/id_rsa":     true,
+		"etc/sshd_config":    false,
+		"opt/cache/token":    true,
+		"opt/cache/a/b/c":    true,
+		"opt/cache":          false,
+		"opt/cache-2/token":  false,
+		"app/config.yaml":    false,
+		"app/bundle.tgz!/.x": false,
+	} {
+		if actual := whiteouts.hides(name); actual != expected {
+			t.Errorf("hides(%q) = %v, want %v", name, actual, expected)
+		}
+	}
+}
+
+func Test_ApplyWhiteouts(t *testing.T) {
+	above := []*layerWhiteouts{
+		nil,
+		{deleted: map[string]bool{"app/.env": true, "pkg/deps.tgz": true}, opaque: map[string]bool{}},
+	}
+	secrets := []output.SecretFound{
+		{CompleteFilename: "app/.env", LayerID: "lower"},
+		{CompleteFilename: "app/settings.py", LayerID: "lower"},
+		{CompleteFilename: "pkg/deps.tgz!/package/.npmrc", LayerID: "lower"},
+	}
+
+	kept := applyWhiteouts(secrets, above)
+	expected := []output.SecretFound{{CompleteFilename: "app/settings.py", LayerID: "lower"}}
+	if !reflect.DeepEqual(kept, expected) {
+		t.Errorf("unexpected secrets after whiteouts\nActual: %+v\nExpected: %+v", kept, expected)
+	}
+
+	if kept = applyWhiteouts([]output.SecretFound{{CompleteFilename: "app/.env"}}, nil); len(kept) != 1 {
+		t.Errorf("the top layer has no whiteouts above it, got %+v", kept)
+	}
+}
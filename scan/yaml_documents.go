@@ -0,0 +1,133 @@
+package scan
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlExtensions = map[string]bool{".yaml": true, ".yml": true}
+
+// One document of a multi-document YAML stream, e.g. a Kubernetes manifest
+type yamlDocument struct {
+	Index     int // 1-based position of the document in the stream
+	Start     int // Offset of the first byte of the document
+	End       int // Offset after the last byte of the document
+	Kind      string
+	Namespace string
+	Name      string
+	Contents  []byte
+}
+
+// Resource name of the document as kind/namespace/name, empty if not a Kubernetes resource
+func (d yamlDocument) resource() string {
+	if d.Kind == "" && d.Name == "" {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{d.Kind, d.Namespace, d.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Split a YAML stream into its documents on the --- and ... markers
+// @parameters
+// contents - Contents of the YAML file
+// @returns
+// []yamlDocument - Documents with their offsets in contents and resource metadata
+func splitYAMLDocuments(contents []byte) []yamlDocument {
+	var documents []yamlDocument
+	start, offset := 0, 0
+	hasContent := false
+
+	closeDocument := func(end int) {
+		if hasContent {
+			documents = append(documents, newYAMLDocument(len(documents)+1, contents, start, end))
+		}
+		hasContent = false
+	}
+
+	for offset < len(contents) {
+		lineEnd := bytes.IndexByte(contents[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(contents)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := bytes.TrimRight(contents[offset:lineEnd], "\r\n")
+
+		if isYAMLDocumentMarker(line) {
+			closeDocument(offset)
+			start = lineEnd
+		} else if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != '#' {
+			hasContent = true
+		}
+		offset = lineEnd
+	}
+	closeDocument(len(contents))
+
+	return documents
+}
+
+func isYAMLDocumentMarker(line []byte) bool {
+	for _, marker := range []string{"---", "..."} {
+		if bytes.HasPrefix(line, []byte(marker)) {
+			rest := line[len(marker):]
+			if len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newYAMLDocument(index int, contents []byte, start, end int) yamlDocument {
+	document := yamlDocument{Index: index, Start: start, End: end, Contents: contents[start:end]}
+
+	var resource struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	// Documents that are not valid YAML are still located, just without a resource name
+	if err := yaml.Unmarshal(document.Contents, &resource); err == nil {
+		document.Kind = resource.Kind
+		document.Name = resource.Metadata.Name
+		document.Namespace = resource.Metadata.Namespace
+	}
+	return document
+}
+
+// Set the document index and resource name of the secrets found in the contents of a YAML file
+// @parameters
+// contents - Contents of the file the secrets were found in
+// extension - Extension of the file
+// secrets - Secrets found in the file, updated in place
+func annotateYAMLDocuments(contents []byte, extension string, secrets []output.SecretFound) {
+	if !yamlExtensions[strings.ToLower(extension)] || len(secrets) == 0 {
+		return
+	}
+
+	documents := splitYAMLDocuments(contents)
+	for i := range secrets {
+		if secrets[i].PartToMatch != signature.ContentsPart {
+			continue
+		}
+		offset := secrets[i].PrintBufferStartIndex + secrets[i].MatchFromByte
+		for _, document := range documents {
+			if document.Start <= offset && offset < document.End {
+				secrets[i].DocumentIndex = document.Index
+				secrets[i].ResourceName = document.resource()
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+const multiDocumentManifest = `# deployment and its credentials
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: prod
+--- # credentials
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-credentials
+  namespace: prod
+stringData:
+  password: hunter2
+`
+
+func Test_SplitYAMLDocuments(t *testing.T) {
+	documents := splitYAMLDocuments([]byte(multiDocumentManifest))
+
+	var resources []string
+	for _, document := range documents {
+		resources = append(resources, document.resource())
+	}
+	expected := []string{"ConfigMap/settings", "Deployment/prod/api", "Secret/prod/db-credentials"}
+	if !reflect.DeepEqual(resources, expected) {
+		t.Errorf("unexpected documents\nActual: %v\nExpected: %v", resources, expected)
+	}
+	if documents[2].Index != 3 {
+		t.Errorf("expected the Secret to be the third document, got %d", documents[2].Index)
+	}
+}
+
+func Test_SplitYAMLDocumentsLeadingMarker(t *testing.T) {
+	documents := splitYAMLDocuments([]byte("---\nkind: Secret\n...\n---\nkind: ConfigMap\n"))
+	if len(documents) != 2 || documents[0].Kind != "Secret" || documents[1].Index != 2 {
+		t.Errorf("unexpected documents: %+v", documents)
+	}
+}
+
+func Test_AnnotateYAMLDocuments(t *testing.T) {
+	contents := []byte(multiDocumentManifest)
+	from := len(multiDocumentManifest) - len("hunter2\n")
+	secrets := []output.SecretFound{
+		{PartToMatch: signature.ContentsPart, PrintBufferStartIndex: from - 4, MatchFromByte: 4},
+		{PartToMatch: signature.ExtPart},
+	}
+
+	annotateYAMLDocuments(contents, ".yaml", secrets)
+
+	if secrets[0].DocumentIndex != 3 || secrets[0].ResourceName != "Secret/prod/db-credentials" {
+		t.Errorf("secret not located in the Secret document: %+v", secrets[0])
+	}
+	if secrets[1].DocumentIndex != 0 {
+		t.Errorf("only content matches should be located: %+v", secrets[1])
+	}
+}
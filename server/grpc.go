@@ -9,15 +9,18 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/khulnasoft-lab/SecretScanner/core"
 	"github.com/khulnasoft-lab/SecretScanner/jobs"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
 	pb "github.com/khulnasoft-lab/agent-plugins-grpc/srcgo"
-
-	//nolint:typecheck
-	"github.com/khulnasoft-lab/golang_sdk/utils/tasks"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
+// Metadata key of the per scan rule overrides, see signature.ParseRuleOverrides
+const RuleOverridesMetadataKey = "rule-overrides"
+
 type gRPCServer struct {
 	socket_path string
 	plugin_name string
@@ -33,28 +36,13 @@ func (s *gRPCServer) ReportJobsStatus(context.Context, *pb.Empty) (*pb.JobReport
 }
 
 func (s *gRPCServer) StopScan(c context.Context, req *pb.StopScanRequest) (*pb.StopScanResult, error) {
-	log.Errorf("Received StopScanRequest: %v", *req)
-	scanID := req.ScanId
-	result := &pb.StopScanResult{
-		Success:     true,
-		Description: "",
-	}
-
-	obj, found := jobs.ScanMap.Load(scanID)
-	if !found {
-		log.Errorf("SecretScanner::Failed to Stop scan, may have already completed successfully or errored out, scan_id: %s", scanID)
-		result.Success = false
-		result.Description = "SecretScanner::Failed to Stop scan"
-		return result, nil
-	} else {
-		log.Errorf("SecretScanner::Stop request submitted")
-		result.Description = "SecretScanner::Stop request submitted"
+	log.Infof("Received StopScanRequest: %v", *req)
+	if err := jobs.StopScan(req.ScanId); err != nil {
+		log.Warnf("SecretScanner::Failed to stop scan: %s", err)
+		return &pb.StopScanResult{Success: false, Description: "SecretScanner::Failed to stop scan: " + err.Error()}, nil
 	}
-
-	scanCtx := obj.(*tasks.ScanContext)
-	scanCtx.StopTriggered.Store(true)
-	scanCtx.Cancel()
-	return result, nil
+	log.Infof("SecretScanner::Stop request submitted for scan %s", req.ScanId)
+	return &pb.StopScanResult{Success: true, Description: "SecretScanner::Stop request submitted"}, nil
 }
 
 func (s *gRPCServer) GetName(context.Context, *pb.Empty) (*pb.Name, error) {
@@ -66,10 +54,28 @@ func (s *gRPCServer) GetUID(context.Context, *pb.Empty) (*pb.Uid, error) {
 }
 
 func (s *gRPCServer) FindSecretInfo(c context.Context, r *pb.FindRequest) (*pb.FindResult, error) {
-	jobs.DispatchScan(r)
+	overrides, err := ruleOverridesFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+	jobs.DispatchScan(r, overrides)
 	return &pb.FindResult{}, nil
 }
 
+// Per scan rule overrides are sent as JSON in the request metadata, as the
+// FindRequest message has no field for them
+func ruleOverridesFromContext(c context.Context) (*signature.RuleOverrides, error) {
+	md, ok := metadata.FromIncomingContext(c)
+	if !ok {
+		return nil, nil
+	}
+	values := md.Get(RuleOverridesMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return nil, nil
+	}
+	return signature.ParseRuleOverrides([]byte(values[0]))
+}
+
 func RunServer(socket_path string, plugin_name string) error {
 
 	sigs := make(chan os.Signal, 1)
@@ -95,12 +101,15 @@ func RunServer(socket_path string, plugin_name string) error {
 	pb.RegisterAgentPluginServer(s, impl)
 	pb.RegisterSecretScannerServer(s, impl)
 	pb.RegisterScannersServer(s, impl)
+	registerScanStatusServer(s, impl)
 	log.Infof("main: server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		return err
 	}
 
 	<-done
+	// Scans still in progress stop with the process, their deferred removals don't run
+	core.DeleteTmpDirs()
 	log.Infof("main: exiting gracefully")
 	return nil
 }
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/khulnasoft-lab/SecretScanner/jobs"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The messages of the plugin protocol can't carry the state of a scan, so the ScanStatus service is described
+// here with well known types: the request is the scan ID as a google.protobuf.StringValue, the response a
+// google.protobuf.Struct with scan_id, scan_status, secrets_found and elapsed_seconds
+const (
+	scanStatusServiceName = "secret_scanner.ScanStatus"
+	getScanStatusMethod   = "GetScanStatus"
+)
+
+type scanStatusServer interface {
+	GetScanStatus(context.Context, *wrapperspb.StringValue) (*structpb.Struct, error)
+}
+
+var scanStatusServiceDesc = grpc.ServiceDesc{
+	ServiceName: scanStatusServiceName,
+	HandlerType: (*scanStatusServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: getScanStatusMethod, Handler: getScanStatusHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "scan_status",
+}
+
+func registerScanStatusServer(s grpc.ServiceRegistrar, srv scanStatusServer) {
+	s.RegisterService(&scanStatusServiceDesc, srv)
+}
+
+func getScanStatusHandler(srv any, ctx context.Context, dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(scanStatusServer).GetScanStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + scanStatusServiceName + "/" + getScanStatusMethod,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(scanStatusServer).GetScanStatus(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// GetScanStatus Report the status of a scan, the secrets it found so far and the time elapsed since it started,
+// for the scans in progress and the last finished ones. Fails with NOT_FOUND for unknown scan IDs
+func (s *gRPCServer) GetScanStatus(c context.Context, req *wrapperspb.StringValue) (*structpb.Struct, error) {
+	scanID := req.GetValue()
+	state, err := jobs.GetScanState(scanID)
+	if errors.Is(err, jobs.ErrUnknownScan) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		log.Errorf("SecretScanner::Failed to get status of scan %s: %s", scanID, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return structpb.NewStruct(map[string]any{
+		"scan_id":         scanID,
+		"scan_status":     state.Status,
+		"secrets_found":   state.SecretsFound,
+		"elapsed_seconds": state.Elapsed.Seconds(),
+	})
+}
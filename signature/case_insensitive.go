@@ -0,0 +1,39 @@
+package signature
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+// Inline flag groups of a regex, e.g. (?i) or (?s-i:...)
+var inlineFlagsRegex = regexp.MustCompile(`\(\?([a-zA-Z]*)(-([a-zA-Z]*))?[:)]`)
+
+// Compile the regex of a signature, case-insensitively if the signature asks for it
+// @parameters
+// signature - Signature with a regex
+// @returns
+// *regexp.Regexp - Compiled regex, used to find the exact position of large matches
+// Error - Errors if any. Otherwise, returns nil
+func compileSignatureRegex(signature core.ConfigSignature) (*regexp.Regexp, error) {
+	if !signature.CaseInsensitive {
+		return regexp.Compile(signature.Regex)
+	}
+	if err := validateCaseInsensitive(signature.Regex); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("(?i)" + signature.Regex)
+}
+
+// Check that a regex compiled case-insensitively does not turn it off with inline flags
+func validateCaseInsensitive(regex string) error {
+	for _, flags := range inlineFlagsRegex.FindAllStringSubmatch(regex, -1) {
+		for _, flag := range flags[3] {
+			if flag == 'i' {
+				return fmt.Errorf("case_insensitive conflicts with inline flags %s in %s", flags[0], regex)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"path/filepath"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rule reported for high entropy strings, which match no signature of the config
+const (
+	GenericHighEntropyRuleID   = -1
+	GenericHighEntropyRuleName = "GenericHighEntropy"
+)
+
+// Key of the entropy detector in the rules matched for a file
+const entropyRuleKey = ^uint(0)
+
+// Hex strings can't exceed 4 bits per char, base64 ones 6 bits. Random hex strings of 32 chars are mostly above
+// 3/4 of the base64 threshold, while hex strings with repeated chars are not
+const hexThresholdRatio = 3.0 / 4.0
+
+// Lockfiles of package managers, full of checksums and digests of the packages rather than secrets
+var lockfiles = map[string]bool{"go.sum": true, "go.work.sum": true, "package-lock.json": true,
+	"npm-shrinkwrap.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "Cargo.lock": true, "Gemfile.lock": true,
+	"poetry.lock": true, "Pipfile.lock": true, "composer.lock": true, "packages.lock.json": true,
+	"gradle.lockfile": true, "mix.lock": true, "pubspec.lock": true, "Podfile.lock": true, "flake.lock": true}
+
+// Prefixes of the digests of images and modules, e.g. sha256:<hex> or h1:<base64>, before the digest
+var digestPrefixes = [][]byte{[]byte("sha1:"), []byte("sha256:"), []byte("sha384:"), []byte("sha512:"),
+	[]byte("md5:"), []byte("h1:")}
+
+// Prefixes of the subresource integrity digests, e.g. sha512-<base64>, part of the digest as - is a base64 char
+var integrityPrefixes = [][]byte{[]byte("sha1-"), []byte("sha256-"), []byte("sha384-"), []byte("sha512-")}
+
+// Checks if a string is the digest of some data rather than a secret, by its prefix
+func isDigest(data []byte, start, end int) bool {
+	for _, prefix := range digestPrefixes {
+		if bytes.HasSuffix(data[:start], prefix) {
+			return true
+		}
+	}
+	for _, prefix := range integrityPrefixes {
+		if bytes.HasPrefix(data[start:end], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Chars of base64, including its URL variant
+func isBase64Char(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '+' || c == '/' || c == '-' || c == '_' || c == '='
+}
+
+func isHexChar(c byte) bool {
+	return 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' || '0' <= c && c <= '9'
+}
+
+// Shannon entropy of a string in bits per char
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range data {
+		counts[c]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(data))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// Find the base64 and hex strings of the data whose entropy is above the threshold
+// @parameters
+// data - Data to search
+// threshold - Minimum entropy of base64 strings in bits per char, hex strings use threshold*3/4
+// minLength - Minimum length of the strings
+// @returns
+// [][2]int - Start and end indexes of the strings found
+func findHighEntropyStrings(data []byte, threshold float64, minLength int) [][2]int {
+	var found [][2]int
+	for i := 0; i < len(data); {
+		if !isBase64Char(data[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(data) && isBase64Char(data[i]) {
+			i++
+		}
+		end := i
+		// Padding is not part of the randomness
+		for end > start && data[end-1] == '=' {
+			end--
+		}
+		token := data[start:end]
+		if len(token) < minLength || isDigest(data, start, end) {
+			continue
+		}
+
+		isHex := true
+		hasDigit := false
+		for _, c := range token {
+			isHex = isHex && isHexChar(c)
+			hasDigit = hasDigit || '0' <= c && c <= '9'
+		}
+		// Random tokens of this length almost always have a digit, identifiers and words don't
+		if !hasDigit {
+			continue
+		}
+		tokenThreshold := threshold
+		if isHex {
+			tokenThreshold = threshold * hexThresholdRatio
+		}
+		if shannonEntropy(token) >= tokenThreshold {
+			found = append(found, [2]int{start, end})
+		}
+	}
+	return found
+}
+
+// MatchHighEntropyStrings Find the base64 and hex strings of the contents of a file with an entropy above
+// --entropy-threshold, which match no signature. The digests are left out, and the lockfiles of package managers
+// are not searched
+// @parameters
+// ctx - Context of the scan, with its rule overrides
+// contents - content of the file
+// path - Complete path of the file
+// layerID - layer ID of this file in the container image
+// found - Secrets already found in the file, strings overlapping them are not reported again
+// @returns
+// []output.SecretFound - List of all secrets found
+func MatchHighEntropyStrings(ctx context.Context, contents []byte, path string, layerID string,
+	found []output.SecretFound, numSecrets *uint, matchedRuleSet map[uint]uint) []output.SecretFound {
+	var tempSecretsFound []output.SecretFound
+	if !RuleSelectedFor(ctx, GenericHighEntropyRuleID) || lockfiles[filepath.Base(path)] {
+		return nil
+	}
+	options := core.GetSession().Options
+
+	for _, loc := range findHighEntropyStrings(contents, *options.EntropyThreshold, int(*options.EntropyMinLength)) {
+		from, to := loc[0], loc[1]
+		if *numSecrets >= *options.MaxSecrets {
+			log.Debugf("MAX secrets exceeded: %d", *numSecrets)
+			break
+		}
+		if overlapsSecrets(from, to, found) {
+			continue
+		}
+		if core.ContainsBlacklistedString(bytes.ToLower(contents[from:to])) {
+			continue
+		}
+
+		// Suppressed strings don't count towards the limits of the matches of a signature
+		suppressed := isSuppressed(contents, from)
+		if suppressed && !*options.ShowSuppressed {
+			continue
+		}
+		if !suppressed {
+			if _, exists := matchedRuleSet[entropyRuleKey]; !exists {
+				matchedRuleSet[entropyRuleKey] = 1
+			} else if !*options.MultipleMatch {
+				continue
+			} else if matchedRuleSet[entropyRuleKey]++; matchedRuleSet[entropyRuleKey] > *options.MaxMultiMatch {
+				continue
+			}
+		}
+
+		start, end, err := displayWindow(contents, from, to)
+		if err != nil {
+			log.Errorf("MatchHighEntropyStrings: %s", err)
+			continue
+		}
+		severity, score := calculateSeverity(contents[from:to], "medium", 5.0)
+		secret := output.SecretFound{
+			LayerID:     layerID,
+			RuleID:      GenericHighEntropyRuleID,
+			RuleName:    GenericHighEntropyRuleName,
+			PartToMatch: ContentsPart,
+			Severity:    severity, SeverityScore: score,
+			CompleteFilename:      path,
+			PrintBufferStartIndex: start, MatchFromByte: from - start, MatchToByte: to - start,
+			MatchedContents: string(contents[start:end]),
+		}
+		if suppressed {
+			suppress(&secret)
+		}
+		tempSecretsFound = append(tempSecretsFound, secret)
+		*numSecrets = *numSecrets + 1
+	}
+	return tempSecretsFound
+}
+
+// Indicates if a range of the contents overlaps a secret found in the contents
+func overlapsSecrets(from, to int, secrets []output.SecretFound) bool {
+	for _, secret := range secrets {
+		if secret.PartToMatch != ContentsPart {
+			continue
+		}
+		start := secret.PrintBufferStartIndex + secret.MatchFromByte
+		end := secret.PrintBufferStartIndex + secret.MatchToByte
+		if from < end && start < to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package signature
+
+import (
+	"testing"
+)
+
+func Test_ShannonEntropy(t *testing.T) {
+	for input, expected := range map[string]float64{
+		"":         0,
+		"aaaa":     0,
+		"abab":     1,
+		"0123abcd": 3,
+	} {
+		if actual := shannonEntropy([]byte(input)); actual != expected {
+			t.Errorf("shannonEntropy(%q) = %f, want %f", input, actual, expected)
+		}
+	}
+}
+
+func Test_FindHighEntropyHexKey(t *testing.T) {
+	key := "9f3b7c1e5a0d8246bf19c3e7a5d02f6b8e4c1a97"
+	contents := []byte("aws:\n  api_key: " + key + "\n")
+
+	found := findHighEntropyStrings(contents, 4.5, 20)
+	if len(found) != 1 {
+		t.Fatalf("expected the 40 char hex key to be found, got %v", found)
+	}
+	if actual := string(contents[found[0][0]:found[0][1]]); actual != key {
+		t.Errorf("unexpected string found %q", actual)
+	}
+}
+
+func Test_FindHighEntropyBase64(t *testing.T) {
+	token := "dGhpcyBpcyBub3QgYSByZWFsIHRva2Vu7Xq2Lp9Zw=="
+	found := findHighEntropyStrings([]byte(`token = "`+token+`"`), 4.5, 20)
+	if len(found) != 1 || found[0][1]-found[0][0] != len(token)-2 {
+		t.Errorf("expected the base64 token without its padding, got %v", found)
+	}
+}
+
+func Test_IgnoreEnglishText(t *testing.T) {
+	text := []byte(`SecretScanner finds unprotected secrets in container images and file systems.
+It matches the contents of every file against a set of signatures, and reports where they are found.
+Internationalization and characteristically_long_identifiers_are_fine as well as 2024-01-15.
+/usr/local/share/documentation/README and https://example.com/a/path/to/somewhere`)
+
+	if found := findHighEntropyStrings(text, 4.5, 20); len(found) != 0 {
+		for _, loc := range found {
+			t.Errorf("unexpected high entropy string %q", text[loc[0]:loc[1]])
+		}
+	}
+}
+
+func Test_IgnoreDigests(t *testing.T) {
+	text := []byte(`golang.org/x/text v0.14.0 h1:ScX5w1eTa3QqT8oi6+ziP7dTV1S2+ALU0bI+0zXKWiQ=
+"integrity": "sha512-ScX5w1eTa3QqT8oi6+ziP7dTV1S2+ALU0bI+0zXKWiQ9f3b7c1e5a0d8246bf19c3e7a5d02f6b8e4c1a97=="
+image: alpine@sha256:9f3b7c1e5a0d8246bf19c3e7a5d02f6b8e4c1a979f3b7c1e5a0d8246bf19c3e7
+checksum: 0123456789012345678901234567890123456789`)
+
+	if found := findHighEntropyStrings(text, 4.5, 20); len(found) != 0 {
+		for _, loc := range found {
+			t.Errorf("unexpected high entropy string %q", text[loc[0]:loc[1]])
+		}
+	}
+}
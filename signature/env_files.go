@@ -0,0 +1,198 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rule reported for the values of the variables of environment files, with --scan-env-files
+const (
+	EnvFileRuleID   = -3
+	EnvFileRuleName = "EnvFileSecret"
+)
+
+// Names of the variables holding secrets, e.g. DB_PASSWORD, GITHUB_TOKEN or AWS_SECRET_ACCESS_KEY
+var secretVariableName = regexp.MustCompile(`(?i)(TOKEN|KEY|PASSWORD|PASSWD|SECRET)S?$`)
+
+// Variable names of environment files, optionally exported
+var envVariableName = regexp.MustCompile(`^(?:export[ \t]+)?([A-Za-z_][A-Za-z0-9_.-]*)[ \t]*=[ \t]*`)
+
+// Values referencing another variable, e.g. ${DB_PASSWORD}, which hold no secret themselves
+var variableReference = regexp.MustCompile(`^\$(\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)$`)
+
+// Values of settings rather than secrets, e.g. TOKEN_REFRESH=true
+var settingValues = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "none": true}
+
+// Variable of an environment file
+type envVariable struct {
+	name string
+	from int // Offsets of the value in the file, without its quotes
+	to   int
+}
+
+// IsEnvFile Checks if a file is an environment file, by its name: .env, .env.production, prod.env...
+// @parameters
+// fileName - Name of the file
+// @returns
+// bool - true if the file is an environment file
+func IsEnvFile(fileName string) bool {
+	name := strings.ToLower(fileName)
+	return name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")
+}
+
+// Variables of an environment file, KEY=VALUE lines. Values may be quoted, with ', " or `, and quoted values may span
+// several lines. Unquoted values end at a comment, a # after a space
+func envVariables(contents []byte) []envVariable {
+	var variables []envVariable
+	for offset := 0; offset < len(contents); {
+		lineEnd := len(contents)
+		if i := bytes.IndexByte(contents[offset:], '\n'); i >= 0 {
+			lineEnd = offset + i
+		}
+		line := contents[offset:lineEnd]
+		indent := len(line) - len(bytes.TrimLeft(line, " \t"))
+		loc := envVariableName.FindSubmatchIndex(line[indent:])
+		if loc == nil {
+			offset = lineEnd + 1
+			continue
+		}
+		variable := envVariable{name: string(line[indent+loc[2] : indent+loc[3]])}
+		from := offset + indent + loc[1]
+
+		quote := byte(0)
+		if from < len(contents) && bytes.IndexByte([]byte("'\"`"), contents[from]) >= 0 {
+			quote = contents[from]
+		}
+		if quote == 0 {
+			value := contents[from:lineEnd]
+			if i := bytes.Index(value, []byte(" #")); i >= 0 {
+				value = value[:i]
+			}
+			if i := bytes.Index(value, []byte("\t#")); i >= 0 {
+				value = value[:i]
+			}
+			variable.from, variable.to = from, from+len(bytes.TrimRight(value, " \t\r"))
+			variables = append(variables, variable)
+			offset = lineEnd + 1
+			continue
+		}
+
+		end := -1
+		for i := from + 1; i < len(contents); i++ {
+			if quote == '"' && contents[i] == '\\' {
+				i++
+			} else if contents[i] == quote {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			// Unterminated quotes, the rest of the file is not parsed
+			break
+		}
+		variable.from, variable.to = from+1, end
+		variables = append(variables, variable)
+		offset = end + 1
+		if i := bytes.IndexByte(contents[offset:], '\n'); i >= 0 {
+			offset += i + 1
+		} else {
+			offset = len(contents)
+		}
+	}
+	return variables
+}
+
+// Checks if the value of a variable is reported: the variable has the name of a secret, or its value has a high entropy
+func isSecretVariable(variable envVariable, value []byte, threshold float64, minLength int) bool {
+	if len(value) == 0 || variableReference.Match(value) {
+		return false
+	}
+	if secretVariableName.MatchString(variable.name) && !settingValues[strings.ToLower(string(value))] {
+		return true
+	}
+	return len(findHighEntropyStrings(value, threshold, minLength)) > 0
+}
+
+// MatchEnvFile Report the values of the variables of an environment file which hold secrets, the variables named like
+// secrets, e.g. DB_PASSWORD or API_KEY, and those with a high entropy value. The variable name is the Key Path of the
+// secrets. Values already found by other rules are not reported again, the secrets found get the variable name instead,
+// even with the rule of the environment files not selected
+// @parameters
+// ctx - Context of the scan, with its rule overrides
+// contents - Contents of the environment file
+// path - Complete path of the file
+// layerID - layer ID of this file in the container image
+// found - Secrets already found in the file, updated in place with the name of their variable
+// numSecrets - Number of secrets found so far, updated with the secrets found
+// @returns
+// []output.SecretFound - Secrets found in the values of the variables
+func MatchEnvFile(ctx context.Context, contents []byte, path string, layerID string, found []output.SecretFound,
+	numSecrets *uint) []output.SecretFound {
+	options := core.GetSession().Options
+	var secretsFound []output.SecretFound
+
+	for _, variable := range envVariables(contents) {
+		from, to := variable.from, variable.to
+		overlapping := false
+		for i := range found {
+			if found[i].PartToMatch != ContentsPart {
+				continue
+			}
+			start := found[i].PrintBufferStartIndex + found[i].MatchFromByte
+			end := found[i].PrintBufferStartIndex + found[i].MatchToByte
+			if from < end && start < to {
+				overlapping = true
+				if found[i].KeyPath == "" {
+					found[i].KeyPath = variable.name
+				}
+			}
+		}
+		value := contents[from:to]
+		if overlapping || !RuleSelectedFor(ctx, EnvFileRuleID) ||
+			!isSecretVariable(variable, value, *options.EntropyThreshold, int(*options.EntropyMinLength)) {
+			continue
+		}
+		if *numSecrets >= *options.MaxSecrets {
+			log.Debugf("MAX secrets exceeded: %d", *numSecrets)
+			break
+		}
+		if core.ContainsBlacklistedString(bytes.ToLower(value)) {
+			continue
+		}
+		suppressed := isSuppressed(contents, from)
+		if suppressed && !*options.ShowSuppressed {
+			continue
+		}
+
+		start, end, err := displayWindow(contents, from, to)
+		if err != nil {
+			log.Errorf("MatchEnvFile: %s", err)
+			continue
+		}
+		severity, score := calculateSeverity(value, "medium", 5.0)
+		secret := output.SecretFound{
+			LayerID:     layerID,
+			RuleID:      EnvFileRuleID,
+			RuleName:    EnvFileRuleName,
+			PartToMatch: ContentsPart,
+			Severity:    severity, SeverityScore: score,
+			CompleteFilename:      path,
+			PrintBufferStartIndex: start, MatchFromByte: from - start, MatchToByte: to - start,
+			MatchedContents: string(contents[start:end]),
+			KeyPath:         variable.name,
+		}
+		if suppressed {
+			suppress(&secret)
+		}
+		secretsFound = append(secretsFound, secret)
+		*numSecrets = *numSecrets + 1
+	}
+	return secretsFound
+}
@@ -0,0 +1,61 @@
+package signature
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_IsEnvFile(t *testing.T) {
+	for name, expected := range map[string]bool{
+		".env": true, ".env.production": true, "prod.env": true, ".ENV": true,
+		"env": false, ".envrc": false, "environment.yaml": false,
+	} {
+		if IsEnvFile(name) != expected {
+			t.Errorf("IsEnvFile(%q) = %v, want %v", name, !expected, expected)
+		}
+	}
+}
+
+func Test_EnvVariables(t *testing.T) {
+	contents := "# comment=1\n" +
+		"DB_PASSWORD=hunter2\n" +
+		"  export API_KEY = 'abc#def' # key\n" +
+		"LOG_LEVEL=debug # verbose\r\n" +
+		"EMPTY=\n" +
+		"CERT=\"line 1\nline \\\"2\\\"\"\n" +
+		"not a variable\n" +
+		"LAST=`value`"
+	var values []string
+	for _, variable := range envVariables([]byte(contents)) {
+		values = append(values, variable.name+"="+contents[variable.from:variable.to])
+	}
+	expected := []string{"DB_PASSWORD=hunter2", "API_KEY=abc#def", "LOG_LEVEL=debug", "EMPTY=",
+		"CERT=line 1\nline \\\"2\\\"", "LAST=value"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("variables %q, want %q", values, expected)
+	}
+}
+
+func Test_IsSecretVariable(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{"DB_PASSWORD", "hunter2", true},
+		{"GITHUB_TOKEN", "abc", true},
+		{"aws_secret_access_key", "abc", true},
+		{"LOG_LEVEL", "debug", false},
+		{"TOKEN_TTL", "3600", false},
+		{"SECRET_ROTATION", "true", false},
+		{"API_KEYS", "${VAULT_API_KEYS}", false},
+		{"API_KEY", "", false},
+		{"ENABLE_API_KEY", "false", false},
+		{"BUILD_ID", "9f3b7c1e5a0d8246bf19c3e7a5d02f6b8e4c1a97", true},
+	} {
+		variable := envVariable{name: test.name}
+		if actual := isSecretVariable(variable, []byte(test.value), 4.5, 20); actual != test.expected {
+			t.Errorf("%s=%s reported %v, want %v", test.name, test.value, actual, test.expected)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package signature
 
 import (
 	"fmt"
-	"os"
+	"time"
 
 	"github.com/flier/gohs/hyperscan"
 	"github.com/khulnasoft-lab/SecretScanner/core"
@@ -11,16 +11,66 @@ import (
 
 // Build hyperscan Databases for matching different parts in the beginning
 // This can be used for repeated scanning
-func BuildHsDb() {
+// @returns
+// Error - Errors if a pattern could not be compiled
+func BuildHsDb() error {
+	start := time.Now()
+	set := currentSignatures.Load()
+	if err := set.buildHsDb(); err != nil {
+		return fmt.Errorf("unable to compile pattern: %w", err)
+	}
+	log.Infof("Compiled %d signatures in %s", len(set.config), time.Since(start))
+	return nil
+}
+
+// Build the hyperscan databases of a set of signatures
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func (s *signatureSet) buildHsDb() error {
 	for _, part := range []string{ContentsPart, FilenamePart, PathPart, ExtPart} {
 		log.Debugf("Creating hyperscan database for %s", part)
-		hspatterns, err := CreateHsPatterns(part)
+		hspatterns := s.hsPatterns(part)
+		if part == ContentsPart && s.contentsPrefilter != nil {
+			var prefiltered []*hyperscan.Pattern
+			hspatterns, prefiltered = s.splitPrefiltered(hspatterns)
+			if len(prefiltered) > 0 {
+				db, err := hyperscan.NewBlockDatabase(prefiltered...)
+				if err != nil {
+					return err
+				}
+				s.prefilteredContentsDb = db
+			}
+		}
+		if len(hspatterns) == 0 {
+			log.Debugf("No patterns to match %s", part)
+			continue
+		}
+		db, err := hyperscan.NewBlockDatabase(hspatterns...)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: Unable to create patterns \"%s\": %s\n", part, err.Error())
-			os.Exit(1)
+			return err
+		}
+		s.hyperscanBlockDbMap[part] = db
+	}
+	return nil
+}
+
+// Split the patterns of the contents between the ones always matched and the ones matched
+// only when one of their keywords is in the contents
+// @parameters
+// hsPatterns - List of hyperscan patterns of the contents
+// @returns
+// []*hyperscan.Pattern - Patterns without keywords
+// []*hyperscan.Pattern - Patterns with keywords
+func (s *signatureSet) splitPrefiltered(hsPatterns []*hyperscan.Pattern) ([]*hyperscan.Pattern, []*hyperscan.Pattern) {
+	var always, prefiltered []*hyperscan.Pattern
+	for _, hsPattern := range hsPatterns {
+		if s.contentsPrefilter.Filtered(hsPattern.Id) {
+			prefiltered = append(prefiltered, hsPattern)
+		} else {
+			always = append(always, hsPattern)
 		}
-		hyperscanBlockDbMap[part] = CreateHsDb(hspatterns)
 	}
+	return always, prefiltered
 }
 
 // Create a list of hyperscan patterns with appropriate flags
@@ -30,27 +80,44 @@ func BuildHsDb() {
 // []*hyperscan.Pattern - List of hyperscan patterns
 // error - Errors if any. Otherwise, returns nil
 func CreateHsPatterns(part string) ([]*hyperscan.Pattern, error) {
+	return currentSignatures.Load().hsPatterns(part), nil
+}
+
+func (s *signatureSet) hsPatterns(part string) []*hyperscan.Pattern {
 	var hsPatterns []*hyperscan.Pattern
 
-	log.Debugf("Number of Complex Patterns for matching %s: %d", part, len(patternSignatureMap[part]))
-	for _, signature := range patternSignatureMap[part] {
+	log.Debugf("Number of Complex Patterns for matching %s: %d", part, len(s.patternSignatureMap[part]))
+	for _, signature := range s.patternSignatureMap[part] {
 		log.Debugf("Pattern Signature %s %s %s %s %s %s %d", signature.Name, signature.Part, signature.Match, signature.Regex, signature.RegexType, signature.Severity, signature.ID)
 
-		// Disable SomLeftMost option for large regex to avoid HS compilation failures.
-		// Postprocess later to find patterns
-		hsPattern := hyperscan.NewPattern(signature.Regex, hyperscan.DotAll|hyperscan.SomLeftMost) // hyperscan.SingleMatch
-		if signature.RegexType == LargeRegexType {
-			hsPattern = hyperscan.NewPattern(signature.Regex, hyperscan.DotAll)
-			if *core.GetSession().Options.MultipleMatch == false {
-				hsPattern = hyperscan.NewPattern(signature.Regex, hyperscan.DotAll|hyperscan.SingleMatch)
-			} else {
-				hsPattern = hyperscan.NewPattern(signature.Regex, hyperscan.DotAll)
-			}
-		}
+		hsPattern := hyperscan.NewPattern(signature.Regex, hsFlags(signature, *core.GetSession().Options.MultipleMatch))
 		hsPattern.Id = signature.ID
 		hsPatterns = append(hsPatterns, hsPattern)
 	}
-	return hsPatterns, nil
+	return hsPatterns
+}
+
+// Hyperscan flags to compile the pattern of a signature with
+// @parameters
+// signature - Signature to be compiled
+// multipleMatch - Indicates if large patterns without max_matches can match several times
+// @returns
+// hyperscan.CompileFlag - Compile flags of the pattern
+func hsFlags(signature core.ConfigSignature, multipleMatch bool) hyperscan.CompileFlag {
+	// Disable SomLeftMost option for large regex to avoid HS compilation failures.
+	// Postprocess later to find patterns
+	flags := hyperscan.DotAll | hyperscan.SomLeftMost // hyperscan.SingleMatch
+	if signature.RegexType == LargeRegexType {
+		flags = hyperscan.DotAll
+		// Unless the rule reports several matches per file
+		if ruleMatchLimit(signature, multipleMatch, 2) == 1 {
+			flags |= hyperscan.SingleMatch
+		}
+	}
+	if signature.CaseInsensitive {
+		flags |= hyperscan.Caseless
+	}
+	return flags
 }
 
 // Create Hyperscan databased, which can be used for repeated scanning
@@ -58,13 +125,13 @@ func CreateHsPatterns(part string) ([]*hyperscan.Pattern, error) {
 // hsPatterns -  List of hyperscan patterns
 // @returns
 // BlockDatabase - Hyperscan database for the given list of patterns
-func CreateHsDb(hsPatterns []*hyperscan.Pattern) hyperscan.BlockDatabase {
+// Error - Errors if a pattern could not be compiled
+func CreateHsDb(hsPatterns []*hyperscan.Pattern) (hyperscan.BlockDatabase, error) {
 	hyperscanBlockDb, err := hyperscan.NewBlockDatabase(hsPatterns...)
 	if err != nil {
-		log.Error("ERROR: Unable to compile pattern ", err.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("unable to compile pattern: %w", err)
 	}
-	return hyperscanBlockDb
+	return hyperscanBlockDb, nil
 }
 
 // Run hyperscan matching on the specified content
@@ -0,0 +1,117 @@
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Default severity scores, same as assigned to signatures without severity
+var severityScores = map[string]float64{
+	output.LOW:    2.5,
+	output.MEDIUM: 5.0,
+	output.HIGH:   7.5,
+}
+
+// RuleOverrides Changes which rules are reported and with which severity for a
+// single scan. The rules are selected by the matchers of the scan, see WithRuleOverrides,
+// and the severities applied to the secrets found, so the compiled signatures shared by
+// all the scans are never modified
+type RuleOverrides struct {
+	// Only report these rule IDs, all rules if empty
+	Enable []int `json:"enable,omitempty"`
+	// Never report these rule IDs
+	Disable []int `json:"disable,omitempty"`
+	// Report these rule IDs with the given severity
+	Severity map[int]string `json:"severity,omitempty"`
+
+	enabled  map[int]bool
+	disabled map[int]bool
+}
+
+// ParseRuleOverrides Parse rule overrides from JSON, e.g.
+// {"enable": [1, 2], "disable": [3], "severity": {"1": "high"}}
+func ParseRuleOverrides(data []byte) (*RuleOverrides, error) {
+	overrides := &RuleOverrides{}
+	if err := json.Unmarshal(data, overrides); err != nil {
+		return nil, fmt.Errorf("invalid rule overrides: %w", err)
+	}
+
+	for id, severity := range overrides.Severity {
+		if _, ok := severityScores[severity]; !ok {
+			return nil, fmt.Errorf("invalid severity %q for rule %d", severity, id)
+		}
+	}
+
+	overrides.enabled = make(map[int]bool, len(overrides.Enable))
+	for _, id := range overrides.Enable {
+		overrides.enabled[id] = true
+	}
+	overrides.disabled = make(map[int]bool, len(overrides.Disable))
+	for _, id := range overrides.Disable {
+		overrides.disabled[id] = true
+	}
+
+	return overrides, nil
+}
+
+// Context key of the rule overrides of a scan
+type ruleOverridesKey struct{}
+
+// WithRuleOverrides Returns the context of a scan applying the rule overrides, so that the matchers skip the rules
+// they turn off before counting their matches
+// @parameters
+// ctx - Context of the scan
+// overrides - Rule overrides of the scan, nil to apply all the rules selected
+// @returns
+// context.Context - Context of the scan with the overrides
+func WithRuleOverrides(ctx context.Context, overrides *RuleOverrides) context.Context {
+	if overrides == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ruleOverridesKey{}, overrides)
+}
+
+// RuleSelectedFor Checks if a rule is applied by a scan: selected for all the scans, see RuleSelected, and not turned
+// off by the rule overrides of the scan
+// @parameters
+// ctx - Context of the scan, with its rule overrides if any
+// id - ID of the rule
+// @returns
+// bool - true if the scan reports the secrets of the rule
+func RuleSelectedFor(ctx context.Context, id int) bool {
+	if !RuleSelected(id) {
+		return false
+	}
+	overrides, _ := ctx.Value(ruleOverridesKey{}).(*RuleOverrides)
+	return overrides.Selects(id)
+}
+
+// Selects Checks if the overrides report a rule
+func (o *RuleOverrides) Selects(id int) bool {
+	if o == nil {
+		return true
+	}
+	return (len(o.enabled) == 0 || o.enabled[id]) && !o.disabled[id]
+}
+
+// Apply Update the severity of the secret according to the overrides
+// @parameters
+// secret - Secret found by the scan
+// @returns
+// bool - false if the secret's rule is disabled and it must not be reported
+func (o *RuleOverrides) Apply(secret *output.SecretFound) bool {
+	if o == nil {
+		return true
+	}
+	if !o.Selects(secret.RuleID) {
+		return false
+	}
+	if severity, ok := o.Severity[secret.RuleID]; ok {
+		secret.Severity = severity
+		secret.SeverityScore = severityScores[severity]
+	}
+	return true
+}
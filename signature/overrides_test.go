@@ -0,0 +1,75 @@
+package signature_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_RuleOverrides(t *testing.T) {
+	overrides, err := signature.ParseRuleOverrides([]byte(`{"disable": [2], "severity": {"3": "high"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := output.SecretFound{RuleID: 1, Severity: output.LOW, SeverityScore: 2.5}
+	if !overrides.Apply(&secret) || secret.Severity != output.LOW {
+		t.Errorf("rule 1 should be reported unchanged, got %+v", secret)
+	}
+
+	secret = output.SecretFound{RuleID: 2, Severity: output.LOW}
+	if overrides.Apply(&secret) {
+		t.Errorf("disabled rule 2 should not be reported")
+	}
+
+	secret = output.SecretFound{RuleID: 3, Severity: output.LOW, SeverityScore: 2.5}
+	if !overrides.Apply(&secret) || secret.Severity != output.HIGH || secret.SeverityScore != 7.5 {
+		t.Errorf("rule 3 should be reported as high, got %+v", secret)
+	}
+}
+
+func Test_RuleOverridesEnable(t *testing.T) {
+	overrides, err := signature.ParseRuleOverrides([]byte(`{"enable": [1, 2], "disable": [2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, expected := range map[int]bool{1: true, 2: false, 3: false} {
+		secret := output.SecretFound{RuleID: id}
+		if reported := overrides.Apply(&secret); reported != expected {
+			t.Errorf("rule %d reported = %v, want %v", id, reported, expected)
+		}
+	}
+
+	// No overrides for the scan, everything is reported
+	var none *signature.RuleOverrides
+	if !none.Apply(&output.SecretFound{RuleID: 3}) {
+		t.Errorf("nil overrides should report every rule")
+	}
+}
+
+func Test_RuleSelectedFor(t *testing.T) {
+	overrides, err := signature.ParseRuleOverrides([]byte(`{"disable": [2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := signature.WithRuleOverrides(context.Background(), overrides)
+
+	for id, expected := range map[int]bool{1: true, 2: false} {
+		if selected := signature.RuleSelectedFor(ctx, id); selected != expected {
+			t.Errorf("rule %d selected = %v, want %v", id, selected, expected)
+		}
+	}
+	// The other scans apply all the rules
+	if !signature.RuleSelectedFor(signature.WithRuleOverrides(context.Background(), nil), 2) {
+		t.Errorf("rule 2 should be selected without overrides")
+	}
+}
+
+func Test_RuleOverridesInvalidSeverity(t *testing.T) {
+	if _, err := signature.ParseRuleOverrides([]byte(`{"severity": {"1": "critical"}}`)); err == nil {
+		t.Errorf("expected an error for an unknown severity")
+	}
+}
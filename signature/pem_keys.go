@@ -0,0 +1,338 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rules reported for the PEM blocks of private keys, and of certificates with --report-certificates
+const (
+	PEMPrivateKeyRuleID    = -5
+	PEMPrivateKeyRuleName  = "PEMPrivateKey"
+	PEMCertificateRuleID   = -6
+	PEMCertificateRuleName = "PEMCertificate"
+)
+
+// Start of a PEM block, with its type
+var pemBegin = regexp.MustCompile(`-----BEGIN ([A-Z0-9][A-Z0-9 ]*)-----`)
+
+// Key held by a PEM block
+type pemKey struct {
+	keyType   string // RSA, EC, ED25519, DSA, or OPENSSH for the OpenSSH keys of an unknown algorithm
+	encrypted bool
+	bits      int // 0 if unknown
+}
+
+// DSA private key of OpenSSL, its parameters first
+type dsaPrivateKey struct {
+	Version int
+	P, Q, G *big.Int
+	Y, X    *big.Int
+}
+
+// Encrypted PKCS #8 private key, the algorithm of the key is in its encrypted data
+type encryptedPrivateKeyInfo struct {
+	Algorithm     asn1.RawValue
+	EncryptedData []byte
+}
+
+// Type of the key of the PEM blocks of traditional private keys, e.g. RSA PRIVATE KEY
+var pemKeyTypes = map[string]string{"RSA PRIVATE KEY": "RSA", "EC PRIVATE KEY": "EC", "DSA PRIVATE KEY": "DSA"}
+
+// Length of the DER encoding of the EC private keys of OpenSSL, by the size of their curve
+var ecKeyLengths = map[int]int{256: 121, 384: 167, 521: 223}
+
+// Nearest of the common sizes of the keys, for the encrypted keys whose size is estimated from their length
+func nearestKeySize(bits float64, sizes []int) int {
+	nearest := sizes[0]
+	for _, size := range sizes[1:] {
+		if math.Abs(float64(size)-bits) < math.Abs(float64(nearest)-bits) {
+			nearest = size
+		}
+	}
+	return nearest
+}
+
+// Approximate size of an encrypted traditional RSA, EC or DSA key, from the length of its DER encoding
+func estimateKeyBits(keyType string, length int) int {
+	switch keyType {
+	case "RSA":
+		// The modulus, the private exponent, and five numbers half as long, the primes and the CRT values
+		return nearestKeySize(float64(length)*8/4.5, []int{1024, 2048, 3072, 4096, 8192})
+	case "EC":
+		closest := 0
+		for bits, ecLength := range ecKeyLengths {
+			if closest == 0 || math.Abs(float64(ecLength-length)) < math.Abs(float64(ecKeyLengths[closest]-length)) {
+				closest = bits
+			}
+		}
+		return closest
+	}
+	return 0
+}
+
+// Reads a string of the SSH wire format, a uint32 length and its bytes
+func readSSHString(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	length := binary.BigEndian.Uint32(data)
+	if uint64(length) > uint64(len(data)-4) {
+		return nil, nil, false
+	}
+	return data[4 : 4+length], data[4+length:], true
+}
+
+// Bit length of an mpint of the SSH wire format
+func sshMPIntBits(mpint []byte) int {
+	return new(big.Int).SetBytes(mpint).BitLen()
+}
+
+// Key of an OpenSSH private key, openssh-key-v1. Its public key is not encrypted, so its type and size are known
+// even if it is encrypted
+func parseOpenSSHKey(data []byte) (pemKey, bool) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return pemKey{}, false
+	}
+	cipher, rest, ok := readSSHString(data[len(magic):])
+	if !ok {
+		return pemKey{}, false
+	}
+	key := pemKey{keyType: "OPENSSH", encrypted: string(cipher) != "none"}
+	// KDF name and options, then the number of keys
+	if _, rest, ok = readSSHString(rest); !ok {
+		return pemKey{}, false
+	}
+	if _, rest, ok = readSSHString(rest); !ok || len(rest) < 4 {
+		return pemKey{}, false
+	}
+	publicKey, _, ok := readSSHString(rest[4:])
+	if !ok {
+		return key, true
+	}
+	algorithm, publicKey, ok := readSSHString(publicKey)
+	if !ok {
+		return key, true
+	}
+	switch name := string(algorithm); {
+	case name == "ssh-rsa":
+		if _, publicKey, ok = readSSHString(publicKey); ok { // Public exponent, then the modulus
+			if modulus, _, ok := readSSHString(publicKey); ok {
+				key.keyType, key.bits = "RSA", sshMPIntBits(modulus)
+			}
+		}
+	case name == "ssh-dss":
+		if p, _, ok := readSSHString(publicKey); ok {
+			key.keyType, key.bits = "DSA", sshMPIntBits(p)
+		}
+	case strings.Contains(name, "ed25519"):
+		key.keyType, key.bits = "ED25519", 256
+	case strings.Contains(name, "ecdsa-sha2-nistp"):
+		key.keyType = "EC"
+		for _, bits := range []int{256, 384, 521} {
+			if strings.Contains(name, "nistp"+strconv.Itoa(bits)) {
+				key.bits = bits
+			}
+		}
+	}
+	return key, true
+}
+
+// Key of a PKCS #1, SEC 1, PKCS #8 or OpenSSL DSA private key, whose type is known from the PEM block or its key
+func parsePrivateKey(block *pem.Block) (pemKey, bool) {
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		return parseOpenSSHKey(block.Bytes)
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		var info encryptedPrivateKeyInfo
+		if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+			return pemKey{}, false
+		}
+		return pemKey{encrypted: true}, true
+	}
+	if keyType, ok := pemKeyTypes[block.Type]; ok && strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		return pemKey{keyType: keyType, encrypted: true, bits: estimateKeyBits(keyType, len(block.Bytes))}, true
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return pemKey{keyType: "RSA", bits: key.N.BitLen()}, true
+		}
+	case "EC PRIVATE KEY":
+		if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return pemKey{keyType: "EC", bits: key.Curve.Params().BitSize}, true
+		}
+	case "DSA PRIVATE KEY":
+		var key dsaPrivateKey
+		if _, err := asn1.Unmarshal(block.Bytes, &key); err == nil && key.P != nil {
+			return pemKey{keyType: "DSA", bits: key.P.BitLen()}, true
+		}
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return pemKey{}, false
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return pemKey{keyType: "RSA", bits: key.N.BitLen()}, true
+		case *ecdsa.PrivateKey:
+			return pemKey{keyType: "EC", bits: key.Curve.Params().BitSize}, true
+		case ed25519.PrivateKey:
+			return pemKey{keyType: "ED25519", bits: 256}, true
+		}
+	}
+	return pemKey{}, false
+}
+
+// Public key of a certificate
+func parseCertificate(block *pem.Block) (pemKey, bool) {
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return pemKey{}, false
+	}
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return pemKey{keyType: "RSA", bits: key.N.BitLen()}, true
+	case *ecdsa.PublicKey:
+		return pemKey{keyType: "EC", bits: key.Curve.Params().BitSize}, true
+	case ed25519.PublicKey:
+		return pemKey{keyType: "ED25519", bits: 256}, true
+	}
+	return pemKey{}, true
+}
+
+// Decodes a PEM block, which may be written with \n escapes in a JSON or YAML string, e.g. in the private_key of
+// the key files of the service accounts of Google Cloud
+func decodePEM(data []byte) *pem.Block {
+	if !bytes.Contains(data, []byte("\n")) {
+		data = bytes.ReplaceAll(data, []byte(`\n`), []byte("\n"))
+	}
+	block, _ := pem.Decode(data)
+	return block
+}
+
+// MatchPEMBlocks Report the private keys of the PEM blocks of a file, decoded to report the type of the key, whether
+// it is encrypted and its size, and with --report-certificates their certificates, at low severity. The secrets
+// already found in a PEM block, e.g. by a rule matching its BEGIN line, get the type, encryption and size of its key
+// instead of being reported again, even with the PEM rules not selected
+// @parameters
+// ctx - Context of the scan, with its rule overrides
+// contents - Contents of the file
+// path - Complete path of the file
+// layerID - layer ID of this file in the container image
+// found - Secrets already found in the file, updated in place with the keys of their PEM block
+// numSecrets - Number of secrets found so far, updated with the secrets found
+// @returns
+// []output.SecretFound - Secrets found in the PEM blocks
+func MatchPEMBlocks(ctx context.Context, contents []byte, path string, layerID string, found []output.SecretFound,
+	numSecrets *uint) []output.SecretFound {
+	options := core.GetSession().Options
+	if !bytes.Contains(contents, []byte("-----BEGIN ")) {
+		return nil
+	}
+	var secretsFound []output.SecretFound
+
+	for _, loc := range pemBegin.FindAllSubmatchIndex(contents, -1) {
+		from, blockType := loc[0], string(contents[loc[2]:loc[3]])
+		privateKey := strings.HasSuffix(blockType, "PRIVATE KEY")
+		if !privateKey && !strings.HasSuffix(blockType, "CERTIFICATE") {
+			continue
+		}
+		end := bytes.Index(contents[loc[1]:], []byte("-----END "+blockType+"-----"))
+		if end < 0 {
+			continue
+		}
+		to := loc[1] + end + len("-----END "+blockType+"-----")
+
+		block := decodePEM(contents[from:to])
+		var key pemKey
+		parsed := false
+		if block != nil && privateKey {
+			key, parsed = parsePrivateKey(block)
+		} else if block != nil {
+			key, parsed = parseCertificate(block)
+		}
+		if !parsed {
+			log.Debugf("MatchPEMBlocks: %s: %s at %d is not valid", path, blockType, from)
+			continue
+		}
+
+		overlapping := false
+		for i := range found {
+			if found[i].PartToMatch != ContentsPart {
+				continue
+			}
+			start := found[i].PrintBufferStartIndex + found[i].MatchFromByte
+			if from <= start && start < to {
+				overlapping = true
+				if privateKey && found[i].KeyType == "" {
+					found[i].KeyType, found[i].KeyEncrypted, found[i].KeyBits = key.keyType, key.encrypted, key.bits
+				}
+			}
+		}
+		ruleID := PEMPrivateKeyRuleID
+		if !privateKey {
+			ruleID = PEMCertificateRuleID
+		}
+		if overlapping || !RuleSelectedFor(ctx, ruleID) || (!privateKey && !*options.ReportCertificates) {
+			continue
+		}
+		if *numSecrets >= *options.MaxSecrets {
+			log.Debugf("MAX secrets exceeded: %d", *numSecrets)
+			break
+		}
+		suppressed := isSuppressed(contents, from)
+		if suppressed && !*options.ShowSuppressed {
+			continue
+		}
+
+		start, stop, err := displayWindow(contents, from, to)
+		if err != nil {
+			log.Errorf("MatchPEMBlocks: %s", err)
+			continue
+		}
+		secret := output.SecretFound{
+			LayerID:     layerID,
+			RuleID:      PEMPrivateKeyRuleID,
+			RuleName:    PEMPrivateKeyRuleName,
+			PartToMatch: ContentsPart,
+			Severity:    output.HIGH, SeverityScore: 10.0,
+			CompleteFilename:      path,
+			PrintBufferStartIndex: start, MatchFromByte: from - start, MatchToByte: to - start,
+			MatchedContents: string(contents[start:stop]),
+			KeyType:         key.keyType, KeyEncrypted: key.encrypted, KeyBits: key.bits,
+		}
+		switch {
+		case !privateKey:
+			secret.RuleID, secret.RuleName = PEMCertificateRuleID, PEMCertificateRuleName
+			secret.Severity, secret.SeverityScore = output.LOW, 2.5
+		case key.encrypted:
+			// The key is of no use without its passphrase
+			secret.Severity, secret.SeverityScore = output.MEDIUM, 5.0
+		}
+		if suppressed {
+			suppress(&secret)
+		}
+		secretsFound = append(secretsFound, secret)
+		*numSecrets = *numSecrets + 1
+	}
+	return secretsFound
+}
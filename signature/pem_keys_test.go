@@ -0,0 +1,137 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// String of the SSH wire format
+func sshString(data []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(data))), data...)
+}
+
+// OpenSSH private key holding a public key, its private part left out
+func openSSHKey(cipher string, publicKey ...[]byte) []byte {
+	var blob []byte
+	for _, field := range publicKey {
+		blob = append(blob, sshString(field)...)
+	}
+	data := []byte("openssh-key-v1\x00")
+	data = append(data, sshString([]byte(cipher))...)
+	data = append(data, sshString([]byte("bcrypt"))...)
+	data = append(data, sshString(nil)...)
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = append(data, sshString(blob)...)
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: append(data, sshString(nil)...)})
+}
+
+func Test_ParsePEMKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsaDER, err := asn1.Marshal(dsaPrivateKey{P: new(big.Int).Lsh(big.NewInt(1), 1023), Q: big.NewInt(3),
+		G: big.NewInt(2), Y: big.NewInt(5), X: big.NewInt(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint:staticcheck // Legacy PEM encryption, still written by openssl genrsa -aes256
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey),
+		[]byte("passphrase"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "example.com"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &ecKey.PublicKey, ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		pem      []byte
+		expected pemKey
+	}{
+		{"RSA PKCS #1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			pemKey{"RSA", false, 2048}},
+		{"RSA PKCS #8", pkcs8(rsaKey), pemKey{"RSA", false, 2048}},
+		{"RSA encrypted", pem.EncodeToMemory(encrypted), pemKey{"RSA", true, 2048}},
+		{"EC SEC 1", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), pemKey{"EC", false, 384}},
+		{"EC PKCS #8", pkcs8(ecKey), pemKey{"EC", false, 384}},
+		{"ED25519 PKCS #8", pkcs8(edKey), pemKey{"ED25519", false, 256}},
+		{"DSA", pem.EncodeToMemory(&pem.Block{Type: "DSA PRIVATE KEY", Bytes: dsaDER}), pemKey{"DSA", false, 1024}},
+		{"OpenSSH ED25519", openSSHKey("none", []byte("ssh-ed25519"), edKey.Public().(ed25519.PublicKey)),
+			pemKey{"ED25519", false, 256}},
+		{"OpenSSH RSA encrypted", openSSHKey("aes256-ctr", []byte("ssh-rsa"), []byte{1, 0, 1}, append([]byte{0},
+			rsaKey.N.Bytes()...)), pemKey{"RSA", true, 2048}},
+		{"OpenSSH ECDSA", openSSHKey("none", []byte("ecdsa-sha2-nistp256"), []byte("nistp256")),
+			pemKey{"EC", false, 256}},
+		{"OpenSSH unknown", openSSHKey("none", []byte("ssh-unknown")), pemKey{"OPENSSH", false, 0}},
+		{"JSON escaped", []byte(strings.ReplaceAll(string(pkcs8(edKey)), "\n", `\n`)), pemKey{"ED25519", false, 256}},
+	} {
+		block := decodePEM(test.pem)
+		if block == nil {
+			t.Errorf("%s: not decoded", test.name)
+			continue
+		}
+		if key, ok := parsePrivateKey(block); !ok || key != test.expected {
+			t.Errorf("%s: key %+v (%v), want %+v", test.name, key, ok, test.expected)
+		}
+	}
+
+	block := decodePEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+	if key, ok := parseCertificate(block); !ok || key != (pemKey{"EC", false, 384}) {
+		t.Errorf("certificate key %+v (%v), want EC 384", key, ok)
+	}
+	if _, ok := parsePrivateKey(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("...")}); ok {
+		t.Error("invalid RSA key parsed")
+	}
+}
+
+func Test_EstimateKeyBits(t *testing.T) {
+	for _, test := range []struct {
+		keyType  string
+		length   int
+		expected int
+	}{
+		{"RSA", 609, 1024}, {"RSA", 1192, 2048}, {"RSA", 1766, 3072}, {"RSA", 2349, 4096},
+		{"EC", 121, 256}, {"EC", 167, 384}, {"EC", 223, 521}, {"DSA", 448, 0},
+	} {
+		if actual := estimateKeyBits(test.keyType, test.length); actual != test.expected {
+			t.Errorf("%s key of %d bytes estimated at %d bits, want %d", test.keyType, test.length, actual,
+				test.expected)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package signature
+
+import (
+	"regexp/syntax"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+// Constants bounding the keywords derived from the regex of a rule
+const (
+	minKeywordLength = 3  // Shorter literals are in almost every file, a rule requiring them runs on all files
+	maxKeywords      = 64 // Alternatives expanded into the keywords of a rule at most
+	maxClassKeywords = 4  // Characters of a class, e.g. [Aa] or [-_], expanded into the keywords at most
+)
+
+// Indicates if the regexes of the rules with keywords run only on the contents containing one of them
+var prefilterEnabled = true
+
+// EnablePrefilter Turn the keyword prefilter on or off, must be called before ProcessSignatures
+// @parameters
+// enabled - Run the regexes of the rules with keywords only on the contents containing one of them
+func EnablePrefilter(enabled bool) {
+	prefilterEnabled = enabled
+}
+
+// Rules whose regexes run only on the contents containing one of their keywords
+type keywordPrefilter struct {
+	matcher  *keywordMatcher
+	rules    [][]int      // IDs of the rules requiring each keyword, by index of the keyword
+	filtered map[int]bool // IDs of all the rules with keywords
+}
+
+// Create the prefilter of a list of signatures, the signatures without keywords always run
+// @parameters
+// signatures - Pattern signatures matching the contents
+// @returns
+// *keywordPrefilter - Prefilter of the signatures, nil if none of them has keywords
+func newKeywordPrefilter(signatures []core.ConfigSignature) *keywordPrefilter {
+	var keywords []string
+	index := make(map[string]int)
+	prefilter := &keywordPrefilter{filtered: make(map[int]bool)}
+	for _, signature := range signatures {
+		ruleKeywords := signatureKeywords(signature)
+		if len(ruleKeywords) == 0 {
+			continue
+		}
+		prefilter.filtered[signature.ID] = true
+		for _, keyword := range ruleKeywords {
+			i, exists := index[keyword]
+			if !exists {
+				i = len(keywords)
+				index[keyword] = i
+				keywords = append(keywords, keyword)
+				prefilter.rules = append(prefilter.rules, nil)
+			}
+			prefilter.rules[i] = append(prefilter.rules[i], signature.ID)
+		}
+	}
+	if len(keywords) == 0 {
+		return nil
+	}
+	prefilter.matcher = newKeywordMatcher(keywords)
+	return prefilter
+}
+
+// Check if a rule runs only on the contents containing one of its keywords
+func (p *keywordPrefilter) Filtered(id int) bool {
+	return p != nil && p.filtered[id]
+}
+
+// Rules with keywords to run on some contents
+// @parameters
+// lowerCaseContents - Contents to match, in lower case
+// @returns
+// map[int]bool - IDs of the rules one of whose keywords is in the contents, empty if none
+func (p *keywordPrefilter) activeRules(lowerCaseContents []byte) map[int]bool {
+	active := make(map[int]bool)
+	for _, keyword := range p.matcher.Find(lowerCaseContents) {
+		for _, id := range p.rules[keyword] {
+			active[id] = true
+		}
+	}
+	return active
+}
+
+// Lower case keywords one of which is in every match of a signature: the keywords of its config,
+// otherwise literals derived from its regex
+// @parameters
+// signature - Pattern signature
+// @returns
+// []string - Keywords of the signature, nil if it has to run on all contents
+func signatureKeywords(signature core.ConfigSignature) []string {
+	if len(signature.Keywords) > 0 {
+		var keywords []string
+		for _, keyword := range signature.Keywords {
+			if keyword != "" {
+				keywords = append(keywords, strings.ToLower(keyword))
+			}
+		}
+		return dedupeStrings(keywords)
+	}
+
+	regex := signature.Regex
+	if signature.CaseInsensitive {
+		regex = "(?i)" + regex
+	}
+	parsed, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	keywords, _ := requiredLiterals(parsed.Simplify())
+	if len(keywords) == 0 || shortestLength(keywords) < minKeywordLength {
+		return nil
+	}
+	return keywords
+}
+
+// How literals relate to the matches of a regex
+type literalKind int
+
+const (
+	containedLiterals literalKind = iota // Every match contains one of the literals
+	prefixLiterals                       // Every match starts with one of the literals
+	exactLiterals                        // Every match is one of the literals
+)
+
+// Lower case literals one of which is in every match of a regex
+// @parameters
+// re - Parsed regex
+// @returns
+// []string - Literals, nil if the matches don't require any
+// literalKind - How the literals relate to the matches
+func requiredLiterals(re *syntax.Regexp) ([]string, literalKind) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{strings.ToLower(string(re.Rune))}, exactLiterals
+	case syntax.OpCharClass:
+		var chars []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i+1]-re.Rune[i] >= 2*maxClassKeywords {
+				return nil, containedLiterals
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				chars = append(chars, strings.ToLower(string(r)))
+			}
+		}
+		chars = dedupeStrings(chars)
+		if len(chars) == 0 || len(chars) > maxClassKeywords {
+			return nil, containedLiterals
+		}
+		return chars, exactLiterals
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, exactLiterals
+	case syntax.OpCapture:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpQuest:
+		literals, kind := requiredLiterals(re.Sub[0])
+		if literals == nil || kind != exactLiterals {
+			return nil, containedLiterals
+		}
+		return dedupeStrings(append(literals, "")), exactLiterals
+	case syntax.OpPlus:
+		literals, kind := requiredLiterals(re.Sub[0])
+		return literals, minKind(kind, prefixLiterals)
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return nil, containedLiterals
+		}
+		literals, kind := requiredLiterals(re.Sub[0])
+		if re.Min != 1 || re.Max != 1 {
+			kind = minKind(kind, prefixLiterals)
+		}
+		return literals, kind
+	case syntax.OpAlternate:
+		var union []string
+		kind := exactLiterals
+		for _, sub := range re.Sub {
+			literals, subKind := requiredLiterals(sub)
+			if literals == nil {
+				return nil, containedLiterals
+			}
+			union = append(union, literals...)
+			kind = minKind(kind, subKind)
+		}
+		union = dedupeStrings(union)
+		if len(union) > maxKeywords {
+			return nil, containedLiterals
+		}
+		return union, kind
+	case syntax.OpConcat:
+		return concatLiterals(re.Sub)
+	}
+	return nil, containedLiterals
+}
+
+// Literals one of which is in every match of a concatenation: consecutive exact parts are combined,
+// with the prefixes of the part following them, and the most selective literals are kept
+func concatLiterals(subs []*syntax.Regexp) ([]string, literalKind) {
+	var best, run []string
+	bestIsPrefix, runStart, exact := false, 0, true
+	endRun := func() {
+		if moreSelective(run, best) {
+			best, bestIsPrefix = run, runStart == 0
+		}
+		run = nil
+	}
+
+	for i, sub := range subs {
+		literals, kind := requiredLiterals(sub)
+		if literals == nil {
+			kind = containedLiterals
+		}
+		if kind != exactLiterals {
+			exact = false
+		}
+
+		if kind != containedLiterals && run != nil {
+			if product := crossProduct(run, literals); product != nil {
+				run = product
+				if kind == prefixLiterals {
+					endRun()
+				}
+				continue
+			}
+			exact = false
+		}
+		endRun()
+		if kind == exactLiterals {
+			run, runStart = literals, i
+		} else if moreSelective(literals, best) {
+			best, bestIsPrefix = literals, i == 0 && kind == prefixLiterals
+		}
+	}
+
+	if exact {
+		return run, exactLiterals
+	}
+	endRun()
+	if bestIsPrefix {
+		return best, prefixLiterals
+	}
+	return best, containedLiterals
+}
+
+func minKind(kind literalKind, other literalKind) literalKind {
+	if other < kind {
+		return other
+	}
+	return kind
+}
+
+// Every prefix followed by every suffix, nil if there are more than maxKeywords of them
+func crossProduct(prefixes []string, suffixes []string) []string {
+	if len(prefixes)*len(suffixes) > maxKeywords {
+		return nil
+	}
+	product := make([]string, 0, len(prefixes)*len(suffixes))
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			product = append(product, prefix+suffix)
+		}
+	}
+	return dedupeStrings(product)
+}
+
+// Check if a list of literals skips more contents than another one: longer shortest literal, then fewer literals
+func moreSelective(literals []string, than []string) bool {
+	if literals == nil {
+		return false
+	}
+	if than == nil {
+		return true
+	}
+	if shortest, thanShortest := shortestLength(literals), shortestLength(than); shortest != thanShortest {
+		return shortest > thanShortest
+	}
+	return len(literals) < len(than)
+}
+
+func shortestLength(literals []string) int {
+	shortest := -1
+	for _, literal := range literals {
+		if shortest < 0 || len(literal) < shortest {
+			shortest = len(literal)
+		}
+	}
+	return shortest
+}
+
+func dedupeStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	deduped := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			deduped = append(deduped, value)
+		}
+	}
+	return deduped
+}
+
+// Aho-Corasick automaton finding a set of keywords in a single pass over the contents
+type keywordMatcher struct {
+	next     [][256]int32 // Next state for every state and byte, failure transitions included
+	outputs  [][]int      // Indexes of the keywords ending at every state
+	keywords int
+}
+
+// Build the automaton of a list of keywords
+// @parameters
+// keywords - Non-empty keywords to find
+// @returns
+// *keywordMatcher - Automaton finding the keywords
+func newKeywordMatcher(keywords []string) *keywordMatcher {
+	m := &keywordMatcher{next: make([][256]int32, 1), outputs: make([][]int, 1), keywords: len(keywords)}
+	for i, keyword := range keywords {
+		state := int32(0)
+		for j := 0; j < len(keyword); j++ {
+			if m.next[state][keyword[j]] == 0 {
+				m.next = append(m.next, [256]int32{})
+				m.outputs = append(m.outputs, nil)
+				m.next[state][keyword[j]] = int32(len(m.next) - 1)
+			}
+			state = m.next[state][keyword[j]]
+		}
+		m.outputs[state] = append(m.outputs[state], i)
+	}
+
+	// Breadth-first, the failure state of a state is known before its children are reached
+	fail := make([]int32, len(m.next))
+	var queue []int32
+	for b := 0; b < 256; b++ {
+		if child := m.next[0][b]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+		for b := 0; b < 256; b++ {
+			child := m.next[state][b]
+			if child == 0 {
+				m.next[state][b] = m.next[fail[state]][b]
+				continue
+			}
+			fail[child] = m.next[fail[state]][b]
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// Find the keywords in some contents
+// @parameters
+// contents - Contents to search
+// @returns
+// []int - Indexes of the keywords found, in the order they are first found
+func (m *keywordMatcher) Find(contents []byte) []int {
+	var found []int
+	seen := make([]bool, m.keywords)
+	state := int32(0)
+	for _, b := range contents {
+		state = m.next[state][b]
+		for _, keyword := range m.outputs[state] {
+			if !seen[keyword] {
+				seen[keyword] = true
+				found = append(found, keyword)
+			}
+		}
+		if len(found) == m.keywords {
+			break
+		}
+	}
+	return found
+}
@@ -0,0 +1,146 @@
+package signature
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Rule reported for the plaintext values of the stringData of Kubernetes Secrets, with --decode-k8s-secrets
+const (
+	K8sStringDataRuleID   = -2
+	K8sStringDataRuleName = "KubernetesSecretStringData"
+)
+
+// Rule reported for the values Terraform marks sensitive in its state and plans, unless another rule matched them
+const (
+	TerraformSensitiveRuleID   = -4
+	TerraformSensitiveRuleName = "TerraformSensitiveValue"
+)
+
+// Rule of the scans themselves rather than of the config, with a negative ID
+type builtinRule struct {
+	output.Rule
+	enabled func(options *core.Options) bool // Whether the options report the rule
+}
+
+// Rules of the scans themselves, selected and listed along with the signatures of the config
+var builtinRules = []builtinRule{
+	{output.Rule{ID: GenericHighEntropyRuleID, Name: GenericHighEntropyRuleName, Part: ContentsPart,
+		Severity: output.MEDIUM, Pattern: "base64 and hex strings with a high entropy"},
+		func(options *core.Options) bool { return !*options.NoEntropy }},
+	{output.Rule{ID: K8sStringDataRuleID, Name: K8sStringDataRuleName, Part: ContentsPart,
+		Severity: output.MEDIUM, Pattern: "stringData values of Kubernetes Secrets"},
+		func(options *core.Options) bool { return *options.DecodeK8sSecrets }},
+	{output.Rule{ID: EnvFileRuleID, Name: EnvFileRuleName, Part: ContentsPart, Severity: output.MEDIUM,
+		Pattern: "variables of .env files named like secrets or with a high entropy"},
+		func(options *core.Options) bool { return *options.ScanEnvFiles }},
+	{output.Rule{ID: TerraformSensitiveRuleID, Name: TerraformSensitiveRuleName, Part: ContentsPart,
+		Severity: output.MEDIUM, Pattern: "values Terraform marks sensitive in its states and plans"},
+		func(options *core.Options) bool { return true }},
+	{output.Rule{ID: PEMPrivateKeyRuleID, Name: PEMPrivateKeyRuleName, Part: ContentsPart, Severity: output.HIGH,
+		Pattern: "private keys of PEM blocks"},
+		func(options *core.Options) bool { return true }},
+	{output.Rule{ID: PEMCertificateRuleID, Name: PEMCertificateRuleName, Part: ContentsPart, Severity: output.LOW,
+		Pattern: "certificates of PEM blocks"},
+		func(options *core.Options) bool { return *options.ReportCertificates }},
+}
+
+// Rules applied by the scans, nil to apply all rules
+var selectedRules map[int]bool
+
+// SelectRules Restrict the rules applied by the scans, must be called before ProcessSignatures
+// @parameters
+// numRules - Number of signatures in the config, their IDs are 0 to numRules-1, the built-in rules have negative IDs
+// enable - IDs of the only rules to apply, all rules if none of them is known
+// disable - IDs of the rules not to apply, removed from the enabled rules
+// @returns
+// []string - Rule IDs given which match no rule, ignored
+func SelectRules(numRules int, enable []string, disable []string) []string {
+	var unknown []string
+	parse := func(ids []string) []int {
+		var parsed []int
+		for _, value := range ids {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || !knownRule(id, numRules) {
+				unknown = append(unknown, value)
+				continue
+			}
+			parsed = append(parsed, id)
+		}
+		return parsed
+	}
+	enabled, disabled := parse(enable), parse(disable)
+
+	if len(enabled) == 0 && len(disabled) == 0 {
+		selectedRules = nil
+		return unknown
+	}
+
+	selectedRules = make(map[int]bool, numRules+len(builtinRules))
+	if len(enabled) == 0 {
+		for _, rule := range builtinRules {
+			selectedRules[rule.ID] = true
+		}
+		for id := 0; id < numRules; id++ {
+			selectedRules[id] = true
+		}
+	}
+	for _, id := range enabled {
+		selectedRules[id] = true
+	}
+	for _, id := range disabled {
+		delete(selectedRules, id)
+	}
+	return unknown
+}
+
+// RuleSelected Checks if a rule is applied by the scans
+func RuleSelected(id int) bool {
+	return selectedRules == nil || selectedRules[id]
+}
+
+func knownRule(id int, numRules int) bool {
+	for _, rule := range builtinRules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return id >= 0 && id < numRules
+}
+
+// Longest pattern listed by ActiveRules, longer ones are shortened
+const maxPatternSummary = 80
+
+// ActiveRules Returns the rules applied by the scans with the signatures processed, after the rule selection
+// @parameters
+// options - Options of the scans, the built-in rules are listed if they report secrets with these options
+// @returns
+// []output.Rule - Rules sorted by ID
+func ActiveRules(options *core.Options) []output.Rule {
+	var rules []output.Rule
+	for _, rule := range builtinRules {
+		if rule.enabled(options) && RuleSelected(rule.ID) {
+			rules = append(rules, rule.Rule)
+		}
+	}
+
+	signatures := acquireSignatures()
+	defer signatures.release()
+	for _, signature := range signatures.signatureIDMap {
+		pattern := signature.Match
+		if pattern == "" {
+			pattern = signature.Regex
+		}
+		if len(pattern) > maxPatternSummary {
+			pattern = pattern[:maxPatternSummary-3] + "..."
+		}
+		rules = append(rules, output.Rule{ID: signature.ID, Name: signature.Name, Part: signature.Part,
+			Severity: signature.Severity, Pattern: pattern, AdHoc: signature.AdHoc})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
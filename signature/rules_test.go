@@ -0,0 +1,93 @@
+package signature_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/signature"
+)
+
+func Test_SelectRules(t *testing.T) {
+	defer signature.SelectRules(0, nil, nil)
+
+	// The built-in rules have IDs -6 to -1
+	all := []int{-6, -5, -4, -3, -2, -1, 0, 1, 2, 3}
+	for _, test := range []struct {
+		enable   []string
+		disable  []string
+		selected []int
+		unknown  []string
+	}{
+		{nil, nil, all, nil},
+		{nil, []string{"1", "-1"}, []int{-6, -5, -4, -3, -2, 0, 2, 3}, nil},
+		{[]string{"2", "3"}, nil, []int{2, 3}, nil},
+		{[]string{"2", "3"}, []string{"3"}, []int{2}, nil},
+		{[]string{"2", "7"}, []string{"x"}, []int{2}, []string{"7", "x"}},
+		{nil, []string{"4"}, all, []string{"4"}},
+		{[]string{"7"}, nil, all, []string{"7"}},
+		{[]string{"7"}, []string{"0"}, []int{-6, -5, -4, -3, -2, -1, 1, 2, 3}, []string{"7"}},
+		{[]string{"-3", "-5"}, []string{"-7"}, []int{-5, -3}, []string{"-7"}},
+	} {
+		unknown := signature.SelectRules(4, test.enable, test.disable)
+		if !reflect.DeepEqual(unknown, test.unknown) {
+			t.Errorf("enable %v, disable %v: unknown rules %v, want %v", test.enable, test.disable, unknown, test.unknown)
+		}
+		var selected []int
+		for id := -6; id < 4; id++ {
+			if signature.RuleSelected(id) {
+				selected = append(selected, id)
+			}
+		}
+		if !reflect.DeepEqual(selected, test.selected) {
+			t.Errorf("enable %v, disable %v: selected rules %v, want %v", test.enable, test.disable, selected, test.selected)
+		}
+	}
+}
+
+func Test_ActiveRules(t *testing.T) {
+	defer signature.ProcessSignatures(nil, "")
+	defer signature.SelectRules(0, nil, nil)
+
+	long := "aws_secret_[A-Za-z0-9]{40}" + strings.Repeat("x", 80)
+	signature.SelectRules(3, nil, []string{"1"})
+	signature.ProcessSignatures([]core.ConfigSignature{
+		{Name: "Private key file", Part: signature.ExtPart, Match: ".pem"},
+		{Name: "Disabled", Part: signature.ContentsPart, Regex: "disabled"},
+		{Name: "AWS secret", Part: signature.ContentsPart, Regex: long, Severity: "high"},
+	}, "")
+
+	options := &core.Options{NoEntropy: new(bool), DecodeK8sSecrets: new(bool), ScanEnvFiles: new(bool),
+		ReportCertificates: new(bool)}
+	privateKeys := output.Rule{ID: -5, Name: "PEMPrivateKey", Part: "contents", Severity: "high",
+		Pattern: "private keys of PEM blocks"}
+	terraform := output.Rule{ID: -4, Name: "TerraformSensitiveValue", Part: "contents", Severity: "medium",
+		Pattern: "values Terraform marks sensitive in its states and plans"}
+	entropy := output.Rule{ID: -1, Name: "GenericHighEntropy", Part: "contents", Severity: "medium",
+		Pattern: "base64 and hex strings with a high entropy"}
+	signatures := []output.Rule{
+		{ID: 0, Name: "Private key file", Part: "extension", Severity: "low", Pattern: ".pem"},
+		{ID: 2, Name: "AWS secret", Part: "contents", Severity: "high", Pattern: long[:77] + "..."},
+	}
+	expected := append([]output.Rule{privateKeys, terraform, entropy}, signatures...)
+	if rules := signature.ActiveRules(options); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("unexpected rules %v, want %v", rules, expected)
+	}
+
+	// The built-in rules are listed if the options report them
+	*options.NoEntropy, *options.DecodeK8sSecrets, *options.ScanEnvFiles = true, true, true
+	*options.ReportCertificates = true
+	expected = append([]output.Rule{
+		{ID: -6, Name: "PEMCertificate", Part: "contents", Severity: "low", Pattern: "certificates of PEM blocks"},
+		privateKeys, terraform,
+		{ID: -3, Name: "EnvFileSecret", Part: "contents", Severity: "medium",
+			Pattern: "variables of .env files named like secrets or with a high entropy"},
+		{ID: -2, Name: "KubernetesSecretStringData", Part: "contents", Severity: "medium",
+			Pattern: "stringData values of Kubernetes Secrets"},
+	}, signatures...)
+	if rules := signature.ActiveRules(options); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("unexpected rules with the built-in rules %v, want %v", rules, expected)
+	}
+}
@@ -0,0 +1,152 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flier/gohs/hyperscan"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Signatures compiled from a config, shared by the concurrent scans and replaced as a whole when the config changes
+type signatureSet struct {
+	// Held for reading while matching, for writing once retired to free the databases
+	lock    sync.RWMutex
+	retired bool
+	// Signatures of the config the set is compiled from
+	config []core.ConfigSignature
+	// Digest of the signatures, identifying the set
+	version string
+	// rules_version of the config the set is compiled from
+	rulesVersion string
+
+	simpleSignatureMap  map[string][]core.ConfigSignature
+	patternSignatureMap map[string][]core.ConfigSignature
+	hyperscanBlockDbMap map[string]hyperscan.BlockDatabase
+	signatureIDMap      map[int]core.ConfigSignature
+	// Keyword prefilter of the contents rules, nil if no rule has keywords or the prefilter is disabled
+	contentsPrefilter *keywordPrefilter
+	// Database of the contents rules with keywords, nil if the keyword prefilter is disabled
+	prefilteredContentsDb hyperscan.BlockDatabase
+}
+
+var (
+	currentSignatures atomic.Pointer[signatureSet]
+	reloadLock        sync.Mutex
+)
+
+func init() {
+	currentSignatures.Store(newSignatureSet(nil, ""))
+}
+
+func newSignatureSet(configSignatures []core.ConfigSignature, rulesVersion string) *signatureSet {
+	digest := sha256.New()
+	if err := json.NewEncoder(digest).Encode(configSignatures); err != nil {
+		log.Warnf("Unable to compute the version of the signatures: %s", err)
+	}
+	return &signatureSet{
+		config:              append([]core.ConfigSignature(nil), configSignatures...),
+		version:             hex.EncodeToString(digest.Sum(nil)),
+		rulesVersion:        rulesVersion,
+		simpleSignatureMap:  make(map[string][]core.ConfigSignature),
+		patternSignatureMap: make(map[string][]core.ConfigSignature),
+		hyperscanBlockDbMap: make(map[string]hyperscan.BlockDatabase),
+		signatureIDMap:      make(map[int]core.ConfigSignature),
+	}
+}
+
+// Version Returns a digest of the signatures the scans match, which changes whenever they are reloaded
+func Version() string {
+	return currentSignatures.Load().version
+}
+
+// Versions Returns the version of SecretScanner, the rules_version of the config and the digest of the
+// signatures the scans match, to record in the reports
+func Versions() output.ReportVersions {
+	set := currentSignatures.Load()
+	return output.ReportVersions{
+		Version:      core.GetSession().Version,
+		RulesVersion: set.rulesVersion,
+		RulesDigest:  set.version,
+	}
+}
+
+// Current signatures, held until released so that a reload doesn't free them while matching
+// @returns
+// *signatureSet - Signatures to match with, to be released after matching
+func acquireSignatures() *signatureSet {
+	for {
+		set := currentSignatures.Load()
+		set.lock.RLock()
+		if !set.retired {
+			return set
+		}
+		// Replaced between the load and the lock, use the new signatures
+		set.lock.RUnlock()
+	}
+}
+
+func (s *signatureSet) release() {
+	s.lock.RUnlock()
+}
+
+// Wait for the matches in progress with the signatures and free their databases
+func (s *signatureSet) retire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.retired = true
+	s.free()
+}
+
+func (s *signatureSet) free() {
+	for part, db := range s.hyperscanBlockDbMap {
+		if err := db.Close(); err != nil {
+			log.Warnf("Unable to free hyperscan database for %s: %s", part, err)
+		}
+	}
+	if s.prefilteredContentsDb != nil {
+		if err := s.prefilteredContentsDb.Close(); err != nil {
+			log.Warnf("Unable to free hyperscan database for %s with keywords: %s", ContentsPart, err)
+		}
+	}
+}
+
+// ReloadSignatures Compile the signatures of a config and use them for the next matches, if they changed.
+// Matches in progress complete with the previous signatures, which are freed afterwards
+// @parameters
+// configSignatures - Signatures from the config, in the order their rule IDs are assigned
+// rulesVersion - rules_version of the config
+// @returns
+// bool - Indicates if the signatures changed and were recompiled
+// Error - Errors if any, the previous signatures are kept. Otherwise, returns nil
+func ReloadSignatures(configSignatures []core.ConfigSignature, rulesVersion string) (bool, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	previous := currentSignatures.Load()
+	if reflect.DeepEqual(previous.config, configSignatures) && previous.rulesVersion == rulesVersion {
+		return false, nil
+	}
+
+	start := time.Now()
+	set, err := processSignatures(configSignatures, rulesVersion)
+	if err != nil {
+		return false, err
+	}
+	if err := set.buildHsDb(); err != nil {
+		set.free()
+		return false, err
+	}
+	log.Infof("Compiled %d signatures in %s", len(configSignatures), time.Since(start))
+
+	currentSignatures.Store(set)
+	previous.retire()
+	return true, nil
+}
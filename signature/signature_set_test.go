@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+func Test_RetireWaitsForMatches(t *testing.T) {
+	previous := newSignatureSet(nil, "")
+	currentSignatures.Store(previous)
+	defer currentSignatures.Store(newSignatureSet(nil, ""))
+
+	matching := acquireSignatures()
+	if matching != previous {
+		t.Fatalf("expected the current signatures")
+	}
+
+	next := newSignatureSet([]core.ConfigSignature{{Name: "rule", Part: ContentsPart, Regex: "secret"}}, "")
+	retired := make(chan struct{})
+	go func() {
+		currentSignatures.Store(next)
+		previous.retire()
+		close(retired)
+	}()
+
+	select {
+	case <-retired:
+		t.Fatalf("signatures retired while matching with them")
+	case <-time.After(50 * time.Millisecond):
+	}
+	matching.release()
+	<-retired
+
+	latest := acquireSignatures()
+	defer latest.release()
+	if latest != next || !previous.retired {
+		t.Errorf("matches after the reload should use the new signatures")
+	}
+}
+
+func Test_ReloadSignaturesUnchanged(t *testing.T) {
+	config := []core.ConfigSignature{{Name: "rule", Part: ContentsPart, Regex: "secret"}}
+	current := newSignatureSet(config, "")
+	currentSignatures.Store(current)
+	defer currentSignatures.Store(newSignatureSet(nil, ""))
+
+	changed, err := ReloadSignatures([]core.ConfigSignature{{Name: "rule", Part: ContentsPart, Regex: "secret"}}, "")
+	if err != nil || changed {
+		t.Errorf("unchanged signatures should not be recompiled: changed %v, error %v", changed, err)
+	}
+
+	changed, err = ReloadSignatures([]core.ConfigSignature{{Name: "rule", Part: ContentsPart, Regex: "secret("}}, "")
+	if err == nil || changed {
+		t.Errorf("invalid signatures should be rejected: changed %v, error %v", changed, err)
+	}
+	if currentSignatures.Load() != current || current.retired {
+		t.Errorf("the previous signatures should be kept after an error")
+	}
+}
+
+func Test_RulesVersionOfReloadedSignatures(t *testing.T) {
+	currentSignatures.Store(newSignatureSet(nil, "2024.05.1"))
+	defer currentSignatures.Store(newSignatureSet(nil, ""))
+
+	// The reports record the rules_version of the signatures matched, not of the config loaded at startup
+	changed, err := ReloadSignatures(nil, "2024.06.1")
+	if err != nil || !changed {
+		t.Fatalf("signatures of a new rules version should be reloaded: changed %v, error %v", changed, err)
+	}
+	if rulesVersion := currentSignatures.Load().rulesVersion; rulesVersion != "2024.06.1" {
+		t.Errorf("rules version %q after the reload, want 2024.06.1", rulesVersion)
+	}
+}
@@ -5,12 +5,13 @@ import (
 	// "regexp/syntax"
 	// "strings"
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"math"
-	"regexp"
+	"strings"
 
 	"github.com/fatih/color"
-	"github.com/flier/gohs/hyperscan"
 	"github.com/khulnasoft-lab/SecretScanner/core"
 	"github.com/khulnasoft-lab/SecretScanner/output"
 	log "github.com/sirupsen/logrus"
@@ -36,39 +37,30 @@ type HsInputOutputData struct {
 	layerID            string
 	secretsFound       *[]output.SecretFound
 	numSecrets         *uint
-	matchedRuleSet     map[uint]uint // Indicates if any rules macthed in the last iteration
-}
-
-// Different map data structures to map to appropriate signatures, DBs etc.
-var (
-	simpleSignatureMap  map[string][]core.ConfigSignature
-	patternSignatureMap map[string][]core.ConfigSignature
-	hyperscanBlockDbMap map[string]hyperscan.BlockDatabase
-	signatureIDMap      map[int]core.ConfigSignature
-)
-
-// Initialize all the data structures
-func init() {
-	// log.Infof("Initializing Patterns....")
-	simpleSignatureMap = make(map[string][]core.ConfigSignature)
-	patternSignatureMap = make(map[string][]core.ConfigSignature)
-	hyperscanBlockDbMap = make(map[string]hyperscan.BlockDatabase)
-	signatureIDMap = make(map[int]core.ConfigSignature)
+	matchedRuleSet     map[uint]uint   // Indicates if any rules macthed in the last iteration
+	activeRules        map[int]bool    // Rules of the prefiltered database whose keywords are in the input, nil for others
+	signatures         *signatureSet   // Signatures matched
+	ctx                context.Context // Context of the scan, matching stops once it is done
 }
 
 // Scan to find simple pattern matches for the path, filename and extension of this file
 // @parameters
+// ctx - Context of the scan, with its rule overrides
 // path - Complete path of the file
 // filename - Name of the file
 // extension - Extension of the file
 // layerID - layer ID of this file in the container image
 // @returns
 // []output.SecretFound - List of all secrets found
-func MatchSimpleSignatures(path string, filename string, extension string, layerID string, numSecrets *uint) []output.SecretFound {
+func MatchSimpleSignatures(ctx context.Context, path string, filename string, extension string, layerID string,
+	numSecrets *uint) []output.SecretFound {
 	var tempSecretsFound []output.SecretFound
 	var matchingPart string
 	var matchingStr string
 
+	signatures := acquireSignatures()
+	defer signatures.release()
+
 	for _, part := range []string{ContentsPart, FilenamePart, PathPart, ExtPart} {
 		switch part {
 		case FilenamePart:
@@ -82,7 +74,7 @@ func MatchSimpleSignatures(path string, filename string, extension string, layer
 			matchingStr = extension
 		}
 
-		secrets := matchString(matchingPart, matchingStr, path, layerID, numSecrets)
+		secrets := matchString(ctx, signatures, matchingPart, matchingStr, path, layerID, numSecrets)
 		tempSecretsFound = append(tempSecretsFound, secrets...)
 	}
 
@@ -91,6 +83,7 @@ func MatchSimpleSignatures(path string, filename string, extension string, layer
 
 // Scan to find complex pattern matches for the contents, path, filename and extension of this file
 // @parameters
+// ctx - Context of the scan, matching stops once it is done
 // contents - content of the file
 // path - Complete path of the file
 // filename - Name of the file
@@ -99,14 +92,40 @@ func MatchSimpleSignatures(path string, filename string, extension string, layer
 // @returns
 // []output.SecretFound - List of all secrets found
 // Error - Errors if any. Otherwise, returns nil
-func MatchPatternSignatures(contents []byte, path string, filename string, extension string, layerID string,
-	numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+func MatchPatternSignatures(ctx context.Context, contents []byte, path string, filename string, extension string,
+	layerID string, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	return matchPatternParts(ctx, []string{ContentsPart, FilenamePart, PathPart, ExtPart}, contents, path, filename,
+		extension, layerID, numSecrets, matchedRuleSet)
+}
+
+// Scan to find complex pattern matches for the contents of a file only, e.g. for a part of the contents
+// @parameters
+// ctx - Context of the scan, matching stops once it is done
+// contents - content of the file
+// path - Complete path of the file
+// layerID - layer ID of this file in the container image
+// @returns
+// []output.SecretFound - List of all secrets found
+// Error - Errors if any. Otherwise, returns nil
+func MatchContentsSignatures(ctx context.Context, contents []byte, path string, layerID string, numSecrets *uint,
+	matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
+	return matchPatternParts(ctx, []string{ContentsPart}, contents, path, "", "", layerID, numSecrets, matchedRuleSet)
+}
+
+func matchPatternParts(ctx context.Context, parts []string, contents []byte, path string, filename string,
+	extension string, layerID string, numSecrets *uint, matchedRuleSet map[uint]uint) ([]output.SecretFound, error) {
 	var tempSecretsFound []output.SecretFound
 	var hsIOData HsInputOutputData
 	var matchingPart string
 	var matchingStr []byte
 
-	for _, part := range []string{ContentsPart, FilenamePart, PathPart, ExtPart} {
+	signatures := acquireSignatures()
+	defer signatures.release()
+
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return tempSecretsFound, err
+		}
 		switch part {
 		case FilenamePart:
 			matchingPart = part
@@ -126,6 +145,12 @@ func MatchPatternSignatures(contents []byte, path string, filename string, exten
 		if len(matchingStr) == 0 {
 			continue
 		}
+		// No database if all the patterns of this part are disabled
+		hyperscanBlockDb, ok := signatures.hyperscanBlockDbMap[matchingPart]
+		prefiltered := matchingPart == ContentsPart && signatures.prefilteredContentsDb != nil
+		if !ok && !prefiltered {
+			continue
+		}
 
 		hsIOData = HsInputOutputData{
 			inputData:          matchingStr,
@@ -135,8 +160,24 @@ func MatchPatternSignatures(contents []byte, path string, filename string, exten
 			secretsFound:       &tempSecretsFound,
 			numSecrets:         numSecrets,
 			matchedRuleSet:     matchedRuleSet,
+			signatures:         signatures,
+			ctx:                ctx,
+		}
+		var err error
+		if ok {
+			err = RunHyperscan(hyperscanBlockDb, hsIOData)
+		}
+		if err == nil && prefiltered {
+			// Contents without any keyword skip the regexes of the rules with keywords
+			hsIOData.activeRules = signatures.contentsPrefilter.activeRules(hsIOData.inputDataLowerCase)
+			if len(hsIOData.activeRules) > 0 {
+				err = RunHyperscan(signatures.prefilteredContentsDb, hsIOData)
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// Hyperscan was stopped by the match handler, the scan is cancelled
+			return tempSecretsFound, ctxErr
 		}
-		err := RunHyperscan(hyperscanBlockDbMap[matchingPart], hsIOData)
 		if err != nil {
 			log.Infof("part: %s, path: %s, filename: %s, extenstion: %s, layerID: %s",
 				part, path, filename, extension, layerID)
@@ -149,10 +190,27 @@ func MatchPatternSignatures(contents []byte, path string, filename string, exten
 }
 
 // Process all the extracted signatures from config file, add severity and severity scores, finally
-// store them in appropriate maps
+// store them in appropriate maps, used by the scans once BuildHsDb is called
 // @parameters
 // configSignatures - Extracted patterns from signature config file
-func ProcessSignatures(configSignatures []core.ConfigSignature) {
+// rulesVersion - rules_version of the config file, recorded in the reports
+func ProcessSignatures(configSignatures []core.ConfigSignature, rulesVersion string) {
+	set, err := processSignatures(configSignatures, rulesVersion)
+	if err != nil {
+		log.Fatal(err)
+	}
+	currentSignatures.Store(set)
+}
+
+// Process the extracted signatures from config file into a new set of signatures
+// @parameters
+// configSignatures - Extracted patterns from signature config file
+// rulesVersion - rules_version of the config file
+// @returns
+// *signatureSet - Signatures, without their hyperscan databases
+// Error - Errors if any. Otherwise, returns nil
+func processSignatures(configSignatures []core.ConfigSignature, rulesVersion string) (*signatureSet, error) {
+	set := newSignatureSet(configSignatures, rulesVersion)
 	var simpleContentSignatures []core.ConfigSignature
 	var simpleExtSignatures []core.ConfigSignature
 	var simpleFilenameSignatures []core.ConfigSignature
@@ -165,6 +223,10 @@ func ProcessSignatures(configSignatures []core.ConfigSignature) {
 
 	for i, signature := range configSignatures {
 		signature.ID = i
+		if !RuleSelected(signature.ID) {
+			log.Debugf("Signature %s disabled, rule ID %d", signature.Name, signature.ID)
+			continue
+		}
 
 		if signature.Match != "" {
 			if signature.Severity == "" {
@@ -199,7 +261,11 @@ func ProcessSignatures(configSignatures []core.ConfigSignature) {
 			log.Debugf("Pattern Signature %s %s %s %s %s %s %d", signature.Name, signature.Part,
 				signature.Match, signature.Regex, signature.RegexType, signature.Severity, signature.ID)
 
-			signature.CompiledRegex = regexp.MustCompile(signature.Regex)
+			compiledRegex, err := compileSignatureRegex(signature)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signature %s: %w", signature.Name, err)
+			}
+			signature.CompiledRegex = compiledRegex
 
 			switch signature.Part {
 			case ContentsPart:
@@ -213,24 +279,33 @@ func ProcessSignatures(configSignatures []core.ConfigSignature) {
 			}
 		}
 
-		signatureIDMap[signature.ID] = signature
+		set.signatureIDMap[signature.ID] = signature
 
 	}
 
-	simpleSignatureMap[ContentsPart] = simpleContentSignatures
-	simpleSignatureMap[ExtPart] = simpleExtSignatures
-	simpleSignatureMap[FilenamePart] = simpleFilenameSignatures
-	simpleSignatureMap[PathPart] = simplePathSignatures
+	set.simpleSignatureMap[ContentsPart] = simpleContentSignatures
+	set.simpleSignatureMap[ExtPart] = simpleExtSignatures
+	set.simpleSignatureMap[FilenamePart] = simpleFilenameSignatures
+	set.simpleSignatureMap[PathPart] = simplePathSignatures
 
-	patternSignatureMap[ContentsPart] = patternContentSignatures
-	patternSignatureMap[ExtPart] = patternExtSignatures
-	patternSignatureMap[FilenamePart] = patternFilenameSignatures
-	patternSignatureMap[PathPart] = patternPathSignatures
+	set.patternSignatureMap[ContentsPart] = patternContentSignatures
+	set.patternSignatureMap[ExtPart] = patternExtSignatures
+	set.patternSignatureMap[FilenamePart] = patternFilenameSignatures
+	set.patternSignatureMap[PathPart] = patternPathSignatures
 
 	for _, part := range []string{ContentsPart, FilenamePart, PathPart, ExtPart} {
-		log.Debugf("Number of Complex Patterns for matching %s: %d", part, len(patternSignatureMap[part]))
-		log.Debugf("Number of Simple Patterns for matching %s: %d", part, len(simpleSignatureMap[part]))
+		log.Debugf("Number of Complex Patterns for matching %s: %d", part, len(set.patternSignatureMap[part]))
+		log.Debugf("Number of Simple Patterns for matching %s: %d", part, len(set.simpleSignatureMap[part]))
+	}
+
+	if prefilterEnabled {
+		set.contentsPrefilter = newKeywordPrefilter(patternContentSignatures)
+	}
+	if set.contentsPrefilter != nil {
+		log.Debugf("Number of Complex Patterns for matching %s with keywords: %d", ContentsPart,
+			len(set.contentsPrefilter.filtered))
 	}
+	return set, nil
 }
 
 // Append one signature to the list of signatures
@@ -243,24 +318,29 @@ func addToSignatures(signature core.ConfigSignature, Signatures *[]core.ConfigSi
 
 // Match simple pattern signatures with path, filename or extension
 // @parameters
+// ctx - Context of the scan, with its rule overrides
+// signatures - Signatures to match
 // part - which part to be matched: path, filename or extension
 // input - input to be matched
 // completeFilename - Complete path of the file
 // layerID - layer ID of this file in the container image
 // @returns
 // []output.SecretFound - List of all secrets found
-func matchString(part string, input string, completeFilename string, layerID string,
-	numSecrets *uint) []output.SecretFound {
+func matchString(ctx context.Context, signatures *signatureSet, part string, input string, completeFilename string,
+	layerID string, numSecrets *uint) []output.SecretFound {
 	var tempSecretsFound []output.SecretFound
 
-	for _, signature := range simpleSignatureMap[part] {
+	for _, signature := range signatures.simpleSignatureMap[part] {
 		// Don't report secrets if number of secrets exceeds MAX value
 		if *numSecrets >= *core.GetSession().Options.MaxSecrets {
 			log.Debugf("MAX secrets exceeded: %d", *numSecrets)
 			return tempSecretsFound
 		}
 
-		if signature.Match == input {
+		if !RuleSelectedFor(ctx, signature.ID) {
+			continue
+		}
+		if from, to, ok := matchSimple(signature, input); ok {
 			if core.ContainsBlacklistedString([]byte(input)) {
 				log.Debugf("matchString: Skipping matches containing blacklisted strings")
 				continue
@@ -276,8 +356,8 @@ func matchString(part string, input string, completeFilename string, layerID str
 				PartToMatch: signature.Part, Match: signature.Match, Regex: signature.Regex,
 				Severity: signature.Severity, SeverityScore: signature.SeverityScore,
 				CompleteFilename: completeFilename,
-				MatchFromByte:    0,
-				MatchToByte:      len(input),
+				MatchFromByte:    from,
+				MatchToByte:      to,
 				MatchedContents:  input,
 			}
 			tempSecretsFound = append(tempSecretsFound, secret)
@@ -288,6 +368,78 @@ func matchString(part string, input string, completeFilename string, layerID str
 	return tempSecretsFound
 }
 
+// Checks if a simple signature matches the input. Without word_boundary, preceded_by and followed_by it
+// has to be the whole input, otherwise the first occurrence of the signature meeting them is matched
+// @parameters
+// signature - Simple signature to match
+// input - Path, filename or extension to match
+// @returns
+// int - Start index of the match
+// int - End index of the match
+// bool - true if the signature matches
+func matchSimple(signature core.ConfigSignature, input string) (int, int, bool) {
+	match := signature.Match
+	if !signature.WordBoundary && signature.PrecededBy == "" && signature.FollowedBy == "" {
+		ok := match == input || (signature.CaseInsensitive && strings.EqualFold(match, input))
+		return 0, len(input), ok
+	}
+	if match == "" {
+		return 0, 0, false
+	}
+
+	searched := input
+	if signature.CaseInsensitive {
+		// ASCII only, so that the indexes are the ones of the input
+		searched, match = asciiLower(input), asciiLower(match)
+	}
+	for offset := 0; offset < len(searched); {
+		i := strings.Index(searched[offset:], match)
+		if i < 0 {
+			break
+		}
+		from, to := offset+i, offset+i+len(match)
+		if simpleContextMatches(signature, input, from, to) {
+			return from, to, true
+		}
+		offset = from + 1
+	}
+	return 0, 0, false
+}
+
+// Checks the word boundaries and the chars around a match of a simple signature
+func simpleContextMatches(signature core.ConfigSignature, input string, from, to int) bool {
+	if signature.WordBoundary && ((from > 0 && isWordChar(input[from-1])) || (to < len(input) && isWordChar(input[to]))) {
+		return false
+	}
+	if signature.PrecededBy != "" {
+		before := strings.TrimRight(input[:from], " \t")
+		if before == "" || !strings.ContainsRune(signature.PrecededBy, rune(before[len(before)-1])) {
+			return false
+		}
+	}
+	if signature.FollowedBy != "" {
+		after := strings.TrimLeft(input[to:], " \t")
+		if after == "" || !strings.ContainsRune(signature.FollowedBy, rune(after[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func asciiLower(s string) string {
+	lower := []byte(s)
+	for i, c := range lower {
+		if 'A' <= c && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	return string(lower)
+}
+
 // Post process after hyperscan finds signature match
 // For large pattern matches, find the start of the match (SOM) before printing
 // @parameters
@@ -303,6 +455,12 @@ func processHsRegexMatch(id uint, from, to uint64, flags uint, context interface
 	hsIOData := context.(HsInputOutputData)
 	secrets := hsIOData.secretsFound
 
+	// Stop matching the input once the scan is cancelled
+	if err := hsIOData.ctx.Err(); err != nil {
+		return err
+	}
+	signatureIDMap := hsIOData.signatures.signatureIDMap
+
 	// Don't report secrets if number of secrets exceeds MAX value
 	if *hsIOData.numSecrets >= *core.GetSession().Options.MaxSecrets {
 		log.Debugf("MAX secrets exceeded: %d", *hsIOData.numSecrets)
@@ -310,6 +468,12 @@ func processHsRegexMatch(id uint, from, to uint64, flags uint, context interface
 	}
 
 	sid := int(id)
+	if hsIOData.activeRules != nil && !hsIOData.activeRules[sid] {
+		return nil // None of the keywords of the rule is in the input
+	}
+	if !RuleSelectedFor(hsIOData.ctx, sid) {
+		return nil // Turned off by the rule overrides of the scan
+	}
 	start = int(from)
 	if signatureIDMap[sid].RegexType == LargeRegexType {
 		// Post process to find start of matching for large patterns
@@ -323,20 +487,29 @@ func processHsRegexMatch(id uint, from, to uint64, flags uint, context interface
 		return nil
 	}
 
-	// Match only once for now, later report only supersets
-	// Report multiple matches, only if MultipleMatch is set to true
-	_, exists := hsIOData.matchedRuleSet[id] // Check, if this pattern matched for this file earlier
-	if !exists {
-		hsIOData.matchedRuleSet[id] = 1 // Add to matched rules for first match
-	} else if *core.GetSession().Options.MultipleMatch == false {
-		return nil // Don't output later matches of this pattern, if multi-match is false
-	} else if *core.GetSession().Options.MultipleMatch == true {
-		hsIOData.matchedRuleSet[id] = hsIOData.matchedRuleSet[id] + 1
-		if hsIOData.matchedRuleSet[id] > *core.GetSession().Options.MaxMultiMatch {
-			return nil // Don't output later matches of this pattern, if #Mateches > MaxThreshold
+	// Suppressed matches don't count as the match of the rule, a later match in the file is still reported
+	if signatureIDMap[sid].Part == ContentsPart && isSuppressed(hsIOData.inputData, start) {
+		if !*core.GetSession().Options.ShowSuppressed {
+			return nil
 		}
+		secret, err := printMatchedSignatures(sid, start, int(to), hsIOData)
+		if err != nil {
+			log.Errorf("processHsRegexMatch: %s", err)
+			return nil
+		}
+		suppress(&secret)
+		*secrets = append(*secrets, secret)
+		*hsIOData.numSecrets = *hsIOData.numSecrets + 1
+		return nil
 	}
 
+	// Report the matches of this pattern in the file up to the limit of the rule
+	options := core.GetSession().Options
+	if hsIOData.matchedRuleSet[id] >= ruleMatchLimit(signatureIDMap[sid], *options.MultipleMatch, *options.MaxMultiMatch) {
+		return nil
+	}
+	hsIOData.matchedRuleSet[id] = hsIOData.matchedRuleSet[id] + 1
+
 	secret, err := printMatchedSignatures(sid, start, int(to), hsIOData)
 	if err != nil {
 		log.Errorf("processHsRegexMatch: %s", err)
@@ -348,6 +521,25 @@ func processHsRegexMatch(id uint, from, to uint64, flags uint, context interface
 	return nil
 }
 
+// Maximum number of matches of a rule reported for a file
+// @parameters
+// signature - Signature of the rule
+// multipleMatch - --multi-match, more than one match of the rules without max_matches is reported
+// maxMultiMatch - --max-multi-match, matches reported with --multi-match
+// @returns
+// uint - max_matches of the rule if set, all matches if -1, otherwise 1 or --max-multi-match with --multi-match
+func ruleMatchLimit(signature core.ConfigSignature, multipleMatch bool, maxMultiMatch uint) uint {
+	switch {
+	case signature.MaxMatches < 0:
+		return ^uint(0)
+	case signature.MaxMatches > 0:
+		return uint(signature.MaxMatches)
+	case multipleMatch:
+		return maxMultiMatch
+	}
+	return 1
+}
+
 // For large regex patterns, if Hyperscan finds a match, then
 // find the matching indexes directly as start of match (SOM) hyperscan flag doesn't work
 // for large patterns.
@@ -360,6 +552,7 @@ func processHsRegexMatch(id uint, from, to uint64, flags uint, context interface
 // int - Exact start index of the large complex regex matches
 func getStartOfLargeRegexMatch(sid int, from, to int, hsIOData HsInputOutputData) int {
 	inputData := hsIOData.inputData
+	signatureIDMap := hsIOData.signatures.signatureIDMap
 	// secrets := hsIOData.secretsFound
 
 	pattern := signatureIDMap[sid].CompiledRegex
@@ -395,6 +588,7 @@ func printMatchedSignatures(sid int, from, to int, hsIOData HsInputOutputData) (
 	inputData := hsIOData.inputData
 	completeFilename := hsIOData.completeFilename
 	layerID := hsIOData.layerID
+	signatureIDMap := hsIOData.signatures.signatureIDMap
 
 	updatedSeverity, updatedScore := calculateSeverity(inputData[from:to], signatureIDMap[sid].Severity, signatureIDMap[sid].SeverityScore)
 
@@ -406,15 +600,9 @@ func printMatchedSignatures(sid int, from, to int, hsIOData HsInputOutputData) (
 	log.Debugf("Secret found in %s of %s within bytes %d and %d", signatureIDMap[sid].Part, completeFilename, from, to)
 	// fmt.Println("Secret found in", signatureIDMap[sid].Part, "of", completeFilename, "withing bytes", from, "and", to)
 
-	start := Max(0, bytes.LastIndexByte(inputData[:from], '\n')) // Avoid -ve value from IndexByte
-	end := to + Max(0, bytes.IndexByte(inputData[to:], '\n'))    // Avoid -ve value from IndexByte
-
-	// Display max 50 bytes before and after the maching string
-	start = Max(start, from-50)
-	end = Min(end, to+50)
-
-	if !(0 <= start && start <= from && from <= to && to <= end && end <= len(inputData)) {
-		return output.SecretFound{}, errors.New("index out of bound while printing matched signatures")
+	start, end, err := displayWindow(inputData, from, to)
+	if err != nil {
+		return output.SecretFound{}, err
 	}
 
 	// coloredMatch := fmt.Sprintf("%s%s%s\n", inputData[start:from], color.RedString(string(inputData[from:to])), inputData[to:end])
@@ -423,7 +611,7 @@ func printMatchedSignatures(sid int, from, to int, hsIOData HsInputOutputData) (
 
 	secret := output.SecretFound{
 		LayerID: layerID,
-		RuleID:  sid, RuleName: signatureIDMap[sid].Name,
+		RuleID:  sid, RuleName: signatureIDMap[sid].Name, AdHoc: signatureIDMap[sid].AdHoc,
 		PartToMatch: signatureIDMap[sid].Part, Match: signatureIDMap[sid].Match, Regex: signatureIDMap[sid].Regex,
 		Severity: updatedSeverity, SeverityScore: updatedScore,
 		CompleteFilename:      completeFilename,
@@ -434,6 +622,29 @@ func printMatchedSignatures(sid int, from, to int, hsIOData HsInputOutputData) (
 	return secret, nil
 }
 
+// Part of the input displayed around a match: the line of the match, at most 50 bytes before and after it
+// @parameters
+// inputData - Input matched
+// from - Start index of the match
+// to - End index of the match
+// @returns
+// int - Start index of the displayed part
+// int - End index of the displayed part
+// Error - Errors if any. Otherwise, returns nil
+func displayWindow(inputData []byte, from, to int) (int, int, error) {
+	start := Max(0, bytes.LastIndexByte(inputData[:from], '\n')) // Avoid -ve value from IndexByte
+	end := to + Max(0, bytes.IndexByte(inputData[to:], '\n'))    // Avoid -ve value from IndexByte
+
+	// Display max 50 bytes before and after the maching string
+	start = Max(start, from-50)
+	end = Min(end, to+50)
+
+	if !(0 <= start && start <= from && from <= to && to <= end && end <= len(inputData)) {
+		return 0, 0, errors.New("index out of bound while printing matched signatures")
+	}
+	return start, end, nil
+}
+
 // Update severity and score based on length of match
 // @parameters
 // inputMatch - Matched portion of the input
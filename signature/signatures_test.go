@@ -0,0 +1,86 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flier/gohs/hyperscan"
+	"github.com/khulnasoft-lab/SecretScanner/core"
+)
+
+func Test_MatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	numSecrets := uint(0)
+	secrets, err := MatchPatternSignatures(ctx, []byte("password=hunter2"), "app/.env", ".env", ".env", "",
+		&numSecrets, map[uint]uint{})
+	if !errors.Is(err, context.Canceled) || len(secrets) != 0 {
+		t.Errorf("cancelled match returned %v, %v, want no secrets and context.Canceled", secrets, err)
+	}
+
+	// Hyperscan is stopped at the next match of a file being matched when the scan is cancelled
+	hsIOData := HsInputOutputData{ctx: ctx, numSecrets: &numSecrets, signatures: newSignatureSet(nil, "")}
+	if err := processHsRegexMatch(0, 0, 8, 0, hsIOData); !errors.Is(err, context.Canceled) {
+		t.Errorf("match handler of a cancelled scan returned %v, want context.Canceled", err)
+	}
+}
+
+func Test_RuleMatchLimit(t *testing.T) {
+	for _, test := range []struct {
+		maxMatches    int
+		multipleMatch bool
+		expected      uint
+	}{
+		{0, false, 1},
+		{0, true, 3},
+		{5, false, 5},
+		{2, true, 2},
+		{-1, false, ^uint(0)},
+		{-1, true, ^uint(0)},
+	} {
+		limit := ruleMatchLimit(core.ConfigSignature{MaxMatches: test.maxMatches}, test.multipleMatch, 3)
+		if limit != test.expected {
+			t.Errorf("max_matches %d, multi-match %v: limit %d, want %d", test.maxMatches, test.multipleMatch,
+				limit, test.expected)
+		}
+	}
+
+	// Large patterns stop at their first match unless more matches are reported
+	large := core.ConfigSignature{RegexType: LargeRegexType}
+	if hsFlags(large, false)&hyperscan.SingleMatch == 0 {
+		t.Errorf("large rule should be compiled single match without --multi-match")
+	}
+	large.MaxMatches = -1
+	if hsFlags(large, false)&hyperscan.SingleMatch != 0 {
+		t.Errorf("large rule with max_matches -1 should not be compiled single match")
+	}
+}
+
+func Test_MatchSimple(t *testing.T) {
+	for _, test := range []struct {
+		signature core.ConfigSignature
+		input     string
+		from, to  int
+		ok        bool
+	}{
+		{core.ConfigSignature{Match: ".pem"}, ".pem", 0, 4, true},
+		{core.ConfigSignature{Match: ".pem"}, ".pem.bak", 0, 0, false},
+		{core.ConfigSignature{Match: "ID_RSA", CaseInsensitive: true}, "id_rsa", 0, 6, true},
+		{core.ConfigSignature{Match: "password", WordBoundary: true}, "config/password.txt", 7, 15, true},
+		{core.ConfigSignature{Match: "password", WordBoundary: true}, "config/passwords.txt", 0, 0, false},
+		{core.ConfigSignature{Match: "password", WordBoundary: true}, "passwords/password", 10, 18, true},
+		{core.ConfigSignature{Match: "Password", WordBoundary: true, CaseInsensitive: true}, "db-PASSWORD", 3, 11, true},
+		{core.ConfigSignature{Match: "password", FollowedBy: "=:"}, "password = x", 0, 8, true},
+		{core.ConfigSignature{Match: "password", FollowedBy: "=:"}, "password_reset", 0, 0, false},
+		{core.ConfigSignature{Match: "secret", PrecededBy: "=:"}, "key: secret", 5, 11, true},
+		{core.ConfigSignature{Match: "secret", PrecededBy: "=:"}, "secret", 0, 0, false},
+	} {
+		from, to, ok := matchSimple(test.signature, test.input)
+		if ok != test.ok || (ok && (from != test.from || to != test.to)) {
+			t.Errorf("%+v on %q: matched %v at %d-%d, want %v at %d-%d", test.signature, test.input, ok, from, to,
+				test.ok, test.from, test.to)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package signature
+
+import (
+	"bytes"
+
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// SuppressionMarker Marker of a comment suppressing the secrets of its line and of the next line
+const SuppressionMarker = "secretscanner:ignore"
+
+// Tokens starting a comment in common languages: C family, shell, Python, YAML, TOML, SQL, Lua,
+// Haskell, INI, Lisp, TeX, Erlang, VB, HTML and XML
+var commentTokens = [][]byte{
+	[]byte("//"), []byte("/*"), []byte("#"), []byte("--"), []byte(";"), []byte("%"), []byte("'"),
+	[]byte("<!--"), []byte("{-"), []byte("(*"),
+}
+
+// Indicates if a line has the suppression marker inside a comment
+func hasSuppressionComment(line []byte) bool {
+	i := bytes.Index(line, []byte(SuppressionMarker))
+	if i < 0 {
+		return false
+	}
+	before := line[:i]
+	for _, token := range commentTokens {
+		if bytes.Contains(before, token) {
+			return true
+		}
+	}
+	// Continuation lines of block comments, and BASIC comments
+	trimmed := bytes.ToLower(bytes.TrimSpace(before))
+	return bytes.HasPrefix(trimmed, []byte("*")) || bytes.Equal(trimmed, []byte("rem")) ||
+		bytes.HasPrefix(trimmed, []byte("rem "))
+}
+
+// Indicates if a match is suppressed by a marker on its line or on the line above
+// @parameters
+// data - Contents matched
+// offset - Start index of the match in data
+// @returns
+// bool - true if the match is suppressed
+func isSuppressed(data []byte, offset int) bool {
+	if offset < 0 || offset > len(data) {
+		return false
+	}
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		lineEnd = offset + i
+	}
+	if hasSuppressionComment(data[lineStart:lineEnd]) {
+		return true
+	}
+	if lineStart == 0 {
+		return false
+	}
+	prevStart := bytes.LastIndexByte(data[:lineStart-1], '\n') + 1
+	return hasSuppressionComment(data[prevStart : lineStart-1])
+}
+
+// Report a secret at the suppressed severity, so that it does not count towards any threshold
+func suppress(secret *output.SecretFound) {
+	secret.Severity = output.SUPPRESSED
+	secret.SeverityScore = 0
+}
@@ -0,0 +1,126 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Global endpoint of STS, whose GetCallerIdentity is a harmless read-only call, answered for any valid credentials
+// whatever their permissions
+const stsEndpoint = "https://sts.amazonaws.com"
+
+// Secret access keys tried for each access key ID, to bound the calls made for files full of base64
+const maxSecretKeyCandidates = 5
+
+var (
+	awsAccessKeyIDRegex  = regexp.MustCompile(`(A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z0-9]{16}`)
+	awsSecretAccessRegex = regexp.MustCompile(`(^|[^A-Za-z0-9/+=])([A-Za-z0-9/+]{40})([^A-Za-z0-9/+=]|$)`)
+)
+
+func init() {
+	Register("aws", NewAWSVerifier(stsEndpoint))
+}
+
+// Verifier of AWS access key IDs, calling STS GetCallerIdentity with the secret access keys found
+// on the same line or in the same file
+type awsVerifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewAWSVerifier Create a verifier of AWS access key IDs
+// @parameters
+// endpoint - URL of STS
+// @returns
+// FileVerifier - Verifier pairing the access key IDs with the secret access keys of their file
+func NewAWSVerifier(endpoint string) FileVerifier {
+	return &awsVerifier{
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout: DefaultTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Verify Verify an access key ID with the secret access keys on its line
+func (v *awsVerifier) Verify(ctx context.Context, secret output.SecretFound) (bool, error) {
+	return v.VerifyInFile(ctx, secret, nil)
+}
+
+// VerifyInFile Verify an access key ID with the secret access keys on its line and in the other secrets of its file
+func (v *awsVerifier) VerifyInFile(ctx context.Context, secret output.SecretFound, others []output.SecretFound) (bool, error) {
+	accessKeyID := awsAccessKeyIDRegex.FindString(matchedSecret(secret))
+	if accessKeyID == "" {
+		return false, fmt.Errorf("no access key id to verify")
+	}
+	candidates := secretKeyCandidates(append([]output.SecretFound{secret}, others...))
+	if len(candidates) == 0 {
+		return false, fmt.Errorf("no secret access key found with access key id %s", accessKeyID)
+	}
+	for _, secretKey := range candidates {
+		live, err := v.getCallerIdentity(ctx, accessKeyID, secretKey)
+		if err != nil || live {
+			return live, err
+		}
+	}
+	return false, nil
+}
+
+func (v *awsVerifier) getCallerIdentity(ctx context.Context, accessKeyID string, secretKey string) (bool, error) {
+	// Each secret access key tried is a request of its own for the rate limit
+	if err := WaitForRequest(ctx); err != nil {
+		return false, err
+	}
+	client := sts.New(sts.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(v.endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKeyID, secretKey, ""),
+		HTTPClient:   v.client,
+		// Retries would bypass the rate limit
+		Retryer: aws.NopRetryer{},
+	})
+	_, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err == nil {
+		return true, nil
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		if respErr.HTTPStatusCode() == http.StatusForbidden {
+			// Invalid key or signature: this secret access key does not belong to the key id
+			return false, nil
+		}
+		return false, fmt.Errorf("sts returned status %d", respErr.HTTPStatusCode())
+	}
+	return false, fmt.Errorf("sts request failed: %w", unwrapURLError(err))
+}
+
+// Distinct strings shaped like secret access keys in the contents of the secrets
+func secretKeyCandidates(secrets []output.SecretFound) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		for _, match := range awsSecretAccessRegex.FindAllStringSubmatch(secret.MatchedContents, -1) {
+			if seen[match[2]] {
+				continue
+			}
+			seen[match[2]] = true
+			candidates = append(candidates, match[2])
+			if len(candidates) == maxSecretKeyCandidates {
+				return candidates
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,128 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+)
+
+// Placeholder replaced by the secret in the requests of HTTP verifiers
+const matchPlaceholder = "{{match}}"
+
+// Bytes of the response read to look for the success substring
+const maxResponseSize = 1024 * 1024
+
+// Verifier sending the request configured for a rule
+type httpVerifier struct {
+	config  core.HTTPVerifierConfig
+	timeout time.Duration
+	client  *http.Client
+}
+
+func newHTTPVerifier(config core.HTTPVerifierConfig) (*httpVerifier, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("http verifier has no url")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodGet
+	}
+	if len(config.SuccessStatus) == 0 {
+		config.SuccessStatus = []int{http.StatusOK}
+	}
+
+	timeout := DefaultTimeout
+	if config.TimeoutSeconds > 0 && time.Duration(config.TimeoutSeconds)*time.Second < timeout {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+
+	return &httpVerifier{
+		config:  config,
+		timeout: timeout,
+		client: &http.Client{
+			Timeout: timeout,
+			// A redirect is the answer of the endpoint, never send the secret elsewhere
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// Verify Send the configured request with the secret and check the response
+func (v *httpVerifier) Verify(ctx context.Context, secret output.SecretFound) (bool, error) {
+	match := matchedSecret(secret)
+	if match == "" {
+		return false, fmt.Errorf("no matched secret to verify")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	target := strings.ReplaceAll(v.config.URL, matchPlaceholder, url.QueryEscape(match))
+	var body io.Reader
+	if v.config.Body != "" {
+		body = strings.NewReader(strings.ReplaceAll(v.config.Body, matchPlaceholder, match))
+	}
+	req, err := http.NewRequestWithContext(ctx, v.config.Method, target, body)
+	if err != nil {
+		return false, err
+	}
+	for name, value := range v.config.Headers {
+		req.Header.Set(name, strings.ReplaceAll(value, matchPlaceholder, match))
+	}
+
+	if err := WaitForRequest(ctx); err != nil {
+		return false, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		// Do not report the url, it may contain the secret
+		return false, fmt.Errorf("%s request failed: %w", v.config.Method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	statusOK := false
+	for _, status := range v.config.SuccessStatus {
+		if resp.StatusCode == status {
+			statusOK = true
+			break
+		}
+	}
+	if !statusOK {
+		return false, nil
+	}
+	if v.config.SuccessBody == "" {
+		return true, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(data), v.config.SuccessBody), nil
+}
+
+// Portion of the matched contents which matched the rule
+func matchedSecret(secret output.SecretFound) string {
+	from, to := secret.MatchFromByte, secret.MatchToByte
+	if from < 0 || to > len(secret.MatchedContents) || from >= to {
+		return ""
+	}
+	return secret.MatchedContents[from:to]
+}
+
+// Error of a request without the URL of url.Error, also when wrapped by the AWS SDK
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package verify_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/verify"
+)
+
+func Test_HTTPVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer live-token" || r.URL.Query().Get("key") != "live-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"active":true}`))
+	}))
+	defer server.Close()
+
+	signatures := []core.ConfigSignature{{
+		Name: "internal token",
+		HTTPVerifier: &core.HTTPVerifierConfig{
+			URL:         server.URL + "/me?key={{match}}",
+			Headers:     map[string]string{"Authorization": "Bearer {{match}}"},
+			SuccessBody: `"active":true`,
+		},
+	}}
+	runner, err := verify.NewRunner(signatures, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []output.SecretFound{
+		{MatchedContents: "token=live-token", MatchFromByte: 6, MatchToByte: 16},
+		{MatchedContents: "token=dead-token", MatchFromByte: 6, MatchToByte: 16},
+	}
+	runner.VerifyAll(context.Background(), secrets)
+
+	if !secrets[0].Verified || secrets[0].VerificationError != "" {
+		t.Errorf("live token should be verified: %+v", secrets[0])
+	}
+	if secrets[1].Verified || secrets[1].VerificationError != "" {
+		t.Errorf("dead token should be unverified without error: %+v", secrets[1])
+	}
+}
+
+func Test_HTTPVerifierUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	runner, err := verify.NewRunner([]core.ConfigSignature{{
+		Name:         "internal token",
+		HTTPVerifier: &core.HTTPVerifierConfig{URL: server.URL, TimeoutSeconds: 1},
+	}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := output.SecretFound{MatchedContents: "secret", MatchToByte: 6}
+	runner.Verify(context.Background(), &secret)
+	if secret.Verified || secret.VerificationError == "" {
+		t.Errorf("unreachable endpoint should leave the secret unverified with an error: %+v", secret)
+	}
+}
+
+func Test_HTTPVerifierInvalid(t *testing.T) {
+	_, err := verify.NewRunner([]core.ConfigSignature{{Name: "no url", HTTPVerifier: &core.HTTPVerifierConfig{}}}, "")
+	if err == nil {
+		t.Errorf("expected an error for an http verifier without url")
+	}
+}
@@ -0,0 +1,359 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	log "github.com/sirupsen/logrus"
+)
+
+// Time allowed to verify a single secret
+const DefaultTimeout = 10 * time.Second
+
+// Verifier Checks whether a secret found is live, e.g. by calling the provider's API
+type Verifier interface {
+	// Verify Returns true if the secret is live. An error leaves the secret unverified.
+	// Each request sent to the provider waits for its slot first, see WaitForRequest
+	Verify(ctx context.Context, secret output.SecretFound) (bool, error)
+}
+
+// FileVerifier Verifier also needing the other secrets found in the same file, e.g. to pair
+// an access key ID with its secret access key
+type FileVerifier interface {
+	Verifier
+	// VerifyInFile Returns true if the secret is live, given the other secrets of its file
+	VerifyInFile(ctx context.Context, secret output.SecretFound, others []output.SecretFound) (bool, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Verifier{}
+)
+
+// Register Make a verifier available to the rules naming it in their verifier field
+func Register(name string, verifier Verifier) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[name] = verifier
+}
+
+func lookup(name string) (Verifier, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	verifier, ok := registry[name]
+	return verifier, ok
+}
+
+// Runner Verifies the secrets found with the verifiers of their rules
+type Runner struct {
+	verifiersLock sync.RWMutex
+	verifiers     map[int]Verifier // by rule ID
+	severities    map[string]bool  // nil verifies all severities
+	Timeout       time.Duration
+	// Secrets verified at the same time by VerifyAll
+	Concurrency int
+	limiter     *rateLimiter
+}
+
+// Default runner used by the scans, nil when --verify is not set
+var Default *Runner
+
+// Enable Create the default runner from the configured signatures
+// @parameters
+// signatures - Signatures from the config, in the order their rule IDs are assigned
+// severities - Comma separated severities to verify, empty for all
+// concurrency - Secrets verified at the same time
+// rate - Maximum verification requests per second, 0 for no limit
+// @returns
+// Error - Errors if any. Otherwise, returns nil
+func Enable(signatures []core.ConfigSignature, severities string, concurrency int, rate float64) error {
+	runner, err := NewRunner(signatures, severities)
+	if err != nil {
+		return err
+	}
+	if concurrency > 0 {
+		runner.Concurrency = concurrency
+	}
+	runner.SetRate(rate)
+	Default = runner
+	return nil
+}
+
+// NewRunner Create a runner for the rules with a registered verifier
+// @parameters
+// signatures - Signatures from the config, in the order their rule IDs are assigned
+// severities - Comma separated severities to verify, empty for all
+// @returns
+// *Runner - Runner verifying the secrets of the selected severities
+// Error - Errors if any. Otherwise, returns nil
+func NewRunner(signatures []core.ConfigSignature, severities string) (*Runner, error) {
+	selected, err := ParseSeverities(severities)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiers, err := newVerifiers(signatures)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		verifiers:   verifiers,
+		severities:  selected,
+		Timeout:     DefaultTimeout,
+		Concurrency: 1,
+	}, nil
+}
+
+// SetSignatures Replace the verifiers of the runner after the signatures are reloaded, as rule IDs may have changed
+// @parameters
+// signatures - Signatures from the config, in the order their rule IDs are assigned
+// @returns
+// Error - Errors if any, the previous verifiers are kept. Otherwise, returns nil
+func (r *Runner) SetSignatures(signatures []core.ConfigSignature) error {
+	if r == nil {
+		return nil
+	}
+	verifiers, err := newVerifiers(signatures)
+	if err != nil {
+		return err
+	}
+	r.verifiersLock.Lock()
+	defer r.verifiersLock.Unlock()
+	r.verifiers = verifiers
+	return nil
+}
+
+func (r *Runner) verifierOf(ruleID int) (Verifier, bool) {
+	r.verifiersLock.RLock()
+	defer r.verifiersLock.RUnlock()
+	verifier, ok := r.verifiers[ruleID]
+	return verifier, ok
+}
+
+// Verifiers of the rules naming one, by rule ID
+func newVerifiers(signatures []core.ConfigSignature) (map[int]Verifier, error) {
+	verifiers := make(map[int]Verifier)
+	for i, signature := range signatures {
+		if signature.HTTPVerifier != nil {
+			verifier, err := newHTTPVerifier(*signature.HTTPVerifier)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", signature.Name, err)
+			}
+			verifiers[i] = verifier
+			continue
+		}
+		if signature.Verifier == "" {
+			continue
+		}
+		verifier, ok := lookup(signature.Verifier)
+		if !ok {
+			log.Warnf("unknown verifier %s for rule %s, its secrets will not be verified", signature.Verifier, signature.Name)
+			continue
+		}
+		verifiers[i] = verifier
+	}
+	return verifiers, nil
+}
+
+// ParseSeverities Parse a comma separated list of severities
+// @returns
+// map[string]bool - Selected severities, nil for all
+// Error - Errors if any. Otherwise, returns nil
+func ParseSeverities(severities string) (map[string]bool, error) {
+	if strings.TrimSpace(severities) == "" || severities == "all" {
+		return nil, nil
+	}
+	selected := make(map[string]bool)
+	for _, severity := range strings.Split(severities, ",") {
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		switch severity {
+		case output.HIGH, output.MEDIUM, output.LOW:
+			selected[severity] = true
+		default:
+			return nil, fmt.Errorf("invalid severity %q, expected high, medium or low", severity)
+		}
+	}
+	return selected, nil
+}
+
+// SetRate Limit the verification requests of the runner
+// @parameters
+// rate - Maximum verifications per second, 0 for no limit
+func (r *Runner) SetRate(rate float64) {
+	if rate <= 0 {
+		r.limiter = nil
+		return
+	}
+	r.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Verify Set the verification result of a secret. Secrets of unselected
+// severities or without a verifier are left unverified
+func (r *Runner) Verify(ctx context.Context, secret *output.SecretFound) {
+	if r == nil {
+		return
+	}
+	r.verify(ctx, secret, nil)
+}
+
+func (r *Runner) verify(ctx context.Context, secret *output.SecretFound, others []output.SecretFound) {
+	if r.severities != nil && !r.severities[secret.Severity] {
+		return
+	}
+	verifier, ok := r.verifierOf(secret.RuleID)
+	if !ok {
+		return
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.limiter != nil {
+		ctx = context.WithValue(ctx, rateLimiterKey{}, r.limiter)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var verified bool
+	var err error
+	if fileVerifier, ok := verifier.(FileVerifier); ok {
+		verified, err = fileVerifier.VerifyInFile(ctx, *secret, others)
+	} else {
+		verified, err = verifier.Verify(ctx, *secret)
+	}
+	secret.Verified = verified && err == nil
+	if err != nil {
+		secret.VerificationError = err.Error()
+	}
+}
+
+// VerifyAll Set the verification result of all the secrets in place, verifying up to
+// Concurrency secrets at the same time
+func (r *Runner) VerifyAll(ctx context.Context, secrets []output.SecretFound) {
+	if r == nil {
+		return
+	}
+
+	// Copy the secrets of each file first, the workers update the secrets in place
+	byFile := map[string][]output.SecretFound{}
+	for _, secret := range secrets {
+		key := secret.LayerID + "\x00" + secret.CompleteFilename
+		byFile[key] = append(byFile[key], secret)
+	}
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range secrets {
+		if _, ok := r.verifierOf(secrets[i].RuleID); !ok {
+			continue
+		}
+		others := byFile[secrets[i].LayerID+"\x00"+secrets[i].CompleteFilename]
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(secret *output.SecretFound) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.verify(ctx, secret, others)
+		}(&secrets[i])
+	}
+	wg.Wait()
+}
+
+// Secrets verified at most at once by VerifyStream
+const streamBatchSize = 256
+
+// VerifyStream Verify the secrets of a stream as they are received, with VerifyAll on each batch of the secrets
+// already received, so that up to Concurrency secrets are verified at the same time
+// @parameters
+// ctx - Context of the scan, cancelling the verification requests
+// secrets - Secrets found by the scan, closed once it stops
+// @returns
+// <-chan output.SecretFound - The secrets, in the same order, once verified. Closed after secrets
+func (r *Runner) VerifyStream(ctx context.Context, secrets <-chan output.SecretFound) <-chan output.SecretFound {
+	if r == nil {
+		return secrets
+	}
+	verified := make(chan output.SecretFound)
+	go func() {
+		defer close(verified)
+		for secret := range secrets {
+			batch := []output.SecretFound{secret}
+		receive:
+			for len(batch) < streamBatchSize {
+				select {
+				case secret, ok := <-secrets:
+					if !ok {
+						break receive
+					}
+					batch = append(batch, secret)
+				default:
+					break receive
+				}
+			}
+			r.VerifyAll(ctx, batch)
+			for _, secret := range batch {
+				verified <- secret
+			}
+		}
+	}()
+	return verified
+}
+
+// Context key of the rate limiter of the verification requests
+type rateLimiterKey struct{}
+
+// WaitForRequest Wait for the slot of the next request of a verifier, spaced by the rate limit of the runner
+// @parameters
+// ctx - Context of the verification
+// @returns
+// Error - Errors if the context is done before the slot. Otherwise, returns nil
+func WaitForRequest(ctx context.Context) error {
+	limiter, _ := ctx.Value(rateLimiterKey{}).(*rateLimiter)
+	return limiter.wait(ctx)
+}
+
+// Spaces the verification requests of all the workers, so that live providers are not flooded
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Wait for the next request slot, or until the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.lock.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,153 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/SecretScanner/core"
+	"github.com/khulnasoft-lab/SecretScanner/output"
+	"github.com/khulnasoft-lab/SecretScanner/verify"
+)
+
+type countingVerifier struct {
+	calls int
+	err   error
+}
+
+func (v *countingVerifier) Verify(ctx context.Context, secret output.SecretFound) (bool, error) {
+	v.calls++
+	return v.err == nil, v.err
+}
+
+func Test_VerifySeverities(t *testing.T) {
+	verifier := &countingVerifier{}
+	verify.Register("test-severities", verifier)
+
+	signatures := []core.ConfigSignature{
+		{Name: "verified", Verifier: "test-severities"},
+		{Name: "not verifiable"},
+	}
+	runner, err := verify.NewRunner(signatures, "high, medium")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []output.SecretFound{
+		{RuleID: 0, Severity: output.HIGH},
+		{RuleID: 0, Severity: output.LOW},
+		{RuleID: 1, Severity: output.HIGH},
+	}
+	runner.VerifyAll(context.Background(), secrets)
+
+	if verifier.calls != 1 {
+		t.Errorf("expected only the high secret to be verified, got %d calls", verifier.calls)
+	}
+	if !secrets[0].Verified || secrets[1].Verified || secrets[2].Verified {
+		t.Errorf("unexpected verification results: %+v", secrets)
+	}
+}
+
+func Test_VerifyAllSeveritiesByDefault(t *testing.T) {
+	verifier := &countingVerifier{err: errors.New("timeout")}
+	verify.Register("test-default", verifier)
+
+	runner, err := verify.NewRunner([]core.ConfigSignature{{Name: "rule", Verifier: "test-default"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []output.SecretFound{{Severity: output.LOW}, {Severity: output.MEDIUM}}
+	runner.VerifyAll(context.Background(), secrets)
+
+	if verifier.calls != 2 {
+		t.Errorf("expected every severity to be verified, got %d calls", verifier.calls)
+	}
+	if secrets[0].Verified || secrets[0].VerificationError != "timeout" {
+		t.Errorf("verification error should leave the secret unverified: %+v", secrets[0])
+	}
+}
+
+func Test_ParseSeveritiesInvalid(t *testing.T) {
+	if _, err := verify.ParseSeverities("high,critical"); err == nil {
+		t.Errorf("expected an error for an unknown severity")
+	}
+}
+
+type blockingVerifier struct {
+	lock    sync.Mutex
+	running int
+	max     int
+}
+
+func (v *blockingVerifier) Verify(ctx context.Context, secret output.SecretFound) (bool, error) {
+	v.lock.Lock()
+	v.running++
+	if v.running > v.max {
+		v.max = v.running
+	}
+	v.lock.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	v.lock.Lock()
+	v.running--
+	v.lock.Unlock()
+	return true, nil
+}
+
+func Test_VerifyAllConcurrency(t *testing.T) {
+	verifier := &blockingVerifier{}
+	verify.Register("test-concurrency", verifier)
+
+	runner, err := verify.NewRunner([]core.ConfigSignature{{Name: "rule", Verifier: "test-concurrency"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Concurrency = 3
+
+	secrets := make([]output.SecretFound, 10)
+	runner.VerifyAll(context.Background(), secrets)
+
+	if verifier.max != 3 {
+		t.Errorf("expected 3 secrets verified at the same time, got %d", verifier.max)
+	}
+	for _, secret := range secrets {
+		if !secret.Verified {
+			t.Errorf("all secrets should be verified: %+v", secrets)
+			break
+		}
+	}
+}
+
+func Test_VerifyStream(t *testing.T) {
+	verifier := &blockingVerifier{}
+	verify.Register("test-stream", verifier)
+
+	runner, err := verify.NewRunner([]core.ConfigSignature{{Name: "rule", Verifier: "test-stream"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Concurrency = 3
+
+	// The secrets found while a batch is verified are verified together
+	secrets := make(chan output.SecretFound, 10)
+	for i := 0; i < 10; i++ {
+		secrets <- output.SecretFound{LineNumber: i}
+	}
+	close(secrets)
+	var lines []int
+	for secret := range runner.VerifyStream(context.Background(), secrets) {
+		if !secret.Verified {
+			t.Errorf("secret %+v not verified", secret)
+		}
+		lines = append(lines, secret.LineNumber)
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("secrets received in order %v, want %v", lines, expected)
+	}
+	if verifier.max != 3 {
+		t.Errorf("expected 3 secrets verified at the same time, got %d", verifier.max)
+	}
+}